@@ -51,10 +51,12 @@ type ResourceRequirements struct {
 	Limits   ResourceList `json:"limits,omitempty"`
 }
 
-// ResourceList defines CPU and memory resources
+// ResourceList defines CPU and memory resources, and as a limit the number
+// of processes
 type ResourceList struct {
 	CPU    string `json:"cpu,omitempty"`
 	Memory string `json:"memory,omitempty"`
+	PIDs   int64  `json:"pids,omitempty"`
 }
 
 // DeploymentInstances tracks deployment instance counts
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/logship"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/metrics"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/supervisor"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/worker"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	// Command line flags
+	var (
+		apiURL         = flag.String("api-url", getEnv("PHOENIX_API_URL", "http://phoenix-api:8080"), "Phoenix API URL")
+		hostID         = flag.String("host-id", getHostID(), "Unique host identifier")
+		pollInterval   = flag.Duration("poll-interval", getDurationEnv("POLL_INTERVAL", 15*time.Second), "Task poll interval")
+		configDir      = flag.String("config-dir", getEnv("CONFIG_DIR", "/etc/phoenix-agent"), "Directory for agent configs")
+		logLevel       = flag.String("log-level", getEnv("LOG_LEVEL", "info"), "Log level (debug, info, warn, error)")
+		pushgatewayURL = flag.String("pushgateway-url", getEnv("PUSHGATEWAY_URL", "http://prometheus-pushgateway:9091"), "Prometheus Pushgateway URL")
+		useNRDOT       = flag.Bool("use-nrdot", getBoolEnv("USE_NRDOT", false), "Use New Relic NRDOT collector instead of OTel")
+		nrLicenseKey   = flag.String("nr-license-key", getEnv("NEW_RELIC_LICENSE_KEY", ""), "New Relic license key")
+		nrOTLPEndpoint = flag.String("nr-otlp-endpoint", getEnv("NEW_RELIC_OTLP_ENDPOINT", "otlp.nr-data.net:4317"), "New Relic OTLP endpoint")
+		labels         = flag.String("labels", getEnv("AGENT_LABELS", ""), "Host labels as comma-separated key=value pairs")
+		reconcile      = flag.Duration("reconcile-interval", getDurationEnv("RECONCILE_INTERVAL", 60*time.Second), "Desired state reconciliation interval (0 disables)")
+		telemetryPorts = flag.Int("telemetry-port-base", getIntEnv("TELEMETRY_PORT_BASE", 18888), "First port collectors serve their own metrics on")
+		cgroupParent   = flag.String("cgroup-parent", getEnv("CGROUP_PARENT", "/sys/fs/cgroup/phoenix-agent"), "cgroup v2 group collectors are confined in (empty disables limits)")
+		verifyKey      = flag.String("config-verify-key", getEnv("CONFIG_VERIFY_KEY", ""), "Base64 Ed25519 public key collector configs must be signed with")
+		concurrency    = flag.String("task-concurrency", getEnv("TASK_CONCURRENCY", ""), "Tasks of each type run at once, as comma-separated type=count pairs")
+		logShipRate    = flag.Int("log-ship-rate", getIntEnv("LOG_SHIP_RATE", 200), "Log lines shipped to the API per second (0 disables)")
+		logShipLevel   = flag.String("log-ship-level", getEnv("LOG_SHIP_LEVEL", "warn"), "Lowest level of agent logs shipped to the API (disabled ships none)")
+	)
+	flag.Parse()
+
+	// Setup logging
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	level, err := zerolog.ParseLevel(*logLevel)
+	if err != nil {
+		level = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(level)
+
+	var logOutput io.Writer = os.Stderr
+	if getEnv("LOG_FORMAT", "json") == "console" {
+		logOutput = zerolog.ConsoleWriter{Out: os.Stderr}
+	}
+	log.Logger = log.Output(logOutput)
+
+	log.Info().
+		Str("api_url", *apiURL).
+		Str("host_id", *hostID).
+		Dur("poll_interval", *pollInterval).
+		Msg("Starting Phoenix Agent")
+
+	// Initialize configuration
+	cfg := &config.Config{
+		APIURL:         *apiURL,
+		HostID:         *hostID,
+		PollInterval:   *pollInterval,
+		ConfigDir:      *configDir,
+		PushgatewayURL: *pushgatewayURL,
+		UseNRDOT:       *useNRDOT,
+		NRLicenseKey:   *nrLicenseKey,
+		NROTLPEndpoint: *nrOTLPEndpoint,
+		CollectorType:  getCollectorType(*useNRDOT),
+		Labels:         parseLabels(*labels),
+
+		ReconcileInterval: *reconcile,
+		TelemetryPortBase: *telemetryPorts,
+		CgroupParent:      *cgroupParent,
+		ConfigVerifyKey:   *verifyKey,
+		TaskConcurrency:   parseConcurrency(*concurrency),
+		LogShipRate:       *logShipRate,
+		LogShipLevel:      *logShipLevel,
+	}
+
+	// Initialize components
+	apiClient := poller.NewClient(cfg)
+	taskSupervisor := supervisor.NewSupervisor(cfg)
+	metricsReporter := metrics.NewReporter(cfg, apiClient)
+	taskPool := worker.NewPool(cfg, apiClient, taskSupervisor)
+	// Reconciliation waits for the tasks of the collector it fixes
+	taskSupervisor.SetTaskLocker(taskPool)
+	logShipper := logship.NewShipper(cfg, apiClient, taskSupervisor)
+
+	// Ship the agent's own log lines along with collector output
+	log.Logger = log.Output(zerolog.MultiLevelWriter(logOutput, logShipper.Writer()))
+
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Take over the collectors of an earlier agent process before any new
+	// tasks run
+	if recovered := taskSupervisor.Recover(); len(recovered) > 0 {
+		log.Info().Int("collectors", len(recovered)).Msg("Recovered collectors from agent state")
+	}
+
+	// Start metrics reporting
+	go metricsReporter.Start(ctx)
+
+	// Ship collector output and agent logs to the API
+	logsShipped := make(chan struct{})
+	go func() {
+		logShipper.Start(ctx)
+		close(logsShipped)
+	}()
+
+	// Heartbeats run apart from task execution so cancellations reach
+	// tasks that are still running
+	go func() {
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+
+		sendHeartbeat(ctx, apiClient, taskSupervisor, taskPool)
+
+		for {
+			select {
+			case <-ticker.C:
+				sendHeartbeat(ctx, apiClient, taskSupervisor, taskPool)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Poll for tasks and run them on workers; polling goes on while
+	// tasks run
+	go taskPool.Start(ctx)
+
+	// Report collector crashes and restarts as they happen
+	go func() {
+		for {
+			select {
+			case event := <-taskSupervisor.CollectorEvents():
+				if err := apiClient.SendCollectorEvent(ctx, event); err != nil {
+					log.Error().Err(err).Str("collector_id", event.CollectorID).Str("event", event.Event).Msg("Failed to send collector event")
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Converge on the desired state; tasks only get there sooner
+	if cfg.ReconcileInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.ReconcileInterval)
+			defer ticker.Stop()
+
+			drifted := false
+			for {
+				select {
+				case <-ticker.C:
+					drifted = reconcileDesiredState(ctx, apiClient, taskSupervisor, drifted)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Start metrics collection worker
+	go func() {
+		metricsTicker := time.NewTicker(30 * time.Second) // Collect metrics every 30 seconds
+		defer metricsTicker.Stop()
+
+		for {
+			select {
+			case <-metricsTicker.C:
+				collectAndSendMetrics(ctx, apiClient, taskSupervisor)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Setup signal handling
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+
+	log.Info().Msg("Shutting down agent...")
+
+	// Create shutdown context with timeout
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer shutdownCancel()
+
+	// Gracefully shutdown supervisor
+	if err := taskSupervisor.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Error during supervisor shutdown")
+	} else {
+		log.Info().Msg("Graceful shutdown completed")
+	}
+
+	// Ship the last output of the stopped collectors
+	cancel()
+	<-logsShipped
+}
+
+// reconcileDesiredState converges the host on its desired state once. Drift
+// is reported, and so is getting back in sync. It returns whether the host
+// drifted.
+func reconcileDesiredState(ctx context.Context, client *poller.Client, supervisor *supervisor.Supervisor, drifted bool) bool {
+	since := time.Now()
+	desired, err := client.GetDesiredState(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get desired state")
+		return drifted
+	}
+
+	report := supervisor.Reconcile(ctx, desired, since)
+	if report.InSync && !drifted {
+		return false
+	}
+
+	if err := client.SendDriftReport(ctx, report); err != nil {
+		log.Error().Err(err).Msg("Failed to send drift report")
+	}
+	return !report.InSync
+}
+
+func sendHeartbeat(ctx context.Context, client *poller.Client, supervisor *supervisor.Supervisor, pool *worker.Pool) {
+	resp, err := client.SendHeartbeat(ctx, supervisor.GetStatus())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send heartbeat")
+		return
+	}
+	supervisor.InventoryReported()
+
+	// Cancellations are repeated until the task finishes, so one for a task
+	// that has not been polled yet is picked up by a later heartbeat
+	for _, c := range resp.CancelTasks {
+		if pool.Cancel(ctx, c.TaskID, c.Reason) {
+			log.Info().Str("task_id", c.TaskID).Str("reason", c.Reason).Msg("Cancelling task")
+		}
+	}
+}
+
+func getHostID() string {
+	// Try to get from environment
+	if hostID := os.Getenv("PHOENIX_HOST_ID"); hostID != "" {
+		return hostID
+	}
+
+	// Try to get hostname
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+
+	// Fallback to a generated ID
+	return fmt.Sprintf("agent-%d", time.Now().Unix())
+}
+
+func collectAndSendMetrics(ctx context.Context, client *poller.Client, supervisor *supervisor.Supervisor) {
+	// Collect metrics from all supervised processes
+	metrics := supervisor.GetMetrics()
+
+	if len(metrics) == 0 {
+		log.Debug().Msg("No metrics to report")
+		return
+	}
+
+	// Add agent-level metadata to each metric
+	status := supervisor.GetStatus()
+	for i := range metrics {
+		metrics[i]["agent_status"] = status.Status
+		metrics[i]["cpu_percent"] = status.ResourceUsage.CPUPercent
+		metrics[i]["memory_percent"] = status.ResourceUsage.MemoryPercent
+		metrics[i]["memory_bytes"] = status.ResourceUsage.MemoryBytes
+	}
+
+	// Send metrics to API
+	if err := client.SendMetrics(ctx, metrics); err != nil {
+		log.Error().Err(err).Msg("Failed to send metrics")
+		return
+	}
+
+	log.Debug().Int("count", len(metrics)).Msg("Metrics sent successfully")
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		switch value {
+		case "true", "1", "yes", "on":
+			return true
+		case "false", "0", "no", "off":
+			return false
+		}
+	}
+	return defaultValue
+}
+
+// parseLabels parses labels given as env=prod,role=web. Pairs without a key
+// are skipped.
+func parseLabels(value string) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		k, v, _ := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		labels[k] = strings.TrimSpace(v)
+	}
+	return labels
+}
+
+// parseConcurrency parses task concurrency given as collector=4,loadsim=1.
+// Pairs without a positive count are skipped.
+func parseConcurrency(value string) map[string]int {
+	concurrency := make(map[string]int)
+	for taskType, count := range parseLabels(value) {
+		if n, err := strconv.Atoi(count); err == nil && n > 0 {
+			concurrency[taskType] = n
+		}
+	}
+	return concurrency
+}
+
+func getCollectorType(useNRDOT bool) string {
+	if useNRDOT {
+		return "nrdot"
+	}
+	return "otel"
+}
@@ -8,6 +8,31 @@ type Config struct {
 	PollInterval   time.Duration
 	ConfigDir      string
 	PushgatewayURL string
+	// ReconcileInterval is how often the host is converged on its desired
+	// state; zero turns reconciliation off
+	ReconcileInterval time.Duration
+	// Labels are reported in every heartbeat and matched by experiment
+	// host selectors
+	Labels map[string]string
+	// TelemetryPortBase is the first port collectors are assigned to serve
+	// their own metrics on; zero uses the default
+	TelemetryPortBase int
+	// CgroupParent is the cgroup v2 group, directly below the cgroup mount,
+	// that collectors get their own groups in; empty runs them without
+	// resource limits
+	CgroupParent string
+	// ConfigVerifyKey is the base64 Ed25519 public key collector configs
+	// must be signed with; empty accepts configs by their SHA-256 alone
+	ConfigVerifyKey string
+	// TaskConcurrency is how many tasks of each type run at once; types
+	// not named keep their default
+	TaskConcurrency map[string]int
+	// LogShipRate is how many log lines a second are shipped to the API;
+	// zero turns log shipping off
+	LogShipRate int
+	// LogShipLevel is the lowest level of the agent's own log that is
+	// shipped along with collector output; "disabled" ships none
+	LogShipLevel string
 
 	// NRDOT Collector configuration
 	UseNRDOT       bool
@@ -0,0 +1,334 @@
+package logship
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/supervisor"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// flushInterval is how often collector logs are read and lines shipped
+	flushInterval = time.Second
+	// maxBatchLines bounds the lines shipped in one request
+	maxBatchLines = 500
+	// maxBufferedLines bounds the lines waiting to be shipped. Collector
+	// logs are not read further while it is full; the agent's own lines
+	// push out the oldest ones instead.
+	maxBufferedLines = 10000
+	// maxBackoff is the longest the shipper waits after the API refused a
+	// batch
+	maxBackoff = time.Minute
+	// shutdownFlushTimeout bounds the last flush when the agent stops
+	shutdownFlushTimeout = 5 * time.Second
+)
+
+// Sender ships log batches; the API client is one
+type Sender interface {
+	SendLogs(ctx context.Context, batch *poller.LogBatch) error
+}
+
+// CollectorLogs lists the log files of supervised collectors; the supervisor
+// is one
+type CollectorLogs interface {
+	CollectorLogs() map[string]supervisor.CollectorLog
+}
+
+// Shipper ships the output of supervised collectors, and the agent's own log
+// lines at or above a level, to the API. Lines are batched and shipped at no
+// more than the configured rate. While the API refuses batches the shipper
+// backs off and lines build up in a bounded buffer.
+type Shipper struct {
+	sender     Sender
+	collectors CollectorLogs
+	rate       int
+	level      zerolog.Level
+
+	// tails are only touched by the shipping loop
+	tails map[string]*tail
+
+	mu     sync.Mutex
+	buffer []poller.LogEntry
+	// dropped counts the lines dropped since the last batch was shipped
+	dropped int
+	tokens  float64
+	refill  time.Time
+	backoff time.Duration
+	retryAt time.Time
+	now     func() time.Time
+}
+
+func NewShipper(cfg *config.Config, sender Sender, collectors CollectorLogs) *Shipper {
+	level, err := zerolog.ParseLevel(cfg.LogShipLevel)
+	if err != nil || level == zerolog.NoLevel {
+		level = zerolog.WarnLevel
+	}
+
+	return &Shipper{
+		sender:     sender,
+		collectors: collectors,
+		rate:       cfg.LogShipRate,
+		level:      level,
+		tails:      make(map[string]*tail),
+		now:        time.Now,
+	}
+}
+
+// Start ships logs until ctx is cancelled, then makes a last attempt to
+// ship what is left
+func (s *Shipper) Start(ctx context.Context) {
+	if s.rate <= 0 {
+		log.Info().Msg("Log shipping is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.tailCollectors()
+			s.flush(ctx)
+		case <-ctx.Done():
+			s.tailCollectors()
+			flushCtx, cancel := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+			s.flush(flushCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// Writer returns a writer for the agent's logger that ships the lines at or
+// above the shipper's level. Writes never block on the API.
+func (s *Shipper) Writer() zerolog.LevelWriter {
+	return &agentWriter{shipper: s}
+}
+
+// tailCollectors reads the new output of every collector, as far as the
+// buffer has room for it
+func (s *Shipper) tailCollectors() {
+	logs := s.collectors.CollectorLogs()
+	for id, file := range logs {
+		if t, ok := s.tails[id]; !ok || t.path != file.Path {
+			s.tails[id] = newTail(id, file.Path)
+		}
+		s.tails[id].taskID = file.TaskID
+	}
+
+	ids := make([]string, 0, len(s.tails))
+	for id := range s.tails {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		room := s.room()
+		if room == 0 {
+			// Backpressure: the lines stay in the log file until the API
+			// has taken what is buffered
+			return
+		}
+
+		t := s.tails[id]
+		entries, err := t.read(room, s.now())
+		if err != nil {
+			log.Debug().Err(err).Str("collector_id", id).Msg("Failed to read collector log")
+		}
+		s.add(entries...)
+
+		// A collector that is no longer supervised has had its last output
+		// read
+		if _, ok := logs[id]; !ok {
+			delete(s.tails, id)
+		}
+	}
+}
+
+// room is how many lines fit in the buffer
+func (s *Shipper) room() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maxBufferedLines - len(s.buffer)
+}
+
+// add buffers lines, dropping the oldest ones if the buffer is full
+func (s *Shipper) add(entries ...poller.LogEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buffer = append(s.buffer, entries...)
+	if overflow := len(s.buffer) - maxBufferedLines; overflow > 0 {
+		s.buffer = append(s.buffer[:0], s.buffer[overflow:]...)
+		s.dropped += overflow
+	}
+}
+
+// flush ships buffered lines in batches, as many as the rate allows. A
+// refused batch is put back and shipping backs off.
+func (s *Shipper) flush(ctx context.Context) {
+	for {
+		batch := s.take()
+		if batch == nil {
+			return
+		}
+
+		if err := s.sender.SendLogs(ctx, batch); err != nil {
+			backoff := s.putBack(batch)
+			// The shipper's own failures are logged at debug after the first,
+			// so a down API does not fill the buffer with them
+			event := log.Debug()
+			if backoff == flushInterval {
+				event = log.Warn()
+			}
+			event.Err(err).Int("lines", len(batch.Logs)).Dur("retry_in", backoff).Msg("Failed to ship logs")
+			return
+		}
+
+		s.mu.Lock()
+		s.backoff = 0
+		s.mu.Unlock()
+	}
+}
+
+// take removes the next batch from the buffer, or returns nil if nothing is
+// buffered, the rate allows no lines now or shipping is backing off
+func (s *Shipper) take() *poller.LogBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Before(s.retryAt) || len(s.buffer) == 0 {
+		return nil
+	}
+
+	// Refill the token bucket; it holds at most a second's worth of lines
+	if !s.refill.IsZero() {
+		s.tokens += now.Sub(s.refill).Seconds() * float64(s.rate)
+	} else {
+		s.tokens = float64(s.rate)
+	}
+	if s.tokens > float64(s.rate) {
+		s.tokens = float64(s.rate)
+	}
+	s.refill = now
+
+	n := len(s.buffer)
+	if n > maxBatchLines {
+		n = maxBatchLines
+	}
+	if n > int(s.tokens) {
+		n = int(s.tokens)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	batch := &poller.LogBatch{
+		Logs:    append([]poller.LogEntry(nil), s.buffer[:n]...),
+		Dropped: s.dropped,
+	}
+	s.buffer = append(s.buffer[:0], s.buffer[n:]...)
+	s.dropped = 0
+	s.tokens -= float64(n)
+	return batch
+}
+
+// putBack returns a refused batch to the front of the buffer and backs off.
+// It returns how long shipping waits.
+func (s *Shipper) putBack(batch *poller.LogBatch) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buffer = append(batch.Logs, s.buffer...)
+	s.dropped += batch.Dropped
+	if overflow := len(s.buffer) - maxBufferedLines; overflow > 0 {
+		s.buffer = s.buffer[overflow:]
+		s.dropped += overflow
+	}
+	// The lines were not shipped, so neither was their share of the rate
+	s.tokens += float64(len(batch.Logs))
+
+	s.backoff *= 2
+	if s.backoff == 0 {
+		s.backoff = flushInterval
+	}
+	if s.backoff > maxBackoff {
+		s.backoff = maxBackoff
+	}
+	s.retryAt = s.now().Add(s.backoff)
+	return s.backoff
+}
+
+// agentWriter turns the agent's JSON log lines into log entries
+type agentWriter struct {
+	shipper *Shipper
+}
+
+func (w *agentWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (w *agentWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	s := w.shipper
+	if s.rate <= 0 || s.level == zerolog.Disabled || level < s.level || level == zerolog.NoLevel {
+		return len(p), nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return len(p), nil
+	}
+
+	entry := poller.LogEntry{
+		Timestamp: s.now(),
+		Level:     level.String(),
+		Source:    "agent",
+	}
+	entry.CollectorID, _ = fields["collector_id"].(string)
+	entry.TaskID, _ = fields["task_id"].(string)
+	entry.Message = formatFields(fields)
+
+	s.add(entry)
+	return len(p), nil
+}
+
+// formatFields renders a log line's message followed by its other fields as
+// sorted key=value pairs
+func formatFields(fields map[string]interface{}) string {
+	message, _ := fields[zerolog.MessageFieldName].(string)
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		switch key {
+		case zerolog.MessageFieldName, zerolog.LevelFieldName, zerolog.TimestampFieldName:
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(message)
+	for _, key := range keys {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%s=%v", key, fields[key])
+	}
+	return truncateLine(b.String())
+}
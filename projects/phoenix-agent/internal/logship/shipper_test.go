@@ -0,0 +1,263 @@
+package logship
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/supervisor"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSender records shipped batches and fails while err is set
+type fakeSender struct {
+	mu      sync.Mutex
+	batches []*poller.LogBatch
+	err     error
+}
+
+func (s *fakeSender) SendLogs(ctx context.Context, batch *poller.LogBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *fakeSender) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []string
+	for _, batch := range s.batches {
+		for _, entry := range batch.Logs {
+			messages = append(messages, entry.Message)
+		}
+	}
+	return messages
+}
+
+type fakeCollectors map[string]supervisor.CollectorLog
+
+func (c fakeCollectors) CollectorLogs() map[string]supervisor.CollectorLog {
+	return c
+}
+
+func newTestShipper(rate int, collectors fakeCollectors) (*Shipper, *fakeSender, *time.Time) {
+	sender := &fakeSender{}
+	shipper := NewShipper(&config.Config{LogShipRate: rate, LogShipLevel: "warn"}, sender, collectors)
+	clock := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	shipper.now = func() time.Time { return clock }
+	return shipper, sender, &clock
+}
+
+func agentEntries(n int) []poller.LogEntry {
+	entries := make([]poller.LogEntry, n)
+	for i := range entries {
+		entries[i] = poller.LogEntry{Message: fmt.Sprintf("line %d", i), Source: "agent"}
+	}
+	return entries
+}
+
+func writeLog(t *testing.T, path string, lines ...string) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	defer file.Close()
+	for _, line := range lines {
+		_, err := file.WriteString(line + "\n")
+		require.NoError(t, err)
+	}
+}
+
+func TestShipper_ShipsCollectorOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exp-1-candidate.log")
+	collectors := fakeCollectors{"exp-1-candidate": {Path: path, TaskID: "task-1"}}
+	shipper, sender, _ := newTestShipper(100, collectors)
+
+	writeLog(t, path,
+		"2024-01-15T10:00:00.000Z\tinfo\tservice/service.go:1\tStarting otelcol",
+		"2024-01-15T10:00:01.000Z\terror\texporter/exporter.go:2\tExporting failed",
+	)
+	shipper.tailCollectors()
+	shipper.flush(context.Background())
+
+	require.Len(t, sender.batches, 1)
+	entries := sender.batches[0].Logs
+	require.Len(t, entries, 2)
+	assert.Equal(t, "collector", entries[1].Source)
+	assert.Equal(t, "exp-1-candidate", entries[1].CollectorID)
+	assert.Equal(t, "task-1", entries[1].TaskID)
+	assert.Equal(t, "error", entries[1].Level)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 0, 1, 0, time.UTC), entries[1].Timestamp)
+
+	// The collector is stopped after a last line; that line is still shipped
+	writeLog(t, path, "panic: runtime error")
+	delete(collectors, "exp-1-candidate")
+	shipper.tailCollectors()
+	shipper.flush(context.Background())
+
+	assert.Equal(t, "panic: runtime error", sender.messages()[2])
+	assert.Empty(t, shipper.tails)
+}
+
+func TestShipper_LimitsRate(t *testing.T) {
+	shipper, sender, clock := newTestShipper(3, fakeCollectors{})
+	shipper.add(agentEntries(10)...)
+
+	shipper.flush(context.Background())
+	assert.Equal(t, []string{"line 0", "line 1", "line 2"}, sender.messages())
+
+	// Nothing more until the bucket refills
+	shipper.flush(context.Background())
+	assert.Len(t, sender.messages(), 3)
+
+	*clock = clock.Add(time.Second)
+	shipper.flush(context.Background())
+	assert.Len(t, sender.messages(), 6)
+}
+
+func TestShipper_BacksOffAndKeepsOrder(t *testing.T) {
+	shipper, sender, clock := newTestShipper(100, fakeCollectors{})
+	shipper.add(agentEntries(3)...)
+
+	sender.err = errors.New("unexpected status code 429")
+	shipper.flush(context.Background())
+	assert.Empty(t, sender.messages())
+	assert.Equal(t, flushInterval, shipper.backoff)
+
+	// Still backing off
+	sender.err = nil
+	shipper.add(poller.LogEntry{Message: "line 3"})
+	shipper.flush(context.Background())
+	assert.Empty(t, sender.messages())
+
+	*clock = clock.Add(flushInterval)
+	shipper.flush(context.Background())
+	assert.Equal(t, []string{"line 0", "line 1", "line 2", "line 3"}, sender.messages())
+	assert.Zero(t, shipper.backoff)
+}
+
+func TestShipper_BoundsBuffer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exp-1-candidate.log")
+	shipper, sender, _ := newTestShipper(maxBatchLines, fakeCollectors{"exp-1-candidate": {Path: path}})
+
+	// The agent's own lines push out the oldest ones
+	shipper.add(agentEntries(maxBufferedLines + 5)...)
+	assert.Equal(t, 5, shipper.dropped)
+	assert.Equal(t, "line 5", shipper.buffer[0].Message)
+
+	// Collector output waits in its file while the buffer is full
+	writeLog(t, path, "collector line")
+	shipper.tailCollectors()
+	assert.Zero(t, shipper.tails["exp-1-candidate"].offset)
+
+	shipper.flush(context.Background())
+	require.NotEmpty(t, sender.batches)
+	assert.Equal(t, 5, sender.batches[0].Dropped)
+	assert.Zero(t, shipper.dropped)
+
+	shipper.tailCollectors()
+	assert.Equal(t, "collector line", shipper.buffer[len(shipper.buffer)-1].Message)
+}
+
+func TestShipper_AgentWriter(t *testing.T) {
+	shipper, _, _ := newTestShipper(100, fakeCollectors{})
+	logger := zerolog.New(shipper.Writer())
+
+	logger.Info().Msg("Heartbeat sent")
+	logger.Error().Str("collector_id", "exp-1-candidate").Int("exit_code", 2).Msg("Collector process exited unexpectedly")
+
+	require.Len(t, shipper.buffer, 1)
+	entry := shipper.buffer[0]
+	assert.Equal(t, "agent", entry.Source)
+	assert.Equal(t, "error", entry.Level)
+	assert.Equal(t, "exp-1-candidate", entry.CollectorID)
+	assert.Equal(t, "Collector process exited unexpectedly collector_id=exp-1-candidate exit_code=2", entry.Message)
+}
+
+func TestShipper_AgentWriterDisabled(t *testing.T) {
+	shipper := NewShipper(&config.Config{LogShipRate: 100, LogShipLevel: "disabled"}, &fakeSender{}, fakeCollectors{})
+	logger := zerolog.New(shipper.Writer())
+	logger.Error().Msg("not shipped")
+	assert.Empty(t, shipper.buffer)
+}
+
+func TestTail_FollowsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collector.log")
+	now := time.Now()
+	tl := newTail("c", path)
+
+	entries, err := tl.read(10, now)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a missing file has no lines yet")
+
+	writeLog(t, path, "one")
+	require.NoError(t, appendRaw(path, "tw"))
+	entries, err = tl.read(10, now)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "one", entries[0].Message)
+
+	// The rest of the line arrives
+	require.NoError(t, appendRaw(path, "o\nthree\n"))
+	entries, err = tl.read(1, now)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "two", entries[0].Message)
+	entries, _ = tl.read(10, now)
+	assert.Equal(t, "three", entries[0].Message)
+
+	// A collector started afresh truncates its log
+	require.NoError(t, os.WriteFile(path, []byte("fresh\n"), 0644))
+	entries, _ = tl.read(10, now)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "fresh", entries[0].Message)
+}
+
+func TestTail_StartsNearEndOfLargeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collector.log")
+	line := strings.Repeat("x", 99)
+	var lines []string
+	for i := 0; i < 2*initialTailBytes/100; i++ {
+		lines = append(lines, line)
+	}
+	writeLog(t, path, lines...)
+	writeLog(t, path, "last")
+
+	entries, err := newTail("c", path).read(maxBufferedLines, time.Now())
+	require.NoError(t, err)
+	assert.Less(t, len(entries), len(lines)/2+2)
+	assert.Equal(t, "last", entries[len(entries)-1].Message)
+	for _, entry := range entries[:len(entries)-1] {
+		assert.Equal(t, line, entry.Message, "lines cut by the start offset are skipped")
+	}
+}
+
+func TestTruncateLine(t *testing.T) {
+	long := strings.Repeat("é", maxLineBytes)
+	cut := truncateLine(long)
+	assert.LessOrEqual(t, len(cut), maxLineBytes)
+	assert.True(t, strings.HasPrefix(long, cut))
+	assert.Equal(t, "short", truncateLine("short"))
+}
+
+func appendRaw(path, text string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(text)
+	return err
+}
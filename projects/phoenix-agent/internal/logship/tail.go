@@ -0,0 +1,175 @@
+package logship
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
+)
+
+const (
+	// maxLineBytes is the longest line shipped; longer ones are cut
+	maxLineBytes = 8 << 10
+	// maxReadBytes bounds what is read from one log file at a time
+	maxReadBytes = 1 << 20
+	// initialTailBytes is how much of a log file's existing output is
+	// shipped when the shipper first sees it
+	initialTailBytes = 64 << 10
+)
+
+// collectorLevels are the levels collectors log with, as they appear in the
+// second tab-separated field of their console output
+var collectorLevels = map[string]string{
+	"debug":  "debug",
+	"info":   "info",
+	"warn":   "warn",
+	"error":  "error",
+	"dpanic": "error",
+	"panic":  "panic",
+	"fatal":  "fatal",
+}
+
+// tail follows the log file of a collector from where it was last read. It
+// starts over when the file is truncated or replaced, as happens when the
+// collector is started afresh.
+type tail struct {
+	collectorID string
+	taskID      string
+	path        string
+	offset      int64
+	info        os.FileInfo
+	// partial is set while the rest of a line that started before the
+	// first read is skipped
+	partial bool
+}
+
+func newTail(collectorID, path string) *tail {
+	return &tail{collectorID: collectorID, path: path}
+}
+
+// read returns up to maxLines new complete lines. Lines it does not return
+// are read again next time.
+func (t *tail) read(maxLines int, now time.Time) ([]poller.LogEntry, error) {
+	file, err := os.Open(t.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case t.info == nil:
+		// Only the end of output written before the shipper saw the file
+		if t.offset = info.Size() - initialTailBytes; t.offset > 0 {
+			t.partial = true
+		} else {
+			t.offset = 0
+		}
+	case !os.SameFile(t.info, info) || info.Size() < t.offset:
+		t.offset = 0
+		t.partial = false
+	}
+	t.info = info
+
+	size := info.Size() - t.offset
+	if size <= 0 {
+		return nil, nil
+	}
+	if size > maxReadBytes {
+		size = maxReadBytes
+	}
+
+	buf := make([]byte, size)
+	n, err := file.ReadAt(buf, t.offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	if t.partial {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			t.offset += int64(len(buf))
+			return nil, nil
+		}
+		t.offset += int64(i + 1)
+		buf = buf[i+1:]
+		t.partial = false
+	}
+
+	var entries []poller.LogEntry
+	for len(entries) < maxLines {
+		line := buf
+		consumed := 0
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+			line = buf[:i]
+			consumed = i + 1
+		} else if len(buf) >= maxLineBytes {
+			// A line too long to ever end within the buffer is cut
+			line = buf[:maxLineBytes]
+			consumed = maxLineBytes
+		} else {
+			// The rest of the line has not been written yet
+			break
+		}
+
+		buf = buf[consumed:]
+		t.offset += int64(consumed)
+
+		text := strings.TrimRight(string(line), "\r")
+		if text == "" {
+			continue
+		}
+		entries = append(entries, t.entry(truncateLine(text), now))
+	}
+
+	return entries, nil
+}
+
+// entry makes a log entry of a collector's output line, taking the time and
+// level from the collector's console format where it has them
+func (t *tail) entry(line string, now time.Time) poller.LogEntry {
+	entry := poller.LogEntry{
+		Timestamp:   now,
+		Level:       "info",
+		Message:     line,
+		Source:      "collector",
+		CollectorID: t.collectorID,
+		TaskID:      t.taskID,
+	}
+
+	fields := strings.SplitN(line, "\t", 3)
+	if len(fields) < 3 {
+		return entry
+	}
+	if level, ok := collectorLevels[strings.ToLower(fields[1])]; ok {
+		entry.Level = level
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		entry.Timestamp = ts
+	}
+	return entry
+}
+
+// truncateLine cuts a line to maxLineBytes without splitting a character
+func truncateLine(line string) string {
+	if len(line) <= maxLineBytes {
+		return line
+	}
+	cut := maxLineBytes
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut]
+}
@@ -4,14 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
 )
 
+// ErrLeaseLost is returned when the API rejects a status update because the
+// task's lease expired or was claimed by another poll
+var ErrLeaseLost = errors.New("task lease lost")
+
 type Client struct {
 	config     *config.Config
 	httpClient *http.Client
@@ -25,14 +31,111 @@ type Task struct {
 	Action       string                 `json:"action"`
 	Config       map[string]interface{} `json:"config"`
 	Priority     int                    `json:"priority"`
+	// LeaseToken must accompany every status update for this task
+	LeaseToken     string     `json:"lease_token"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
 }
 
 type AgentStatus struct {
-	HostID        string        `json:"host_id"`
-	AgentVersion  string        `json:"agent_version"`
-	Status        string        `json:"status"`
-	ActiveTasks   []string      `json:"active_tasks"`
-	ResourceUsage ResourceUsage `json:"resource_usage"`
+	HostID        string            `json:"host_id"`
+	AgentVersion  string            `json:"agent_version"`
+	Status        string            `json:"status"`
+	ActiveTasks   []string          `json:"active_tasks"`
+	ResourceUsage ResourceUsage     `json:"resource_usage"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	// RecoveredCollectors lists the collectors taken over from an earlier
+	// agent process; it is sent until a heartbeat is accepted
+	RecoveredCollectors []RecoveredCollector `json:"recovered_collectors,omitempty"`
+}
+
+// RecoveredCollector is a collector found in the agent state file on
+// startup and what became of it
+type RecoveredCollector struct {
+	CollectorID  string `json:"collector_id"`
+	Variant      string `json:"variant"`
+	ConfigHash   string `json:"config_hash"`
+	Pid          int    `json:"pid,omitempty"`
+	TaskID       string `json:"task_id,omitempty"`
+	ExperimentID string `json:"experiment_id,omitempty"`
+	DeploymentID string `json:"deployment_id,omitempty"`
+	Recovery     string `json:"recovery"`
+	Error        string `json:"error,omitempty"`
+}
+
+// HeartbeatResponse carries instructions the API hands back with a heartbeat
+type HeartbeatResponse struct {
+	CancelTasks []TaskCancellation `json:"cancel_tasks"`
+}
+
+// TaskCancellation asks the agent to abort a task it is running
+type TaskCancellation struct {
+	TaskID string `json:"task_id"`
+	Reason string `json:"reason"`
+}
+
+// CollectorEvent tells the API that a supervised collector crashed, was
+// restarted or was given up on
+type CollectorEvent struct {
+	CollectorID  string    `json:"collector_id"`
+	ExperimentID string    `json:"experiment_id,omitempty"`
+	DeploymentID string    `json:"deployment_id,omitempty"`
+	Variant      string    `json:"variant"`
+	Event        string    `json:"event"`
+	ExitCode     int       `json:"exit_code"`
+	Restarts     int       `json:"restarts"`
+	RestartIn    string    `json:"restart_in,omitempty"`
+	Pid          int       `json:"pid,omitempty"`
+	LogTail      string    `json:"log_tail,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// DesiredState is what the API wants this host to run. The agent converges
+// on it alongside the tasks it is handed.
+type DesiredState struct {
+	HostID         string                 `json:"host_id"`
+	Revision       string                 `json:"revision"`
+	GeneratedAt    time.Time              `json:"generated_at"`
+	Collectors     []DesiredCollector     `json:"collectors"`
+	LoadSimulation *DesiredLoadSimulation `json:"load_simulation,omitempty"`
+}
+
+// DesiredCollector is a collector that should be running, with the task
+// that starts it
+type DesiredCollector struct {
+	ID           string                 `json:"id"`
+	Variant      string                 `json:"variant"`
+	ExperimentID string                 `json:"experiment_id,omitempty"`
+	DeploymentID string                 `json:"deployment_id,omitempty"`
+	ConfigHash   string                 `json:"config_hash"`
+	TaskType     string                 `json:"task_type"`
+	TaskAction   string                 `json:"task_action"`
+	Config       map[string]interface{} `json:"config"`
+}
+
+// DesiredLoadSimulation is the load simulation that should run
+type DesiredLoadSimulation struct {
+	ExperimentID string `json:"experiment_id"`
+	Profile      string `json:"profile"`
+	Duration     string `json:"duration"`
+}
+
+// DriftReport tells the API how the host differed from its desired state
+// and what the agent did about it
+type DriftReport struct {
+	Revision  string       `json:"revision"`
+	CheckedAt time.Time    `json:"checked_at"`
+	InSync    bool         `json:"in_sync"`
+	Drift     []StateDrift `json:"drift"`
+}
+
+// StateDrift is one difference between desired and actual state
+type StateDrift struct {
+	Kind         string `json:"kind"`
+	CollectorID  string `json:"collector_id,omitempty"`
+	ExperimentID string `json:"experiment_id,omitempty"`
+	DeploymentID string `json:"deployment_id,omitempty"`
+	Action       string `json:"action"`
+	Error        string `json:"error,omitempty"`
 }
 
 type ResourceUsage struct {
@@ -80,10 +183,11 @@ func (c *Client) GetTasks(ctx context.Context) ([]*Task, error) {
 	return tasks, nil
 }
 
-// UpdateTaskStatus updates the status of a task
-func (c *Client) UpdateTaskStatus(ctx context.Context, taskID, status string, result map[string]interface{}, errorMessage string) error {
+// UpdateTaskStatus updates the status of a task under the lease it was claimed with
+func (c *Client) UpdateTaskStatus(ctx context.Context, task *Task, status string, result map[string]interface{}, errorMessage string) error {
 	payload := map[string]interface{}{
-		"status": status,
+		"status":      status,
+		"lease_token": task.LeaseToken,
 	}
 	if result != nil {
 		payload["result"] = result
@@ -97,7 +201,7 @@ func (c *Client) UpdateTaskStatus(ctx context.Context, taskID, status string, re
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/%s/status", c.config.GetAPIEndpoint("/tasks"), taskID)
+	url := fmt.Sprintf("%s/%s/status", c.config.GetAPIEndpoint("/tasks"), task.ID)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
@@ -112,6 +216,11 @@ func (c *Client) UpdateTaskStatus(ctx context.Context, taskID, status string, re
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusConflict {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w: %s", ErrLeaseLost, string(body))
+	}
+
 	if resp.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
@@ -120,19 +229,19 @@ func (c *Client) UpdateTaskStatus(ctx context.Context, taskID, status string, re
 	return nil
 }
 
-// SendHeartbeat sends agent status to the API
-func (c *Client) SendHeartbeat(ctx context.Context, status *AgentStatus) error {
+// SendHeartbeat sends agent status to the API and returns its reply
+func (c *Client) SendHeartbeat(ctx context.Context, status *AgentStatus) (*HeartbeatResponse, error) {
 	status.HostID = c.config.HostID
 	status.AgentVersion = "1.0.0" // TODO: Make this configurable
 
 	data, err := json.Marshal(status)
 	if err != nil {
-		return fmt.Errorf("failed to marshal status: %w", err)
+		return nil, fmt.Errorf("failed to marshal status: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", c.config.GetAPIEndpoint("/heartbeat"), bytes.NewReader(data))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -140,16 +249,24 @@ func (c *Client) SendHeartbeat(ctx context.Context, status *AgentStatus) error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send heartbeat: %w", err)
+		return nil, fmt.Errorf("failed to send heartbeat: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusNoContent {
+	var heartbeatResp HeartbeatResponse
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		// Older API versions reply without a body
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&heartbeatResp); err != nil {
+			return nil, fmt.Errorf("failed to decode heartbeat response: %w", err)
+		}
+	default:
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
 	}
 
-	return nil
+	return &heartbeatResp, nil
 }
 
 // SendMetrics sends collected metrics to the API
@@ -186,14 +303,38 @@ func (c *Client) SendMetrics(ctx context.Context, metrics []map[string]interface
 	return nil
 }
 
-// SendLogs sends logs to the API
-func (c *Client) SendLogs(ctx context.Context, taskID string, logs []LogEntry) error {
-	payload := map[string]interface{}{
-		"task_id": taskID,
-		"logs":    logs,
+// SendCollectorEvent reports a collector crash or restart to the API
+func (c *Client) SendCollectorEvent(ctx context.Context, event *CollectorEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal collector event: %w", err)
 	}
 
-	data, err := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", c.config.GetAPIEndpoint("/collectors/events"), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Agent-Host-ID", c.config.HostID)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send collector event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// SendLogs ships a batch of collector and agent log lines to the API
+func (c *Client) SendLogs(ctx context.Context, batch *LogBatch) error {
+	data, err := json.Marshal(batch)
 	if err != nil {
 		return fmt.Errorf("failed to marshal logs: %w", err)
 	}
@@ -220,8 +361,160 @@ func (c *Client) SendLogs(ctx context.Context, taskID string, logs []LogEntry) e
 	return nil
 }
 
+// LogBatch is a batch of log lines shipped to the API. Dropped counts the
+// lines the agent had to drop since the last batch it shipped.
+type LogBatch struct {
+	Logs    []LogEntry `json:"logs"`
+	Dropped int        `json:"dropped,omitempty"`
+}
+
+// LogEntry is a line of a collector's output or of the agent's own log
 type LogEntry struct {
 	Timestamp time.Time `json:"timestamp"`
 	Level     string    `json:"level"`
 	Message   string    `json:"message"`
+	// Source is "collector" or "agent"
+	Source      string `json:"source"`
+	CollectorID string `json:"collector_id,omitempty"`
+	TaskID      string `json:"task_id,omitempty"`
+}
+
+// GetDesiredState fetches the desired state of this host
+func (c *Client) GetDesiredState(ctx context.Context) (*DesiredState, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.config.GetAPIEndpoint("/desired-state"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Agent-Host-ID", c.config.HostID)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get desired state: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	var state DesiredState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return nil, fmt.Errorf("failed to decode desired state: %w", err)
+	}
+
+	return &state, nil
+}
+
+// SendDriftReport reports drift from the desired state to the API
+func (c *Client) SendDriftReport(ctx context.Context, report *DriftReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal drift report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.config.GetAPIEndpoint("/desired-state/report"), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Agent-Host-ID", c.config.HostID)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send drift report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+const (
+	// configFetchAttempts is how often a config download is tried before
+	// giving up
+	configFetchAttempts = 3
+	// configFetchTimeout bounds each attempt
+	configFetchTimeout = 30 * time.Second
+	// maxConfigSize is the largest config the agent accepts
+	maxConfigSize = 16 << 20
+)
+
+// configFetchBackoff is the wait before the second attempt; it doubles with
+// every further attempt
+var configFetchBackoff = time.Second
+
+// FetchConfig downloads a collector config. URLs starting with "/" are on
+// the API; requests to the API carry the agent's credentials, requests
+// elsewhere do not. Network errors and 5xx or 429 responses are retried.
+func (c *Client) FetchConfig(ctx context.Context, url string) ([]byte, error) {
+	if strings.HasPrefix(url, "/") {
+		url = c.config.APIURL + url
+	}
+
+	backoff := configFetchBackoff
+	var err error
+	for attempt := 1; attempt <= configFetchAttempts; attempt++ {
+		var data []byte
+		var retry bool
+		data, retry, err = c.fetchConfig(ctx, url)
+		if err == nil {
+			return data, nil
+		}
+		if !retry || attempt == configFetchAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to download config: %w", ctx.Err())
+		}
+	}
+
+	return nil, err
+}
+
+// fetchConfig makes one attempt at downloading a config and reports whether
+// a failed attempt is worth retrying
+func (c *Client) fetchConfig(ctx context.Context, url string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, configFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if strings.HasPrefix(url, c.config.APIURL+"/") {
+		req.Header.Set("X-Agent-Host-ID", c.config.HostID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to download config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf("unexpected status code downloading config: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read config: %w", err)
+	}
+	if len(data) > maxConfigSize {
+		return nil, false, fmt.Errorf("config is larger than %d bytes", maxConfigSize)
+	}
+
+	return data, false, nil
 }
@@ -0,0 +1,54 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchConfig_RetriesServerErrors(t *testing.T) {
+	backoff := configFetchBackoff
+	configFetchBackoff = time.Millisecond
+	t.Cleanup(func() { configFetchBackoff = backoff })
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		assert.Equal(t, "host-1", r.Header.Get("X-Agent-Host-ID"))
+		if attempts < configFetchAttempts {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "receivers: {}\n")
+	}))
+	defer server.Close()
+
+	client := NewClient(&config.Config{APIURL: server.URL, HostID: "host-1"})
+	data, err := client.FetchConfig(context.Background(), "/api/v1/pipelines/deployments/dep-1/config")
+	require.NoError(t, err)
+	assert.Equal(t, "receivers: {}\n", string(data))
+	assert.Equal(t, configFetchAttempts, attempts)
+}
+
+func TestFetchConfig_DoesNotRetryClientErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		// Hosts other than the API do not get the agent's credentials
+		assert.Empty(t, r.Header.Get("X-Agent-Host-ID"))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewClient(&config.Config{APIURL: "http://phoenix-api:8080", HostID: "host-1"})
+	_, err := client.FetchConfig(context.Background(), server.URL+"/missing.yaml")
+	assert.ErrorContains(t, err, "404")
+	assert.Equal(t, 1, attempts)
+}
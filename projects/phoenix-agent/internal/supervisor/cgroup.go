@@ -0,0 +1,355 @@
+package supervisor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// cpuPeriod is the cpu.max period quotas are given in, in microseconds
+const cpuPeriod = 100000
+
+// cgroupControllers are enabled for the groups of collectors
+var cgroupControllers = []string{"cpu", "memory", "pids"}
+
+// ResourceLimits bound what a collector or load simulation may use. Zero
+// values are unlimited.
+type ResourceLimits struct {
+	// CPU is in cores
+	CPU float64 `json:"cpu,omitempty"`
+	// Memory is in bytes
+	Memory int64 `json:"memory,omitempty"`
+	PIDs   int64 `json:"pids,omitempty"`
+}
+
+// IsZero reports whether no limit is set
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// ParseResourceLimits reads the limits in the "resources" of a task config,
+// which has the shape of a deployment's ResourceRequirements. CPU is given
+// in cores or millicores ("500m"), memory in bytes or with a unit ("512Mi",
+// "1G").
+func ParseResourceLimits(config map[string]interface{}) (ResourceLimits, error) {
+	var limits ResourceLimits
+
+	resources, _ := config["resources"].(map[string]interface{})
+	spec, _ := resources["limits"].(map[string]interface{})
+	if spec == nil {
+		return limits, nil
+	}
+
+	var err error
+	if value, ok := spec["cpu"]; ok {
+		if limits.CPU, err = parseCPU(value); err != nil {
+			return limits, fmt.Errorf("invalid cpu limit: %w", err)
+		}
+	}
+	if value, ok := spec["memory"]; ok {
+		if limits.Memory, err = parseMemory(value); err != nil {
+			return limits, fmt.Errorf("invalid memory limit: %w", err)
+		}
+	}
+	if value, ok := spec["pids"].(float64); ok {
+		if value < 0 {
+			return limits, fmt.Errorf("invalid pids limit: %v", value)
+		}
+		limits.PIDs = int64(value)
+	}
+
+	return limits, nil
+}
+
+func parseCPU(value interface{}) (float64, error) {
+	var cores float64
+	switch v := value.(type) {
+	case float64:
+		cores = v
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		millis, isMillis := strings.CutSuffix(v, "m")
+		n, err := strconv.ParseFloat(millis, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a CPU quantity", v)
+		}
+		cores = n
+		if isMillis {
+			cores = n / 1000
+		}
+	default:
+		return 0, fmt.Errorf("%v is not a CPU quantity", value)
+	}
+
+	if cores < 0 {
+		return 0, fmt.Errorf("%v is negative", value)
+	}
+	return cores, nil
+}
+
+// memoryUnits are the multipliers of memory quantity suffixes
+var memoryUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+}
+
+func parseMemory(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case float64:
+		if v < 0 {
+			return 0, fmt.Errorf("%v is negative", v)
+		}
+		return int64(v), nil
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		// "512MiB" is read as "512Mi"
+		quantity := strings.TrimSuffix(v, "B")
+		multiplier := int64(1)
+		for _, unit := range memoryUnits {
+			if number, ok := strings.CutSuffix(quantity, unit.suffix); ok {
+				quantity, multiplier = number, unit.multiplier
+				break
+			}
+		}
+		n, err := strconv.ParseFloat(quantity, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a memory quantity", v)
+		}
+		return int64(n * float64(multiplier)), nil
+	default:
+		return 0, fmt.Errorf("%v is not a memory quantity", value)
+	}
+}
+
+// cgroups places collectors and load simulations in their own cgroup v2
+// groups below a parent group the agent owns
+type cgroups struct {
+	// parent is empty when cgroups are disabled or unavailable, in which
+	// case processes run without limits
+	parent string
+}
+
+// newCgroups prepares parent, a group directly below the cgroup v2 mount,
+// for the groups of collectors. An empty parent disables cgroups.
+func newCgroups(parent string) *cgroups {
+	if parent == "" {
+		return &cgroups{}
+	}
+
+	if err := prepareCgroupParent(parent); err != nil {
+		log.Warn().
+			Err(err).
+			Str("cgroup_parent", parent).
+			Msg("cgroup v2 is unavailable, collectors run without resource limits")
+		return &cgroups{}
+	}
+
+	return &cgroups{parent: parent}
+}
+
+func prepareCgroupParent(parent string) error {
+	mount := filepath.Dir(parent)
+	available, err := os.ReadFile(filepath.Join(mount, "cgroup.controllers"))
+	if err != nil {
+		return fmt.Errorf("%s is not a cgroup v2 mount: %w", mount, err)
+	}
+
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("failed to create cgroup: %w", err)
+	}
+
+	// Controllers must be enabled on every level above the groups using
+	// them; those the kernel lacks are left out
+	var enable []string
+	for _, controller := range cgroupControllers {
+		if bytes.Contains(available, []byte(controller)) {
+			enable = append(enable, "+"+controller)
+		}
+	}
+	if len(enable) == 0 {
+		return fmt.Errorf("none of the %s controllers are available", strings.Join(cgroupControllers, ", "))
+	}
+	control := []byte(strings.Join(enable, " "))
+	for _, dir := range []string{mount, parent} {
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), control, 0644); err != nil {
+			return fmt.Errorf("failed to enable cgroup controllers in %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// enabled reports whether processes can be placed in groups
+func (c *cgroups) enabled() bool {
+	return c != nil && c.parent != ""
+}
+
+// path is the group called name
+func (c *cgroups) path(name string) string {
+	return filepath.Join(c.parent, name)
+}
+
+// place moves pid into the group called name, creating it with limits. It
+// returns the group's path.
+func (c *cgroups) place(name string, pid int, limits ResourceLimits) (string, error) {
+	path := c.path(name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	if err := setLimits(path, limits); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return "", fmt.Errorf("failed to move process into cgroup: %w", err)
+	}
+	return path, nil
+}
+
+// setLimits writes limits to a group, lifting those that are not set
+func setLimits(path string, limits ResourceLimits) error {
+	cpuMax := fmt.Sprintf("max %d", cpuPeriod)
+	if limits.CPU > 0 {
+		cpuMax = fmt.Sprintf("%d %d", int64(limits.CPU*cpuPeriod), cpuPeriod)
+	}
+	memoryMax := "max"
+	if limits.Memory > 0 {
+		memoryMax = strconv.FormatInt(limits.Memory, 10)
+	}
+	pidsMax := "max"
+	if limits.PIDs > 0 {
+		pidsMax = strconv.FormatInt(limits.PIDs, 10)
+	}
+
+	for file, value := range map[string]string{
+		"cpu.max":    cpuMax,
+		"memory.max": memoryMax,
+		"pids.max":   pidsMax,
+	} {
+		// A controller the kernel lacks has no files; that only matters
+		// if its limit is asked for
+		err := os.WriteFile(filepath.Join(path, file), []byte(value), 0644)
+		if err != nil && !strings.HasPrefix(value, "max") {
+			return fmt.Errorf("failed to set %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// remove deletes a group once the processes in it are gone
+func (c *cgroups) remove(path string) {
+	if path == "" {
+		return
+	}
+
+	// The kernel may take a moment to empty the group of a process that
+	// just exited
+	var err error
+	for attempt := 0; attempt < 10; attempt++ {
+		err = os.Remove(path)
+		if err == nil || os.IsNotExist(err) {
+			return
+		}
+		if !errors.Is(err, syscall.EBUSY) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	log.Warn().Err(err).Str("cgroup", path).Msg("Failed to remove cgroup")
+}
+
+// cgroupStats reads what the limits of a group did to the processes in it
+func cgroupStats(path string) map[string]interface{} {
+	stats := map[string]interface{}{}
+
+	memoryEvents := readKeyedFile(filepath.Join(path, "memory.events"))
+	if value, ok := memoryEvents["oom_kill"]; ok {
+		stats["oom_kills"] = value
+	}
+	if value, ok := memoryEvents["max"]; ok {
+		stats["memory_max_events"] = value
+	}
+
+	cpuStat := readKeyedFile(filepath.Join(path, "cpu.stat"))
+	if value, ok := cpuStat["nr_throttled"]; ok {
+		stats["cpu_throttled_periods"] = value
+	}
+	if value, ok := cpuStat["throttled_usec"]; ok {
+		stats["cpu_throttled_seconds"] = float64(value) / 1e6
+	}
+
+	if data, err := os.ReadFile(filepath.Join(path, "memory.current")); err == nil {
+		if value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			stats["memory_current_bytes"] = value
+		}
+	}
+
+	return stats
+}
+
+// readKeyedFile reads a cgroup file of "key value" lines
+func readKeyedFile(path string) map[string]int64 {
+	values := map[string]int64{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values
+}
+
+// collectorCgroup names the group of a collector after its config, so a
+// blue/green replacement gets a group of its own
+func collectorCgroup(process *Process) string {
+	return "collector-" + strings.TrimSuffix(filepath.Base(process.configFile), ".yaml")
+}
+
+// confine places a launched collector in its group. Without cgroups it runs
+// without limits. m.mu must be held.
+func (m *CollectorManager) confine(process *Process) {
+	limits := process.options.Limits
+	if !m.cgroups.enabled() {
+		if !limits.IsZero() {
+			log.Warn().Str("id", process.ID).Msg("cgroups are unavailable, running collector without its resource limits")
+		}
+		return
+	}
+
+	path, err := m.cgroups.place(collectorCgroup(process), process.Pid, limits)
+	if err != nil {
+		log.Warn().Err(err).Str("id", process.ID).Msg("Failed to place collector in its cgroup, running it without resource limits")
+		return
+	}
+	process.cgroup = path
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
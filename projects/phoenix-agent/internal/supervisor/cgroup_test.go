@@ -0,0 +1,125 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCgroupMount makes a directory look like a cgroup v2 mount and returns
+// a parent group below it
+func fakeCgroupMount(t *testing.T) string {
+	mount := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(mount, "cgroup.controllers"), []byte("cpuset cpu io memory pids\n"), 0644))
+	return filepath.Join(mount, "phoenix-agent")
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestParseResourceLimits(t *testing.T) {
+	limits, err := ParseResourceLimits(map[string]interface{}{
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{"cpu": "100m"},
+			"limits": map[string]interface{}{
+				"cpu":    "500m",
+				"memory": "512Mi",
+				"pids":   float64(64),
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ResourceLimits{CPU: 0.5, Memory: 512 << 20, PIDs: 64}, limits)
+
+	limits, err = ParseResourceLimits(map[string]interface{}{
+		"resources": map[string]interface{}{
+			"limits": map[string]interface{}{"cpu": float64(2), "memory": "1G"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ResourceLimits{CPU: 2, Memory: 1e9}, limits)
+
+	limits, err = ParseResourceLimits(map[string]interface{}{})
+	require.NoError(t, err)
+	assert.True(t, limits.IsZero())
+
+	_, err = ParseResourceLimits(map[string]interface{}{
+		"resources": map[string]interface{}{
+			"limits": map[string]interface{}{"memory": "lots"},
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestNewCgroups_FallsBackWithoutCgroupV2(t *testing.T) {
+	assert.False(t, newCgroups("").enabled())
+	assert.False(t, newCgroups(filepath.Join(t.TempDir(), "phoenix-agent")).enabled())
+}
+
+func TestCgroups_PlaceSetsLimits(t *testing.T) {
+	parent := fakeCgroupMount(t)
+	groups := newCgroups(parent)
+	require.True(t, groups.enabled())
+	assert.Equal(t, "+cpu +memory +pids", readFile(t, filepath.Join(filepath.Dir(parent), "cgroup.subtree_control")))
+	assert.Equal(t, "+cpu +memory +pids", readFile(t, filepath.Join(parent, "cgroup.subtree_control")))
+
+	path, err := groups.place("collector-exp-1-candidate", 1234, ResourceLimits{CPU: 0.5, Memory: 256 << 20})
+	require.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(parent, "collector-exp-1-candidate"), path)
+	assert.Equal(t, "50000 100000", readFile(t, filepath.Join(path, "cpu.max")))
+	assert.Equal(t, "268435456", readFile(t, filepath.Join(path, "memory.max")))
+	assert.Equal(t, "max", readFile(t, filepath.Join(path, "pids.max")))
+	assert.Equal(t, "1234", readFile(t, filepath.Join(path, "cgroup.procs")))
+}
+
+func TestCgroupStats(t *testing.T) {
+	path := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "memory.events"), []byte("low 0\nhigh 0\nmax 12\noom 3\noom_kill 2\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "cpu.stat"), []byte("usage_usec 900000\nnr_periods 40\nnr_throttled 7\nthrottled_usec 1500000\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "memory.current"), []byte("1048576\n"), 0644))
+
+	assert.Equal(t, map[string]interface{}{
+		"oom_kills":             int64(2),
+		"memory_max_events":     int64(12),
+		"cpu_throttled_periods": int64(7),
+		"cpu_throttled_seconds": 1.5,
+		"memory_current_bytes":  int64(1048576),
+	}, cgroupStats(path))
+}
+
+func TestCollectorManager_StartPlacesCollectorInCgroup(t *testing.T) {
+	dir := t.TempDir()
+	parent := fakeCgroupMount(t)
+	manager := NewCollectorManager(&config.Config{ConfigDir: dir, CgroupParent: parent})
+
+	spec := &collectorSpec{config: "receivers: {}\n", binary: fakeCollector(t, dir, false)}
+	limits := ResourceLimits{Memory: 128 << 20, PIDs: 32}
+	manager.mu.Lock()
+	err := manager.startSpec(context.Background(), "exp-1-candidate", "candidate", spec, CollectorOptions{Limits: limits})
+	manager.mu.Unlock()
+	require.NoError(t, err)
+	t.Cleanup(manager.StopAll)
+
+	group := filepath.Join(parent, "collector-exp-1-candidate")
+	pid := manager.GetProcessInfo("exp-1-candidate")["pid"].(int)
+	assert.Equal(t, "134217728", readFile(t, filepath.Join(group, "memory.max")))
+	assert.Equal(t, "32", readFile(t, filepath.Join(group, "pids.max")))
+	assert.Equal(t, strconv.Itoa(pid), readFile(t, filepath.Join(group, "cgroup.procs")))
+
+	// An OOM kill in the group is reported with the collector's metrics
+	require.NoError(t, os.WriteFile(filepath.Join(group, "memory.events"), []byte("oom_kill 1\n"), 0644))
+	metrics := manager.GetMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, int64(1), metrics[0]["oom_kills"])
+	assert.Equal(t, limits, metrics[0]["limits"])
+}
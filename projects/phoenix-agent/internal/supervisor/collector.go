@@ -1,9 +1,10 @@
 package supervisor
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,59 +14,172 @@ import (
 	"time"
 
 	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
 	"github.com/rs/zerolog/log"
 )
 
+// collectorEventBuffer bounds the collector events waiting to be sent to
+// the API; further events are dropped while it is full
+const collectorEventBuffer = 64
+
 type CollectorManager struct {
 	config    *config.Config
 	processes map[string]*Process
-	mu        sync.RWMutex
+	events    chan *poller.CollectorEvent
+	// shutdown keeps collectors stopped by Shutdown in the state file so
+	// the next agent process restarts them
+	shutdown bool
+	// ports are the telemetry ports assigned to collectors
+	ports   map[int]bool
+	cgroups *cgroups
+	// fetcher downloads configs through the agent's API client
+	fetcher ConfigFetcher
+	mu      sync.RWMutex
 }
 
+// Process is a supervised collector. It stays listed after exiting so its
+// restart count and last exit code can still be reported.
 type Process struct {
-	ID      string
-	Variant string
-	Cmd     *exec.Cmd
-	Pid     int
+	ID         string
+	Variant    string
+	Cmd        *exec.Cmd
+	Pid        int
+	ConfigHash string
+	StartedAt  time.Time
+
+	State        string
+	Restarts     int
+	LastExitCode int
+	LastExitAt   *time.Time
+	LastLogTail  string
+
+	options CollectorOptions
+	binary  string
+	args    []string
+	// env is added to the agent's environment
+	env     []string
+	logPath string
+	// configFile is the config the collector runs; slot says whether it
+	// listens on its usual ports or, after a blue/green update, on the
+	// alternate ones
+	configFile string
+	slot       int
+	// telemetryPort is where the collector serves its own metrics
+	telemetryPort int
+	// cpuSample is the CPU time the collector had used when last scraped
+	cpuSample *cpuSample
+	// cgroup is the path of the collector's cgroup, if it has one
+	cgroup string
+	// adopted is set for a collector started by an earlier agent process;
+	// it is not a child of this one
+	adopted bool
+	// crashes counts consecutive crashes; it is reset once the collector
+	// has run for the policy's StableAfter
+	crashes  int
+	stopping bool
+	// reloading is set while a collector is given its new config by
+	// SIGHUP; an exit meanwhile is left to Update
+	reloading    bool
+	updating     bool
+	exited       chan struct{}
+	restartTimer *time.Timer
+}
+
+// CollectorOptions are what a collector is started for and how it is
+// supervised
+type CollectorOptions struct {
+	TaskID       string
+	ExperimentID string
+	DeploymentID string
+	// DesiredHash is the config hash the API assigned the collector; the
+	// desired state names the same hash while the config is unchanged
+	DesiredHash string
+	Restart     RestartPolicy
+	Limits      ResourceLimits
+	// ConfigSHA256 is the digest the downloaded config must have, and
+	// ConfigSignature its signature by the API
+	ConfigSHA256    string
+	ConfigSignature string
 }
 
 func NewCollectorManager(cfg *config.Config) *CollectorManager {
 	return &CollectorManager{
 		config:    cfg,
 		processes: make(map[string]*Process),
+		events:    make(chan *poller.CollectorEvent, collectorEventBuffer),
+		ports:     make(map[int]bool),
+		cgroups:   newCgroups(cfg.CgroupParent),
+		fetcher:   poller.NewClient(cfg),
 	}
 }
 
-// Start starts a new OTel collector process
-func (m *CollectorManager) Start(id, variant, configURL string, vars map[string]string) error {
+// Events delivers the crash, restart and crash-loop events of supervised
+// collectors
+func (m *CollectorManager) Events() <-chan *poller.CollectorEvent {
+	return m.events
+}
+
+// Start starts a new OTel collector process. Cancelling ctx aborts the start
+// up to the point the process is launched; the collector itself outlives it
+// and is restarted according to opts.Restart when it exits.
+func (m *CollectorManager) Start(ctx context.Context, id, variant, configURL string, vars map[string]string, opts CollectorOptions) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Check if already running
-	if _, exists := m.processes[id]; exists {
-		return fmt.Errorf("collector %s already running", id)
+	// Check if already running; a collector that exited for good may be
+	// started again
+	if existing, exists := m.processes[id]; exists {
+		if existing.State != CollectorExited && existing.State != CollectorCrashLoop {
+			return fmt.Errorf("collector %s already running", id)
+		}
+		delete(m.processes, id)
 	}
 
+	spec, err := m.prepare(ctx, id, variant, configURL, vars, opts)
+	if err != nil {
+		return err
+	}
+
+	return m.startSpec(ctx, id, variant, spec, opts)
+}
+
+// collectorSpec is how a collector is run: its rendered config and the
+// command that runs it
+type collectorSpec struct {
+	config string
+	binary string
+	// args follow the --config flag
+	args []string
+	// env is added to the agent's environment
+	env []string
+}
+
+// command returns the arguments that run the collector with the config at
+// path, serving its own metrics on telemetryPort
+func (s *collectorSpec) command(path string, telemetryPort int) []string {
+	return append([]string{
+		"--config", path,
+		"--set", fmt.Sprintf("service.telemetry.metrics.address=127.0.0.1:%d", telemetryPort),
+	}, s.args...)
+}
+
+func (m *CollectorManager) configPath(id string) string {
+	return filepath.Join(m.config.ConfigDir, fmt.Sprintf("%s.yaml", id))
+}
+
+// prepare downloads, verifies and renders the config of a collector and
+// works out the command that runs it
+func (m *CollectorManager) prepare(ctx context.Context, id, variant, configURL string, vars map[string]string, opts CollectorOptions) (*collectorSpec, error) {
 	// Download and process config
-	config, err := m.downloadConfig(configURL)
+	config, err := m.fetchConfig(ctx, configURL, opts)
 	if err != nil {
-		return fmt.Errorf("failed to download config: %w", err)
+		return nil, fmt.Errorf("failed to download config: %w", err)
 	}
 
 	// Apply variable substitution
 	processedConfig, err := m.applyVariables(config, vars, id, variant)
 	if err != nil {
-		return fmt.Errorf("failed to apply variables: %w", err)
-	}
-
-	// Write config to disk
-	configPath := filepath.Join(m.config.ConfigDir, fmt.Sprintf("%s.yaml", id))
-	if err := os.MkdirAll(m.config.ConfigDir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
-	}
-
-	if err := os.WriteFile(configPath, []byte(processedConfig), 0644); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+		return nil, fmt.Errorf("failed to apply variables: %w", err)
 	}
 
 	// Determine which collector binary to use
@@ -83,17 +197,15 @@ func (m *CollectorManager) Start(id, variant, configURL string, vars map[string]
 		}
 		
 		if nrLicenseKey == "" {
-			return fmt.Errorf("NEW_RELIC_LICENSE_KEY is required when using NRDOT collector")
+			return nil, fmt.Errorf("NEW_RELIC_LICENSE_KEY is required when using NRDOT collector")
 		}
 	}
 
 	// Prepare command with appropriate binary
-	cmdArgs := []string{"--config", configPath}
+	var cmdArgs []string
 
 	// Add collector-specific arguments
-	if collectorBinary == "otelcol-contrib" {
-		cmdArgs = append(cmdArgs, "--set", "service.telemetry.metrics.address=:0")
-	} else if collectorBinary == "nrdot" {
+	if collectorBinary == "nrdot" {
 		// NRDOT-specific flags
 		cmdArgs = append(cmdArgs,
 			"--feature-gates", "exporter.newrelic.cardinality_reduction",
@@ -101,14 +213,12 @@ func (m *CollectorManager) Start(id, variant, configURL string, vars map[string]
 		)
 	}
 
-	cmd := exec.Command(collectorBinary, cmdArgs...)
-
 	// Set environment variables
-	env := append(os.Environ(),
+	env := []string{
 		fmt.Sprintf("EXPERIMENT_ID=%s", strings.Split(id, "-")[0]),
 		fmt.Sprintf("VARIANT=%s", variant),
 		fmt.Sprintf("HOST_ID=%s", m.config.HostID),
-	)
+	}
 
 	// Add New Relic specific environment variables if using NRDOT
 	if collectorBinary == "nrdot" {
@@ -136,34 +246,57 @@ func (m *CollectorManager) Start(id, variant, configURL string, vars map[string]
 		}
 	}
 
-	cmd.Env = env
+	return &collectorSpec{
+		config: processedConfig,
+		binary: collectorBinary,
+		args:   cmdArgs,
+		env:    env,
+	}, nil
+}
 
-	// Set up logging
-	logFile, err := os.Create(filepath.Join(m.config.ConfigDir, fmt.Sprintf("%s.log", id)))
-	if err != nil {
-		return fmt.Errorf("failed to create log file: %w", err)
+// startSpec writes the config of a collector and launches it. m.mu must be
+// held.
+func (m *CollectorManager) startSpec(ctx context.Context, id, variant string, spec *collectorSpec, opts CollectorOptions) error {
+	// Write config to disk
+	configPath := m.configPath(id)
+	if err := os.MkdirAll(m.config.ConfigDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
+	if err := os.WriteFile(configPath, []byte(spec.config), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
 
-	// Start process
-	if err := cmd.Start(); err != nil {
-		logFile.Close()
-		return fmt.Errorf("failed to start collector: %w", err)
+	// Don't launch a collector for a task that was cancelled meanwhile
+	if err := context.Cause(ctx); err != nil {
+		os.Remove(configPath)
+		return err
 	}
 
+	configHash := sha256.Sum256([]byte(spec.config))
+	port := m.allocatePort()
+
 	process := &Process{
-		ID:      id,
-		Variant: variant,
-		Cmd:     cmd,
-		Pid:     cmd.Process.Pid,
+		ID:         id,
+		Variant:    variant,
+		ConfigHash: hex.EncodeToString(configHash[:]),
+		options:    opts,
+		binary:     spec.binary,
+		args:       spec.command(configPath, port),
+		env:        spec.env,
+		logPath:    filepath.Join(m.config.ConfigDir, fmt.Sprintf("%s.log", id)),
+		configFile: configPath,
+
+		telemetryPort: port,
 	}
 
-	m.processes[id] = process
+	if err := m.launch(process, true); err != nil {
+		delete(m.ports, port)
+		return err
+	}
 
-	// Monitor process in background
-	go m.monitorProcess(process, logFile)
+	m.processes[id] = process
+	m.saveState()
 
 	log.Info().
 		Str("id", id).
@@ -184,35 +317,56 @@ func (m *CollectorManager) Stop(id string) error {
 		return fmt.Errorf("collector %s not found", id)
 	}
 
-	// Send graceful shutdown signal
-	if err := process.Cmd.Process.Signal(os.Interrupt); err != nil {
-		log.Warn().Err(err).Str("id", id).Msg("Failed to send interrupt signal, killing process")
-		process.Cmd.Process.Kill()
+	// An intended exit is not a crash
+	process.stopping = true
+	if process.restartTimer != nil {
+		process.restartTimer.Stop()
 	}
 
-	// Wait for process to exit (with timeout)
-	done := make(chan error, 1)
-	go func() {
-		done <- process.Cmd.Wait()
-	}()
+	if process.State == CollectorRunning {
+		// Send graceful shutdown signal
+		if err := process.signal(os.Interrupt); err != nil {
+			log.Warn().Err(err).Str("id", id).Msg("Failed to send interrupt signal, killing process")
+			process.signal(os.Kill)
+		}
 
-	select {
-	case <-done:
-		log.Info().Str("id", id).Msg("Collector stopped gracefully")
-	case <-time.After(10 * time.Second):
-		log.Warn().Str("id", id).Msg("Collector stop timeout, force killing")
-		process.Cmd.Process.Kill()
+		// Wait for process to exit (with timeout); monitorProcess reaps it
+		select {
+		case <-process.exited:
+			log.Info().Str("id", id).Msg("Collector stopped gracefully")
+		case <-time.After(10 * time.Second):
+			log.Warn().Str("id", id).Msg("Collector stop timeout, force killing")
+			process.signal(os.Kill)
+		}
 	}
 
 	delete(m.processes, id)
+	delete(m.ports, process.telemetryPort)
+	m.cgroups.remove(process.cgroup)
+
+	// A collector stopped for an agent shutdown is started again by the
+	// next agent process
+	if m.shutdown {
+		return nil
+	}
+	m.saveState()
 
 	// Clean up config file
-	configPath := filepath.Join(m.config.ConfigDir, fmt.Sprintf("%s.yaml", id))
-	os.Remove(configPath)
+	os.Remove(process.configFile)
 
 	return nil
 }
 
+// Shutdown stops all collectors for an agent shutdown. Unlike StopAll it
+// leaves them in the state file for the next agent process to restart.
+func (m *CollectorManager) Shutdown() {
+	m.mu.Lock()
+	m.shutdown = true
+	m.mu.Unlock()
+
+	m.StopAll()
+}
+
 // StopAll stops all running collectors
 func (m *CollectorManager) StopAll() {
 	m.mu.Lock()
@@ -240,30 +394,112 @@ func (m *CollectorManager) GetProcessInfo(id string) map[string]interface{} {
 	}
 
 	return map[string]interface{}{
-		"pid":     process.Pid,
-		"variant": process.Variant,
+		"pid":         process.Pid,
+		"variant":     process.Variant,
+		"config_hash": process.ConfigHash,
+		"started_at":  process.StartedAt,
+		"state":       process.State,
+		"restarts":    process.Restarts,
 	}
 }
 
-// GetMetrics returns metrics for all running collectors
-func (m *CollectorManager) GetMetrics() []map[string]interface{} {
+// CollectorSnapshot is the supervised state of a collector
+type CollectorSnapshot struct {
+	ID           string
+	DeploymentID string
+	State        string
+	DesiredHash  string
+	StartedAt    time.Time
+}
+
+// Snapshot returns the supervised state of every collector
+func (m *CollectorManager) Snapshot() map[string]CollectorSnapshot {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	snapshot := make(map[string]CollectorSnapshot, len(m.processes))
+	for id, process := range m.processes {
+		snapshot[id] = CollectorSnapshot{
+			ID:           id,
+			DeploymentID: process.options.DeploymentID,
+			State:        process.State,
+			DesiredHash:  process.options.DesiredHash,
+			StartedAt:    process.StartedAt,
+		}
+	}
+	return snapshot
+}
+
+// CollectorLog is the log file a supervised collector writes its output to
+type CollectorLog struct {
+	Path   string
+	TaskID string
+}
+
+// CollectorLogs returns the log file of every supervised collector
+func (m *CollectorManager) CollectorLogs() map[string]CollectorLog {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	logs := make(map[string]CollectorLog, len(m.processes))
+	for id, process := range m.processes {
+		logs[id] = CollectorLog{Path: process.logPath, TaskID: process.options.TaskID}
+	}
+	return logs
+}
+
+// GetMetrics returns metrics for all supervised collectors, including those
+// waiting to be restarted or given up on. Running collectors are scraped for
+// their own telemetry and resource usage.
+func (m *CollectorManager) GetMetrics() []map[string]interface{} {
+	m.mu.RLock()
+
 	var metrics []map[string]interface{}
+	var targets []telemetryTarget
 	for id, process := range m.processes {
-		metrics = append(metrics, map[string]interface{}{
-			"collector_id": id,
-			"variant":      process.Variant,
-			"pid":          process.Pid,
-			"running":      true,
-		})
+		metric := map[string]interface{}{
+			"collector_id":   id,
+			"variant":        process.Variant,
+			"pid":            process.Pid,
+			"running":        process.State == CollectorRunning,
+			"state":          process.State,
+			"restart_policy": process.options.Restart.withDefaults().Mode,
+			"restarts":       process.Restarts,
+		}
+		if process.LastExitAt != nil {
+			metric["last_exit_code"] = process.LastExitCode
+			metric["last_exit_at"] = *process.LastExitAt
+		}
+		if process.cgroup != "" {
+			for name, value := range cgroupStats(process.cgroup) {
+				metric[name] = value
+			}
+		}
+		if limits := process.options.Limits; !limits.IsZero() {
+			metric["limits"] = limits
+		}
+		if process.State == CollectorRunning && process.telemetryPort > 0 {
+			metric["telemetry_port"] = process.telemetryPort
+			targets = append(targets, telemetryTarget{
+				metric:  metric,
+				process: process,
+				pid:     process.Pid,
+				port:    process.telemetryPort,
+			})
+		}
+		metrics = append(metrics, metric)
+	}
+	m.mu.RUnlock()
+
+	// Scrape without the lock; collectors may be slow to answer
+	for _, target := range targets {
+		m.addTelemetry(target)
 	}
 
 	return metrics
 }
 
-func (m *CollectorManager) downloadConfig(url string) (string, error) {
+func (m *CollectorManager) downloadConfig(ctx context.Context, url string) (string, error) {
 	// Handle file:// URLs
 	if strings.HasPrefix(url, "file://") {
 		path := strings.TrimPrefix(url, "file://")
@@ -275,19 +511,9 @@ func (m *CollectorManager) downloadConfig(url string) (string, error) {
 	}
 
 	// Handle HTTP/HTTPS URLs
-	resp, err := http.Get(url)
+	data, err := m.fetcher.FetchConfig(ctx, url)
 	if err != nil {
-		return "", fmt.Errorf("failed to download config: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read config: %w", err)
+		return "", err
 	}
 
 	return string(data), nil
@@ -324,26 +550,195 @@ func (m *CollectorManager) applyVariables(config string, vars map[string]string,
 	return buf.String(), nil
 }
 
-func (m *CollectorManager) monitorProcess(process *Process, logFile *os.File) {
-	defer logFile.Close()
+// launch starts the process of a collector and supervises it. The log is
+// truncated on the first launch and appended to on restarts. m.mu must be
+// held.
+func (m *CollectorManager) launch(process *Process, truncate bool) error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if truncate {
+		flags |= os.O_TRUNC
+	}
 
+	// Set up logging
+	logFile, err := os.OpenFile(process.logPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+
+	cmd := exec.Command(process.binary, process.args...)
+	cmd.Env = append(os.Environ(), process.env...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	// Start process
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return fmt.Errorf("failed to start collector: %w", err)
+	}
+
+	process.Cmd = cmd
+	process.Pid = cmd.Process.Pid
+	process.StartedAt = time.Now()
+	process.State = CollectorRunning
+	process.adopted = false
+	process.exited = make(chan struct{})
+	m.confine(process)
+
+	// Monitor process in background
+	go m.monitorProcess(process, cmd, process.exited, logFile)
+
+	return nil
+}
+
+// monitorProcess waits for a collector to exit and, unless it was stopped,
+// handles the exit according to its restart policy
+func (m *CollectorManager) monitorProcess(process *Process, cmd *exec.Cmd, exited chan struct{}, logFile *os.File) {
 	// Wait for process to exit
-	err := process.Cmd.Wait()
+	err := cmd.Wait()
+	logFile.Close()
+	close(exited)
 
 	m.mu.Lock()
-	delete(m.processes, process.ID)
-	m.mu.Unlock()
+	defer m.mu.Unlock()
 
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("id", process.ID).
-			Int("pid", process.Pid).
-			Msg("Collector process exited with error")
-	} else {
+	if process.stopping || m.processes[process.ID] != process {
 		log.Info().
 			Str("id", process.ID).
 			Int("pid", process.Pid).
-			Msg("Collector process exited normally")
+			Msg("Collector process exited")
+		return
+	}
+
+	exitCode := cmd.ProcessState.ExitCode()
+	if process.reloading {
+		m.reloadExited(process, exitCode)
+		return
+	}
+
+	log.Error().
+		Err(err).
+		Str("id", process.ID).
+		Int("pid", process.Pid).
+		Int("exit_code", exitCode).
+		Msg("Collector process exited unexpectedly")
+
+	m.handleExit(process, exitCode)
+}
+
+// handleExit records an unexpected exit of a collector and restarts it
+// after a backoff, or gives up on it. m.mu must be held.
+func (m *CollectorManager) handleExit(process *Process, exitCode int) {
+	policy := process.options.Restart.withDefaults()
+	now := time.Now()
+
+	process.LastExitCode = exitCode
+	process.LastExitAt = &now
+	process.LastLogTail = logTail(process.logPath)
+
+	if now.Sub(process.StartedAt) >= policy.StableAfter {
+		process.crashes = 0
+	}
+
+	event := m.newEvent(process, "exited")
+
+	switch {
+	case !policy.shouldRestart(exitCode):
+		process.State = CollectorExited
+
+	case process.crashes >= policy.MaxRestarts:
+		process.State = CollectorCrashLoop
+		event.Event = "crash_loop"
+		log.Error().
+			Str("id", process.ID).
+			Int("restarts", process.Restarts).
+			Msg("Collector is crash looping, giving up")
+
+	default:
+		process.crashes++
+		delay := policy.backoff(process.crashes)
+		process.State = CollectorBackoff
+		process.restartTimer = time.AfterFunc(delay, func() { m.restart(process) })
+
+		event.Event = "crashed"
+		event.RestartIn = delay.String()
+		log.Warn().
+			Str("id", process.ID).
+			Dur("delay", delay).
+			Msg("Restarting collector after backoff")
+	}
+
+	m.saveState()
+	m.emit(event)
+}
+
+// restart launches a collector again after its backoff
+func (m *CollectorManager) restart(process *Process) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if process.stopping || m.processes[process.ID] != process {
+		return
+	}
+
+	if err := m.launch(process, false); err != nil {
+		// A collector that cannot be launched counts as another crash
+		log.Error().Err(err).Str("id", process.ID).Msg("Failed to restart collector")
+		process.StartedAt = time.Now()
+		m.handleExit(process, -1)
+		return
+	}
+
+	process.Restarts++
+	m.saveState()
+	m.emit(m.newEvent(process, "restarted"))
+
+	log.Info().
+		Str("id", process.ID).
+		Int("pid", process.Pid).
+		Int("restarts", process.Restarts).
+		Msg("Restarted OTel collector")
+}
+
+// signal sends sig to a collector, whether it is a child of this agent or
+// was adopted
+func (p *Process) signal(sig os.Signal) error {
+	if p.Cmd != nil && !p.adopted {
+		return p.Cmd.Process.Signal(sig)
+	}
+	proc, err := os.FindProcess(p.Pid)
+	if err != nil {
+		return err
+	}
+	return proc.Signal(sig)
+}
+
+func (m *CollectorManager) newEvent(process *Process, event string) *poller.CollectorEvent {
+	e := &poller.CollectorEvent{
+		CollectorID:  process.ID,
+		ExperimentID: process.options.ExperimentID,
+		DeploymentID: process.options.DeploymentID,
+		Variant:      process.Variant,
+		Event:        event,
+		ExitCode:     process.LastExitCode,
+		Restarts:     process.Restarts,
+		Timestamp:    time.Now(),
+	}
+	if event == "restarted" {
+		e.Pid = process.Pid
+	} else {
+		e.LogTail = process.LastLogTail
+	}
+	return e
+}
+
+// emit queues an event for the API without blocking supervision
+func (m *CollectorManager) emit(event *poller.CollectorEvent) {
+	select {
+	case m.events <- event:
+	default:
+		log.Warn().
+			Str("id", event.CollectorID).
+			Str("event", event.Event).
+			Msg("Collector event buffer full, dropping event")
 	}
 }
@@ -0,0 +1,168 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// configCacheEntries bounds the verified configs kept in the cache; the
+// least recently used are removed first
+const configCacheEntries = 64
+
+// ConfigFetcher downloads collector configs from http(s) URLs
+type ConfigFetcher interface {
+	FetchConfig(ctx context.Context, url string) ([]byte, error)
+}
+
+// fetchConfig returns the config a task names. A config with an expected
+// SHA-256 is taken from the cache when it is there, and otherwise
+// downloaded, verified and cached. With a verify key configured, the
+// SHA-256 must be signed with it.
+func (m *CollectorManager) fetchConfig(ctx context.Context, configURL string, opts CollectorOptions) (string, error) {
+	sum := strings.ToLower(opts.ConfigSHA256)
+	if err := m.verifySignature(sum, opts.ConfigSignature); err != nil {
+		return "", err
+	}
+
+	if sum == "" {
+		log.Warn().Str("url", configURL).Msg("Config has no expected SHA-256, running it unverified")
+		return m.downloadConfig(ctx, configURL)
+	}
+
+	if config, ok := m.cachedConfig(sum); ok {
+		return config, nil
+	}
+
+	config, err := m.downloadConfig(ctx, configURL)
+	if err != nil {
+		return "", err
+	}
+	if actual := configHash(config); actual != sum {
+		return "", fmt.Errorf("config from %s has SHA-256 %s, expected %s", configURL, actual, sum)
+	}
+
+	if err := m.cacheConfig(sum, config); err != nil {
+		log.Warn().Err(err).Str("sha256", sum).Msg("Failed to cache config")
+	}
+	return config, nil
+}
+
+// verifySignature checks that signature is the Ed25519 signature of the
+// SHA-256 digest sum under the configured verify key. Without a key any
+// config is accepted.
+func (m *CollectorManager) verifySignature(sum, signature string) error {
+	if m.config.ConfigVerifyKey == "" {
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(m.config.ConfigVerifyKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("config verify key is not a base64 Ed25519 public key")
+	}
+	if sum == "" || signature == "" {
+		return fmt.Errorf("config is not signed")
+	}
+
+	digest, err := hex.DecodeString(sum)
+	if err != nil || len(digest) != sha256.Size {
+		return fmt.Errorf("invalid config SHA-256 %q", sum)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(key), digest, sig) {
+		return fmt.Errorf("config signature does not match its SHA-256")
+	}
+	return nil
+}
+
+// configCachePath is where the verified config with SHA-256 sum is cached
+func (m *CollectorManager) configCachePath(sum string) string {
+	return filepath.Join(m.config.ConfigDir, "cache", sum+".yaml")
+}
+
+// cachedConfig returns the cached config with SHA-256 sum. A cached file
+// that no longer hashes to its name is removed.
+func (m *CollectorManager) cachedConfig(sum string) (string, bool) {
+	path := m.configCachePath(sum)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	config := string(data)
+	if configHash(config) != sum {
+		log.Warn().Str("path", path).Msg("Cached config is corrupt, downloading it again")
+		os.Remove(path)
+		return "", false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return config, true
+}
+
+// cacheConfig stores a verified config under its SHA-256 and prunes the
+// cache to configCacheEntries
+func (m *CollectorManager) cacheConfig(sum, config string) error {
+	path := m.configCachePath(sum)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config cache: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(config), 0644); err != nil {
+		return fmt.Errorf("failed to write cached config: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write cached config: %w", err)
+	}
+
+	pruneConfigCache(filepath.Dir(path))
+	return nil
+}
+
+// pruneConfigCache removes the least recently used configs beyond
+// configCacheEntries
+func pruneConfigCache(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type cached struct {
+		path    string
+		modTime time.Time
+	}
+	var configs []cached
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		configs = append(configs, cached{filepath.Join(dir, entry.Name()), info.ModTime()})
+	}
+	if len(configs) <= configCacheEntries {
+		return
+	}
+
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].modTime.After(configs[j].modTime)
+	})
+	for _, config := range configs[configCacheEntries:] {
+		os.Remove(config.path)
+	}
+}
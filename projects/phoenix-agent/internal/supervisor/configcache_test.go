@@ -0,0 +1,114 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cachedCollectorConfig = "receivers:\n  otlp: {}\n"
+
+// fakeFetcher serves configs by URL and counts downloads
+type fakeFetcher struct {
+	configs map[string]string
+	fetches int
+}
+
+func (f *fakeFetcher) FetchConfig(ctx context.Context, url string) ([]byte, error) {
+	f.fetches++
+	config, ok := f.configs[url]
+	if !ok {
+		return nil, fmt.Errorf("unexpected status code downloading config: 404")
+	}
+	return []byte(config), nil
+}
+
+func newFetchingManager(t *testing.T, cfg *config.Config) (*CollectorManager, *fakeFetcher) {
+	cfg.ConfigDir = t.TempDir()
+	manager := NewCollectorManager(cfg)
+	fetcher := &fakeFetcher{configs: map[string]string{"https://configs.example.com/a.yaml": cachedCollectorConfig}}
+	manager.fetcher = fetcher
+	return manager, fetcher
+}
+
+func TestFetchConfig_VerifiesAndCaches(t *testing.T) {
+	manager, fetcher := newFetchingManager(t, &config.Config{})
+	opts := CollectorOptions{ConfigSHA256: configHash(cachedCollectorConfig)}
+
+	config, err := manager.fetchConfig(context.Background(), "https://configs.example.com/a.yaml", opts)
+	require.NoError(t, err)
+	assert.Equal(t, cachedCollectorConfig, config)
+	assert.Equal(t, cachedCollectorConfig, readFile(t, manager.configCachePath(opts.ConfigSHA256)))
+
+	// Once cached, the config no longer needs the network
+	fetcher.configs = nil
+	config, err = manager.fetchConfig(context.Background(), "https://configs.example.com/a.yaml", opts)
+	require.NoError(t, err)
+	assert.Equal(t, cachedCollectorConfig, config)
+	assert.Equal(t, 1, fetcher.fetches)
+}
+
+func TestFetchConfig_RejectsHashMismatch(t *testing.T) {
+	manager, _ := newFetchingManager(t, &config.Config{})
+	opts := CollectorOptions{ConfigSHA256: configHash("something else")}
+
+	_, err := manager.fetchConfig(context.Background(), "https://configs.example.com/a.yaml", opts)
+	assert.ErrorContains(t, err, "expected "+opts.ConfigSHA256)
+	assert.NoFileExists(t, manager.configCachePath(opts.ConfigSHA256))
+}
+
+func TestFetchConfig_RedownloadsCorruptCache(t *testing.T) {
+	manager, fetcher := newFetchingManager(t, &config.Config{})
+	sum := configHash(cachedCollectorConfig)
+	require.NoError(t, manager.cacheConfig(sum, cachedCollectorConfig))
+	require.NoError(t, os.WriteFile(manager.configCachePath(sum), []byte("tampered"), 0644))
+
+	config, err := manager.fetchConfig(context.Background(), "https://configs.example.com/a.yaml", CollectorOptions{ConfigSHA256: sum})
+	require.NoError(t, err)
+	assert.Equal(t, cachedCollectorConfig, config)
+	assert.Equal(t, 1, fetcher.fetches)
+}
+
+func TestFetchConfig_ChecksSignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	manager, _ := newFetchingManager(t, &config.Config{ConfigVerifyKey: base64.StdEncoding.EncodeToString(public)})
+
+	sum := configHash(cachedCollectorConfig)
+	digest, err := hex.DecodeString(sum)
+	require.NoError(t, err)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, digest))
+
+	url := "https://configs.example.com/a.yaml"
+	_, err = manager.fetchConfig(context.Background(), url, CollectorOptions{ConfigSHA256: sum, ConfigSignature: signature})
+	require.NoError(t, err)
+
+	_, err = manager.fetchConfig(context.Background(), url, CollectorOptions{ConfigSHA256: sum})
+	assert.ErrorContains(t, err, "not signed")
+
+	forged := base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte("other digest")))
+	_, err = manager.fetchConfig(context.Background(), url, CollectorOptions{ConfigSHA256: sum, ConfigSignature: forged})
+	assert.ErrorContains(t, err, "does not match")
+}
+
+func TestPruneConfigCache(t *testing.T) {
+	manager, _ := newFetchingManager(t, &config.Config{})
+	for i := 0; i <= configCacheEntries; i++ {
+		config := fmt.Sprintf("# config %d\n", i)
+		require.NoError(t, manager.cacheConfig(configHash(config), config))
+	}
+
+	entries, err := os.ReadDir(filepath.Join(manager.config.ConfigDir, "cache"))
+	require.NoError(t, err)
+	assert.Len(t, entries, configCacheEntries)
+}
@@ -0,0 +1,427 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+// Diagnostics are the commands command tasks may run; there is no way to run
+// anything else
+const (
+	DiagnosticLogTail        = "log_tail"
+	DiagnosticRenderedConfig = "rendered_config"
+	DiagnosticListeningPorts = "listening_ports"
+	DiagnosticDiskUsage      = "disk_usage"
+	DiagnosticValidateConfig = "validate_config"
+	DiagnosticProcStats      = "proc_stats"
+)
+
+const (
+	// maxDiagnosticOutput caps the text a diagnostic returns
+	maxDiagnosticOutput = 64 << 10
+	// maxProcFileOutput caps each /proc file of proc_stats
+	maxProcFileOutput = 16 << 10
+	// maxListeningPorts caps the sockets listening_ports lists
+	maxListeningPorts = 500
+
+	defaultLogTailLines = 200
+	maxLogTailLines     = 5000
+)
+
+// procRoot is where the proc filesystem is mounted
+var procRoot = "/proc"
+
+// procStatFiles are the files of a collector's /proc directory proc_stats
+// returns
+var procStatFiles = []string{"status", "stat", "statm", "io", "limits"}
+
+// secretLine matches config lines whose value looks like a credential. A
+// bare "key", as in attribute processors, is not one.
+var secretLine = regexp.MustCompile(`(?im)^(\s*-?\s*"?[\w.-]*(?:api[_-]?key|license[_-]?key|access[_-]?key|private[_-]?key|token|secret|password|passwd|authorization|credentials?)[\w.-]*"?\s*:\s*)\S.*$`)
+
+// diagnosticCollector is what diagnostics need to know about a collector,
+// copied under the manager's lock
+type diagnosticCollector struct {
+	id         string
+	pid        int
+	state      string
+	binary     string
+	env        []string
+	configFile string
+	logPath    string
+}
+
+// Diagnose runs one of the built-in diagnostics. Collector diagnostics take
+// the collector_id of a supervised collector from config.
+func (m *CollectorManager) Diagnose(ctx context.Context, command string, config map[string]interface{}) (map[string]interface{}, error) {
+	switch command {
+	case DiagnosticListeningPorts:
+		return m.listeningPorts(ctx)
+	case DiagnosticDiskUsage:
+		return m.diskUsage(ctx)
+	case DiagnosticLogTail, DiagnosticRenderedConfig, DiagnosticValidateConfig, DiagnosticProcStats:
+	default:
+		return nil, fmt.Errorf("unknown diagnostic: %s", command)
+	}
+
+	id, _ := config["collector_id"].(string)
+	if id == "" {
+		return nil, fmt.Errorf("missing collector_id in config")
+	}
+	collector, err := m.diagnosticCollector(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	switch command {
+	case DiagnosticLogTail:
+		lines := defaultLogTailLines
+		if value, ok := config["lines"].(float64); ok && value > 0 {
+			lines = int(value)
+		}
+		result, err = collectorLogTail(collector, lines)
+	case DiagnosticRenderedConfig:
+		result, err = renderedConfig(collector)
+	case DiagnosticValidateConfig:
+		result, err = validateCollectorConfig(ctx, collector)
+	case DiagnosticProcStats:
+		result, err = procStats(collector)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result["collector_id"] = id
+	return result, nil
+}
+
+func (m *CollectorManager) diagnosticCollector(id string) (*diagnosticCollector, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	process, exists := m.processes[id]
+	if !exists {
+		return nil, fmt.Errorf("collector %s not found", id)
+	}
+
+	return &diagnosticCollector{
+		id:         id,
+		pid:        process.Pid,
+		state:      process.State,
+		binary:     process.binary,
+		env:        process.env,
+		configFile: process.configFile,
+		logPath:    process.logPath,
+	}, nil
+}
+
+// collectorLogTail returns the last lines of a collector's log
+func collectorLogTail(collector *diagnosticCollector, lines int) (map[string]interface{}, error) {
+	if lines > maxLogTailLines {
+		lines = maxLogTailLines
+	}
+
+	tail, truncated, err := tailFile(collector.logPath, maxDiagnosticOutput, lines)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collector log: %w", err)
+	}
+
+	return map[string]interface{}{
+		"output":    tail,
+		"lines":     strings.Count(tail, "\n") + 1,
+		"truncated": truncated,
+	}, nil
+}
+
+// renderedConfig returns the config a collector runs with, with credentials
+// redacted
+func renderedConfig(collector *diagnosticCollector) (map[string]interface{}, error) {
+	data, err := os.ReadFile(collector.configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collector config: %w", err)
+	}
+
+	config := redactSecrets(string(data), collector.env)
+	output, truncated := truncateHead(config, maxDiagnosticOutput)
+	return map[string]interface{}{
+		"path":        collector.configFile,
+		"config_hash": configHash(string(data)),
+		"output":      output,
+		"truncated":   truncated,
+	}, nil
+}
+
+// redactSecrets blanks the values of credential-like config keys and of
+// credential-like environment variables the collector is given
+func redactSecrets(config string, env []string) string {
+	config = secretLine.ReplaceAllString(config, "${1}[REDACTED]")
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+		if value != "" && secretLine.MatchString(name+": x") {
+			config = strings.ReplaceAll(config, value, "[REDACTED]")
+		}
+	}
+	return config
+}
+
+// validateCollectorConfig runs the collector binary's validate command on
+// the config the collector runs with. An invalid config is a result, not a
+// failure of the diagnostic.
+func validateCollectorConfig(ctx context.Context, collector *diagnosticCollector) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, collector.binary, "validate", "--config", collector.configFile)
+	cmd.Env = append(os.Environ(), collector.env...)
+	output, err := cmd.CombinedOutput()
+
+	result := map[string]interface{}{
+		"path":  collector.configFile,
+		"valid": err == nil,
+	}
+	if err != nil {
+		if _, exited := err.(*exec.ExitError); !exited {
+			return nil, fmt.Errorf("failed to run %s validate: %w", collector.binary, err)
+		}
+		result["error"] = err.Error()
+	}
+
+	text, truncated := truncateTail(redactSecrets(string(output), collector.env), maxDiagnosticOutput)
+	result["output"] = text
+	result["truncated"] = truncated
+	return result, nil
+}
+
+// procStats captures the /proc files of a running collector
+func procStats(collector *diagnosticCollector) (map[string]interface{}, error) {
+	if collector.state != CollectorRunning || collector.pid <= 0 {
+		return nil, fmt.Errorf("collector %s is not running", collector.id)
+	}
+
+	dir := filepath.Join(procRoot, strconv.Itoa(collector.pid))
+	files := make(map[string]string, len(procStatFiles))
+	errors := make(map[string]string)
+	truncated := false
+	for _, name := range procStatFiles {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			errors[name] = err.Error()
+			continue
+		}
+		text, cut := truncateHead(string(data), maxProcFileOutput)
+		files[name] = text
+		truncated = truncated || cut
+	}
+
+	result := map[string]interface{}{
+		"pid":       collector.pid,
+		"files":     files,
+		"truncated": truncated,
+	}
+	if fds, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		result["open_fds"] = len(fds)
+	}
+	if len(errors) > 0 {
+		result["errors"] = errors
+	}
+	return result, nil
+}
+
+// listeningPorts lists the TCP sockets listening on the host and the bound
+// UDP sockets, naming the collector that owns each
+func (m *CollectorManager) listeningPorts(ctx context.Context) (map[string]interface{}, error) {
+	connections, err := psnet.ConnectionsWithContext(ctx, "inet")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sockets: %w", err)
+	}
+
+	m.mu.RLock()
+	collectors := make(map[int32]string, len(m.processes))
+	for id, process := range m.processes {
+		if process.State == CollectorRunning && process.Pid > 0 {
+			collectors[int32(process.Pid)] = id
+		}
+	}
+	m.mu.RUnlock()
+
+	ports := []map[string]interface{}{}
+	truncated := false
+	for _, conn := range connections {
+		protocol := socketProtocol(conn)
+		listening := (protocol == "tcp" && conn.Status == "LISTEN") || (protocol == "udp" && conn.Raddr.Port == 0)
+		if !listening {
+			continue
+		}
+		if len(ports) == maxListeningPorts {
+			truncated = true
+			break
+		}
+
+		port := map[string]interface{}{
+			"protocol": protocol,
+			"address":  conn.Laddr.IP,
+			"port":     conn.Laddr.Port,
+			"pid":      conn.Pid,
+		}
+		if id, ok := collectors[conn.Pid]; ok {
+			port["collector_id"] = id
+		}
+		ports = append(ports, port)
+	}
+
+	return map[string]interface{}{
+		"ports":     ports,
+		"truncated": truncated,
+	}, nil
+}
+
+func socketProtocol(conn psnet.ConnectionStat) string {
+	protocol := "tcp"
+	if conn.Type == syscall.SOCK_DGRAM {
+		protocol = "udp"
+	}
+	if conn.Family == syscall.AF_INET6 {
+		protocol += "6"
+	}
+	return protocol
+}
+
+// diskUsage reports the space and inodes of the file system holding the
+// config directory and what the directory itself takes up
+func (m *CollectorManager) diskUsage(ctx context.Context) (map[string]interface{}, error) {
+	dir := m.config.ConfigDir
+	usage, err := disk.UsageWithContext(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk usage of %s: %w", dir, err)
+	}
+
+	var files, bytes, cacheBytes int64
+	cache := filepath.Join(dir, "cache")
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		files++
+		bytes += info.Size()
+		if strings.HasPrefix(path, cache+string(filepath.Separator)) {
+			cacheBytes += info.Size()
+		}
+		return nil
+	})
+
+	return map[string]interface{}{
+		"path":                dir,
+		"total_bytes":         usage.Total,
+		"used_bytes":          usage.Used,
+		"free_bytes":          usage.Free,
+		"used_percent":        usage.UsedPercent,
+		"inodes_total":        usage.InodesTotal,
+		"inodes_used":         usage.InodesUsed,
+		"inodes_free":         usage.InodesFree,
+		"inodes_used_percent": usage.InodesUsedPercent,
+		"config_dir_files":    files,
+		"config_dir_bytes":    bytes,
+		"config_cache_bytes":  cacheBytes,
+	}, nil
+}
+
+// tailFile returns at most the last maxLines lines and maxBytes bytes of a
+// file, and whether any of the file was left out
+func tailFile(path string, maxBytes int64, maxLines int) (string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", false, err
+	}
+
+	offset := info.Size() - maxBytes
+	if offset < 0 {
+		offset = 0
+	}
+	data := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(data, offset); err != nil {
+		return "", false, err
+	}
+
+	text := strings.TrimRight(string(data), "\n")
+	truncated := offset > 0
+	if truncated {
+		// The first line is likely cut off
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+		text = validUTF8Suffix(text)
+	}
+
+	lines := strings.Split(text, "\n")
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+		truncated = true
+	}
+	return strings.Join(lines, "\n"), truncated, nil
+}
+
+// truncateHead keeps the start of text, at most limit bytes, cut at a line
+// end where there is one and never inside a UTF-8 sequence
+func truncateHead(text string, limit int) (string, bool) {
+	if len(text) <= limit {
+		return text, false
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	head := text[:cut]
+	if i := strings.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i+1]
+	}
+	return head, true
+}
+
+// truncateTail keeps the end of text, at most limit bytes, cut at a line
+// start where there is one and never inside a UTF-8 sequence
+func truncateTail(text string, limit int) (string, bool) {
+	if len(text) <= limit {
+		return text, false
+	}
+
+	tail := validUTF8Suffix(text[len(text)-limit:])
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	return tail, true
+}
+
+// validUTF8Suffix drops the continuation bytes of a rune cut off at the
+// start of text
+func validUTF8Suffix(text string) string {
+	for i := 0; i < len(text) && i < utf8.UTFMax; i++ {
+		if utf8.RuneStart(text[i]) {
+			return text[i:]
+		}
+	}
+	return text
+}
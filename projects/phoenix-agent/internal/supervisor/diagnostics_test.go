@@ -0,0 +1,144 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateHead(t *testing.T) {
+	text, truncated := truncateHead("short\n", 64)
+	assert.Equal(t, "short\n", text)
+	assert.False(t, truncated)
+
+	text, truncated = truncateHead("first line\nsecond line\n", 15)
+	assert.Equal(t, "first line\n", text)
+	assert.True(t, truncated)
+
+	// A cut inside a rune backs off to its start
+	text, truncated = truncateHead("ééééé", 5)
+	assert.Equal(t, "éé", text)
+	assert.True(t, truncated)
+}
+
+func TestTruncateTail(t *testing.T) {
+	text, truncated := truncateTail("first line\nsecond line\n", 15)
+	assert.Equal(t, "second line\n", text)
+	assert.True(t, truncated)
+
+	text, _ = truncateTail("ééééé", 5)
+	assert.True(t, utf8.ValidString(text))
+	assert.Equal(t, "éé", text)
+}
+
+func TestRedactSecrets(t *testing.T) {
+	config := `exporters:
+  otlphttp:
+    headers:
+      api-key: abc123
+      X-License-Key: "def456"
+processors:
+  attributes:
+    actions:
+      - key: host.name
+        action: delete
+extensions:
+  basicauth:
+    client_auth:
+      password: hunter2
+  bearertokenauth:
+    token: tok
+receivers:
+  otlp:
+    endpoint: nr-0123456789
+`
+	redacted := redactSecrets(config, []string{"NEW_RELIC_LICENSE_KEY=nr-0123456789", "HOST_ID=host-1"})
+
+	for _, secret := range []string{"abc123", "def456", "hunter2", "tok\n", "nr-0123456789"} {
+		assert.NotContains(t, redacted, secret)
+	}
+	assert.Contains(t, redacted, "api-key: [REDACTED]")
+	assert.Contains(t, redacted, "- key: host.name", "attribute keys are not credentials")
+}
+
+func TestTailFile(t *testing.T) {
+	path := t.TempDir() + "/collector.log"
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+
+	tail, truncated, err := tailFile(path, 1<<20, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "line 97\nline 98\nline 99", tail)
+	assert.True(t, truncated)
+
+	// A byte cap drops the line it cuts into
+	tail, truncated, err = tailFile(path, 12, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "line 99", tail)
+	assert.True(t, truncated)
+}
+
+func TestDiagnose_CollectorDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewCollectorManager(&config.Config{ConfigDir: dir})
+	startFake(t, manager, fakeCollector(t, dir, false))
+
+	manager.mu.RLock()
+	logPath := manager.processes["exp-1-candidate"].logPath
+	manager.mu.RUnlock()
+	require.NoError(t, os.WriteFile(logPath, []byte("starting\nerror: exporter failed\n"), 0644))
+
+	ctx := context.Background()
+	collector := map[string]interface{}{"collector_id": "exp-1-candidate"}
+
+	result, err := manager.Diagnose(ctx, DiagnosticLogTail, map[string]interface{}{"collector_id": "exp-1-candidate", "lines": float64(1)})
+	require.NoError(t, err)
+	assert.Equal(t, "error: exporter failed", result["output"])
+	assert.Equal(t, true, result["truncated"])
+
+	result, err = manager.Diagnose(ctx, DiagnosticRenderedConfig, collector)
+	require.NoError(t, err)
+	assert.Equal(t, "receivers: {}\n", result["output"])
+
+	result, err = manager.Diagnose(ctx, DiagnosticValidateConfig, collector)
+	require.NoError(t, err)
+	assert.Equal(t, true, result["valid"])
+
+	result, err = manager.Diagnose(ctx, DiagnosticProcStats, collector)
+	require.NoError(t, err)
+	assert.Contains(t, result["files"].(map[string]string)["status"], "Pid:")
+
+	_, err = manager.Diagnose(ctx, DiagnosticLogTail, map[string]interface{}{"collector_id": "exp-9-candidate"})
+	assert.ErrorContains(t, err, "not found")
+	_, err = manager.Diagnose(ctx, DiagnosticLogTail, map[string]interface{}{})
+	assert.ErrorContains(t, err, "missing collector_id")
+}
+
+func TestDiagnose_HostDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewCollectorManager(&config.Config{ConfigDir: dir})
+	require.NoError(t, manager.cacheConfig(configHash("receivers: {}\n"), "receivers: {}\n"))
+
+	result, err := manager.Diagnose(context.Background(), DiagnosticDiskUsage, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result["config_dir_files"])
+	assert.Equal(t, int64(len("receivers: {}\n")), result["config_cache_bytes"])
+	assert.Contains(t, result, "inodes_free")
+
+	result, err = manager.Diagnose(context.Background(), DiagnosticListeningPorts, nil)
+	require.NoError(t, err)
+	assert.Contains(t, result, "ports")
+
+	_, err = manager.Diagnose(context.Background(), "shell", map[string]interface{}{"command": "rm -rf /"})
+	assert.ErrorContains(t, err, "unknown diagnostic")
+}
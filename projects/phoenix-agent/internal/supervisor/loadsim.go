@@ -15,8 +15,14 @@ type LoadSimManager struct {
 	activeJob   *exec.Cmd
 	activeJobMu sync.Mutex
 	cancelFunc  context.CancelFunc
+	// done is closed once the active job has exited and been cleaned up
+	done        chan struct{}
 	cleanupChan chan struct{}
 	cleanupWg   sync.WaitGroup
+	// cgroups confines jobs when set; cgroup is the group of the active
+	// job
+	cgroups *cgroups
+	cgroup  string
 }
 
 func NewLoadSimManager() *LoadSimManager {
@@ -25,11 +31,18 @@ func NewLoadSimManager() *LoadSimManager {
 	}
 }
 
-// Start starts a load simulation with the given profile
-func (m *LoadSimManager) Start(profile, durationStr string) error {
+// Start starts a load simulation with the given profile and returns once the
+// job is launched. The job runs for its duration, until Stop is called or
+// until ctx is cancelled, whichever comes first. The job is held to limits
+// where cgroups are available.
+func (m *LoadSimManager) Start(ctx context.Context, profile, durationStr string, limits ResourceLimits) error {
 	m.activeJobMu.Lock()
 	defer m.activeJobMu.Unlock()
 
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+
 	if m.activeJob != nil {
 		return fmt.Errorf("load simulation already running")
 	}
@@ -49,7 +62,7 @@ func (m *LoadSimManager) Start(profile, durationStr string) error {
 		return fmt.Errorf("unknown profile: %s", profile)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	jobCtx, cancel := context.WithTimeout(ctx, duration)
 	m.cancelFunc = cancel
 
 	// Set environment variables for the script
@@ -57,19 +70,32 @@ func (m *LoadSimManager) Start(profile, durationStr string) error {
 	env = append(env, fmt.Sprintf("LOAD_PROFILE=%s", profile))
 	env = append(env, fmt.Sprintf("LOAD_DURATION=%s", durationStr))
 
-	m.activeJob = exec.CommandContext(ctx, "bash", "-c", script)
+	m.activeJob = exec.CommandContext(jobCtx, "bash", "-c", script)
 	m.activeJob.Env = env
 
 	// Start the job
 	if err := m.activeJob.Start(); err != nil {
+		cancel()
 		m.activeJob = nil
 		m.cancelFunc = nil
 		return fmt.Errorf("failed to start load simulation: %w", err)
 	}
+	m.done = make(chan struct{})
+
+	m.cgroup = ""
+	if m.cgroups.enabled() {
+		path, err := m.cgroups.place("loadsim", m.activeJob.Process.Pid, limits)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to place load simulation in its cgroup, running it without resource limits")
+		}
+		m.cgroup = path
+	} else if !limits.IsZero() {
+		log.Warn().Msg("cgroups are unavailable, running load simulation without its resource limits")
+	}
 
 	// Monitor job in background
 	m.cleanupWg.Add(1)
-	go m.monitorJob(profile, duration)
+	go m.monitorJob(jobCtx, m.activeJob, m.done, profile, duration, m.cgroup)
 
 	// Log profile information
 	var profileInfo ProfileInfo
@@ -93,55 +119,55 @@ func (m *LoadSimManager) Start(profile, durationStr string) error {
 	return nil
 }
 
-// Stop stops the current load simulation
+// Stop stops the current load simulation and waits until it has exited
 func (m *LoadSimManager) Stop() error {
 	m.activeJobMu.Lock()
-	defer m.activeJobMu.Unlock()
+	job, done := m.activeJob, m.done
+	m.activeJobMu.Unlock()
 
-	if m.activeJob == nil {
+	if job == nil {
 		return nil // Nothing to stop
 	}
 
 	pid := 0
-	if m.activeJob.Process != nil {
-		pid = m.activeJob.Process.Pid
-	}
+	if job.Process != nil {
+		pid = job.Process.Pid
 
-	// Cancel context to stop the job
-	if m.cancelFunc != nil {
-		m.cancelFunc()
+		// Send SIGTERM first for graceful shutdown
+		job.Process.Signal(os.Interrupt)
 	}
 
-	// Send SIGTERM first for graceful shutdown
-	if m.activeJob.Process != nil {
-		m.activeJob.Process.Signal(os.Interrupt)
-
-		// Give it 2 seconds to terminate gracefully
-		done := make(chan struct{})
-		go func() {
-			m.activeJob.Wait()
-			close(done)
-		}()
-
-		select {
-		case <-done:
-			// Process terminated gracefully
-		case <-time.After(2 * time.Second):
-			// Force kill if not terminated
-			m.activeJob.Process.Kill()
+	// Give it 2 seconds to terminate gracefully; cancelling the job's
+	// context kills it
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		m.activeJobMu.Lock()
+		if m.activeJob == job && m.cancelFunc != nil {
+			m.cancelFunc()
 		}
+		m.activeJobMu.Unlock()
+		<-done
 	}
 
-	// Wait for monitor goroutine to finish
-	m.cleanupWg.Wait()
-
-	m.activeJob = nil
-	m.cancelFunc = nil
-
 	log.Info().Int("pid", pid).Msg("Stopped load simulation")
 	return nil
 }
 
+// Done returns a channel that is closed once the active load simulation has
+// exited. It is already closed when no simulation is running.
+func (m *LoadSimManager) Done() <-chan struct{} {
+	m.activeJobMu.Lock()
+	defer m.activeJobMu.Unlock()
+
+	if m.activeJob == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return m.done
+}
+
 // GetMetrics returns metrics about the load simulation
 func (m *LoadSimManager) GetMetrics() map[string]interface{} {
 	m.activeJobMu.Lock()
@@ -158,10 +184,16 @@ func (m *LoadSimManager) GetMetrics() map[string]interface{} {
 		pid = m.activeJob.Process.Pid
 	}
 
-	return map[string]interface{}{
+	metrics := map[string]interface{}{
 		"load_sim_active": true,
 		"pid":             pid,
 	}
+	if m.cgroup != "" {
+		for name, value := range cgroupStats(m.cgroup) {
+			metrics[name] = value
+		}
+	}
+	return metrics
 }
 
 // Shutdown gracefully shuts down the load simulation manager
@@ -193,28 +225,45 @@ func (m *LoadSimManager) Shutdown(ctx context.Context) error {
 	}
 }
 
-func (m *LoadSimManager) monitorJob(profile string, duration time.Duration) {
+// monitorJob waits for job to exit, killing it once jobCtx is done, then
+// cleans up after it and closes done
+func (m *LoadSimManager) monitorJob(jobCtx context.Context, job *exec.Cmd, done chan struct{}, profile string, duration time.Duration, cgroup string) {
 	defer m.cleanupWg.Done()
+	defer close(done)
 
 	// Create a timer for maximum duration
 	timer := time.NewTimer(duration + 30*time.Second) // Extra 30s buffer
 	defer timer.Stop()
 
 	pid := 0
-	if m.activeJob.Process != nil {
-		pid = m.activeJob.Process.Pid
+	if job.Process != nil {
+		pid = job.Process.Pid
 	}
 
 	// Monitor the job
 	jobDone := make(chan error, 1)
 	go func() {
-		jobDone <- m.activeJob.Wait()
+		jobDone <- job.Wait()
 	}()
 
 	var err error
 	select {
 	case err = <-jobDone:
 		// Job completed
+	case <-jobCtx.Done():
+		// Duration elapsed, stopped or cancelled by the task that started it
+		if cause := context.Cause(jobCtx); cause != context.DeadlineExceeded {
+			log.Info().
+				Str("profile", profile).
+				Int("pid", pid).
+				Str("reason", cause.Error()).
+				Msg("Load simulation cancelled, terminating it")
+		}
+
+		if job.Process != nil {
+			job.Process.Kill()
+		}
+		err = <-jobDone
 	case <-timer.C:
 		// Timeout - force kill
 		log.Warn().
@@ -223,16 +272,20 @@ func (m *LoadSimManager) monitorJob(profile string, duration time.Duration) {
 			Dur("duration", duration).
 			Msg("Load simulation exceeded maximum duration, forcing termination")
 
-		if m.activeJob.Process != nil {
-			m.activeJob.Process.Kill()
+		if job.Process != nil {
+			job.Process.Kill()
 		}
 		err = <-jobDone
 	}
 
 	// Cleanup any child processes
 	m.cleanupChildProcesses(pid)
+	m.cgroups.remove(cgroup)
 
 	m.activeJobMu.Lock()
+	if m.cancelFunc != nil {
+		m.cancelFunc()
+	}
 	m.activeJob = nil
 	m.cancelFunc = nil
 	m.activeJobMu.Unlock()
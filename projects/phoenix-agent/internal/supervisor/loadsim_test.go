@@ -2,9 +2,12 @@ package supervisor
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -14,7 +17,7 @@ func TestLoadSimManager_Lifecycle(t *testing.T) {
 
 	t.Run("StartAndStop", func(t *testing.T) {
 		// Start a load simulation
-		err := manager.Start("steady", "5s")
+		err := manager.Start(context.Background(), "steady", "5s", ResourceLimits{})
 		require.NoError(t, err)
 
 		// Check metrics
@@ -36,11 +39,11 @@ func TestLoadSimManager_Lifecycle(t *testing.T) {
 
 	t.Run("CannotStartMultiple", func(t *testing.T) {
 		// Start first simulation
-		err := manager.Start("steady", "5s")
+		err := manager.Start(context.Background(), "steady", "5s", ResourceLimits{})
 		require.NoError(t, err)
 
 		// Try to start another
-		err = manager.Start("spike", "5s")
+		err = manager.Start(context.Background(), "spike", "5s", ResourceLimits{})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "already running")
 
@@ -55,20 +58,20 @@ func TestLoadSimManager_Lifecycle(t *testing.T) {
 	})
 
 	t.Run("InvalidProfile", func(t *testing.T) {
-		err := manager.Start("invalid-profile", "5s")
+		err := manager.Start(context.Background(), "invalid-profile", "5s", ResourceLimits{})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "unknown profile")
 	})
 
 	t.Run("InvalidDuration", func(t *testing.T) {
-		err := manager.Start("steady", "invalid")
+		err := manager.Start(context.Background(), "steady", "invalid", ResourceLimits{})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid duration")
 	})
 
 	t.Run("TimeoutHandling", func(t *testing.T) {
 		// Start with very short duration
-		err := manager.Start("steady", "1s")
+		err := manager.Start(context.Background(), "steady", "1s", ResourceLimits{})
 		require.NoError(t, err)
 
 		// Wait for it to complete
@@ -81,7 +84,7 @@ func TestLoadSimManager_Lifecycle(t *testing.T) {
 
 	t.Run("GracefulShutdown", func(t *testing.T) {
 		// Start a simulation
-		err := manager.Start("steady", "10s")
+		err := manager.Start(context.Background(), "steady", "10s", ResourceLimits{})
 		require.NoError(t, err)
 
 		// Shutdown with context
@@ -97,6 +100,66 @@ func TestLoadSimManager_Lifecycle(t *testing.T) {
 	})
 }
 
+func TestLoadSimManager_CancelStopsRunningJob(t *testing.T) {
+	manager := NewLoadSimManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := manager.Start(ctx, "steady", "30s", ResourceLimits{})
+	require.NoError(t, err)
+	assert.True(t, manager.GetMetrics()["load_sim_active"].(bool))
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-manager.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("load simulation still running after its context was cancelled")
+	}
+	assert.False(t, manager.GetMetrics()["load_sim_active"].(bool))
+
+	// The manager accepts a new simulation once the cancelled one is gone
+	err = manager.Start(context.Background(), "steady", "1s", ResourceLimits{})
+	require.NoError(t, err)
+	require.NoError(t, manager.Stop())
+}
+
+func TestSupervisor_LoadSimStartTaskRunsUntilCancelled(t *testing.T) {
+	s := NewSupervisor(&config.Config{ConfigDir: t.TempDir()})
+	task := &poller.Task{
+		ID:     "load-1",
+		Type:   "loadsim",
+		Action: "start",
+		Config: map[string]interface{}{"profile": "steady", "duration": "30s"},
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := s.ExecuteTask(ctx, task)
+		errs <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		active, _ := s.loadSimManager.GetMetrics()["load_sim_active"].(bool)
+		return active
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel(fmt.Errorf("%w: experiment stopped", ErrTaskCancelled))
+
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, ErrTaskCancelled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("load simulation task still running after it was cancelled")
+	}
+	assert.False(t, s.loadSimManager.GetMetrics()["load_sim_active"].(bool))
+}
+
 func TestLoadSimManager_ProfileScripts(t *testing.T) {
 	manager := NewLoadSimManager()
 
@@ -129,7 +192,7 @@ func TestLoadSimManager_ConcurrentAccess(t *testing.T) {
 	manager := NewLoadSimManager()
 
 	// Start a simulation
-	err := manager.Start("steady", "5s")
+	err := manager.Start(context.Background(), "steady", "5s", ResourceLimits{})
 	require.NoError(t, err)
 
 	// Concurrent access to GetMetrics
@@ -161,7 +224,7 @@ func TestLoadSimManager_ProcessCleanup(t *testing.T) {
 	manager := NewLoadSimManager()
 
 	// Start a simulation that creates child processes
-	err := manager.Start("normal", "3s")
+	err := manager.Start(context.Background(), "normal", "3s", ResourceLimits{})
 	require.NoError(t, err)
 
 	// Get the PID
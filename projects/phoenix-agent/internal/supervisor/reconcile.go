@@ -0,0 +1,240 @@
+package supervisor
+
+import (
+	"context"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
+	"github.com/rs/zerolog/log"
+)
+
+// Kinds of drift between the desired and actual state of the host
+const (
+	DriftMissing        = "missing"
+	DriftUnexpected     = "unexpected"
+	DriftConfigChanged  = "config_changed"
+	DriftNotRunning     = "not_running"
+	DriftLoadSimMissing = "load_simulation_missing"
+	DriftLoadSimExtra   = "load_simulation_unexpected"
+)
+
+// What reconciliation did about a drift
+const (
+	ActionStarted   = "started"
+	ActionStopped   = "stopped"
+	ActionRestarted = "restarted"
+	ActionNone      = "none"
+)
+
+// Reconcile converges the host on its desired state and reports how it
+// differed. Missing collectors are started with the task the desired state
+// names, collectors with another config hash are restarted and collectors
+// it does not list are stopped. Collectors that crashed or exited are
+// reported but left to their restart policy. Anything started after since,
+// when the desired state was requested, is left alone: the desired state
+// may not know about it yet. Each collector is reconciled under the lock its
+// tasks share, so a fix never interleaves with a task for the same collector.
+func (s *Supervisor) Reconcile(ctx context.Context, desired *poller.DesiredState, since time.Time) *poller.DriftReport {
+	report := &poller.DriftReport{
+		Revision:  desired.Revision,
+		CheckedAt: time.Now(),
+		Drift:     []poller.StateDrift{},
+	}
+
+	wanted := make(map[string]bool, len(desired.Collectors))
+
+	for _, collector := range desired.Collectors {
+		wanted[collector.ID] = true
+
+		if drift := s.reconcileCollector(ctx, collector, since); drift != nil {
+			report.Drift = append(report.Drift, *drift)
+		}
+	}
+
+	for id, current := range s.collectorManager.Snapshot() {
+		if wanted[id] {
+			continue
+		}
+
+		if drift := s.reconcileUnexpected(ctx, current, since); drift != nil {
+			report.Drift = append(report.Drift, *drift)
+		}
+	}
+
+	if drift := s.reconcileLoadSim(ctx, desired.LoadSimulation, since); drift != nil {
+		report.Drift = append(report.Drift, *drift)
+	}
+
+	for _, drift := range report.Drift {
+		log.Warn().
+			Str("kind", drift.Kind).
+			Str("collector_id", drift.CollectorID).
+			Str("action", drift.Action).
+			Str("error", drift.Error).
+			Msg("Host drifted from desired state")
+	}
+
+	report.InSync = len(report.Drift) == 0
+	return report
+}
+
+// reconcileCollector starts a desired collector that is missing and restarts
+// one whose config changed
+func (s *Supervisor) reconcileCollector(ctx context.Context, collector poller.DesiredCollector, since time.Time) *poller.StateDrift {
+	task := desiredTask(collector)
+	unlock, err := s.lock(ctx, task)
+	if err != nil {
+		return nil
+	}
+	defer unlock()
+
+	drift := &poller.StateDrift{
+		CollectorID:  collector.ID,
+		ExperimentID: collector.ExperimentID,
+		DeploymentID: collector.DeploymentID,
+	}
+
+	current, exists := s.collectorManager.Snapshot()[collector.ID]
+	switch {
+	case !exists:
+		drift.Kind = DriftMissing
+		drift.Action = ActionStarted
+		if _, err := s.executeTask(ctx, task); err != nil {
+			drift.Error = err.Error()
+		}
+
+	case current.State == CollectorExited || current.State == CollectorCrashLoop:
+		drift.Kind = DriftNotRunning
+		drift.Action = ActionNone
+
+	case current.DesiredHash != collector.ConfigHash && current.StartedAt.Before(since):
+		drift.Kind = DriftConfigChanged
+		drift.Action = ActionRestarted
+		if err := s.collectorManager.Stop(collector.ID); err != nil {
+			drift.Error = err.Error()
+		} else if _, err := s.executeTask(ctx, task); err != nil {
+			drift.Error = err.Error()
+		}
+
+	default:
+		return nil
+	}
+
+	return drift
+}
+
+// reconcileUnexpected stops a collector the desired state does not list
+func (s *Supervisor) reconcileUnexpected(ctx context.Context, collector CollectorSnapshot, since time.Time) *poller.StateDrift {
+	unlock, err := s.lockCollector(ctx, collector)
+	if err != nil {
+		return nil
+	}
+	defer unlock()
+
+	// A task may have stopped it, or replaced it, while we waited
+	id := collector.ID
+	current, exists := s.collectorManager.Snapshot()[id]
+	if !exists || !current.StartedAt.Before(since) {
+		return nil
+	}
+
+	drift := &poller.StateDrift{
+		Kind:        DriftUnexpected,
+		CollectorID: id,
+		Action:      ActionStopped,
+	}
+	if err := s.collectorManager.Stop(id); err != nil {
+		drift.Error = err.Error()
+	}
+	return drift
+}
+
+// lockCollector waits until no task for the collector is running and keeps
+// tasks for it from starting until unlock is called. A deployment's
+// collector is managed by deployment tasks, so it takes their lock.
+func (s *Supervisor) lockCollector(ctx context.Context, collector CollectorSnapshot) (unlock func(), err error) {
+	if collector.DeploymentID != "" {
+		return s.lock(ctx, &poller.Task{Type: "deployment", Config: map[string]interface{}{"deployment_id": collector.DeploymentID}})
+	}
+	return s.lock(ctx, &poller.Task{Type: "collector", Config: map[string]interface{}{"id": collector.ID}})
+}
+
+// lock takes the lock that tasks like task share from the task locker, if
+// there is one
+func (s *Supervisor) lock(ctx context.Context, task *poller.Task) (unlock func(), err error) {
+	s.mu.RLock()
+	locker := s.locker
+	s.mu.RUnlock()
+
+	if locker == nil {
+		return func() {}, nil
+	}
+	return locker.Lock(ctx, task)
+}
+
+// desiredTask is the task that starts a desired collector. Reconciling the
+// collector holds the lock this task shares with the collector's other
+// tasks.
+func desiredTask(collector poller.DesiredCollector) *poller.Task {
+	return &poller.Task{
+		ID:           "desired-" + collector.ID,
+		ExperimentID: collector.ExperimentID,
+		Type:         collector.TaskType,
+		Action:       collector.TaskAction,
+		Config:       collector.Config,
+	}
+}
+
+// reconcileLoadSim starts the desired load simulation unless it already ran
+// for its experiment, and stops one that is not desired
+func (s *Supervisor) reconcileLoadSim(ctx context.Context, desired *poller.DesiredLoadSimulation, since time.Time) *poller.StateDrift {
+	active, _ := s.loadSimManager.GetMetrics()["load_sim_active"].(bool)
+
+	s.mu.RLock()
+	experiment, startedAt := s.loadSimExperiment, s.loadSimStartedAt
+	s.mu.RUnlock()
+
+	switch {
+	case desired != nil && !active && experiment != desired.ExperimentID:
+		drift := &poller.StateDrift{
+			Kind:         DriftLoadSimMissing,
+			ExperimentID: desired.ExperimentID,
+			Action:       ActionStarted,
+		}
+		task := &poller.Task{
+			ID:           "desired-loadsim-" + desired.ExperimentID,
+			ExperimentID: desired.ExperimentID,
+			Type:         "loadsim",
+			Action:       "start",
+			Config: map[string]interface{}{
+				"profile":  desired.Profile,
+				"duration": desired.Duration,
+			},
+		}
+		unlock, err := s.lock(ctx, task)
+		if err != nil {
+			return nil
+		}
+		defer unlock()
+
+		// Started without waiting for it to finish; it runs until its
+		// duration is up or ctx is done
+		if _, err := s.startLoadSim(ctx, task); err != nil {
+			drift.Error = err.Error()
+		}
+		return drift
+
+	case desired == nil && active && startedAt.Before(since):
+		drift := &poller.StateDrift{
+			Kind:         DriftLoadSimExtra,
+			ExperimentID: experiment,
+			Action:       ActionStopped,
+		}
+		if err := s.loadSimManager.Stop(); err != nil {
+			drift.Error = err.Error()
+		}
+		return drift
+	}
+
+	return nil
+}
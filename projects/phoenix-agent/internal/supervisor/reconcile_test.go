@@ -0,0 +1,198 @@
+package supervisor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupervisor_Reconcile(t *testing.T) {
+	s := NewSupervisor(&config.Config{ConfigDir: t.TempDir()})
+	started := time.Now().Add(-time.Hour)
+
+	// Collectors left without a process, so no collector binary is needed
+	for id, process := range map[string]*Process{
+		"exp-1-baseline": {State: CollectorCrashLoop, options: CollectorOptions{DesiredHash: "a"}},
+		"exp-1-topk":     {State: CollectorBackoff, options: CollectorOptions{DesiredHash: "old"}},
+		"exp-0-baseline": {State: CollectorExited},
+	} {
+		process.ID = id
+		process.StartedAt = started
+		s.collectorManager.processes[id] = process
+	}
+
+	desired := &poller.DesiredState{
+		Revision: "r1",
+		Collectors: []poller.DesiredCollector{
+			{ID: "exp-1-baseline", ExperimentID: "exp-1", ConfigHash: "a", TaskType: "collector", TaskAction: "start"},
+			{ID: "exp-1-topk", ExperimentID: "exp-1", ConfigHash: "new", TaskType: "collector", TaskAction: "start",
+				Config: map[string]interface{}{"id": "exp-1-topk", "variant": "topk"}},
+			{ID: "exp-1-extra", ExperimentID: "exp-1", ConfigHash: "b", TaskType: "collector", TaskAction: "start",
+				Config: map[string]interface{}{"id": "exp-1-extra", "variant": "extra"}},
+		},
+	}
+
+	report := s.Reconcile(context.Background(), desired, time.Now())
+	assert.False(t, report.InSync)
+	assert.Equal(t, "r1", report.Revision)
+
+	drift := map[string]poller.StateDrift{}
+	for _, d := range report.Drift {
+		drift[d.CollectorID] = d
+	}
+	require.Len(t, drift, 4)
+
+	assert.Equal(t, DriftNotRunning, drift["exp-1-baseline"].Kind)
+	assert.Equal(t, ActionNone, drift["exp-1-baseline"].Action)
+
+	assert.Equal(t, DriftConfigChanged, drift["exp-1-topk"].Kind)
+	assert.Equal(t, ActionRestarted, drift["exp-1-topk"].Action)
+	// The task config lacks a configUrl, so the restart fails
+	assert.Contains(t, drift["exp-1-topk"].Error, "configUrl")
+
+	assert.Equal(t, DriftMissing, drift["exp-1-extra"].Kind)
+	assert.Equal(t, ActionStarted, drift["exp-1-extra"].Action)
+
+	assert.Equal(t, DriftUnexpected, drift["exp-0-baseline"].Kind)
+	assert.Equal(t, ActionStopped, drift["exp-0-baseline"].Action)
+	assert.Empty(t, drift["exp-0-baseline"].Error)
+	assert.NotContains(t, s.collectorManager.Snapshot(), "exp-0-baseline")
+}
+
+func TestSupervisor_ReconcileLeavesNewCollectors(t *testing.T) {
+	s := NewSupervisor(&config.Config{ConfigDir: t.TempDir()})
+	since := time.Now()
+
+	// Started by a task after the desired state was requested
+	s.collectorManager.processes["exp-2-baseline"] = &Process{
+		ID:        "exp-2-baseline",
+		State:     CollectorExited,
+		StartedAt: since.Add(time.Second),
+	}
+
+	report := s.Reconcile(context.Background(), &poller.DesiredState{Revision: "r2"}, since)
+	assert.True(t, report.InSync)
+	assert.Empty(t, report.Drift)
+}
+
+// fakeLocker records the keys locks were taken for and whether each was
+// released. Keys are built like the worker pool's task keys, and a key in
+// held waits until its channel is closed, like a task running in the pool.
+type fakeLocker struct {
+	mu       sync.Mutex
+	locked   []string
+	released int
+	held     map[string]chan struct{}
+}
+
+func (l *fakeLocker) Lock(ctx context.Context, task *poller.Task) (func(), error) {
+	id, _ := task.Config["id"].(string)
+	if task.Type == "deployment" {
+		id, _ = task.Config["deployment_id"].(string)
+	}
+	key := task.Type + "/" + id
+
+	l.mu.Lock()
+	l.locked = append(l.locked, key)
+	running := l.held[key]
+	l.mu.Unlock()
+
+	if running != nil {
+		select {
+		case <-running:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() {
+		l.mu.Lock()
+		l.released++
+		l.mu.Unlock()
+	}, nil
+}
+
+func TestSupervisor_ReconcileLocksCollectors(t *testing.T) {
+	s := NewSupervisor(&config.Config{ConfigDir: t.TempDir()})
+	locker := &fakeLocker{}
+	s.SetTaskLocker(locker)
+
+	s.collectorManager.processes["exp-0-baseline"] = &Process{
+		ID:        "exp-0-baseline",
+		State:     CollectorExited,
+		StartedAt: time.Now().Add(-time.Hour),
+	}
+
+	desired := &poller.DesiredState{
+		Revision: "r3",
+		Collectors: []poller.DesiredCollector{
+			{ID: "exp-1-baseline", ExperimentID: "exp-1", ConfigHash: "a", TaskType: "collector", TaskAction: "start",
+				Config: map[string]interface{}{"id": "exp-1-baseline"}},
+		},
+	}
+
+	s.Reconcile(context.Background(), desired, time.Now())
+	assert.Equal(t, []string{"collector/exp-1-baseline", "collector/exp-0-baseline"}, locker.locked)
+	assert.Equal(t, 2, locker.released)
+}
+
+func TestSupervisor_ReconcileWaitsForDeploymentTasks(t *testing.T) {
+	s := NewSupervisor(&config.Config{ConfigDir: t.TempDir(), HostID: "host-a"})
+	running := make(chan struct{})
+	locker := &fakeLocker{held: map[string]chan struct{}{"deployment/dep-1": running}}
+	s.SetTaskLocker(locker)
+
+	// A deployment's collector the desired state no longer lists
+	s.collectorManager.processes["dep-dep-0-host-a"] = &Process{
+		ID:        "dep-dep-0-host-a",
+		State:     CollectorExited,
+		StartedAt: time.Now().Add(-time.Hour),
+		options:   CollectorOptions{DeploymentID: "dep-0"},
+	}
+
+	desired := &poller.DesiredState{
+		Revision: "r4",
+		Collectors: []poller.DesiredCollector{
+			{ID: "dep-dep-1-host-a", DeploymentID: "dep-1", ConfigHash: "a", TaskType: "deployment", TaskAction: "deploy",
+				Config: map[string]interface{}{"deployment_id": "dep-1"}},
+		},
+	}
+
+	done := make(chan *poller.DriftReport, 1)
+	go func() {
+		done <- s.Reconcile(context.Background(), desired, time.Now())
+	}()
+
+	// A deployment task for dep-1 is running, so its collector waits
+	select {
+	case <-done:
+		t.Fatal("reconciled a deployment's collector while its deployment task was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(running)
+	var report *poller.DriftReport
+	select {
+	case report = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reconcile did not finish after the deployment task did")
+	}
+
+	locker.mu.Lock()
+	assert.ElementsMatch(t, []string{"deployment/dep-1", "deployment/dep-0"}, locker.locked)
+	assert.Equal(t, 2, locker.released)
+	locker.mu.Unlock()
+
+	drift := map[string]poller.StateDrift{}
+	for _, d := range report.Drift {
+		drift[d.CollectorID] = d
+	}
+	assert.Equal(t, DriftMissing, drift["dep-dep-1-host-a"].Kind)
+	assert.Equal(t, DriftUnexpected, drift["dep-dep-0-host-a"].Kind)
+}
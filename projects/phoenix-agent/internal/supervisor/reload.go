@@ -0,0 +1,586 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// How Update gave a collector its new config
+const (
+	// UpdateReload collectors reloaded the config in place on SIGHUP
+	UpdateReload = "reload"
+	// UpdateBlueGreen collectors were replaced by a collector started on
+	// the alternate ports, and stopped once it was healthy
+	UpdateBlueGreen = "blue_green"
+	// UpdateRestart collectors were not running and were started again
+	UpdateRestart = "restart"
+)
+
+const (
+	// slotPortOffset is added to the listen ports of a collector running
+	// in the alternate slot, so it can run next to the one it replaces
+	slotPortOffset = 1000
+
+	validateTimeout    = 30 * time.Second
+	greenHealthTimeout = 30 * time.Second
+	healthPollInterval = 500 * time.Millisecond
+
+	// validateOutputLimit bounds the validation output kept in errors
+	validateOutputLimit = 1024
+)
+
+var (
+	// reloadSettle is how long a collector must keep running after SIGHUP
+	// for its reload to count as done
+	reloadSettle = 3 * time.Second
+	// greenSettle is how long a replacement without a health_check
+	// extension must keep running to count as healthy
+	greenSettle = 5 * time.Second
+)
+
+// listenAddress matches host:port addresses, with the host optional
+var listenAddress = regexp.MustCompile(`^(\[[^\]]*\]|[^:/\s]*):(\d+)$`)
+
+// wildcardHosts are the hosts of addresses that listen on all interfaces
+var wildcardHosts = map[string]bool{
+	"":        true,
+	"0.0.0.0": true,
+	"[::]":    true,
+}
+
+// Update gives a collector a new config without a gap in what it collects
+// and returns the strategy used. The config is validated before anything is
+// changed. A collector whose command line stays the same reloads it in
+// place; otherwise, or if the reload fails, a replacement is started on the
+// alternate ports and the old collector is stopped once it is healthy. A
+// collector that is not running is started again.
+func (m *CollectorManager) Update(ctx context.Context, id, variant, configURL string, vars map[string]string, opts CollectorOptions) (string, error) {
+	spec, err := m.prepare(ctx, id, variant, configURL, vars, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return m.update(ctx, id, variant, spec, opts)
+}
+
+func (m *CollectorManager) update(ctx context.Context, id, variant string, spec *collectorSpec, opts CollectorOptions) (string, error) {
+	m.mu.Lock()
+	process, exists := m.processes[id]
+	if exists && process.updating {
+		m.mu.Unlock()
+		return "", fmt.Errorf("collector %s is already being updated", id)
+	}
+
+	if !exists || process.State != CollectorRunning {
+		// With nothing running there is no data flow to keep
+		if exists {
+			process.stopping = true
+			if process.restartTimer != nil {
+				process.restartTimer.Stop()
+			}
+			delete(m.processes, id)
+			delete(m.ports, process.telemetryPort)
+			m.cgroups.remove(process.cgroup)
+		}
+		err := m.startSpec(ctx, id, variant, spec, opts)
+		m.mu.Unlock()
+		if err != nil {
+			return "", err
+		}
+		return UpdateRestart, nil
+	}
+
+	process.updating = true
+	reloadable := process.binary == spec.binary &&
+		slices.Equal(process.args, spec.command(process.configFile, process.telemetryPort)) &&
+		slices.Equal(process.env, spec.env)
+	slot := process.slot
+	path := process.configFile
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		process.updating = false
+		m.mu.Unlock()
+	}()
+
+	// The config the collector runs, for putting back if the update fails
+	var previous []byte
+	if reloadable {
+		config, err := slotConfig(spec.config, slot)
+		if err != nil {
+			return "", err
+		}
+		if previous, err = os.ReadFile(path); err != nil {
+			return "", fmt.Errorf("failed to read current config: %w", err)
+		}
+		if err := m.stage(ctx, spec, config, path); err != nil {
+			return "", err
+		}
+
+		err = m.reload(ctx, process, variant, config, opts)
+		if err == nil {
+			return UpdateReload, nil
+		}
+		log.Warn().Err(err).Str("id", id).Msg("Collector config reload failed, falling back to a blue/green update")
+	}
+
+	if err := m.blueGreen(ctx, process, variant, spec, opts); err != nil {
+		if previous != nil {
+			os.WriteFile(path, previous, 0644)
+		}
+
+		// A collector that died reloading is left to its restart policy
+		m.mu.Lock()
+		if process.State == CollectorExited && m.processes[id] == process {
+			m.handleExit(process, process.LastExitCode)
+		}
+		m.mu.Unlock()
+		return "", err
+	}
+
+	return UpdateBlueGreen, nil
+}
+
+// reload signals a collector to reload its config, which is already in
+// place. It counts as done if the collector is still running reloadSettle
+// later.
+func (m *CollectorManager) reload(ctx context.Context, process *Process, variant, config string, opts CollectorOptions) error {
+	m.mu.Lock()
+	if m.processes[process.ID] != process || process.State != CollectorRunning {
+		m.mu.Unlock()
+		return fmt.Errorf("collector is no longer running")
+	}
+	process.reloading = true
+	if err := process.signal(syscall.SIGHUP); err != nil {
+		process.reloading = false
+		m.mu.Unlock()
+		return fmt.Errorf("failed to signal collector: %w", err)
+	}
+	exited := process.exited
+	m.mu.Unlock()
+
+	select {
+	case <-exited:
+	case <-time.After(reloadSettle):
+	case <-ctx.Done():
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case <-exited:
+		// The exit is recorded by reloadExited, which clears reloading
+		return fmt.Errorf("collector exited on SIGHUP")
+	default:
+	}
+	process.reloading = false
+
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+
+	process.Variant = variant
+	process.ConfigHash = configHash(config)
+	process.options = opts
+	if process.cgroup != "" {
+		if err := setLimits(process.cgroup, opts.Limits); err != nil {
+			log.Warn().Err(err).Str("id", process.ID).Msg("Failed to update collector resource limits")
+		}
+	}
+	m.saveState()
+
+	log.Info().
+		Str("id", process.ID).
+		Int("pid", process.Pid).
+		Msg("Reloaded OTel collector config")
+
+	return nil
+}
+
+// reloadExited records a collector that exited while reloading its config.
+// Update replaces it; once the update is over the exit is handled like any
+// other. m.mu must be held.
+func (m *CollectorManager) reloadExited(process *Process, exitCode int) {
+	process.reloading = false
+	if !process.updating {
+		m.handleExit(process, exitCode)
+		return
+	}
+
+	now := time.Now()
+	process.State = CollectorExited
+	process.LastExitCode = exitCode
+	process.LastExitAt = &now
+	process.LastLogTail = logTail(process.logPath)
+
+	log.Warn().
+		Str("id", process.ID).
+		Int("exit_code", exitCode).
+		Msg("Collector exited while reloading its config")
+}
+
+// blueGreen starts a collector with the new config in the other slot and,
+// once it is healthy, stops the collector it replaces
+func (m *CollectorManager) blueGreen(ctx context.Context, blue *Process, variant string, spec *collectorSpec, opts CollectorOptions) error {
+	m.mu.RLock()
+	slot := 1 - blue.slot
+	m.mu.RUnlock()
+
+	path := m.slotConfigPath(blue.ID, slot)
+	config, err := slotConfig(spec.config, slot)
+	if err != nil {
+		return err
+	}
+	if err := m.stage(ctx, spec, config, path); err != nil {
+		return err
+	}
+
+	// Until it is registered, an exit of the replacement is only logged
+	m.mu.Lock()
+	port := m.allocatePort()
+	green := &Process{
+		ID:         blue.ID,
+		Variant:    variant,
+		ConfigHash: configHash(config),
+		options:    opts,
+		binary:     spec.binary,
+		args:       spec.command(path, port),
+		env:        spec.env,
+		logPath:    blue.logPath,
+		configFile: path,
+		slot:       slot,
+
+		telemetryPort: port,
+	}
+	err = m.launch(green, false)
+	if err != nil {
+		delete(m.ports, port)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	if err := waitHealthy(ctx, green, config); err != nil {
+		m.discard(green)
+		return fmt.Errorf("replacement collector did not become healthy: %w", err)
+	}
+
+	m.mu.Lock()
+	if m.processes[blue.ID] != blue {
+		m.mu.Unlock()
+		m.discard(green)
+		return fmt.Errorf("collector %s was stopped during the update", blue.ID)
+	}
+	blue.stopping = true
+	if blue.restartTimer != nil {
+		blue.restartTimer.Stop()
+	}
+	running := blue.State == CollectorRunning
+	m.processes[blue.ID] = green
+	delete(m.ports, blue.telemetryPort)
+	m.saveState()
+	m.mu.Unlock()
+
+	if running {
+		terminate(blue)
+	}
+	if blue.configFile != green.configFile {
+		os.Remove(blue.configFile)
+	}
+	if blue.cgroup != green.cgroup {
+		m.cgroups.remove(blue.cgroup)
+	}
+
+	log.Info().
+		Str("id", green.ID).
+		Int("old_pid", blue.Pid).
+		Int("pid", green.Pid).
+		Int("slot", slot).
+		Msg("Replaced OTel collector with blue/green update")
+
+	return nil
+}
+
+// discard stops a replacement collector that is not taking over, and
+// removes its config
+func (m *CollectorManager) discard(green *Process) {
+	green.stopping = true
+	terminate(green)
+	os.Remove(green.configFile)
+	m.cgroups.remove(green.cgroup)
+
+	m.mu.Lock()
+	delete(m.ports, green.telemetryPort)
+	m.mu.Unlock()
+}
+
+// terminate stops a collector process, killing it if it does not exit in
+// time
+func terminate(process *Process) {
+	if err := process.signal(os.Interrupt); err != nil {
+		process.signal(os.Kill)
+	}
+
+	select {
+	case <-process.exited:
+	case <-time.After(10 * time.Second):
+		log.Warn().Str("id", process.ID).Msg("Collector stop timeout, force killing")
+		process.signal(os.Kill)
+	}
+}
+
+// stage writes a config to path atomically, once the collector binary has
+// validated it
+func (m *CollectorManager) stage(ctx context.Context, spec *collectorSpec, config, path string) error {
+	staged := path + ".new"
+	if err := os.WriteFile(staged, []byte(config), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	if err := validateConfig(ctx, spec, staged); err != nil {
+		os.Remove(staged)
+		return err
+	}
+
+	if err := os.Rename(staged, path); err != nil {
+		os.Remove(staged)
+		return fmt.Errorf("failed to replace config: %w", err)
+	}
+	return nil
+}
+
+// validateConfig runs the validate command of the collector binary on a
+// config
+func validateConfig(ctx context.Context, spec *collectorSpec, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, spec.binary, "validate", "--config", path)
+	cmd.Env = append(os.Environ(), spec.env...)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(output) > validateOutputLimit {
+			output = output[len(output)-validateOutputLimit:]
+		}
+		return fmt.Errorf("config failed validation: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// waitHealthy waits for a collector's health_check extension to report it
+// healthy or, without one, for it to keep running for greenSettle
+func waitHealthy(ctx context.Context, process *Process, config string) error {
+	url := healthURL(config)
+	if url == "" {
+		select {
+		case <-process.exited:
+			return fmt.Errorf("collector exited")
+		case <-time.After(greenSettle):
+			return nil
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+
+	timeout := time.NewTimer(greenHealthTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	for {
+		select {
+		case <-process.exited:
+			return fmt.Errorf("collector exited")
+		case <-timeout.C:
+			return fmt.Errorf("no healthy response from %s within %s", url, greenHealthTimeout)
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-ticker.C:
+			resp, err := client.Get(url)
+			if err != nil {
+				continue
+			}
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+	}
+}
+
+// healthURL returns the URL of the enabled health_check extension of a
+// config, or "" if it has none
+func healthURL(config string) string {
+	var parsed struct {
+		Extensions map[string]struct {
+			Endpoint string `yaml:"endpoint"`
+			Path     string `yaml:"path"`
+		} `yaml:"extensions"`
+		Service struct {
+			Extensions []string `yaml:"extensions"`
+		} `yaml:"service"`
+	}
+	if err := yaml.Unmarshal([]byte(config), &parsed); err != nil {
+		return ""
+	}
+
+	for _, name := range parsed.Service.Extensions {
+		if name != "health_check" && !strings.HasPrefix(name, "health_check/") {
+			continue
+		}
+
+		extension := parsed.Extensions[name]
+		match := listenAddress.FindStringSubmatch(extension.Endpoint)
+		if match == nil {
+			return ""
+		}
+		host := match[1]
+		if wildcardHosts[host] {
+			host = "127.0.0.1"
+		}
+		path := extension.Path
+		if path == "" {
+			path = "/"
+		}
+		return fmt.Sprintf("http://%s:%s%s", host, match[2], path)
+	}
+	return ""
+}
+
+// slotConfigPath is where the config of a collector in slot is written
+func (m *CollectorManager) slotConfigPath(id string, slot int) string {
+	if slot == 0 {
+		return m.configPath(id)
+	}
+	return filepath.Join(m.config.ConfigDir, fmt.Sprintf("%s.alt.yaml", id))
+}
+
+// slotConfig returns a config with its listen ports moved for slot
+func slotConfig(config string, slot int) (string, error) {
+	return shiftPorts(config, slot*slotPortOffset)
+}
+
+// shiftPorts adds offset to the ports a collector config listens on.
+// Receivers and exporters also name the remote endpoints they connect to,
+// so only their addresses on all interfaces are moved.
+func shiftPorts(config string, offset int) (string, error) {
+	if offset == 0 {
+		return config, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(config), &doc); err != nil {
+		return "", fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return config, nil
+	}
+	root := doc.Content[0]
+
+	shiftSection(mappingValue(root, "receivers"), offset, true)
+	shiftSection(mappingValue(root, "exporters"), offset, true)
+	shiftSection(mappingValue(root, "extensions"), offset, false)
+	shiftSection(mappingValue(mappingValue(root, "service"), "telemetry"), offset, false)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return "", fmt.Errorf("failed to encode config: %w", err)
+	}
+	encoder.Close()
+	return buf.String(), nil
+}
+
+// shiftSection moves the endpoint and address ports in a config section.
+// Outside receivers and exporters separate port settings are moved too.
+func shiftSection(node *yaml.Node, offset int, wildcardOnly bool) {
+	if node == nil {
+		return
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Kind != yaml.ScalarNode {
+				shiftSection(value, offset, wildcardOnly)
+				continue
+			}
+
+			switch {
+			case key.Value == "endpoint" || key.Value == "address":
+				value.Value = shiftAddress(value.Value, offset, wildcardOnly)
+			case key.Value == "port" && !wildcardOnly:
+				if port, err := strconv.Atoi(value.Value); err == nil && port > 0 && port+offset <= 65535 {
+					value.Value = strconv.Itoa(port + offset)
+				}
+			}
+		}
+
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			shiftSection(item, offset, wildcardOnly)
+		}
+	}
+}
+
+// shiftAddress moves the port of a host:port address. Port 0, which picks a
+// free port, is left alone.
+func shiftAddress(address string, offset int, wildcardOnly bool) string {
+	match := listenAddress.FindStringSubmatch(address)
+	if match == nil {
+		return address
+	}
+
+	host := match[1]
+	if wildcardOnly && !wildcardHosts[host] {
+		return address
+	}
+
+	port, err := strconv.Atoi(match[2])
+	if err != nil || port == 0 || port+offset > 65535 {
+		return address
+	}
+	return fmt.Sprintf("%s:%d", host, port+offset)
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func configHash(config string) string {
+	hash := sha256.Sum256([]byte(config))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,170 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const reloadTestConfig = `receivers:
+  otlp:
+    protocols:
+      grpc:
+        endpoint: 0.0.0.0:4317
+  redis:
+    endpoint: localhost:6379
+exporters:
+  prometheus:
+    endpoint: "0.0.0.0:8889"
+  otlp:
+    endpoint: otelcol:4317
+extensions:
+  health_check:
+    endpoint: 127.0.0.1:13133
+service:
+  extensions: [health_check]
+  telemetry:
+    metrics:
+      address: 0.0.0.0:8888
+`
+
+// fakeCollector writes a collector stand-in that validates configs not
+// containing "invalid" and, if handleHUP is set, survives SIGHUP
+func fakeCollector(t *testing.T, dir string, handleHUP bool) string {
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = validate ]; then\n" +
+		"  grep -q invalid \"$3\" && exit 1\n" +
+		"  exit 0\n" +
+		"fi\n"
+	if handleHUP {
+		script += "trap 'echo reloaded' HUP\n"
+	}
+	script += "while :; do sleep 0.1; done\n"
+
+	path := filepath.Join(dir, "fake-otelcol")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func shortenSettles(t *testing.T) {
+	reload, green := reloadSettle, greenSettle
+	reloadSettle, greenSettle = 300*time.Millisecond, 300*time.Millisecond
+	t.Cleanup(func() { reloadSettle, greenSettle = reload, green })
+}
+
+func startFake(t *testing.T, manager *CollectorManager, binary string) *collectorSpec {
+	spec := &collectorSpec{config: "receivers: {}\n", binary: binary}
+	manager.mu.Lock()
+	err := manager.startSpec(context.Background(), "exp-1-candidate", "candidate", spec, CollectorOptions{})
+	manager.mu.Unlock()
+	require.NoError(t, err)
+	t.Cleanup(manager.StopAll)
+	return spec
+}
+
+func TestShiftPorts(t *testing.T) {
+	shifted, err := shiftPorts(reloadTestConfig, slotPortOffset)
+	require.NoError(t, err)
+
+	var parsed map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(shifted), &parsed))
+
+	value := func(path ...string) interface{} {
+		var node interface{} = parsed
+		for _, key := range path {
+			node = node.(map[string]interface{})[key]
+		}
+		return node
+	}
+
+	assert.Equal(t, "0.0.0.0:5317", value("receivers", "otlp", "protocols", "grpc", "endpoint"))
+	assert.Equal(t, "localhost:6379", value("receivers", "redis", "endpoint"), "remote endpoints stay")
+	assert.Equal(t, "0.0.0.0:9889", value("exporters", "prometheus", "endpoint"))
+	assert.Equal(t, "otelcol:4317", value("exporters", "otlp", "endpoint"))
+	assert.Equal(t, "127.0.0.1:14133", value("extensions", "health_check", "endpoint"))
+	assert.Equal(t, "0.0.0.0:9888", value("service", "telemetry", "metrics", "address"))
+
+	unchanged, err := shiftPorts(reloadTestConfig, 0)
+	require.NoError(t, err)
+	assert.Equal(t, reloadTestConfig, unchanged)
+}
+
+func TestHealthURL(t *testing.T) {
+	assert.Equal(t, "http://127.0.0.1:13133/", healthURL(reloadTestConfig))
+	assert.Equal(t, "", healthURL("extensions:\n  health_check: {}\nservice:\n  extensions: []\n"))
+	assert.Equal(t, "http://127.0.0.1:1234/health",
+		healthURL("extensions:\n  health_check/a:\n    endpoint: :1234\n    path: /health\nservice:\n  extensions: [health_check/a]\n"))
+}
+
+func TestCollectorManager_UpdateReloadsInPlace(t *testing.T) {
+	shortenSettles(t)
+	dir := t.TempDir()
+	manager := NewCollectorManager(&config.Config{ConfigDir: dir})
+	spec := startFake(t, manager, fakeCollector(t, dir, true))
+	pid := manager.GetProcessInfo("exp-1-candidate")["pid"]
+
+	updated := *spec
+	updated.config = "receivers: {otlp: {}}\n"
+	strategy, err := manager.update(context.Background(), "exp-1-candidate", "candidate", &updated, CollectorOptions{DesiredHash: "v2"})
+	require.NoError(t, err)
+
+	assert.Equal(t, UpdateReload, strategy)
+	assert.Equal(t, pid, manager.GetProcessInfo("exp-1-candidate")["pid"])
+	assert.Equal(t, "v2", manager.Snapshot()["exp-1-candidate"].DesiredHash)
+
+	content, err := os.ReadFile(filepath.Join(dir, "exp-1-candidate.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, updated.config, string(content))
+}
+
+func TestCollectorManager_UpdateFallsBackToBlueGreen(t *testing.T) {
+	shortenSettles(t)
+	dir := t.TempDir()
+	manager := NewCollectorManager(&config.Config{ConfigDir: dir})
+	spec := startFake(t, manager, fakeCollector(t, dir, false))
+	pid := manager.GetProcessInfo("exp-1-candidate")["pid"]
+
+	updated := *spec
+	updated.config = "receivers:\n  otlp:\n    endpoint: 0.0.0.0:4317\n"
+	strategy, err := manager.update(context.Background(), "exp-1-candidate", "candidate", &updated, CollectorOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, UpdateBlueGreen, strategy)
+	info := manager.GetProcessInfo("exp-1-candidate")
+	assert.NotEqual(t, pid, info["pid"])
+	assert.Equal(t, CollectorRunning, info["state"])
+	assert.False(t, processExists(pid.(int)), "old collector is stopped")
+
+	// The replacement runs on the alternate ports
+	content, err := os.ReadFile(filepath.Join(dir, "exp-1-candidate.alt.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "0.0.0.0:5317")
+	assert.NoFileExists(t, filepath.Join(dir, "exp-1-candidate.yaml"))
+}
+
+func TestCollectorManager_UpdateRejectsInvalidConfig(t *testing.T) {
+	shortenSettles(t)
+	dir := t.TempDir()
+	manager := NewCollectorManager(&config.Config{ConfigDir: dir})
+	spec := startFake(t, manager, fakeCollector(t, dir, true))
+	pid := manager.GetProcessInfo("exp-1-candidate")["pid"]
+
+	updated := *spec
+	updated.config = "invalid: true\n"
+	_, err := manager.update(context.Background(), "exp-1-candidate", "candidate", &updated, CollectorOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "validation")
+
+	// The collector keeps running its old config
+	assert.Equal(t, pid, manager.GetProcessInfo("exp-1-candidate")["pid"])
+	content, err := os.ReadFile(filepath.Join(dir, "exp-1-candidate.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, spec.config, string(content))
+}
@@ -0,0 +1,134 @@
+package supervisor
+
+import (
+	"fmt"
+	"time"
+)
+
+// Restart modes of a collector
+const (
+	// RestartAlways restarts a collector whenever it exits
+	RestartAlways = "always"
+	// RestartOnFailure restarts a collector that exits with a non-zero code
+	// or is killed by a signal
+	RestartOnFailure = "on-failure"
+	// RestartNever leaves an exited collector down
+	RestartNever = "never"
+)
+
+// Collector states reported in GetMetrics
+const (
+	CollectorRunning   = "running"
+	CollectorBackoff   = "backoff"
+	CollectorCrashLoop = "crash_loop"
+	CollectorExited    = "exited"
+)
+
+const (
+	defaultMaxRestarts    = 5
+	defaultInitialBackoff = 1 * time.Second
+	defaultMaxBackoff     = 2 * time.Minute
+	// defaultStableAfter is how long a collector must run before its
+	// earlier crashes no longer count toward the crash-loop cap
+	defaultStableAfter = 10 * time.Minute
+
+	// logTailBytes bounds the collector log captured on each crash
+	logTailBytes = 4096
+	logTailLines = 40
+)
+
+// RestartPolicy decides whether and when an exited collector is started
+// again. Zero fields take their defaults.
+type RestartPolicy struct {
+	Mode string
+	// MaxRestarts is how many consecutive crashes are restarted before the
+	// collector is left in crash_loop
+	MaxRestarts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	StableAfter    time.Duration
+}
+
+// ParseRestartPolicy builds a restart policy from a task config's
+// restart_policy and max_restarts keys. A missing mode defaults to
+// on-failure.
+func ParseRestartPolicy(config map[string]interface{}) (RestartPolicy, error) {
+	policy := RestartPolicy{Mode: RestartOnFailure}
+
+	if mode, ok := config["restart_policy"].(string); ok && mode != "" {
+		switch mode {
+		case RestartAlways, RestartOnFailure, RestartNever:
+			policy.Mode = mode
+		default:
+			return policy, fmt.Errorf("unknown restart_policy %q", mode)
+		}
+	}
+
+	// Numbers arrive as float64 from JSON
+	if max, ok := config["max_restarts"].(float64); ok {
+		if max < 0 {
+			return policy, fmt.Errorf("max_restarts must not be negative")
+		}
+		policy.MaxRestarts = int(max)
+		if policy.MaxRestarts == 0 {
+			// Zero restarts means never
+			policy.Mode = RestartNever
+		}
+	}
+
+	return policy, nil
+}
+
+func (p RestartPolicy) withDefaults() RestartPolicy {
+	if p.Mode == "" {
+		p.Mode = RestartOnFailure
+	}
+	if p.MaxRestarts <= 0 {
+		p.MaxRestarts = defaultMaxRestarts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	if p.StableAfter <= 0 {
+		p.StableAfter = defaultStableAfter
+	}
+	return p
+}
+
+// shouldRestart reports whether a collector that exited with exitCode is
+// restarted. Killed collectors exit with -1.
+func (p RestartPolicy) shouldRestart(exitCode int) bool {
+	switch p.Mode {
+	case RestartAlways:
+		return true
+	case RestartNever:
+		return false
+	default:
+		return exitCode != 0
+	}
+}
+
+// backoff returns the delay before restarting after the given number of
+// consecutive crashes, doubling from InitialBackoff up to MaxBackoff
+func (p RestartPolicy) backoff(crashes int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < crashes; i++ {
+		delay *= 2
+		if delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// logTail returns the last lines of a collector log
+func logTail(path string) string {
+	tail, _, err := tailFile(path, logTailBytes, logTailLines)
+	if err != nil {
+		return ""
+	}
+	return tail
+}
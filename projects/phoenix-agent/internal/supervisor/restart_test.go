@@ -0,0 +1,136 @@
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	policy, err := ParseRestartPolicy(map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, RestartOnFailure, policy.Mode)
+
+	policy, err = ParseRestartPolicy(map[string]interface{}{
+		"restart_policy": "always",
+		"max_restarts":   float64(3),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, RestartAlways, policy.Mode)
+	assert.Equal(t, 3, policy.MaxRestarts)
+
+	policy, err = ParseRestartPolicy(map[string]interface{}{"max_restarts": float64(0)})
+	require.NoError(t, err)
+	assert.Equal(t, RestartNever, policy.Mode)
+
+	_, err = ParseRestartPolicy(map[string]interface{}{"restart_policy": "sometimes"})
+	assert.Error(t, err)
+
+	_, err = ParseRestartPolicy(map[string]interface{}{"max_restarts": float64(-1)})
+	assert.Error(t, err)
+}
+
+func TestRestartPolicy_ShouldRestart(t *testing.T) {
+	always := RestartPolicy{Mode: RestartAlways}
+	onFailure := RestartPolicy{Mode: RestartOnFailure}
+	never := RestartPolicy{Mode: RestartNever}
+
+	assert.True(t, always.shouldRestart(0))
+	assert.True(t, always.shouldRestart(1))
+	assert.False(t, onFailure.shouldRestart(0))
+	assert.True(t, onFailure.shouldRestart(1))
+	assert.True(t, onFailure.shouldRestart(-1))
+	assert.False(t, never.shouldRestart(1))
+}
+
+func TestRestartPolicy_Backoff(t *testing.T) {
+	policy := RestartPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	assert.Equal(t, time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 8*time.Second, policy.backoff(4))
+	assert.Equal(t, 10*time.Second, policy.backoff(5))
+	assert.Equal(t, 10*time.Second, policy.backoff(50))
+}
+
+func TestLogTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collector.log")
+	assert.Empty(t, logTail(path))
+
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+
+	tail := strings.Split(logTail(path), "\n")
+	assert.Len(t, tail, logTailLines)
+	assert.Equal(t, "line 99", tail[len(tail)-1])
+}
+
+func TestCollectorManager_HandleExit(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewCollectorManager(&config.Config{ConfigDir: dir})
+
+	process := &Process{
+		ID:        "exp-1-candidate",
+		Variant:   "candidate",
+		StartedAt: time.Now(),
+		State:     CollectorRunning,
+		logPath:   filepath.Join(dir, "exp-1-candidate.log"),
+		options: CollectorOptions{
+			ExperimentID: "exp-1",
+			// Keep restarts from firing during the test
+			Restart: RestartPolicy{Mode: RestartOnFailure, MaxRestarts: 2, InitialBackoff: time.Hour},
+		},
+	}
+	require.NoError(t, os.WriteFile(process.logPath, []byte("panic: boom\n"), 0644))
+	manager.processes[process.ID] = process
+
+	manager.mu.Lock()
+	manager.handleExit(process, 2)
+	manager.mu.Unlock()
+
+	event := <-manager.Events()
+	assert.Equal(t, "crashed", event.Event)
+	assert.Equal(t, "exp-1", event.ExperimentID)
+	assert.Equal(t, 2, event.ExitCode)
+	assert.Equal(t, "panic: boom", event.LogTail)
+	assert.Equal(t, CollectorBackoff, process.State)
+	process.restartTimer.Stop()
+
+	// The cap is reached after MaxRestarts consecutive crashes
+	process.crashes = 2
+	manager.mu.Lock()
+	manager.handleExit(process, 2)
+	manager.mu.Unlock()
+
+	event = <-manager.Events()
+	assert.Equal(t, "crash_loop", event.Event)
+	assert.Equal(t, CollectorCrashLoop, process.State)
+
+	metrics := manager.GetMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, false, metrics[0]["running"])
+	assert.Equal(t, 2, metrics[0]["last_exit_code"])
+
+	// A clean exit is not restarted on-failure
+	process.crashes = 0
+	manager.mu.Lock()
+	manager.handleExit(process, 0)
+	manager.mu.Unlock()
+
+	event = <-manager.Events()
+	assert.Equal(t, "exited", event.Event)
+	assert.Equal(t, CollectorExited, process.State)
+
+	require.NoError(t, manager.Stop(process.ID))
+	assert.Empty(t, manager.GetMetrics())
+}
@@ -0,0 +1,368 @@
+package supervisor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// stateFile is kept in ConfigDir and lists the collectors the agent
+	// supervises, so a restarted agent can take them over
+	stateFile    = "agent-state.json"
+	stateVersion = 1
+
+	// adoptedPollInterval is how often an adopted collector, which this
+	// agent cannot wait on, is checked for having exited
+	adoptedPollInterval = 2 * time.Second
+)
+
+// How a collector from the state file was recovered
+const (
+	// RecoveryAdopted collectors were still running and are supervised
+	// again
+	RecoveryAdopted = "adopted"
+	// RecoveryRestarted collectors had exited and were started again from
+	// their saved config
+	RecoveryRestarted = "restarted"
+	// RecoveryLost collectors could not be recovered
+	RecoveryLost = "lost"
+)
+
+// agentState is the content of the state file
+type agentState struct {
+	Version    int              `json:"version"`
+	HostID     string           `json:"host_id"`
+	SavedAt    time.Time        `json:"saved_at"`
+	Collectors []savedCollector `json:"collectors"`
+}
+
+// savedCollector is what it takes to adopt or restart a collector
+type savedCollector struct {
+	ID           string    `json:"id"`
+	Variant      string    `json:"variant"`
+	ConfigHash   string    `json:"config_hash"`
+	Pid          int       `json:"pid"`
+	StartedAt    time.Time `json:"started_at"`
+	Restarts     int       `json:"restarts"`
+	TaskID       string    `json:"task_id,omitempty"`
+	ExperimentID string    `json:"experiment_id,omitempty"`
+	DeploymentID string    `json:"deployment_id,omitempty"`
+	DesiredHash  string    `json:"desired_hash,omitempty"`
+	RestartMode  string    `json:"restart_policy"`
+	MaxRestarts  int       `json:"max_restarts"`
+	Binary       string    `json:"binary"`
+	Args         []string  `json:"args"`
+	Env          []string  `json:"env"`
+	LogPath      string    `json:"log_path"`
+	ConfigPath   string    `json:"config_path,omitempty"`
+	Slot         int       `json:"slot,omitempty"`
+	// TelemetryPort is zero for collectors started before ports were
+	// assigned
+	TelemetryPort int             `json:"telemetry_port,omitempty"`
+	Limits        *ResourceLimits `json:"limits,omitempty"`
+}
+
+func (m *CollectorManager) statePath() string {
+	return filepath.Join(m.config.ConfigDir, stateFile)
+}
+
+// saveState writes the collectors that should be running to the state
+// file. Collectors that exited for good are left out. m.mu must be held.
+func (m *CollectorManager) saveState() {
+	if m.shutdown {
+		return
+	}
+
+	state := agentState{
+		Version:    stateVersion,
+		HostID:     m.config.HostID,
+		SavedAt:    time.Now(),
+		Collectors: []savedCollector{},
+	}
+	for _, process := range m.processes {
+		if process.State != CollectorRunning && process.State != CollectorBackoff {
+			continue
+		}
+		saved := savedCollector{
+			ID:           process.ID,
+			Variant:      process.Variant,
+			ConfigHash:   process.ConfigHash,
+			Pid:          process.Pid,
+			StartedAt:    process.StartedAt,
+			Restarts:     process.Restarts,
+			TaskID:       process.options.TaskID,
+			ExperimentID: process.options.ExperimentID,
+			DeploymentID: process.options.DeploymentID,
+			DesiredHash:  process.options.DesiredHash,
+			RestartMode:  process.options.Restart.Mode,
+			MaxRestarts:  process.options.Restart.MaxRestarts,
+			Binary:       process.binary,
+			Args:         process.args,
+			Env:          process.env,
+			LogPath:      process.logPath,
+			ConfigPath:   process.configFile,
+			Slot:         process.slot,
+
+			TelemetryPort: process.telemetryPort,
+		}
+		if !process.options.Limits.IsZero() {
+			limits := process.options.Limits
+			saved.Limits = &limits
+		}
+		state.Collectors = append(state.Collectors, saved)
+	}
+
+	if err := writeState(m.statePath(), &state); err != nil {
+		log.Error().Err(err).Msg("Failed to save agent state")
+	}
+}
+
+// writeState replaces the state file atomically. It holds collector
+// credentials, so only the agent may read it.
+func writeState(path string, state *agentState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write agent state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace agent state: %w", err)
+	}
+	return nil
+}
+
+// readState reads the state file. A missing file is an empty state.
+func readState(path string) (*agentState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &agentState{Version: stateVersion}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent state: %w", err)
+	}
+
+	var state agentState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse agent state: %w", err)
+	}
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported agent state version %d", state.Version)
+	}
+	return &state, nil
+}
+
+// Recover takes over the collectors listed in the state file by an earlier
+// agent process. Collectors still running are adopted; the others are
+// restarted from their saved configs. It returns what became of each.
+func (m *CollectorManager) Recover() []poller.RecoveredCollector {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := readState(m.statePath())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to recover collectors")
+		return nil
+	}
+
+	recovered := make([]poller.RecoveredCollector, 0, len(state.Collectors))
+	for _, saved := range state.Collectors {
+		result := m.recoverCollector(saved)
+		recovered = append(recovered, result)
+
+		log.Info().
+			Str("id", saved.ID).
+			Str("recovery", result.Recovery).
+			Int("pid", result.Pid).
+			Str("error", result.Error).
+			Msg("Recovered collector")
+	}
+
+	m.saveState()
+
+	return recovered
+}
+
+// recoverCollector adopts or restarts one saved collector. m.mu must be
+// held.
+func (m *CollectorManager) recoverCollector(saved savedCollector) poller.RecoveredCollector {
+	result := poller.RecoveredCollector{
+		CollectorID:  saved.ID,
+		Variant:      saved.Variant,
+		ConfigHash:   saved.ConfigHash,
+		TaskID:       saved.TaskID,
+		ExperimentID: saved.ExperimentID,
+		DeploymentID: saved.DeploymentID,
+	}
+
+	if _, exists := m.processes[saved.ID]; exists {
+		result.Recovery = RecoveryLost
+		result.Error = "collector is already supervised"
+		return result
+	}
+
+	process := &Process{
+		ID:         saved.ID,
+		Variant:    saved.Variant,
+		ConfigHash: saved.ConfigHash,
+		Restarts:   saved.Restarts,
+		options: CollectorOptions{
+			TaskID:       saved.TaskID,
+			ExperimentID: saved.ExperimentID,
+			DeploymentID: saved.DeploymentID,
+			DesiredHash:  saved.DesiredHash,
+			Restart:      RestartPolicy{Mode: saved.RestartMode, MaxRestarts: saved.MaxRestarts},
+		},
+		binary:     saved.Binary,
+		args:       saved.Args,
+		env:        saved.Env,
+		logPath:    saved.LogPath,
+		configFile: saved.ConfigPath,
+		slot:       saved.Slot,
+
+		telemetryPort: saved.TelemetryPort,
+	}
+	if saved.Limits != nil {
+		process.options.Limits = *saved.Limits
+	}
+
+	// State files written before blue/green updates name no config path
+	if process.configFile == "" {
+		process.configFile = m.configPath(saved.ID)
+	}
+	configPath := process.configFile
+
+	if saved.Pid > 0 && collectorAlive(saved.Pid, configPath) {
+		m.adopt(process, saved.Pid, saved.StartedAt)
+		m.processes[saved.ID] = process
+		m.ports[process.telemetryPort] = true
+		// The collector is still in the group it was placed in
+		if m.cgroups.enabled() {
+			if path := m.cgroups.path(collectorCgroup(process)); dirExists(path) {
+				process.cgroup = path
+			}
+		}
+		result.Recovery = RecoveryAdopted
+		result.Pid = saved.Pid
+		return result
+	}
+
+	// The collector is gone; start it again if its config is unchanged
+	config, err := os.ReadFile(configPath)
+	if err != nil {
+		result.Recovery = RecoveryLost
+		result.Error = fmt.Sprintf("config is missing: %v", err)
+		return result
+	}
+	hash := sha256.Sum256(config)
+	if hex.EncodeToString(hash[:]) != saved.ConfigHash {
+		result.Recovery = RecoveryLost
+		result.Error = "config changed since the collector was started"
+		return result
+	}
+
+	if err := m.launch(process, false); err != nil {
+		result.Recovery = RecoveryLost
+		result.Error = err.Error()
+		return result
+	}
+	m.processes[saved.ID] = process
+	m.ports[process.telemetryPort] = true
+	result.Recovery = RecoveryRestarted
+	result.Pid = process.Pid
+	return result
+}
+
+// adopt supervises a collector started by an earlier agent process. It is
+// not a child of this agent, so its exit is noticed by polling and its exit
+// code is unknown. m.mu must be held.
+func (m *CollectorManager) adopt(process *Process, pid int, startedAt time.Time) {
+	process.Pid = pid
+	process.StartedAt = startedAt
+	process.State = CollectorRunning
+	process.adopted = true
+	process.exited = make(chan struct{})
+
+	go m.watchAdopted(process, pid, process.exited)
+}
+
+// watchAdopted waits for an adopted collector to exit and handles the exit
+// like monitorProcess does for children
+func (m *CollectorManager) watchAdopted(process *Process, pid int, exited chan struct{}) {
+	ticker := time.NewTicker(adoptedPollInterval)
+	defer ticker.Stop()
+
+	for processExists(pid) {
+		<-ticker.C
+	}
+	close(exited)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if process.stopping || m.processes[process.ID] != process {
+		return
+	}
+	if process.reloading {
+		m.reloadExited(process, -1)
+		return
+	}
+
+	log.Error().
+		Str("id", process.ID).
+		Int("pid", pid).
+		Msg("Adopted collector process exited unexpectedly")
+
+	m.handleExit(process, -1)
+}
+
+// collectorAlive reports whether pid is still the collector running
+// configPath, rather than another process that reused the pid
+func collectorAlive(pid int, configPath string) bool {
+	if !processExists(pid) {
+		return false
+	}
+
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		// Without procfs the pid is the best we know
+		return true
+	}
+	return bytes.Contains(cmdline, []byte(configPath))
+}
+
+// processExists reports whether a process with pid exists. Zombies count
+// as exited.
+func processExists(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+		return false
+	}
+
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	// The state follows the parenthesised command name
+	if i := bytes.LastIndexByte(stat, ')'); i >= 0 && i+2 < len(stat) {
+		return stat[i+2] != 'Z'
+	}
+	return true
+}
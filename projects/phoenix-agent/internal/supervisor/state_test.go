@@ -0,0 +1,120 @@
+package supervisor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCollectorConfig writes a collector config the way Start does and
+// returns its path and hash
+func writeCollectorConfig(t *testing.T, dir, id string) (string, string) {
+	content := []byte("receivers: {}\n")
+	path := filepath.Join(dir, id+".yaml")
+	require.NoError(t, os.WriteFile(path, content, 0644))
+	hash := sha256.Sum256(content)
+	return path, hex.EncodeToString(hash[:])
+}
+
+// sleeper saves a collector stand-in that names its config on the command
+// line, as collectors do
+func sleeper(id, configPath, hash string) savedCollector {
+	return savedCollector{
+		ID:           id,
+		Variant:      "candidate",
+		ConfigHash:   hash,
+		TaskID:       "task-1",
+		ExperimentID: "exp-1",
+		RestartMode:  RestartOnFailure,
+		Binary:       "tail",
+		Args:         []string{"-f", configPath},
+		LogPath:      configPath + ".log",
+	}
+}
+
+func TestCollectorManager_RecoverAdoptsRunningCollector(t *testing.T) {
+	dir := t.TempDir()
+	configPath, hash := writeCollectorConfig(t, dir, "exp-1-candidate")
+
+	// A collector left behind by an earlier agent process
+	cmd := exec.Command("tail", "-f", configPath)
+	require.NoError(t, cmd.Start())
+	go cmd.Wait()
+	require.Eventually(t, func() bool {
+		return collectorAlive(cmd.Process.Pid, configPath)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	saved := sleeper("exp-1-candidate", configPath, hash)
+	saved.Pid = cmd.Process.Pid
+	require.NoError(t, writeState(filepath.Join(dir, stateFile), &agentState{
+		Version:    stateVersion,
+		Collectors: []savedCollector{saved},
+	}))
+
+	manager := NewCollectorManager(&config.Config{ConfigDir: dir})
+	recovered := manager.Recover()
+
+	require.Len(t, recovered, 1)
+	assert.Equal(t, RecoveryAdopted, recovered[0].Recovery)
+	assert.Equal(t, cmd.Process.Pid, recovered[0].Pid)
+	assert.Equal(t, "task-1", recovered[0].TaskID)
+
+	metrics := manager.GetMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, true, metrics[0]["running"])
+
+	require.NoError(t, manager.Stop("exp-1-candidate"))
+	assert.Eventually(t, func() bool { return !processExists(cmd.Process.Pid) }, 5*time.Second, 100*time.Millisecond)
+
+	state, err := readState(filepath.Join(dir, stateFile))
+	require.NoError(t, err)
+	assert.Empty(t, state.Collectors)
+}
+
+func TestCollectorManager_RecoverRestartsExitedCollector(t *testing.T) {
+	dir := t.TempDir()
+	configPath, hash := writeCollectorConfig(t, dir, "exp-1-candidate")
+	_, otherHash := writeCollectorConfig(t, dir, "exp-1-baseline")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "exp-1-baseline.yaml"), []byte("changed\n"), 0644))
+
+	require.NoError(t, writeState(filepath.Join(dir, stateFile), &agentState{
+		Version: stateVersion,
+		Collectors: []savedCollector{
+			sleeper("exp-1-candidate", configPath, hash),
+			sleeper("exp-1-baseline", filepath.Join(dir, "exp-1-baseline.yaml"), otherHash),
+		},
+	}))
+
+	manager := NewCollectorManager(&config.Config{ConfigDir: dir})
+	recovered := manager.Recover()
+	require.Len(t, recovered, 2)
+
+	byID := map[string]string{}
+	for _, r := range recovered {
+		byID[r.CollectorID] = r.Recovery
+	}
+	assert.Equal(t, RecoveryRestarted, byID["exp-1-candidate"])
+	assert.Equal(t, RecoveryLost, byID["exp-1-baseline"])
+
+	// Only the restarted collector is saved again
+	state, err := readState(filepath.Join(dir, stateFile))
+	require.NoError(t, err)
+	require.Len(t, state.Collectors, 1)
+	assert.Equal(t, "exp-1-candidate", state.Collectors[0].ID)
+	assert.NotZero(t, state.Collectors[0].Pid)
+
+	// Stopping for an agent shutdown keeps the collector for the next one
+	manager.Shutdown()
+	state, err = readState(filepath.Join(dir, stateFile))
+	require.NoError(t, err)
+	assert.Len(t, state.Collectors, 1)
+	assert.FileExists(t, configPath)
+}
@@ -2,6 +2,7 @@ package supervisor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -14,28 +15,73 @@ import (
 	"github.com/shirou/gopsutil/v3/mem"
 )
 
+// ErrTaskCancelled is returned by ExecuteTask when the task was cancelled
+// while it was running
+var ErrTaskCancelled = errors.New("task cancelled")
+
+// TaskLocker hands out the locks that tasks which must not run at once
+// share, so work the supervisor does on its own waits for those tasks. The
+// worker pool is one.
+type TaskLocker interface {
+	Lock(ctx context.Context, task *poller.Task) (unlock func(), err error)
+}
+
 type Supervisor struct {
 	config           *config.Config
 	collectorManager *CollectorManager
 	loadSimManager   *LoadSimManager
 	activeTasks      sync.Map
-	mu               sync.RWMutex
+	locker           TaskLocker
+	// recovered is reported with heartbeats until one is accepted
+	recovered []poller.RecoveredCollector
+	// loadSimExperiment is the experiment the last load simulation was
+	// started for, so reconciliation does not repeat a finished one
+	loadSimExperiment string
+	loadSimStartedAt  time.Time
+	mu                sync.RWMutex
 }
 
 func NewSupervisor(cfg *config.Config) *Supervisor {
+	collectorManager := NewCollectorManager(cfg)
+
+	// Load simulations are confined like collectors
+	loadSimManager := NewLoadSimManager()
+	loadSimManager.cgroups = collectorManager.cgroups
+
 	return &Supervisor{
 		config:           cfg,
-		collectorManager: NewCollectorManager(cfg),
-		loadSimManager:   NewLoadSimManager(),
+		collectorManager: collectorManager,
+		loadSimManager:   loadSimManager,
 	}
 }
 
-// ExecuteTask executes a task based on its type
+// SetTaskLocker makes reconciliation take the locks of the tasks it
+// overlaps with from locker
+func (s *Supervisor) SetTaskLocker(locker TaskLocker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locker = locker
+}
+
+// ExecuteTask executes a task based on its type. If ctx is cancelled with a
+// cause wrapping ErrTaskCancelled before the task finishes, that cause is
+// returned.
 func (s *Supervisor) ExecuteTask(ctx context.Context, task *poller.Task) (map[string]interface{}, error) {
 	// Track active task
 	s.activeTasks.Store(task.ID, task)
 	defer s.activeTasks.Delete(task.ID)
 
+	result, err := s.executeTask(ctx, task)
+	if err != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, ErrTaskCancelled) {
+			return nil, cause
+		}
+	}
+
+	return result, err
+}
+
+func (s *Supervisor) executeTask(ctx context.Context, task *poller.Task) (map[string]interface{}, error) {
 	switch task.Type {
 	case "collector":
 		return s.executeCollectorTask(ctx, task)
@@ -51,10 +97,6 @@ func (s *Supervisor) ExecuteTask(ctx context.Context, task *poller.Task) (map[st
 }
 
 func (s *Supervisor) executeCollectorTask(ctx context.Context, task *poller.Task) (map[string]interface{}, error) {
-	// Pass context to the collector operations in the future
-	// For now, just acknowledge the parameter to suppress warning
-	_ = ctx
-
 	config := task.Config
 
 	id, ok := config["id"].(string)
@@ -67,6 +109,19 @@ func (s *Supervisor) executeCollectorTask(ctx context.Context, task *poller.Task
 		return nil, fmt.Errorf("missing variant in config")
 	}
 
+	restart, err := ParseRestartPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := ParseResourceLimits(config)
+	if err != nil {
+		return nil, err
+	}
+	desiredHash, _ := config["config_hash"].(string)
+	opts := CollectorOptions{TaskID: task.ID, ExperimentID: task.ExperimentID, DesiredHash: desiredHash, Restart: restart, Limits: limits}
+	opts.ConfigSHA256, _ = config["config_sha256"].(string)
+	opts.ConfigSignature, _ = config["config_signature"].(string)
+
 	switch task.Action {
 	case "start":
 		configURL, ok := config["configUrl"].(string)
@@ -104,14 +159,11 @@ func (s *Supervisor) executeCollectorTask(ctx context.Context, task *poller.Task
 			vars["METRICS_PUSHGATEWAY_URL"] = pushgatewayURL
 		}
 
-		if err := s.collectorManager.Start(id, variant, configURL, vars); err != nil {
+		if err := s.collectorManager.Start(ctx, id, variant, configURL, vars, opts); err != nil {
 			return nil, fmt.Errorf("failed to start collector: %w", err)
 		}
 
-		return map[string]interface{}{
-			"status": "started",
-			"pid":    s.collectorManager.GetProcessInfo(id),
-		}, nil
+		return s.collectorResult("started", id), nil
 
 	case "stop":
 		if err := s.collectorManager.Stop(id); err != nil {
@@ -123,9 +175,6 @@ func (s *Supervisor) executeCollectorTask(ctx context.Context, task *poller.Task
 		}, nil
 
 	case "update":
-		// Stop and restart with new config
-		s.collectorManager.Stop(id)
-
 		configURL, ok := config["configUrl"].(string)
 		if !ok {
 			return nil, fmt.Errorf("missing configUrl in config")
@@ -161,14 +210,15 @@ func (s *Supervisor) executeCollectorTask(ctx context.Context, task *poller.Task
 			vars["METRICS_PUSHGATEWAY_URL"] = pushgatewayURL
 		}
 
-		if err := s.collectorManager.Start(id, variant, configURL, vars); err != nil {
+		// Reload in place where possible so no data is dropped
+		strategy, err := s.collectorManager.Update(ctx, id, variant, configURL, vars, opts)
+		if err != nil {
 			return nil, fmt.Errorf("failed to update collector: %w", err)
 		}
 
-		return map[string]interface{}{
-			"status": "updated",
-			"pid":    s.collectorManager.GetProcessInfo(id),
-		}, nil
+		result := s.collectorResult("updated", id)
+		result["strategy"] = strategy
+		return result, nil
 
 	default:
 		return nil, fmt.Errorf("unknown collector action: %s", task.Action)
@@ -176,30 +226,22 @@ func (s *Supervisor) executeCollectorTask(ctx context.Context, task *poller.Task
 }
 
 func (s *Supervisor) executeLoadSimTask(ctx context.Context, task *poller.Task) (map[string]interface{}, error) {
-	// Pass context to load sim operations in the future
-	// For now, just acknowledge the parameter to suppress warning
-	_ = ctx
-
-	config := task.Config
-
 	switch task.Action {
 	case "start":
-		profile, ok := config["profile"].(string)
-		if !ok {
-			return nil, fmt.Errorf("missing profile in config")
+		// The task runs for as long as the simulation, so cancelling it
+		// stops the simulation
+		profile, err := s.startLoadSim(ctx, task)
+		if err != nil {
+			return nil, err
 		}
 
-		durationStr, ok := config["duration"].(string)
-		if !ok {
-			durationStr = "60s"
-		}
-
-		if err := s.loadSimManager.Start(profile, durationStr); err != nil {
-			return nil, fmt.Errorf("failed to start load simulation: %w", err)
+		<-s.loadSimManager.Done()
+		if err := context.Cause(ctx); err != nil {
+			return nil, err
 		}
 
 		return map[string]interface{}{
-			"status":  "started",
+			"status":  "finished",
 			"profile": profile,
 		}, nil
 
@@ -217,11 +259,55 @@ func (s *Supervisor) executeLoadSimTask(ctx context.Context, task *poller.Task)
 	}
 }
 
-func (s *Supervisor) executePipelineDeploymentTask(ctx context.Context, task *poller.Task) (map[string]interface{}, error) {
-	// Pass context to pipeline operations in the future
-	// For now, just acknowledge the parameter to suppress warning
-	_ = ctx
+// startLoadSim launches the load simulation a loadsim start task describes
+// and returns its profile. The simulation is killed once ctx is done.
+func (s *Supervisor) startLoadSim(ctx context.Context, task *poller.Task) (string, error) {
+	config := task.Config
+
+	profile, ok := config["profile"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing profile in config")
+	}
 
+	durationStr, ok := config["duration"].(string)
+	if !ok {
+		durationStr = "60s"
+	}
+
+	limits, err := ParseResourceLimits(config)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.loadSimManager.Start(ctx, profile, durationStr, limits); err != nil {
+		return "", fmt.Errorf("failed to start load simulation: %w", err)
+	}
+
+	s.mu.Lock()
+	s.loadSimExperiment = task.ExperimentID
+	s.loadSimStartedAt = time.Now()
+	s.mu.Unlock()
+
+	return profile, nil
+}
+
+// collectorResult reports what the API needs to track a running collector:
+// its pid, a hash of the rendered config and when it started
+func (s *Supervisor) collectorResult(status, id string) map[string]interface{} {
+	result := map[string]interface{}{
+		"status": status,
+	}
+
+	if info := s.collectorManager.GetProcessInfo(id); info != nil {
+		result["pid"] = info["pid"]
+		result["config_hash"] = info["config_hash"]
+		result["started_at"] = info["started_at"]
+	}
+
+	return result
+}
+
+func (s *Supervisor) executePipelineDeploymentTask(ctx context.Context, task *poller.Task) (map[string]interface{}, error) {
 	config := task.Config
 
 	deploymentID, ok := config["deployment_id"].(string)
@@ -239,6 +325,19 @@ func (s *Supervisor) executePipelineDeploymentTask(ctx context.Context, task *po
 		return nil, fmt.Errorf("missing or empty pipeline_config in config")
 	}
 
+	restart, err := ParseRestartPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := ParseResourceLimits(config)
+	if err != nil {
+		return nil, err
+	}
+	desiredHash, _ := config["config_hash"].(string)
+	opts := CollectorOptions{TaskID: task.ID, DeploymentID: deploymentID, DesiredHash: desiredHash, Restart: restart, Limits: limits}
+	opts.ConfigSHA256, _ = config["config_sha256"].(string)
+	opts.ConfigSignature, _ = config["config_signature"].(string)
+
 	switch task.Action {
 	case "deploy":
 		// Create a unique ID for this collector instance
@@ -264,7 +363,7 @@ func (s *Supervisor) executePipelineDeploymentTask(ctx context.Context, task *po
 		}
 
 		// Start collector with the pipeline config
-		if err := s.collectorManager.Start(collectorID, deploymentName, "file://"+configPath, vars); err != nil {
+		if err := s.collectorManager.Start(ctx, collectorID, deploymentName, "file://"+configPath, vars, opts); err != nil {
 			os.Remove(configPath) // Clean up temp file
 			return nil, fmt.Errorf("failed to deploy pipeline: %w", err)
 		}
@@ -295,9 +394,8 @@ func (s *Supervisor) executePipelineDeploymentTask(ctx context.Context, task *po
 		}, nil
 
 	case "update":
-		// Stop and redeploy with new config
+		// Reload the deployment's collector with the new config
 		collectorID := fmt.Sprintf("dep-%s-%s", deploymentID, s.config.HostID)
-		s.collectorManager.Stop(collectorID)
 
 		// Write new pipeline config
 		configPath := fmt.Sprintf("/tmp/pipeline-%s.yaml", collectorID)
@@ -305,7 +403,6 @@ func (s *Supervisor) executePipelineDeploymentTask(ctx context.Context, task *po
 			return nil, fmt.Errorf("failed to write pipeline config: %w", err)
 		}
 
-		// Restart with new config
 		vars := make(map[string]string)
 		if params, ok := config["parameters"].(map[string]interface{}); ok {
 			for k, v := range params {
@@ -318,7 +415,8 @@ func (s *Supervisor) executePipelineDeploymentTask(ctx context.Context, task *po
 			vars["METRICS_PUSHGATEWAY_URL"] = pushgatewayURL
 		}
 
-		if err := s.collectorManager.Start(collectorID, deploymentName, "file://"+configPath, vars); err != nil {
+		strategy, err := s.collectorManager.Update(ctx, collectorID, deploymentName, "file://"+configPath, vars, opts)
+		if err != nil {
 			os.Remove(configPath)
 			return nil, fmt.Errorf("failed to update pipeline: %w", err)
 		}
@@ -334,6 +432,7 @@ func (s *Supervisor) executePipelineDeploymentTask(ctx context.Context, task *po
 			"deployment_id": deploymentID,
 			"collector_id":  collectorID,
 			"pid":           s.collectorManager.GetProcessInfo(collectorID),
+			"strategy":      strategy,
 		}, nil
 
 	default:
@@ -341,15 +440,16 @@ func (s *Supervisor) executePipelineDeploymentTask(ctx context.Context, task *po
 	}
 }
 
+// executeCommandTask runs one of the built-in diagnostics named by the task
+// action
 func (s *Supervisor) executeCommandTask(ctx context.Context, task *poller.Task) (map[string]interface{}, error) {
-	// Execute arbitrary commands (for future extensibility)
-	// Using context and task parameters to avoid unused parameter warnings
-	_ = ctx
-	_ = task
-
-	return map[string]interface{}{
-		"status": "not_implemented",
-	}, fmt.Errorf("command tasks not yet implemented")
+	result, err := s.collectorManager.Diagnose(ctx, task.Action, task.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	result["diagnostic"] = task.Action
+	return result, nil
 }
 
 // GetStatus returns the current agent status
@@ -370,9 +470,15 @@ func (s *Supervisor) GetStatus() *poller.AgentStatus {
 		cpuUsage = cpuPercent[0]
 	}
 
+	s.mu.RLock()
+	recovered := s.recovered
+	s.mu.RUnlock()
+
 	return &poller.AgentStatus{
-		Status:      "healthy",
-		ActiveTasks: activeTasks,
+		Status:              "healthy",
+		ActiveTasks:         activeTasks,
+		Labels:              s.config.Labels,
+		RecoveredCollectors: recovered,
 		ResourceUsage: poller.ResourceUsage{
 			CPUPercent:    cpuUsage,
 			MemoryPercent: memInfo.UsedPercent,
@@ -399,9 +505,9 @@ func (s *Supervisor) Shutdown(ctx context.Context) error {
 	// Create error channel to collect shutdown errors
 	errChan := make(chan error, 2)
 
-	// Shutdown collectors
+	// Shutdown collectors; the next agent process restarts them
 	go func() {
-		s.collectorManager.StopAll()
+		s.collectorManager.Shutdown()
 		errChan <- nil
 	}()
 
@@ -431,6 +537,37 @@ func (s *Supervisor) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// Recover takes over the collectors of an earlier agent process. The
+// result is reported with heartbeats until InventoryReported is called.
+func (s *Supervisor) Recover() []poller.RecoveredCollector {
+	recovered := s.collectorManager.Recover()
+
+	s.mu.Lock()
+	s.recovered = recovered
+	s.mu.Unlock()
+
+	return recovered
+}
+
+// InventoryReported stops reporting the recovered collectors once the API
+// has accepted them
+func (s *Supervisor) InventoryReported() {
+	s.mu.Lock()
+	s.recovered = nil
+	s.mu.Unlock()
+}
+
+// CollectorEvents delivers the crash, restart and crash-loop events of
+// supervised collectors
+func (s *Supervisor) CollectorEvents() <-chan *poller.CollectorEvent {
+	return s.collectorManager.Events()
+}
+
+// CollectorLogs returns the log file of every supervised collector
+func (s *Supervisor) CollectorLogs() map[string]CollectorLog {
+	return s.collectorManager.CollectorLogs()
+}
+
 // GetMetrics returns metrics from all managed processes
 func (s *Supervisor) GetMetrics() []map[string]interface{} {
 	var metrics []map[string]interface{}
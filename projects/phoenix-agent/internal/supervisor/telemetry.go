@@ -0,0 +1,195 @@
+package supervisor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	psprocess "github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	// defaultTelemetryPortBase is the first port assigned to collectors
+	// for their own metrics when the agent config names none
+	defaultTelemetryPortBase = 18888
+
+	telemetryScrapeTimeout = 2 * time.Second
+)
+
+// telemetryFields maps the collector's own metrics to the names the agent
+// reports them under. Values are summed over all receivers, processors and
+// exporters; a _total suffix added by newer collectors is ignored.
+var telemetryFields = map[string]string{
+	"otelcol_receiver_accepted_metric_points":    "accepted_points",
+	"otelcol_receiver_refused_metric_points":     "refused_points",
+	"otelcol_processor_dropped_metric_points":    "dropped_points",
+	"otelcol_exporter_sent_metric_points":        "sent_points",
+	"otelcol_exporter_send_failed_metric_points": "send_failed_points",
+	"otelcol_exporter_queue_size":                "queue_size",
+	"otelcol_exporter_queue_capacity":            "queue_capacity",
+}
+
+// telemetryTarget is a running collector to scrape, with the pid and port
+// it had when GetMetrics listed it
+type telemetryTarget struct {
+	metric  map[string]interface{}
+	process *Process
+	pid     int
+	port    int
+}
+
+// cpuSample is the CPU time a collector process had used at a point in time
+type cpuSample struct {
+	pid     int
+	seconds float64
+	at      time.Time
+}
+
+// allocatePort assigns a collector the first telemetry port that is neither
+// assigned nor in use on the host. m.mu must be held.
+func (m *CollectorManager) allocatePort() int {
+	port := m.config.TelemetryPortBase
+	if port == 0 {
+		port = defaultTelemetryPortBase
+	}
+
+	for ; port < 65535; port++ {
+		if m.ports[port] || !portFree(port) {
+			continue
+		}
+		m.ports[port] = true
+		return port
+	}
+
+	// Every port is taken; let the collector pick one, unscraped
+	return 0
+}
+
+// portFree reports whether nothing listens on port on the loopback
+// interface
+func portFree(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
+// addTelemetry adds what a collector reports about the data passing through
+// it and its resource usage to its metric
+func (m *CollectorManager) addTelemetry(target telemetryTarget) {
+	telemetry, err := scrapeTelemetry(target.port)
+	if err != nil {
+		target.metric["telemetry_error"] = err.Error()
+	}
+	for name, value := range telemetry {
+		target.metric[name] = value
+	}
+
+	proc, err := psprocess.NewProcess(int32(target.pid))
+	if err != nil {
+		return
+	}
+	if memory, err := proc.MemoryInfo(); err == nil {
+		target.metric["process_rss_bytes"] = memory.RSS
+	}
+	times, err := proc.Times()
+	if err != nil {
+		return
+	}
+
+	sample := &cpuSample{pid: target.pid, seconds: times.User + times.System, at: time.Now()}
+	m.mu.Lock()
+	previous := target.process.cpuSample
+	target.process.cpuSample = sample
+	m.mu.Unlock()
+
+	// CPU is measured since the last scrape, or since the process started
+	if previous != nil && previous.pid == sample.pid && sample.at.After(previous.at) {
+		elapsed := sample.at.Sub(previous.at).Seconds()
+		target.metric["process_cpu_percent"] = (sample.seconds - previous.seconds) / elapsed * 100
+	} else if percent, err := proc.CPUPercent(); err == nil {
+		target.metric["process_cpu_percent"] = percent
+	}
+}
+
+// scrapeTelemetry reads the metrics a collector serves about itself. All
+// reported fields are present, at zero if the collector has no such metric.
+func scrapeTelemetry(port int) (map[string]float64, error) {
+	client := &http.Client{Timeout: telemetryScrapeTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape collector telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code scraping collector telemetry: %d", resp.StatusCode)
+	}
+
+	return parseTelemetry(resp.Body)
+}
+
+// parseTelemetry sums the collector metrics in telemetryFields from the
+// Prometheus text format
+func parseTelemetry(r io.Reader) (map[string]float64, error) {
+	telemetry := make(map[string]float64, len(telemetryFields))
+	for _, field := range telemetryFields {
+		telemetry[field] = 0
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		name, value, ok := parseSample(line)
+		if !ok {
+			continue
+		}
+		if field, ok := telemetryFields[strings.TrimSuffix(name, "_total")]; ok {
+			telemetry[field] += value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read collector telemetry: %w", err)
+	}
+
+	return telemetry, nil
+}
+
+// parseSample splits a sample line into its metric name and value
+func parseSample(line string) (string, float64, bool) {
+	end := strings.IndexAny(line, "{ ")
+	if end <= 0 {
+		return "", 0, false
+	}
+	name, rest := line[:end], line[end:]
+
+	if rest[0] == '{' {
+		closing := strings.LastIndexByte(rest, '}')
+		if closing < 0 {
+			return "", 0, false
+		}
+		rest = rest[closing+1:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", 0, false
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return name, value, true
+}
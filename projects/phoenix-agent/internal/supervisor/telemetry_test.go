@@ -0,0 +1,92 @@
+package supervisor
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const collectorTelemetry = `# HELP otelcol_receiver_accepted_metric_points Number of metric points successfully pushed into the pipeline.
+# TYPE otelcol_receiver_accepted_metric_points counter
+otelcol_receiver_accepted_metric_points{receiver="hostmetrics",transport=""} 1200
+otelcol_receiver_accepted_metric_points{receiver="otlp",transport="grpc"} 300
+otelcol_receiver_refused_metric_points_total{receiver="otlp",transport="grpc"} 5
+otelcol_processor_dropped_metric_points{processor="filter/topk"} 900
+otelcol_exporter_sent_metric_points{exporter="prometheusremotewrite"} 590
+otelcol_exporter_send_failed_metric_points{exporter="prometheusremotewrite"} 10
+otelcol_exporter_queue_size{exporter="prometheusremotewrite"} 42
+otelcol_exporter_queue_capacity{exporter="prometheusremotewrite"} 1000
+otelcol_process_uptime{service_instance_id="a{b}"} 12.5 1700000000000
+`
+
+func TestParseTelemetry(t *testing.T) {
+	telemetry, err := parseTelemetry(strings.NewReader(collectorTelemetry))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]float64{
+		"accepted_points":    1500,
+		"refused_points":     5,
+		"dropped_points":     900,
+		"sent_points":        590,
+		"send_failed_points": 10,
+		"queue_size":         42,
+		"queue_capacity":     1000,
+	}, telemetry)
+}
+
+func TestScrapeTelemetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/metrics", r.URL.Path)
+		fmt.Fprint(w, collectorTelemetry)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	require.NoError(t, err)
+
+	telemetry, err := scrapeTelemetry(port)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1500), telemetry["accepted_points"])
+}
+
+func TestCollectorManager_AllocatePortSkipsTakenPorts(t *testing.T) {
+	// A port something else on the host listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	busy := listener.Addr().(*net.TCPAddr).Port
+
+	manager := NewCollectorManager(&config.Config{TelemetryPortBase: busy})
+	manager.ports[busy+1] = true
+
+	port := manager.allocatePort()
+	assert.Greater(t, port, busy+1)
+	assert.True(t, manager.ports[port])
+}
+
+func TestCollectorManager_GetMetricsReportsProcessUsage(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewCollectorManager(&config.Config{ConfigDir: dir})
+	startFake(t, manager, fakeCollector(t, dir, false))
+
+	metrics := manager.GetMetrics()
+	require.Len(t, metrics, 1)
+
+	metric := metrics[0]
+	assert.NotZero(t, metric["telemetry_port"])
+	assert.Contains(t, metric, "process_rss_bytes")
+	assert.Contains(t, metric, "process_cpu_percent")
+	// The stand-in serves no telemetry
+	assert.Contains(t, metric, "telemetry_error")
+}
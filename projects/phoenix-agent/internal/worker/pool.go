@@ -0,0 +1,419 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/supervisor"
+	"github.com/rs/zerolog/log"
+)
+
+// maxQueuedTasks bounds the tasks waiting for a worker; polling pauses
+// while the queue is full so claimed tasks do not sit out their lease
+const maxQueuedTasks = 64
+
+// DefaultConcurrency is how many tasks of each type run at once when the
+// agent config names no limit. Other types run one at a time.
+var DefaultConcurrency = map[string]int{
+	"collector":  4,
+	"deployment": 2,
+	"loadsim":    2,
+	"command":    2,
+}
+
+// TaskSource hands out tasks and takes their status; the API client is one
+type TaskSource interface {
+	GetTasks(ctx context.Context) ([]*poller.Task, error)
+	UpdateTaskStatus(ctx context.Context, task *poller.Task, status string, result map[string]interface{}, errorMessage string) error
+}
+
+// Executor runs tasks; the supervisor is one
+type Executor interface {
+	ExecuteTask(ctx context.Context, task *poller.Task) (map[string]interface{}, error)
+}
+
+// Pool runs polled tasks on workers. Tasks run by priority, highest first,
+// with at most the configured number of each type at once. Tasks for the
+// same collector run one at a time in the order they arrived, so a start and
+// a stop never interleave. Polling carries on while tasks run.
+type Pool struct {
+	config   *config.Config
+	source   TaskSource
+	executor Executor
+	limits   map[string]int
+
+	queue []*queuedTask
+	// running counts the running tasks of each type
+	running map[string]int
+	// busy are the keys of running tasks and of locks handed out by Lock
+	busy map[string]bool
+	// released is closed, and replaced, whenever a key is freed
+	released chan struct{}
+	// tasks are the IDs of queued and running tasks
+	tasks map[string]bool
+	// cancels cancel the running tasks by ID
+	cancels map[string]context.CancelCauseFunc
+	seq     uint64
+	wake    chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+}
+
+type queuedTask struct {
+	task *poller.Task
+	key  string
+	seq  uint64
+}
+
+func NewPool(cfg *config.Config, source TaskSource, executor Executor) *Pool {
+	limits := make(map[string]int, len(DefaultConcurrency))
+	for taskType, limit := range DefaultConcurrency {
+		limits[taskType] = limit
+	}
+	for taskType, limit := range cfg.TaskConcurrency {
+		if limit > 0 {
+			limits[taskType] = limit
+		}
+	}
+
+	return &Pool{
+		config:   cfg,
+		source:   source,
+		executor: executor,
+		limits:   limits,
+		running:  make(map[string]int),
+		busy:     make(map[string]bool),
+		released: make(chan struct{}),
+		tasks:    make(map[string]bool),
+		cancels:  make(map[string]context.CancelCauseFunc),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Start polls for tasks and runs them until ctx is cancelled
+func (p *Pool) Start(ctx context.Context) {
+	go p.dispatchLoop(ctx)
+
+	ticker := time.NewTicker(p.config.PollInterval)
+	defer ticker.Stop()
+
+	// Initial poll immediately
+	p.poll(ctx)
+
+	for {
+		select {
+		case <-ticker.C:
+			p.poll(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Wait blocks until the running tasks have finished
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+func (p *Pool) poll(ctx context.Context) {
+	if p.Queued() >= maxQueuedTasks {
+		log.Debug().Int("queued", p.Queued()).Msg("Task queue is full, skipping poll")
+		return
+	}
+
+	tasks, err := p.source.GetTasks(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get tasks")
+		return
+	}
+
+	if len(tasks) == 0 {
+		log.Debug().Msg("No pending tasks")
+		return
+	}
+
+	log.Info().Int("count", len(tasks)).Msg("Received tasks")
+	p.Submit(tasks...)
+}
+
+// Submit queues tasks to run. A task that is already queued takes the
+// lease it was handed again with; one that is already running is ignored.
+func (p *Pool) Submit(tasks ...*poller.Task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, task := range tasks {
+		if p.tasks[task.ID] {
+			p.requeue(task)
+			continue
+		}
+
+		p.seq++
+		p.queue = append(p.queue, &queuedTask{task: task, key: TaskKey(task), seq: p.seq})
+		p.tasks[task.ID] = true
+	}
+
+	p.signal()
+}
+
+// requeue swaps a queued task for the copy a later poll handed out. p.mu
+// must be held.
+func (p *Pool) requeue(task *poller.Task) {
+	for _, queued := range p.queue {
+		if queued.task.ID == task.ID {
+			queued.task = task
+			return
+		}
+	}
+	log.Warn().Str("task_id", task.ID).Msg("Task handed out again while it is running, ignoring it")
+}
+
+// Cancel cancels a task. A queued task is dropped and reported cancelled
+// right away; a running task has its context cancelled and is reported
+// cancelled once it returns. It returns false if the task is neither queued
+// nor running.
+func (p *Pool) Cancel(ctx context.Context, taskID, reason string) bool {
+	cause := fmt.Errorf("%w: %s", supervisor.ErrTaskCancelled, reason)
+
+	p.mu.Lock()
+	if cancel, ok := p.cancels[taskID]; ok {
+		p.mu.Unlock()
+		cancel(cause)
+		return true
+	}
+
+	var cancelled *poller.Task
+	for i, queued := range p.queue {
+		if queued.task.ID == taskID {
+			cancelled = queued.task
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			delete(p.tasks, taskID)
+			break
+		}
+	}
+	p.mu.Unlock()
+
+	if cancelled == nil {
+		return false
+	}
+
+	if err := p.source.UpdateTaskStatus(ctx, cancelled, "cancelled", nil, cause.Error()); err != nil {
+		log.Error().Err(err).Str("task_id", taskID).Msg("Failed to update task status")
+	}
+	return true
+}
+
+// Lock waits until no task with the key of task is running, then holds the
+// key until unlock is called so tasks with it stay queued meanwhile. Tasks
+// without a key need no lock.
+func (p *Pool) Lock(ctx context.Context, task *poller.Task) (unlock func(), err error) {
+	key := TaskKey(task)
+	if key == "" {
+		return func() {}, nil
+	}
+
+	p.mu.Lock()
+	for p.busy[key] {
+		released := p.released
+		p.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		p.mu.Lock()
+	}
+	p.busy[key] = true
+	p.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.release(key)
+		})
+	}, nil
+}
+
+// Queued returns the number of tasks waiting for a worker
+func (p *Pool) Queued() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue)
+}
+
+// release frees a key and wakes whoever waits for one. p.mu must be held.
+func (p *Pool) release(key string) {
+	if key == "" {
+		return
+	}
+	delete(p.busy, key)
+	close(p.released)
+	p.released = make(chan struct{})
+	p.signal()
+}
+
+// signal wakes the dispatcher. p.mu must be held.
+func (p *Pool) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) dispatchLoop(ctx context.Context) {
+	for {
+		select {
+		case <-p.wake:
+			p.dispatch(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dispatch starts every queued task that has a free worker of its type and
+// whose key is free
+func (p *Pool) dispatch(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sort.SliceStable(p.queue, func(i, j int) bool {
+		if p.queue[i].task.Priority != p.queue[j].task.Priority {
+			return p.queue[i].task.Priority > p.queue[j].task.Priority
+		}
+		return p.queue[i].seq < p.queue[j].seq
+	})
+
+	// A task waits for the tasks with its key that arrived before it, even
+	// when it has a higher priority
+	first := make(map[string]uint64)
+	for _, queued := range p.queue {
+		if queued.key == "" {
+			continue
+		}
+		if seq, ok := first[queued.key]; !ok || queued.seq < seq {
+			first[queued.key] = queued.seq
+		}
+	}
+
+	remaining := p.queue[:0]
+	for _, queued := range p.queue {
+		taskType := queued.task.Type
+		blocked := queued.key != "" && (p.busy[queued.key] || first[queued.key] != queued.seq)
+		if blocked || p.running[taskType] >= p.limit(taskType) {
+			remaining = append(remaining, queued)
+			continue
+		}
+
+		p.running[taskType]++
+		if queued.key != "" {
+			p.busy[queued.key] = true
+		}
+		taskCtx, cancel := context.WithCancelCause(ctx)
+		p.cancels[queued.task.ID] = cancel
+		p.wg.Add(1)
+		go p.run(ctx, taskCtx, queued)
+	}
+	p.queue = remaining
+}
+
+// limit is the number of tasks of a type that may run at once
+func (p *Pool) limit(taskType string) int {
+	if limit, ok := p.limits[taskType]; ok {
+		return limit
+	}
+	return 1
+}
+
+// run executes a task under taskCtx, which Cancel cancels, and frees its
+// worker and key afterwards
+func (p *Pool) run(ctx, taskCtx context.Context, queued *queuedTask) {
+	defer func() {
+		p.mu.Lock()
+		p.cancels[queued.task.ID](nil)
+		delete(p.cancels, queued.task.ID)
+		p.running[queued.task.Type]--
+		p.release(queued.key)
+		delete(p.tasks, queued.task.ID)
+		p.signal()
+		p.mu.Unlock()
+		p.wg.Done()
+	}()
+
+	p.execute(ctx, taskCtx, queued.task)
+}
+
+// execute runs a task under taskCtx and reports its outcome under ctx, so a
+// cancelled task is still reported
+func (p *Pool) execute(ctx, taskCtx context.Context, task *poller.Task) {
+	log.Info().
+		Str("task_id", task.ID).
+		Str("type", task.Type).
+		Str("action", task.Action).
+		Int("priority", task.Priority).
+		Msg("Executing task")
+
+	// Update task status to running
+	if err := p.source.UpdateTaskStatus(ctx, task, "running", nil, ""); err != nil {
+		if errors.Is(err, poller.ErrLeaseLost) {
+			// Another poll owns this task now; running it here would duplicate work
+			log.Warn().Err(err).Str("task_id", task.ID).Msg("Skipping task with lost lease")
+			return
+		}
+		log.Error().Err(err).Str("task_id", task.ID).Msg("Failed to update task status")
+	}
+
+	result, err := p.executor.ExecuteTask(taskCtx, task)
+	if err != nil && !errors.Is(err, supervisor.ErrTaskCancelled) {
+		if cause := context.Cause(taskCtx); errors.Is(cause, supervisor.ErrTaskCancelled) {
+			err = cause
+		}
+	}
+	if errors.Is(err, supervisor.ErrTaskCancelled) {
+		log.Info().Err(err).Str("task_id", task.ID).Msg("Task cancelled")
+		p.source.UpdateTaskStatus(ctx, task, "cancelled", nil, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("task_id", task.ID).Msg("Task execution failed")
+		p.source.UpdateTaskStatus(ctx, task, "failed", nil, err.Error())
+		return
+	}
+
+	// Update task status to completed
+	if err := p.source.UpdateTaskStatus(ctx, task, "completed", result, ""); err != nil {
+		log.Error().Err(err).Str("task_id", task.ID).Msg("Failed to update task status")
+	}
+}
+
+// TaskKey is what tasks that must not run at once share: the collector a
+// task acts on, or starting the load simulation. A load simulation start
+// runs as long as the simulation, so stops have no key and can end it.
+// Other tasks have no key.
+func TaskKey(task *poller.Task) string {
+	switch task.Type {
+	case "collector":
+		if id, ok := task.Config["id"].(string); ok {
+			return "collector/" + id
+		}
+	case "deployment":
+		if id, ok := task.Config["deployment_id"].(string); ok {
+			return "deployment/" + id
+		}
+	case "loadsim":
+		if task.Action == "start" {
+			return "loadsim"
+		}
+	}
+	return ""
+}
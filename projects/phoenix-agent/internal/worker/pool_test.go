@@ -0,0 +1,312 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/config"
+	"github.com/phoenix/platform/projects/phoenix-agent/internal/poller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource hands out queued batches of tasks and records status updates
+type fakeSource struct {
+	mu       sync.Mutex
+	batches  [][]*poller.Task
+	polls    int32
+	statuses map[string][]string
+}
+
+func (s *fakeSource) GetTasks(ctx context.Context) ([]*poller.Task, error) {
+	atomic.AddInt32(&s.polls, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.batches) == 0 {
+		return nil, nil
+	}
+	batch := s.batches[0]
+	s.batches = s.batches[1:]
+	return batch, nil
+}
+
+func (s *fakeSource) UpdateTaskStatus(ctx context.Context, task *poller.Task, status string, result map[string]interface{}, errorMessage string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[task.ID] = append(s.statuses[task.ID], status)
+	return nil
+}
+
+func (s *fakeSource) status(taskID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statuses[taskID]...)
+}
+
+// fakeExecutor runs each task until it is released or cancelled and records
+// the order tasks started in and how many ran at once
+type fakeExecutor struct {
+	mu      sync.Mutex
+	started []string
+	release map[string]chan struct{}
+	running int
+	peak    int
+}
+
+func newFakeExecutor() *fakeExecutor {
+	return &fakeExecutor{release: make(map[string]chan struct{})}
+}
+
+func (e *fakeExecutor) gate(taskID string) chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.release[taskID]; !ok {
+		e.release[taskID] = make(chan struct{})
+	}
+	return e.release[taskID]
+}
+
+func (e *fakeExecutor) ExecuteTask(ctx context.Context, task *poller.Task) (map[string]interface{}, error) {
+	gate := e.gate(task.ID)
+
+	e.mu.Lock()
+	e.started = append(e.started, task.ID)
+	e.running++
+	if e.running > e.peak {
+		e.peak = e.running
+	}
+	e.mu.Unlock()
+
+	var err error
+	select {
+	case <-gate:
+	case <-ctx.Done():
+		err = context.Cause(ctx)
+	}
+
+	e.mu.Lock()
+	e.running--
+	e.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"status": "done"}, nil
+}
+
+func (e *fakeExecutor) finish(taskID string) {
+	close(e.gate(taskID))
+}
+
+func (e *fakeExecutor) startedTasks() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.started...)
+}
+
+func collectorTask(id, collectorID, action string, priority int) *poller.Task {
+	return &poller.Task{
+		ID:       id,
+		Type:     "collector",
+		Action:   action,
+		Priority: priority,
+		Config:   map[string]interface{}{"id": collectorID},
+	}
+}
+
+func newTestPool(t *testing.T, concurrency map[string]int) (*Pool, *fakeSource, *fakeExecutor, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := &fakeSource{statuses: make(map[string][]string)}
+	executor := newFakeExecutor()
+	pool := NewPool(&config.Config{PollInterval: 10 * time.Millisecond, TaskConcurrency: concurrency}, source, executor)
+	go pool.dispatchLoop(ctx)
+	t.Cleanup(func() {
+		cancel()
+		pool.Wait()
+	})
+	return pool, source, executor, ctx
+}
+
+func waitStarted(t *testing.T, executor *fakeExecutor, ids ...string) {
+	require.Eventually(t, func() bool {
+		started := executor.startedTasks()
+		return len(started) >= len(ids)
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, ids, executor.startedTasks())
+}
+
+func TestPool_RunsHigherPriorityFirst(t *testing.T) {
+	pool, _, executor, _ := newTestPool(t, map[string]int{"collector": 1})
+
+	pool.Submit(collectorTask("busy", "exp-1-baseline", "start", 0))
+	waitStarted(t, executor, "busy")
+
+	pool.Submit(
+		collectorTask("low", "exp-2-baseline", "start", 0),
+		collectorTask("high", "exp-3-baseline", "stop", 2),
+	)
+	executor.finish("busy")
+	waitStarted(t, executor, "busy", "high")
+
+	executor.finish("high")
+	waitStarted(t, executor, "busy", "high", "low")
+	executor.finish("low")
+}
+
+func TestPool_SerializesTasksForOneCollector(t *testing.T) {
+	pool, source, executor, _ := newTestPool(t, map[string]int{"collector": 4})
+
+	// The stop outranks the start but must not overtake it
+	pool.Submit(
+		collectorTask("start", "exp-1-candidate", "start", 1),
+		collectorTask("stop", "exp-1-candidate", "stop", 2),
+		collectorTask("other", "exp-1-baseline", "start", 1),
+	)
+	require.Eventually(t, func() bool { return len(executor.startedTasks()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.ElementsMatch(t, []string{"start", "other"}, executor.startedTasks())
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, executor.startedTasks(), 2, "stop ran while start was running")
+
+	executor.finish("start")
+	require.Eventually(t, func() bool { return len(executor.startedTasks()) == 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "stop", executor.startedTasks()[2])
+	assert.Equal(t, []string{"running", "completed"}, source.status("start"))
+
+	executor.finish("stop")
+	executor.finish("other")
+}
+
+func TestPool_LimitsConcurrencyPerType(t *testing.T) {
+	pool, _, executor, _ := newTestPool(t, map[string]int{"collector": 2})
+
+	pool.Submit(
+		collectorTask("a", "a", "start", 0),
+		collectorTask("b", "b", "start", 0),
+		collectorTask("c", "c", "start", 0),
+		&poller.Task{ID: "load", Type: "loadsim", Action: "start"},
+	)
+	require.Eventually(t, func() bool { return len(executor.startedTasks()) == 3 }, time.Second, 5*time.Millisecond)
+	assert.Contains(t, executor.startedTasks(), "load")
+	assert.Equal(t, 1, pool.Queued())
+
+	executor.finish("a")
+	require.Eventually(t, func() bool { return len(executor.startedTasks()) == 4 }, time.Second, 5*time.Millisecond)
+	for _, id := range []string{"b", "c", "load"} {
+		executor.finish(id)
+	}
+
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+	assert.Equal(t, 3, executor.peak)
+}
+
+func TestPool_CancelDropsQueuedTask(t *testing.T) {
+	pool, source, executor, ctx := newTestPool(t, map[string]int{"collector": 1})
+
+	pool.Submit(collectorTask("busy", "a", "start", 0), collectorTask("queued", "b", "start", 0))
+	waitStarted(t, executor, "busy")
+
+	assert.True(t, pool.Cancel(ctx, "queued", "experiment stopped"))
+	assert.False(t, pool.Cancel(ctx, "unknown", "experiment stopped"))
+	assert.Equal(t, []string{"cancelled"}, source.status("queued"))
+	assert.Zero(t, pool.Queued())
+
+	executor.finish("busy")
+}
+
+func TestPool_CancelStopsRunningTask(t *testing.T) {
+	pool, source, executor, ctx := newTestPool(t, map[string]int{"collector": 1})
+
+	pool.Submit(collectorTask("busy", "a", "start", 0), collectorTask("next", "a", "stop", 0))
+	waitStarted(t, executor, "busy")
+
+	assert.True(t, pool.Cancel(ctx, "busy", "experiment stopped"))
+	require.Eventually(t, func() bool {
+		return len(source.status("busy")) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"running", "cancelled"}, source.status("busy"))
+
+	// The worker and the collector key are free again
+	waitStarted(t, executor, "busy", "next")
+	executor.finish("next")
+}
+
+func TestPool_LockHoldsTaskKey(t *testing.T) {
+	pool, _, executor, ctx := newTestPool(t, nil)
+
+	pool.Submit(collectorTask("first", "a", "start", 0))
+	waitStarted(t, executor, "first")
+
+	// Lock waits for the running task with the same key
+	locked := make(chan func(), 1)
+	go func() {
+		unlock, err := pool.Lock(ctx, collectorTask("", "a", "stop", 0))
+		assert.NoError(t, err)
+		locked <- unlock
+	}()
+	select {
+	case <-locked:
+		t.Fatal("lock taken while a task with its key is running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	executor.finish("first")
+	var unlock func()
+	select {
+	case unlock = <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("lock not taken after the task with its key finished")
+	}
+
+	// Tasks with the key stay queued while it is held; others run
+	pool.Submit(collectorTask("second", "a", "stop", 0), collectorTask("other", "b", "start", 0))
+	waitStarted(t, executor, "first", "other")
+	assert.NotContains(t, executor.startedTasks(), "second")
+
+	unlock()
+	waitStarted(t, executor, "first", "other", "second")
+	executor.finish("second")
+	executor.finish("other")
+
+	// A wait whose context ends gives up
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	held, err := pool.Lock(ctx, collectorTask("", "c", "start", 0))
+	require.NoError(t, err)
+	defer held()
+	_, err = pool.Lock(waitCtx, collectorTask("", "c", "stop", 0))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestPool_PollsWhileTasksRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := &fakeSource{
+		statuses: make(map[string][]string),
+		batches:  [][]*poller.Task{{collectorTask("slow", "a", "start", 0)}, {collectorTask("later", "b", "stop", 2)}},
+	}
+	executor := newFakeExecutor()
+	pool := NewPool(&config.Config{PollInterval: 10 * time.Millisecond}, source, executor)
+	go pool.Start(ctx)
+	defer func() {
+		cancel()
+		pool.Wait()
+	}()
+
+	// The task from the second poll starts while the first is still running
+	require.Eventually(t, func() bool { return len(executor.startedTasks()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&source.polls), int32(2))
+
+	executor.finish("slow")
+	executor.finish("later")
+}
+
+func TestTaskKey(t *testing.T) {
+	assert.Equal(t, "collector/exp-1-baseline", TaskKey(collectorTask("t", "exp-1-baseline", "start", 0)))
+	assert.Equal(t, "deployment/dep-1", TaskKey(&poller.Task{Type: "deployment", Config: map[string]interface{}{"deployment_id": "dep-1"}}))
+	assert.Equal(t, "loadsim", TaskKey(&poller.Task{Type: "loadsim", Action: "start"}))
+	assert.Empty(t, TaskKey(&poller.Task{Type: "loadsim", Action: "stop"}))
+	assert.Empty(t, TaskKey(&poller.Task{Type: "command"}))
+}
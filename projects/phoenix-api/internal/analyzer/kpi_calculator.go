@@ -29,8 +29,15 @@ func NewKPICalculator(promURL string) (*KPICalculator, error) {
 	}, nil
 }
 
-// CalculateExperimentKPIs calculates all KPIs for an experiment
+// CalculateExperimentKPIs calculates all KPIs for an experiment with a
+// single candidate
 func (k *KPICalculator) CalculateExperimentKPIs(ctx context.Context, expID string, duration time.Duration) (*models.KPIResult, error) {
+	return k.CalculateVariantKPIs(ctx, expID, []string{"candidate"}, duration)
+}
+
+// CalculateVariantKPIs calculates the KPIs of every candidate variant
+// against the baseline and ranks the candidates
+func (k *KPICalculator) CalculateVariantKPIs(ctx context.Context, expID string, candidates []string, duration time.Duration) (*models.KPIResult, error) {
 	endTime := time.Now()
 	startTime := endTime.Add(-duration)
 
@@ -40,142 +47,166 @@ func (k *KPICalculator) CalculateExperimentKPIs(ctx context.Context, expID strin
 		Errors:       []string{},
 	}
 
-	// Calculate cardinality reduction
-	cardinalityReduction, err := k.calculateCardinalityReduction(ctx, expID, endTime)
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Sprintf("cardinality calculation failed: %v", err))
-	} else {
-		result.CardinalityReduction = cardinalityReduction
+	baseline := k.measureVariant(ctx, expID, models.BaselineVariant, startTime, endTime)
+
+	kpis := make([]models.VariantKPIs, 0, len(candidates))
+	for _, candidate := range candidates {
+		measured := k.measureVariant(ctx, expID, candidate, startTime, endTime)
+		kpis = append(kpis, k.compareVariants(ctx, expID, baseline, measured))
 	}
 
-	// Calculate CPU usage
-	cpuBaseline, cpuCandidate, err := k.calculateResourceUsage(ctx, expID, "cpu", startTime, endTime)
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Sprintf("CPU usage calculation failed: %v", err))
-	} else {
-		result.CPUUsage.Baseline = cpuBaseline
-		result.CPUUsage.Candidate = cpuCandidate
-		if cpuBaseline > 0 {
-			result.CPUUsage.Reduction = ((cpuBaseline - cpuCandidate) / cpuBaseline) * 100
-		}
+	RankCandidates(result, kpis)
+	return result, nil
+}
+
+// variantMeasurement holds the raw metrics of one variant. errs is keyed by
+// the KPI a failed query belongs to.
+type variantMeasurement struct {
+	variant     string
+	cardinality float64
+	cpu         float64
+	memory      float64
+	ingestRate  float64
+	keyMetrics  int
+	errs        map[string]error
+}
+
+func (k *KPICalculator) measureVariant(ctx context.Context, expID, variant string, start, end time.Time) *variantMeasurement {
+	m := &variantMeasurement{variant: variant, errs: make(map[string]error)}
+
+	var err error
+	if m.cardinality, err = k.queryCardinality(ctx, expID, variant, end); err != nil {
+		m.errs["cardinality"] = err
+	}
+	if m.cpu, err = k.queryResourceUsage(ctx, expID, "cpu", variant, start, end); err != nil {
+		m.errs["CPU usage"] = err
 	}
+	if m.memory, err = k.queryResourceUsage(ctx, expID, "memory", variant, start, end); err != nil {
+		m.errs["memory usage"] = err
+	}
+	if m.ingestRate, err = k.queryIngestRate(ctx, expID, variant, start, end); err != nil {
+		m.errs["ingest rate"] = err
+	}
+	m.keyMetrics = k.countKeyMetrics(ctx, expID, variant, end)
 
-	// Calculate memory usage
-	memBaseline, memCandidate, err := k.calculateResourceUsage(ctx, expID, "memory", startTime, endTime)
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Sprintf("memory usage calculation failed: %v", err))
-	} else {
-		result.MemoryUsage.Baseline = memBaseline
-		result.MemoryUsage.Candidate = memCandidate
-		if memBaseline > 0 {
-			result.MemoryUsage.Reduction = ((memBaseline - memCandidate) / memBaseline) * 100
-		}
+	return m
+}
+
+// compareVariants derives the KPIs of a candidate from its metrics and the
+// baseline's
+func (k *KPICalculator) compareVariants(ctx context.Context, expID string, baseline, candidate *variantMeasurement) models.VariantKPIs {
+	kpis := models.VariantKPIs{
+		Variant: candidate.variant,
+		Errors:  []string{},
 	}
 
-	// Calculate ingest rate
-	ingestBaseline, ingestCandidate, err := k.calculateIngestRate(ctx, expID, startTime, endTime)
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Sprintf("ingest rate calculation failed: %v", err))
-	} else {
-		result.IngestRate.Baseline = ingestBaseline
-		result.IngestRate.Candidate = ingestCandidate
-		if ingestBaseline > 0 {
-			result.IngestRate.Reduction = ((ingestBaseline - ingestCandidate) / ingestBaseline) * 100
+	failed := func(kpi string) bool {
+		for _, m := range []*variantMeasurement{baseline, candidate} {
+			if err, ok := m.errs[kpi]; ok {
+				kpis.Errors = append(kpis.Errors, fmt.Sprintf("%s calculation failed: %s %s query failed: %v", kpi, m.variant, kpi, err))
+				return true
+			}
 		}
+		return false
+	}
+
+	// Calculate cardinality reduction
+	if !failed("cardinality") && baseline.cardinality > 0 {
+		kpis.CardinalityReduction = ((baseline.cardinality - candidate.cardinality) / baseline.cardinality) * 100
+
+		log.Info().
+			Str("experiment_id", expID).
+			Str("variant", candidate.variant).
+			Float64("baseline", baseline.cardinality).
+			Float64("candidate", candidate.cardinality).
+			Float64("reduction", kpis.CardinalityReduction).
+			Msg("Calculated cardinality reduction")
+	}
+
+	// Calculate CPU, memory and ingest rate against the baseline
+	if !failed("CPU usage") {
+		kpis.CPUUsage = compare(baseline.cpu, candidate.cpu)
+	}
+	if !failed("memory usage") {
+		kpis.MemoryUsage = compare(baseline.memory, candidate.memory)
+	}
+	if !failed("ingest rate") {
+		kpis.IngestRate = compare(baseline.ingestRate, candidate.ingestRate)
 	}
 
 	// Calculate cost reduction based on actual metrics ingestion rates
-	if result.IngestRate.Baseline > 0 && result.IngestRate.Candidate > 0 {
+	if kpis.IngestRate.Baseline > 0 && kpis.IngestRate.Candidate > 0 {
 		// Calculate monthly costs for baseline and candidate
-		baselineMonthlyCost := k.CalculateEstimatedCost(ctx, result.IngestRate.Baseline)
-		candidateMonthlyCost := k.CalculateEstimatedCost(ctx, result.IngestRate.Candidate)
+		baselineMonthlyCost := k.CalculateEstimatedCost(ctx, kpis.IngestRate.Baseline)
+		candidateMonthlyCost := k.CalculateEstimatedCost(ctx, kpis.IngestRate.Candidate)
 
 		// Calculate cost reduction percentage
-		result.CostReduction = k.CalculateROI(baselineMonthlyCost, candidateMonthlyCost)
+		kpis.CostReduction = k.CalculateROI(baselineMonthlyCost, candidateMonthlyCost)
 
 		log.Info().
 			Str("experiment_id", expID).
+			Str("variant", candidate.variant).
 			Float64("baseline_cost", baselineMonthlyCost).
 			Float64("candidate_cost", candidateMonthlyCost).
-			Float64("cost_reduction", result.CostReduction).
+			Float64("cost_reduction", kpis.CostReduction).
 			Msg("Calculated cost metrics")
 	} else {
 		// Fallback to weighted model if ingestion rates unavailable
-		result.CostReduction = (result.CardinalityReduction * 0.7) +
-			(result.CPUUsage.Reduction * 0.2) +
-			(result.MemoryUsage.Reduction * 0.1)
+		kpis.CostReduction = (kpis.CardinalityReduction * 0.7) +
+			(kpis.CPUUsage.Reduction * 0.2) +
+			(kpis.MemoryUsage.Reduction * 0.1)
 	}
 
-	// Calculate data accuracy (simplified - check if key metrics are still present)
-	accuracy, err := k.calculateDataAccuracy(ctx, expID, endTime)
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Sprintf("accuracy calculation failed: %v", err))
+	// Calculate data accuracy (simplified - share of the baseline's key
+	// metrics still present in the candidate)
+	if baseline.keyMetrics == 0 {
+		kpis.DataAccuracy = 100 // If no baseline metrics, assume 100% accuracy
 	} else {
-		result.DataAccuracy = accuracy
+		kpis.DataAccuracy = (float64(candidate.keyMetrics) / float64(baseline.keyMetrics)) * 100
 	}
 
-	return result, nil
+	return kpis
 }
 
-func (k *KPICalculator) calculateCardinalityReduction(ctx context.Context, expID string, timestamp time.Time) (float64, error) {
-	// Use Phoenix-specific cardinality metrics
-	baselineQuery := fmt.Sprintf(`
-		phoenix_observer_kpi_store_phoenix_pipeline_output_cardinality_estimate{
-			experiment_id="%s",
-			pipeline="metrics/full_fidelity"
-		}
-	`, expID)
-
-	baselineCardinality, err := k.queryScalar(ctx, baselineQuery, timestamp)
-	if err != nil {
-		// Fallback to counting unique series
-		baselineQuery = fmt.Sprintf(`
-			count(count by (__name__)({experiment_id="%s",variant="baseline"}))
-		`, expID)
-		baselineCardinality, err = k.queryScalar(ctx, baselineQuery, timestamp)
-		if err != nil {
-			return 0, fmt.Errorf("baseline cardinality query failed: %w", err)
-		}
+func compare(baseline, candidate float64) models.KPIComparison {
+	c := models.KPIComparison{Baseline: baseline, Candidate: candidate}
+	if baseline > 0 {
+		c.Reduction = ((baseline - candidate) / baseline) * 100
 	}
+	return c
+}
 
-	// Count unique series for candidate
-	candidateQuery := fmt.Sprintf(`
-		phoenix_observer_kpi_store_phoenix_pipeline_output_cardinality_estimate{
-			experiment_id="%s",
-			pipeline="metrics/optimised"
-		}
-	`, expID)
+// observerPipelines maps the variants of a two-way experiment to the
+// pipelines the Phoenix observer estimates cardinality for
+var observerPipelines = map[string]string{
+	"baseline":  "metrics/full_fidelity",
+	"candidate": "metrics/optimised",
+}
 
-	candidateCardinality, err := k.queryScalar(ctx, candidateQuery, timestamp)
-	if err != nil {
-		// Fallback to counting unique series
-		candidateQuery = fmt.Sprintf(`
-			count(count by (__name__)({experiment_id="%s",variant="candidate"}))
-		`, expID)
-		candidateCardinality, err = k.queryScalar(ctx, candidateQuery, timestamp)
-		if err != nil {
-			return 0, fmt.Errorf("candidate cardinality query failed: %w", err)
-		}
-	}
+func (k *KPICalculator) queryCardinality(ctx context.Context, expID, variant string, timestamp time.Time) (float64, error) {
+	// Use Phoenix-specific cardinality metrics
+	if pipeline, ok := observerPipelines[variant]; ok {
+		query := fmt.Sprintf(`
+			phoenix_observer_kpi_store_phoenix_pipeline_output_cardinality_estimate{
+				experiment_id="%s",
+				pipeline="%s"
+			}
+		`, expID, pipeline)
 
-	if baselineCardinality == 0 {
-		return 0, nil
+		if cardinality, err := k.queryScalar(ctx, query, timestamp); err == nil {
+			return cardinality, nil
+		}
 	}
 
-	reduction := ((baselineCardinality - candidateCardinality) / baselineCardinality) * 100
-
-	log.Info().
-		Str("experiment_id", expID).
-		Float64("baseline", baselineCardinality).
-		Float64("candidate", candidateCardinality).
-		Float64("reduction", reduction).
-		Msg("Calculated cardinality reduction")
-
-	return reduction, nil
+	// Fallback to counting unique series
+	query := fmt.Sprintf(`
+		count(count by (__name__)({experiment_id="%s",variant="%s"}))
+	`, expID, variant)
+	return k.queryScalar(ctx, query, timestamp)
 }
 
-func (k *KPICalculator) calculateResourceUsage(ctx context.Context, expID string, resource string, start, end time.Time) (baseline, candidate float64, err error) {
-	var query string
+func (k *KPICalculator) queryResourceUsage(ctx context.Context, expID, resource, variant string, start, end time.Time) (float64, error) {
+	var query, fallback string
 
 	switch resource {
 	case "cpu":
@@ -185,6 +216,7 @@ func (k *KPICalculator) calculateResourceUsage(ctx context.Context, expID string
 			experiment_id="%s",
 			variant="%s"
 		}[5m]))`
+		fallback = `avg(agent.cpu.percent{experiment_id="%s",variant="%s"})`
 	case "memory":
 		// Use Phoenix-specific memory metrics
 		query = `avg(process_resident_memory_bytes{
@@ -192,134 +224,70 @@ func (k *KPICalculator) calculateResourceUsage(ctx context.Context, expID string
 			experiment_id="%s",
 			variant="%s"
 		})`
+		fallback = `avg(agent.memory.used_bytes{experiment_id="%s",variant="%s"})`
 	default:
-		return 0, 0, fmt.Errorf("unknown resource type: %s", resource)
+		return 0, fmt.Errorf("unknown resource type: %s", resource)
 	}
 
-	// Query baseline
-	baselineQuery := fmt.Sprintf(query, expID, "baseline")
-	baseline, err = k.queryRangeAvg(ctx, baselineQuery, start, end)
+	value, err := k.queryRangeAvg(ctx, fmt.Sprintf(query, expID, variant), start, end)
 	if err != nil {
 		// Fallback to agent metrics
-		if resource == "cpu" {
-			baselineQuery = fmt.Sprintf(`avg(agent.cpu.percent{experiment_id="%s",variant="baseline"})`, expID)
-		} else {
-			baselineQuery = fmt.Sprintf(`avg(agent.memory.used_bytes{experiment_id="%s",variant="baseline"})`, expID)
-		}
-		baseline, err = k.queryRangeAvg(ctx, baselineQuery, start, end)
-		if err != nil {
-			return 0, 0, fmt.Errorf("baseline %s query failed: %w", resource, err)
-		}
+		value, err = k.queryRangeAvg(ctx, fmt.Sprintf(fallback, expID, variant), start, end)
 	}
-
-	// Query candidate
-	candidateQuery := fmt.Sprintf(query, expID, "candidate")
-	candidate, err = k.queryRangeAvg(ctx, candidateQuery, start, end)
-	if err != nil {
-		// Fallback to agent metrics
-		if resource == "cpu" {
-			candidateQuery = fmt.Sprintf(`avg(agent.cpu.percent{experiment_id="%s",variant="candidate"})`, expID)
-		} else {
-			candidateQuery = fmt.Sprintf(`avg(agent.memory.used_bytes{experiment_id="%s",variant="candidate"})`, expID)
-		}
-		candidate, err = k.queryRangeAvg(ctx, candidateQuery, start, end)
-		if err != nil {
-			return 0, 0, fmt.Errorf("candidate %s query failed: %w", resource, err)
-		}
-	}
-
-	return baseline, candidate, nil
+	return value, err
 }
 
-func (k *KPICalculator) calculateIngestRate(ctx context.Context, expID string, start, end time.Time) (baseline, candidate float64, err error) {
-	// Use Phoenix-specific OTEL collector metrics
-	query := `sum(rate(otelcol_processor_accepted_metric_points{
-		experiment_id="%s",
-		variant="%s"
-	}[5m]))`
-
-	// Query baseline
-	baselineQuery := fmt.Sprintf(query, expID, "baseline")
-	baseline, err = k.queryRangeAvg(ctx, baselineQuery, start, end)
-	if err != nil {
+func (k *KPICalculator) queryIngestRate(ctx context.Context, expID, variant string, start, end time.Time) (float64, error) {
+	queries := []string{
+		// Use Phoenix-specific OTEL collector metrics
+		`sum(rate(otelcol_processor_accepted_metric_points{experiment_id="%s",variant="%s"}[5m]))`,
 		// Fallback to receiver metrics
-		baselineQuery = fmt.Sprintf(`sum(rate(otelcol_receiver_accepted_metric_points{experiment_id="%s",variant="baseline"}[5m]))`, expID)
-		baseline, err = k.queryRangeAvg(ctx, baselineQuery, start, end)
-		if err != nil {
-			// Final fallback to generic metrics
-			baselineQuery = fmt.Sprintf(`sum(rate(up{experiment_id="%s",variant="baseline"}[5m])) * 1000`, expID)
-			baseline, err = k.queryRangeAvg(ctx, baselineQuery, start, end)
-			if err != nil {
-				return 0, 0, fmt.Errorf("baseline ingest query failed: %w", err)
-			}
-		}
+		`sum(rate(otelcol_receiver_accepted_metric_points{experiment_id="%s",variant="%s"}[5m]))`,
+		// Final fallback to generic metrics
+		`sum(rate(up{experiment_id="%s",variant="%s"}[5m])) * 1000`,
 	}
 
-	// Query candidate
-	candidateQuery := fmt.Sprintf(query, expID, "candidate")
-	candidate, err = k.queryRangeAvg(ctx, candidateQuery, start, end)
-	if err != nil {
-		// Fallback to receiver metrics
-		candidateQuery = fmt.Sprintf(`sum(rate(otelcol_receiver_accepted_metric_points{experiment_id="%s",variant="candidate"}[5m]))`, expID)
-		candidate, err = k.queryRangeAvg(ctx, candidateQuery, start, end)
-		if err != nil {
-			// Final fallback to generic metrics
-			candidateQuery = fmt.Sprintf(`sum(rate(up{experiment_id="%s",variant="candidate"}[5m])) * 1000`, expID)
-			candidate, err = k.queryRangeAvg(ctx, candidateQuery, start, end)
-			if err != nil {
-				return 0, 0, fmt.Errorf("candidate ingest query failed: %w", err)
-			}
+	var err error
+	for _, query := range queries {
+		var value float64
+		if value, err = k.queryRangeAvg(ctx, fmt.Sprintf(query, expID, variant), start, end); err == nil {
+			return value, nil
 		}
 	}
-
-	return baseline, candidate, nil
+	return 0, err
 }
 
-func (k *KPICalculator) calculateDataAccuracy(ctx context.Context, expID string, timestamp time.Time) (float64, error) {
-	// Check if key metrics are present in both baseline and candidate
-	// These are critical business metrics that should be preserved
-	keyMetrics := []string{
-		// HTTP metrics
-		"http_server_duration_seconds",
-		"http_server_request_count_total",
-		"http_server_active_requests",
-		// System metrics
-		"process_cpu_seconds_total",
-		"process_resident_memory_bytes",
-		"go_goroutines",
-		// Business metrics
-		"phoenix_api_request_duration_seconds",
-		"phoenix_experiment_active",
-		"phoenix_pipeline_deployments_total",
-		// Agent metrics
-		"agent_cpu_percent",
-		"agent_memory_used_bytes",
-		"agent_uptime_seconds",
-	}
-
-	baselinePresent := 0
-	candidatePresent := 0
+// keyMetrics are critical business metrics that should be preserved by
+// every candidate
+var keyMetrics = []string{
+	// HTTP metrics
+	"http_server_duration_seconds",
+	"http_server_request_count_total",
+	"http_server_active_requests",
+	// System metrics
+	"process_cpu_seconds_total",
+	"process_resident_memory_bytes",
+	"go_goroutines",
+	// Business metrics
+	"phoenix_api_request_duration_seconds",
+	"phoenix_experiment_active",
+	"phoenix_pipeline_deployments_total",
+	// Agent metrics
+	"agent_cpu_percent",
+	"agent_memory_used_bytes",
+	"agent_uptime_seconds",
+}
 
+// countKeyMetrics returns how many of the key metrics a variant emits
+func (k *KPICalculator) countKeyMetrics(ctx context.Context, expID, variant string, timestamp time.Time) int {
+	present := 0
 	for _, metric := range keyMetrics {
-		// Check baseline
-		baselineQuery := fmt.Sprintf(`count(%s{experiment_id="%s",variant="baseline"})`, metric, expID)
-		if val, err := k.queryScalar(ctx, baselineQuery, timestamp); err == nil && val > 0 {
-			baselinePresent++
-		}
-
-		// Check candidate
-		candidateQuery := fmt.Sprintf(`count(%s{experiment_id="%s",variant="candidate"})`, metric, expID)
-		if val, err := k.queryScalar(ctx, candidateQuery, timestamp); err == nil && val > 0 {
-			candidatePresent++
+		query := fmt.Sprintf(`count(%s{experiment_id="%s",variant="%s"})`, metric, expID, variant)
+		if val, err := k.queryScalar(ctx, query, timestamp); err == nil && val > 0 {
+			present++
 		}
 	}
-
-	if baselinePresent == 0 {
-		return 100, nil // If no baseline metrics, assume 100% accuracy
-	}
-
-	accuracy := (float64(candidatePresent) / float64(baselinePresent)) * 100
-	return accuracy, nil
+	return present
 }
 
 func (k *KPICalculator) queryScalar(ctx context.Context, query string, timestamp time.Time) (float64, error) {
@@ -419,8 +387,9 @@ func (k *KPICalculator) CalculateROI(baselineCost, optimizedCost float64) float6
 	return roi
 }
 
-// GetAdditionalMetrics fetches additional performance metrics
-func (k *KPICalculator) GetAdditionalMetrics(ctx context.Context, expID string, duration time.Duration) map[string]float64 {
+// GetAdditionalMetrics fetches additional performance metrics, including
+// the error rate of each of the given variants
+func (k *KPICalculator) GetAdditionalMetrics(ctx context.Context, expID string, variants []string, duration time.Duration) map[string]float64 {
 	metrics := make(map[string]float64)
 	endTime := time.Now()
 	startTime := endTime.Add(-duration)
@@ -442,6 +411,16 @@ func (k *KPICalculator) GetAdditionalMetrics(ctx context.Context, expID string,
 		metrics["error_rate"] = val * 100 // Convert to percentage
 	}
 
+	// Error rate per variant, so candidates can be compared to the baseline
+	for _, variant := range variants {
+		variantErrorQuery := fmt.Sprintf(`sum(rate(otelcol_processor_refused_metric_points{experiment_id="%s",variant="%s"}[5m])) /
+		(sum(rate(otelcol_receiver_accepted_metric_points{experiment_id="%s",variant="%s"}[5m])) + 0.1)`, expID, variant, expID, variant)
+
+		if val, err := k.queryRangeAvg(ctx, variantErrorQuery, startTime, endTime); err == nil {
+			metrics[variant+"_error_rate"] = val * 100
+		}
+	}
+
 	// Pipeline efficiency
 	efficiencyQuery := fmt.Sprintf(`
 		(sum(rate(otelcol_processor_accepted_metric_points{experiment_id="%s"}[5m])) /
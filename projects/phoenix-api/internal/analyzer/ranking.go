@@ -0,0 +1,47 @@
+package analyzer
+
+import (
+	"sort"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+// minRankedDataAccuracy is the data accuracy below which a candidate ranks
+// behind every candidate that reaches it
+const minRankedDataAccuracy = 98.0
+
+// RankCandidates orders the candidates of result best first and copies the
+// KPIs of the best one into the result's top-level fields. A candidate that
+// loses too much data ranks behind those that do not; otherwise the larger
+// cost reduction wins, then the larger cardinality reduction.
+func RankCandidates(result *models.KPIResult, candidates []models.VariantKPIs) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if accurateA, accurateB := a.DataAccuracy >= minRankedDataAccuracy, b.DataAccuracy >= minRankedDataAccuracy; accurateA != accurateB {
+			return accurateA
+		}
+		if a.CostReduction != b.CostReduction {
+			return a.CostReduction > b.CostReduction
+		}
+		return a.CardinalityReduction > b.CardinalityReduction
+	})
+
+	for i := range candidates {
+		candidates[i].Rank = i + 1
+	}
+	result.Candidates = candidates
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	best := candidates[0]
+	result.BestCandidate = best.Variant
+	result.CardinalityReduction = best.CardinalityReduction
+	result.CostReduction = best.CostReduction
+	result.CPUUsage = best.CPUUsage
+	result.MemoryUsage = best.MemoryUsage
+	result.IngestRate = best.IngestRate
+	result.DataAccuracy = best.DataAccuracy
+	result.Errors = append(result.Errors, best.Errors...)
+}
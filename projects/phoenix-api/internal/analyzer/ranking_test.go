@@ -0,0 +1,32 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func TestRankCandidates(t *testing.T) {
+	result := &models.KPIResult{Errors: []string{}}
+	candidates := []models.VariantKPIs{
+		{Variant: "lossy", CostReduction: 80, DataAccuracy: 90},
+		{Variant: "topk", CostReduction: 40, CardinalityReduction: 50, DataAccuracy: 99},
+		{Variant: "sampled", CostReduction: 40, CardinalityReduction: 60, DataAccuracy: 100, Errors: []string{"partial"}},
+	}
+
+	RankCandidates(result, candidates)
+
+	want := []string{"sampled", "topk", "lossy"}
+	for i, variant := range want {
+		if result.Candidates[i].Variant != variant || result.Candidates[i].Rank != i+1 {
+			t.Fatalf("rank %d: expected %s, got %+v", i+1, variant, result.Candidates[i])
+		}
+	}
+
+	if result.BestCandidate != "sampled" || result.CardinalityReduction != 60 || result.DataAccuracy != 100 {
+		t.Errorf("expected the best candidate's KPIs on the result, got %+v", result)
+	}
+	if len(result.Errors) != 1 {
+		t.Errorf("expected the best candidate's errors on the result, got %v", result.Errors)
+	}
+}
@@ -0,0 +1,479 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/controller"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/store"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// GET /api/v1/agent/tasks - Long polling endpoint for agents to get tasks
+func (s *Server) handleAgentGetTasks(w http.ResponseWriter, r *http.Request) {
+	hostID := r.Context().Value("hostID").(string)
+
+	// Long polling with 30s timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	// Claim pending tasks for this host; they come back assigned and leased
+	tasks, err := s.taskQueue.ClaimTasks(ctx, hostID)
+	if err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to get tasks")
+		respondError(w, http.StatusInternalServerError, "Failed to get tasks")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tasks)
+}
+
+// POST /api/v1/agent/tasks/{taskId}/status - Update task status
+func (s *Server) handleTaskStatusUpdate(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskId")
+	hostID := r.Context().Value("hostID").(string)
+
+	var update struct {
+		Status       string                 `json:"status"`
+		LeaseToken   string                 `json:"lease_token"`
+		Result       map[string]interface{} `json:"result,omitempty"`
+		ErrorMessage string                 `json:"error_message,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate task belongs to this host
+	task, err := s.taskQueue.GetTask(r.Context(), taskID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	if task.HostID != hostID {
+		respondError(w, http.StatusForbidden, "Task does not belong to this host")
+		return
+	}
+
+	// Update task status under the agent's lease
+	err = s.taskQueue.ReportTaskStatus(r.Context(), taskID, update.LeaseToken, update.Status, update.Result, update.ErrorMessage)
+	if errors.Is(err, store.ErrTaskLeaseExpired) || errors.Is(err, store.ErrTaskLeaseMismatch) || errors.Is(err, store.ErrTaskCancelled) || errors.Is(err, store.ErrTaskFinished) {
+		log.Warn().Err(err).Str("task", taskID).Str("host", hostID).Msg("Rejected task status update")
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("task", taskID).Msg("Failed to update task status")
+		respondError(w, http.StatusInternalServerError, "Failed to update task status")
+		return
+	}
+
+	// Broadcast update via WebSocket
+	data, _ := json.Marshal(map[string]interface{}{
+		"task_id": taskID,
+		"host_id": hostID,
+		"status":  update.Status,
+	})
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "task_update",
+		Data: data,
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/v1/agent/heartbeat - Agent heartbeat. The reply lists the tasks
+// the agent should abort.
+func (s *Server) handleAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
+	hostID := r.Context().Value("hostID").(string)
+
+	var heartbeat models.AgentHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	heartbeat.HostID = hostID
+	heartbeat.LastHeartbeat = time.Now()
+
+	// Update agent status; the reported status is replaced by the agent's
+	// liveness state
+	if err := s.liveness.RecordHeartbeat(r.Context(), &heartbeat); err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to update agent status")
+		respondError(w, http.StatusInternalServerError, "Failed to update agent status")
+		return
+	}
+
+	// Broadcast status update
+	data, _ := json.Marshal(heartbeat)
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "agent_heartbeat",
+		Data: data,
+	}
+
+	// A live agent keeps the leases on the tasks it is working on
+	if err := s.taskQueue.RenewLeases(r.Context(), hostID); err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to renew task leases")
+	}
+
+	// Hand over cancellation requests for tasks the agent is running
+	cancellations, err := s.taskQueue.GetTaskCancellations(r.Context(), hostID)
+	if err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to get task cancellations")
+		cancellations = []models.TaskCancellation{}
+	}
+
+	respondJSON(w, http.StatusOK, models.HeartbeatResponse{CancelTasks: cancellations})
+}
+
+// POST /api/v1/agent/metrics - Push metrics from agent
+func (s *Server) handleAgentMetrics(w http.ResponseWriter, r *http.Request) {
+	hostID := r.Context().Value("hostID").(string)
+
+	var metrics struct {
+		Timestamp time.Time                `json:"timestamp"`
+		Metrics   []map[string]interface{} `json:"metrics"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&metrics); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Store metrics in cache for faster queries
+	for _, metric := range metrics.Metrics {
+		if err := s.store.CacheMetric(r.Context(), hostID, metric); err != nil {
+			log.Error().Err(err).Str("host", hostID).Msg("Failed to cache metric")
+		}
+	}
+
+	// Also forward to Pushgateway if configured
+	if s.config.Features.UsePushgateway {
+		// TODO: Implement Pushgateway client
+		log.Debug().Str("host", hostID).Int("count", len(metrics.Metrics)).Msg("Would forward metrics to Pushgateway")
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// POST /api/v1/agent/collectors/events - Report a collector crash, restart
+// or crash loop
+func (s *Server) handleCollectorEvent(w http.ResponseWriter, r *http.Request) {
+	hostID := r.Context().Value("hostID").(string)
+
+	var event models.CollectorEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if event.CollectorID == "" {
+		respondError(w, http.StatusBadRequest, "collector_id is required")
+		return
+	}
+
+	if err := s.liveness.RecordCollectorEvent(r.Context(), hostID, &event); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Broadcast collector event
+	data, _ := json.Marshal(map[string]interface{}{
+		"host_id": hostID,
+		"event":   event,
+	})
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "collector_event",
+		Data: data,
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// maxAgentLogBody bounds the body of a shipped log batch
+const maxAgentLogBody = 16 << 20
+
+// POST /api/v1/agent/logs - Ship collector output and agent log lines. A
+// bounded window of lines is kept per host and collector.
+func (s *Server) handleAgentLogs(w http.ResponseWriter, r *http.Request) {
+	hostID := r.Context().Value("hostID").(string)
+
+	var batch controller.AgentLogBatch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAgentLogBody)).Decode(&batch); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	entries, err := controller.PrepareAgentLogs(hostID, batch, time.Now())
+	if err != nil {
+		respondError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if batch.Dropped > 0 {
+		log.Warn().Str("host", hostID).Int("dropped", batch.Dropped).Msg("Agent dropped log lines")
+	}
+
+	if err := s.store.AppendAgentLogs(r.Context(), entries, s.config.AgentLogs.MaxLinesPerCollector); err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to store agent logs")
+		respondError(w, http.StatusInternalServerError, "Failed to store logs")
+		return
+	}
+
+	// Broadcast logs via WebSocket for real-time monitoring
+	data, _ := json.Marshal(map[string]interface{}{
+		"host_id": hostID,
+		"task_id": batch.TaskID,
+		"logs":    entries,
+	})
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "agent_logs",
+		Data: data,
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GET /api/v1/fleet/agents/{hostId}/logs - The latest collector output and
+// agent log lines shipped by an agent, oldest first
+func (s *Server) handleListAgentLogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := models.AgentLogQuery{
+		HostID:      chi.URLParam(r, "hostId"),
+		CollectorID: query.Get("collector_id"),
+		TaskID:      query.Get("task_id"),
+		Limit:       500,
+	}
+
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 5000 {
+			respondError(w, http.StatusBadRequest, "Invalid limit, expected 1 to 5000")
+			return
+		}
+		q.Limit = parsed
+	}
+
+	levels, err := controller.AgentLogLevelsFrom(query.Get("level"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q.Levels = levels
+
+	if value := query.Get("after_id"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			respondError(w, http.StatusBadRequest, "Invalid after_id")
+			return
+		}
+		q.AfterID = parsed
+	}
+
+	// since is a timestamp or how far back to go
+	if value := query.Get("since"); value != "" {
+		if since, err := time.Parse(time.RFC3339, value); err == nil {
+			q.Since = since
+		} else if ago, err := time.ParseDuration(value); err == nil && ago > 0 {
+			q.Since = time.Now().Add(-ago)
+		} else {
+			respondError(w, http.StatusBadRequest, "Invalid since, expected an RFC 3339 time or a duration")
+			return
+		}
+	}
+
+	entries, err := s.store.ListAgentLogs(r.Context(), q)
+	if err != nil {
+		log.Error().Err(err).Str("host", q.HostID).Msg("Failed to list agent logs")
+		respondError(w, http.StatusInternalServerError, "Failed to list agent logs")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// PUT /api/v1/fleet/agents/{hostId}/labels - Replace the labels assigned to
+// an agent. Assigned labels override labels the agent reports itself.
+func (s *Server) handleSetAgentLabels(w http.ResponseWriter, r *http.Request) {
+	hostID := chi.URLParam(r, "hostId")
+
+	var req struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := controller.ValidateLabels(req.Labels); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := s.store.SetAgentLabels(r.Context(), hostID, req.Labels)
+	if errors.Is(err, store.ErrAgentNotFound) {
+		respondError(w, http.StatusNotFound, "Agent not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to set agent labels")
+		respondError(w, http.StatusInternalServerError, "Failed to set agent labels")
+		return
+	}
+
+	agent, err := s.store.GetAgent(r.Context(), hostID)
+	if err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to get agent")
+		respondError(w, http.StatusInternalServerError, "Failed to get agent")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, agent)
+}
+
+// GET /api/v1/fleet/agents/{hostId}/events - List the latest liveness
+// events of an agent
+func (s *Server) handleListAgentEvents(w http.ResponseWriter, r *http.Request) {
+	hostID := chi.URLParam(r, "hostId")
+
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			respondError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	events, err := s.store.ListAgentEvents(r.Context(), hostID, limit)
+	if err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to list agent events")
+		respondError(w, http.StatusInternalServerError, "Failed to list agent events")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, events)
+}
+
+// POST /api/v1/fleet/agents/{hostId}/diagnostics - Ask an agent to run one
+// of the built-in diagnostics. The result lands on the returned task.
+func (s *Server) handleRunAgentDiagnostic(w http.ResponseWriter, r *http.Request) {
+	hostID := chi.URLParam(r, "hostId")
+
+	var req controller.DiagnosticRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	task, err := controller.DiagnosticTask(hostID, req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err = s.store.GetAgent(r.Context(), hostID)
+	if errors.Is(err, store.ErrAgentNotFound) {
+		respondError(w, http.StatusNotFound, "Agent not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to get agent")
+		respondError(w, http.StatusInternalServerError, "Failed to get agent")
+		return
+	}
+
+	if err := s.taskQueue.Enqueue(r.Context(), task); err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to enqueue diagnostic task")
+		respondError(w, http.StatusInternalServerError, "Failed to enqueue diagnostic")
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, task)
+}
+
+// GET /api/v1/fleet/agents/{hostId}/diagnostics/{taskId} - The status and
+// result of a diagnostic
+func (s *Server) handleGetAgentDiagnostic(w http.ResponseWriter, r *http.Request) {
+	hostID := chi.URLParam(r, "hostId")
+	taskID := chi.URLParam(r, "taskId")
+
+	task, err := s.taskQueue.GetTask(r.Context(), taskID)
+	if errors.Is(err, store.ErrTaskNotFound) {
+		respondError(w, http.StatusNotFound, "Diagnostic not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("task_id", taskID).Msg("Failed to get diagnostic task")
+		respondError(w, http.StatusInternalServerError, "Failed to get diagnostic")
+		return
+	}
+	if task.HostID != hostID || task.Type != "command" {
+		respondError(w, http.StatusNotFound, "Diagnostic not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, task)
+}
+
+// GET /api/v1/agent/desired-state - What the agent's host should be running
+func (s *Server) handleAgentDesiredState(w http.ResponseWriter, r *http.Request) {
+	hostID := r.Context().Value("hostID").(string)
+	s.respondDesiredState(w, r, hostID)
+}
+
+// GET /api/v1/fleet/agents/{hostId}/desired-state - What a host should be
+// running
+func (s *Server) handleGetHostDesiredState(w http.ResponseWriter, r *http.Request) {
+	s.respondDesiredState(w, r, chi.URLParam(r, "hostId"))
+}
+
+func (s *Server) respondDesiredState(w http.ResponseWriter, r *http.Request, hostID string) {
+	state, err := s.desiredStates.For(r.Context(), hostID)
+	if err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to build desired state")
+		respondError(w, http.StatusInternalServerError, "Failed to build desired state")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, state)
+}
+
+// POST /api/v1/agent/desired-state/report - Report drift from the desired
+// state
+func (s *Server) handleDriftReport(w http.ResponseWriter, r *http.Request) {
+	hostID := r.Context().Value("hostID").(string)
+
+	var report models.DriftReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := s.desiredStates.RecordReport(r.Context(), hostID, &report); err != nil {
+		log.Error().Err(err).Str("host", hostID).Msg("Failed to record drift report")
+		respondError(w, http.StatusInternalServerError, "Failed to record drift report")
+		return
+	}
+
+	// Broadcast drift report
+	data, _ := json.Marshal(map[string]interface{}{
+		"host_id": hostID,
+		"report":  report,
+	})
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "agent_drift",
+		Data: data,
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
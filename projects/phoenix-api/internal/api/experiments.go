@@ -0,0 +1,632 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/controller"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/store"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// experimentRequest is the body of the create and check-overlap endpoints.
+// It takes either a full config or the CLI's pipeline and node fields.
+type experimentRequest struct {
+	Name               string                     `json:"name"`
+	Description        string                     `json:"description"`
+	Config             models.ExperimentConfig    `json:"config"`
+	Namespace          string                     `json:"namespace"`
+	BaselinePipeline   string                     `json:"baseline_pipeline"`
+	CandidatePipeline  string                     `json:"candidate_pipeline"`
+	CandidatePipelines []string                   `json:"candidate_pipelines"`
+	TargetNodes        map[string]string          `json:"target_nodes"`
+	Selector           string                     `json:"selector"`
+	SampleSize         int                        `json:"sample_size"`
+	SamplePercent      float64                    `json:"sample_percent"`
+	Rollout            *models.RolloutPlan        `json:"rollout"`
+	Schedule           *models.ExperimentSchedule `json:"schedule"`
+	Parameters         map[string]interface{}     `json:"parameters"`
+}
+
+// buildExperiment validates a create request and builds the experiment and
+// schedule it describes. A preview, as used by the overlap check, does not
+// require a name.
+func buildExperiment(req *experimentRequest, preview bool) (*models.Experiment, *models.ExperimentSchedule, error) {
+	// Validate request
+	if req.Name == "" && !preview {
+		return nil, nil, errors.New("Name is required")
+	}
+
+	// A/B/n experiments name one variant per candidate pipeline
+	if req.CandidatePipeline == "" && len(req.CandidatePipelines) > 0 {
+		req.CandidatePipeline = req.CandidatePipelines[0]
+	}
+
+	// Handle CLI-style request format
+	if req.BaselinePipeline != "" && req.CandidatePipeline != "" {
+		// Convert CLI format to API format
+		req.Config.BaselineTemplate = models.PipelineTemplate{
+			Name: req.BaselinePipeline,
+			URL:  fmt.Sprintf("/api/v1/pipelines/templates/%s", req.BaselinePipeline),
+		}
+		req.Config.CandidateTemplate = models.PipelineTemplate{
+			Name: req.CandidatePipeline,
+			URL:  fmt.Sprintf("/api/v1/pipelines/templates/%s", req.CandidatePipeline),
+		}
+		if len(req.CandidatePipelines) > 1 {
+			req.Config.Variants = nil
+			for _, pipeline := range req.CandidatePipelines {
+				req.Config.Variants = append(req.Config.Variants, models.ExperimentVariant{
+					Name: pipeline,
+					Template: models.PipelineTemplate{
+						Name: pipeline,
+						URL:  fmt.Sprintf("/api/v1/pipelines/templates/%s", pipeline),
+					},
+				})
+			}
+		}
+		
+		// Convert target nodes to target hosts
+		if len(req.TargetNodes) > 0 {
+			for _, host := range req.TargetNodes {
+				req.Config.TargetHosts = append(req.Config.TargetHosts, host)
+			}
+		}
+
+		if req.Selector != "" {
+			req.Config.HostSelector = &models.HostSelector{
+				Selector:      req.Selector,
+				SampleSize:    req.SampleSize,
+				SamplePercent: req.SamplePercent,
+			}
+		}
+
+		if req.Rollout != nil {
+			req.Config.Rollout = req.Rollout
+		}
+	}
+
+	if req.Config.HostSelector != nil {
+		if len(req.Config.TargetHosts) > 0 {
+			return nil, nil, errors.New("Target hosts and a host selector cannot both be given")
+		}
+		if err := controller.ValidateHostSelector(req.Config.HostSelector); err != nil {
+			return nil, nil, err
+		}
+		// Hosts are picked when the experiment starts
+		req.Config.HostSelector.ResolvedAt = nil
+		req.Config.HostSelector.MatchedHosts = 0
+	} else if len(req.Config.TargetHosts) == 0 {
+		return nil, nil, errors.New("At least one target host or a host selector is required")
+	}
+
+	if len(req.Config.Variants) > 0 {
+		if err := controller.ValidateVariants(req.Config.Variants); err != nil {
+			return nil, nil, err
+		}
+		// The first candidate stands in wherever a single candidate is shown
+		if req.Config.CandidateTemplate.Name == "" && req.Config.CandidateTemplate.URL == "" {
+			req.Config.CandidateTemplate = req.Config.Variants[0].Template
+		}
+	}
+
+	if err := controller.ValidateGuardrails(req.Config.Guardrails); err != nil {
+		return nil, nil, err
+	}
+
+	if req.Config.Rollout != nil {
+		if err := controller.ValidateRolloutPlan(req.Config.Rollout); err != nil {
+			return nil, nil, err
+		}
+		// Progress is tracked by the controller once the experiment starts
+		req.Config.Rollout.CurrentWave = 0
+		req.Config.Rollout.State = ""
+		req.Config.Rollout.PausedReason = ""
+	}
+
+	var schedule *models.ExperimentSchedule
+	if req.Schedule != nil {
+		if err := controller.ValidateSchedule(req.Schedule); err != nil {
+			return nil, nil, err
+		}
+		schedule = &models.ExperimentSchedule{
+			StartAt:  req.Schedule.StartAt,
+			Cron:     req.Schedule.Cron,
+			Timezone: req.Schedule.Timezone,
+			Enabled:  true,
+		}
+		next, err := controller.NextScheduleRun(schedule, time.Now())
+		if err != nil || next == nil {
+			return nil, nil, errors.New("schedule: never starts a run")
+		}
+		schedule.NextRunAt = next
+	}
+
+	// Deployment mode will be managed at the pipeline level
+
+	// Create experiment
+	exp := &models.Experiment{
+		Name:        req.Name,
+		Description: req.Description,
+		Phase:       "created",
+		Config:      req.Config,
+		Status:      models.ExperimentStatus{},
+		Metadata: map[string]interface{}{
+			"namespace": req.Namespace,
+		},
+	}
+
+	// Add parameters to metadata (including NRDOT parameters)
+	if req.Parameters != nil {
+		for key, value := range req.Parameters {
+			exp.Metadata[key] = value
+		}
+		
+		// Also add parameters to pipeline template variables for template rendering
+		if exp.Config.BaselineTemplate.Variables == nil {
+			exp.Config.BaselineTemplate.Variables = make(map[string]string)
+		}
+		if exp.Config.CandidateTemplate.Variables == nil {
+			exp.Config.CandidateTemplate.Variables = make(map[string]string)
+		}
+		
+		// Convert parameters to string values for template variables
+		for key, value := range req.Parameters {
+			strValue := fmt.Sprintf("%v", value)
+			exp.Config.BaselineTemplate.Variables[key] = strValue
+			exp.Config.CandidateTemplate.Variables[key] = strValue
+		}
+	}
+
+	if req.Namespace == "" {
+		req.Namespace = "default" // Use default namespace if not specified
+		exp.Metadata["namespace"] = req.Namespace
+	}
+
+	// A scheduled experiment is never started itself; each run is a copy
+	if schedule != nil {
+		exp.Phase = "scheduled"
+	}
+
+	return exp, schedule, nil
+}
+
+// POST /api/v1/experiments - Create a new experiment
+func (s *Server) handleCreateExperiment(w http.ResponseWriter, r *http.Request) {
+	var req experimentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	exp, schedule, err := buildExperiment(&req, false)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// A scheduled experiment is checked when its runs start
+	if schedule == nil {
+		overlap, err := s.expController.CheckOverlap(r.Context(), exp)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to check experiment overlap")
+			respondError(w, http.StatusInternalServerError, "Failed to check experiment overlap")
+			return
+		}
+		if overlap.Severity == models.OverlapSeverityBlocking {
+			respondError(w, http.StatusConflict, overlapMessage(overlap))
+			return
+		}
+	}
+
+	if err := s.store.CreateExperiment(r.Context(), exp); err != nil {
+		log.Error().Err(err).Msg("Failed to create experiment")
+		respondError(w, http.StatusInternalServerError, "Failed to create experiment")
+		return
+	}
+
+	if schedule != nil {
+		schedule.ExperimentID = exp.ID
+		if err := s.store.CreateExperimentSchedule(r.Context(), schedule); err != nil {
+			log.Error().Err(err).Str("experiment_id", exp.ID).Msg("Failed to create experiment schedule")
+			respondError(w, http.StatusInternalServerError, "Failed to create experiment schedule")
+			return
+		}
+		exp.Schedule = schedule
+	}
+
+	// Broadcast creation event
+	expData, _ := json.Marshal(exp)
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "experiment_created",
+		Data: json.RawMessage(expData),
+	}
+
+	respondJSON(w, http.StatusCreated, exp)
+}
+
+// POST /api/v1/experiments/check-overlap - Check an experiment for overlaps
+// with other experiments, pipeline deployments and load simulations
+func (s *Server) handleCheckExperimentOverlap(w http.ResponseWriter, r *http.Request) {
+	var req experimentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	exp, _, err := buildExperiment(&req, true)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := s.expController.CheckOverlap(r.Context(), exp)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to check experiment overlap")
+		respondError(w, http.StatusInternalServerError, "Failed to check experiment overlap")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// overlapMessage describes a blocking overlap along with the experiments
+// it is with
+func overlapMessage(overlap *models.OverlapResult) string {
+	if len(overlap.ConflictingExpIDs) == 0 {
+		return overlap.Message
+	}
+	return fmt.Sprintf("%s (conflicting experiments: %s)", overlap.Message, strings.Join(overlap.ConflictingExpIDs, ", "))
+}
+
+// GET /api/v1/experiments - List experiments
+func (s *Server) handleListExperiments(w http.ResponseWriter, r *http.Request) {
+	experiments, err := s.store.ListExperiments(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list experiments")
+		respondError(w, http.StatusInternalServerError, "Failed to list experiments")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, experiments)
+}
+
+// GET /api/v1/experiments/{id} - Get experiment details
+func (s *Server) handleGetExperiment(w http.ResponseWriter, r *http.Request) {
+	expID := chi.URLParam(r, "id")
+
+	exp, err := s.store.GetExperiment(r.Context(), expID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Experiment not found")
+		return
+	}
+
+	if err := s.expController.PopulateStatus(r.Context(), exp); err != nil {
+		log.Error().Err(err).Str("experiment_id", expID).Msg("Failed to get experiment host status")
+	}
+
+	schedule, err := s.store.GetExperimentSchedule(r.Context(), expID)
+	switch {
+	case err == nil:
+		exp.Schedule = schedule
+	case !errors.Is(err, store.ErrScheduleNotFound):
+		log.Error().Err(err).Str("experiment_id", expID).Msg("Failed to get experiment schedule")
+	}
+
+	respondJSON(w, http.StatusOK, exp)
+}
+
+// PUT /api/v1/experiments/{id}/phase - Update experiment phase
+func (s *Server) handleUpdateExperimentPhase(w http.ResponseWriter, r *http.Request) {
+	expID := chi.URLParam(r, "id")
+
+	var req struct {
+		Phase string `json:"phase"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := s.store.UpdateExperimentPhase(r.Context(), expID, req.Phase); err != nil {
+		log.Error().Err(err).Msg("Failed to update experiment phase")
+		respondError(w, http.StatusInternalServerError, "Failed to update experiment phase")
+		return
+	}
+
+	// Broadcast phase update
+	phaseData, _ := json.Marshal(map[string]string{
+		"experiment_id": expID,
+		"phase":         req.Phase,
+	})
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "experiment_phase_updated",
+		Data: json.RawMessage(phaseData),
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/v1/experiments/{id}/start - Start an experiment
+func (s *Server) handleStartExperiment(w http.ResponseWriter, r *http.Request) {
+	expID := chi.URLParam(r, "id")
+
+	exp, err := s.store.GetExperiment(r.Context(), expID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Experiment not found")
+		return
+	}
+
+	if exp.Phase == "scheduled" {
+		respondError(w, http.StatusConflict, "Scheduled experiments are started by their schedule")
+		return
+	}
+
+	// Start experiment using agent architecture
+	if err := s.expController.StartExperiment(r.Context(), exp); err != nil {
+		if errors.Is(err, store.ErrHostLocked) {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		log.Error().Err(err).Str("experiment_id", expID).Msg("Failed to start experiment")
+		respondError(w, http.StatusInternalServerError, "Failed to start experiment")
+		return
+	}
+
+	// Broadcast experiment started event
+	startData, _ := json.Marshal(map[string]interface{}{
+		"experiment_id": expID,
+		"name":          exp.Name,
+		"phase":         exp.Phase,
+		"config":        exp.Config,
+	})
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "experiment_started",
+		Data: startData,
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// POST /api/v1/experiments/{id}/rollout/resume - Continue a paused rollout
+func (s *Server) handleResumeRollout(w http.ResponseWriter, r *http.Request) {
+	s.handleRolloutAction(w, r, "resume", s.expController.ResumeRollout, "experiment_rollout_resumed")
+}
+
+// POST /api/v1/experiments/{id}/rollout/abort - Abort an unfinished rollout
+func (s *Server) handleAbortRollout(w http.ResponseWriter, r *http.Request) {
+	s.handleRolloutAction(w, r, "abort", s.expController.AbortRollout, "experiment_rollout_aborted")
+}
+
+func (s *Server) handleRolloutAction(w http.ResponseWriter, r *http.Request, action string,
+	apply func(ctx context.Context, experimentID string) error, messageType websocket.MessageType) {
+	expID := chi.URLParam(r, "id")
+
+	if _, err := s.store.GetExperiment(r.Context(), expID); err != nil {
+		respondError(w, http.StatusNotFound, "Experiment not found")
+		return
+	}
+
+	err := apply(r.Context(), expID)
+	switch {
+	case errors.Is(err, controller.ErrNoRollout),
+		errors.Is(err, controller.ErrRolloutNotPaused),
+		errors.Is(err, controller.ErrRolloutNotActive):
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		log.Error().Err(err).Str("experiment_id", expID).Str("action", action).Msg("Failed to update rollout")
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to %s rollout", action))
+		return
+	}
+
+	exp, err := s.store.GetExperiment(r.Context(), expID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get experiment")
+		return
+	}
+
+	rolloutData, _ := json.Marshal(map[string]interface{}{
+		"experiment_id": expID,
+		"action":        action,
+		"phase":         exp.Phase,
+		"rollout":       exp.Config.Rollout,
+	})
+	s.hub.Broadcast <- &websocket.Message{
+		Type: messageType,
+		Data: rolloutData,
+	}
+
+	respondJSON(w, http.StatusOK, exp)
+}
+
+// GET /api/v1/experiments/{id}/runs - List the runs started by an experiment's schedule
+func (s *Server) handleListScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	expID := chi.URLParam(r, "id")
+
+	runs, err := s.store.ListScheduleRuns(r.Context(), expID)
+	if err != nil {
+		log.Error().Err(err).Str("experiment_id", expID).Msg("Failed to list schedule runs")
+		respondError(w, http.StatusInternalServerError, "Failed to list schedule runs")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, runs)
+}
+
+// DELETE /api/v1/experiments/{id}/schedule - Stop an experiment's schedule from starting runs
+func (s *Server) handleDisableSchedule(w http.ResponseWriter, r *http.Request) {
+	expID := chi.URLParam(r, "id")
+
+	err := s.store.DisableExperimentSchedule(r.Context(), expID)
+	switch {
+	case errors.Is(err, store.ErrScheduleNotFound):
+		respondError(w, http.StatusNotFound, "Experiment schedule not found")
+		return
+	case err != nil:
+		log.Error().Err(err).Str("experiment_id", expID).Msg("Failed to disable experiment schedule")
+		respondError(w, http.StatusInternalServerError, "Failed to disable experiment schedule")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/v1/experiments/{id}/transitions - List scheduled phase transitions
+func (s *Server) handleListExperimentTransitions(w http.ResponseWriter, r *http.Request) {
+	expID := chi.URLParam(r, "id")
+
+	transitions, err := s.store.ListTransitions(r.Context(), expID)
+	if err != nil {
+		log.Error().Err(err).Str("experiment_id", expID).Msg("Failed to list scheduled transitions")
+		respondError(w, http.StatusInternalServerError, "Failed to list scheduled transitions")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, transitions)
+}
+
+// POST /api/v1/experiments/{id}/stop - Stop an experiment
+func (s *Server) handleStopExperiment(w http.ResponseWriter, r *http.Request) {
+	expID := chi.URLParam(r, "id")
+
+	if err := s.expController.StopExperiment(r.Context(), expID); err != nil {
+		log.Error().Err(err).Str("experiment_id", expID).Msg("Failed to stop experiment")
+		respondError(w, http.StatusInternalServerError, "Failed to stop experiment")
+		return
+	}
+
+	// Broadcast experiment stopped event
+	stopData, _ := json.Marshal(map[string]interface{}{
+		"experiment_id": expID,
+		"reason":        "user_requested",
+	})
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "experiment_stopped",
+		Data: stopData,
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GET /api/v1/experiments/{id}/metrics - Get experiment metrics
+func (s *Server) handleGetExperimentMetrics(w http.ResponseWriter, r *http.Request) {
+	expID := chi.URLParam(r, "id")
+
+	// Get experiment to check if it exists
+	_, err := s.store.GetExperiment(r.Context(), expID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Experiment not found")
+		return
+	}
+
+	// Get metrics from store
+	metrics, err := s.store.GetExperimentMetrics(r.Context(), expID)
+	if err != nil {
+		log.Error().Err(err).Str("experiment_id", expID).Msg("Failed to get experiment metrics")
+		respondError(w, http.StatusInternalServerError, "Failed to get metrics")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, metrics)
+}
+
+// GET /api/v1/experiments/{id}/metrics - Get experiment metrics (old implementation)
+func (s *Server) handleGetExperimentMetrics_old(w http.ResponseWriter, r *http.Request) {
+	expID := chi.URLParam(r, "id")
+
+	// Get experiment to check if it exists
+	exp, err := s.store.GetExperiment(r.Context(), expID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Experiment not found")
+		return
+	}
+
+	// Build metrics response structure that matches CLI expectations
+	metrics := map[string]interface{}{
+		"experiment_id": expID,
+		"timestamp":     time.Now(),
+		"summary": map[string]interface{}{
+			"total_metrics":         0,
+			"metrics_per_second":    0,
+			"cardinality_reduction": 0,
+			"cpu_usage":             0,
+			"memory_usage":          0,
+		},
+		"baseline": map[string]interface{}{
+			"cardinality":     []interface{}{},
+			"cpu_usage":       []interface{}{},
+			"memory_usage":    []interface{}{},
+			"network_traffic": []interface{}{},
+		},
+		"candidate": map[string]interface{}{
+			"cardinality":     []interface{}{},
+			"cpu_usage":       []interface{}{},
+			"memory_usage":    []interface{}{},
+			"network_traffic": []interface{}{},
+		},
+	}
+
+	// If experiment has KPIs in status, add them to summary
+	if exp.Status.KPIs != nil && len(exp.Status.KPIs) > 0 {
+		if summary, ok := metrics["summary"].(map[string]interface{}); ok {
+			// Extract KPI values if they exist
+			if cardReduction, ok := exp.Status.KPIs["cardinality_reduction"]; ok {
+				summary["cardinality_reduction"] = cardReduction
+			}
+			if cpuUsage, ok := exp.Status.KPIs["cpu_usage"]; ok {
+				summary["cpu_usage"] = cpuUsage
+			}
+			if memUsage, ok := exp.Status.KPIs["memory_usage"]; ok {
+				summary["memory_usage"] = memUsage
+			}
+			if totalMetrics, ok := exp.Status.KPIs["total_metrics"]; ok {
+				summary["total_metrics"] = totalMetrics
+			}
+			if metricsPerSec, ok := exp.Status.KPIs["metrics_per_second"]; ok {
+				summary["metrics_per_second"] = metricsPerSec
+			}
+		}
+	}
+
+	// TODO: In the future, integrate with MetricsCollector service to get time-series data
+	// For now, return empty time series arrays which the CLI can handle
+
+	respondJSON(w, http.StatusOK, metrics)
+}
+
+// POST /api/v1/experiments/{id}/promote - Promote experiment to production
+func (s *Server) handlePromoteExperiment(w http.ResponseWriter, r *http.Request) {
+	expID := chi.URLParam(r, "id")
+
+	// The variant may be left out when the experiment has one candidate
+	var req struct {
+		Variant string `json:"variant"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := s.expController.PromoteExperiment(r.Context(), expID, req.Variant); err != nil {
+		if errors.Is(err, controller.ErrUnknownVariant) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Error().Err(err).Str("experiment_id", expID).Msg("Failed to promote experiment")
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// IdempotencyKeyTTL is how long the response to a request sent with an
+// Idempotency-Key header is kept for replay
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength matches the idempotency_keys.key column
+const maxIdempotencyKeyLength = 255
+
+// idempotencyMiddleware makes requests carrying an Idempotency-Key header
+// safe to retry. The first request with a key runs normally and its response
+// is stored; later requests with the same key get that response replayed
+// without running the handler again. Server errors are not stored, so the
+// request can be retried with the same key.
+func (s *Server) idempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			respondError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		record, reserved, err := s.store.ReserveIdempotencyKey(r.Context(), key, r.Method, r.URL.Path)
+		if err != nil {
+			log.Error().Err(err).Str("idempotency_key", key).Msg("Failed to reserve idempotency key")
+			respondError(w, http.StatusInternalServerError, "Failed to process Idempotency-Key")
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.Method != r.Method || record.Path != r.URL.Path:
+				respondError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+			case record.CompletedAt == nil:
+				respondError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+			default:
+				if record.ContentType != "" {
+					w.Header().Set("Content-Type", record.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.StatusCode)
+				w.Write(record.ResponseBody)
+			}
+			return
+		}
+
+		// The outcome is recorded even when the client has gone away, since
+		// that is exactly when it will retry
+		saveCtx := context.WithoutCancel(r.Context())
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			if p := recover(); p != nil {
+				s.releaseIdempotencyKey(saveCtx, key)
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status >= http.StatusInternalServerError {
+			s.releaseIdempotencyKey(saveCtx, key)
+			return
+		}
+
+		contentType := recorder.Header().Get("Content-Type")
+		if err := s.store.CompleteIdempotencyKey(saveCtx, key, recorder.status, contentType, recorder.body.Bytes()); err != nil {
+			log.Error().Err(err).Str("idempotency_key", key).Msg("Failed to store idempotent response")
+		}
+	})
+}
+
+func (s *Server) releaseIdempotencyKey(ctx context.Context, key string) {
+	if err := s.store.ReleaseIdempotencyKey(ctx, key); err != nil {
+		log.Error().Err(err).Str("idempotency_key", key).Msg("Failed to release idempotency key")
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of its
+// status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/store"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/tasks"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// GET /api/v1/tasks/dead-letter - List tasks whose retry policy gave up
+func (s *Server) handleListDeadLetterTasks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filters := map[string]interface{}{
+		"host_id":       query.Get("host_id"),
+		"experiment_id": query.Get("experiment_id"),
+		"type":          query.Get("type"),
+		"limit":         100,
+	}
+	if l := query.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil {
+			filters["limit"] = parsed
+		}
+	}
+
+	deadLetter, err := s.taskQueue.ListDeadLetterTasks(r.Context(), filters)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list dead-letter tasks")
+		respondError(w, http.StatusInternalServerError, "Failed to list dead-letter tasks")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, deadLetter)
+}
+
+// POST /api/v1/tasks/dead-letter/{id}/requeue - Retry a dead-lettered task
+func (s *Server) handleRequeueDeadLetterTask(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+
+	task, err := s.taskQueue.RequeueDeadLetterTask(r.Context(), taskID)
+	if err != nil {
+		respondDeadLetterError(w, taskID, err)
+		return
+	}
+
+	data, _ := json.Marshal(map[string]string{
+		"task_id":     taskID,
+		"new_task_id": task.ID,
+	})
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "task_requeued",
+		Data: data,
+	}
+
+	respondJSON(w, http.StatusCreated, task)
+}
+
+// DELETE /api/v1/tasks/dead-letter/{id} - Discard a dead-lettered task
+func (s *Server) handleDiscardDeadLetterTask(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+
+	if err := s.taskQueue.DiscardDeadLetterTask(r.Context(), taskID); err != nil {
+		respondDeadLetterError(w, taskID, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/v1/tasks/{id}/cancel - Cancel a queued task, or ask the agent
+// running it to abort
+func (s *Server) handleCancelTask(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "cancelled by user"
+	}
+
+	task, err := s.taskQueue.CancelTask(r.Context(), taskID, reason)
+	switch {
+	case errors.Is(err, store.ErrTaskNotFound):
+		respondError(w, http.StatusNotFound, "Task not found")
+		return
+	case errors.Is(err, tasks.ErrTaskFinished):
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		log.Error().Err(err).Str("task_id", taskID).Msg("Failed to cancel task")
+		respondError(w, http.StatusInternalServerError, "Failed to cancel task")
+		return
+	}
+
+	data, _ := json.Marshal(map[string]string{
+		"task_id": taskID,
+		"status":  task.Status,
+		"reason":  reason,
+	})
+	s.hub.Broadcast <- &websocket.Message{
+		Type: "task_cancel_requested",
+		Data: data,
+	}
+
+	// A running task is only cancelled once its agent has aborted it
+	status := http.StatusOK
+	if task.Status != "cancelled" {
+		status = http.StatusAccepted
+	}
+	respondJSON(w, status, task)
+}
+
+func respondDeadLetterError(w http.ResponseWriter, taskID string, err error) {
+	switch {
+	case errors.Is(err, store.ErrTaskNotFound):
+		respondError(w, http.StatusNotFound, "Task not found")
+	case errors.Is(err, tasks.ErrNotDeadLettered):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		log.Error().Err(err).Str("task_id", taskID).Msg("Dead-letter operation failed")
+		respondError(w, http.StatusInternalServerError, "Failed to update task")
+	}
+}
@@ -18,10 +18,19 @@ type Config struct {
 	Features       Features
 	CostRates      CostRates
 	Timeouts       Timeouts
+	Liveness       Liveness
+	// ConfigSigningKey is the base64 Ed25519 key the config digests of
+	// collector tasks are signed with; empty leaves them unsigned
+	ConfigSigningKey string
+	// AgentLogs bounds the logs agents ship
+	AgentLogs AgentLogs
 }
 
 type Features struct {
 	UsePushgateway bool
+	// TaskNotifications enables Postgres LISTEN/NOTIFY wakeups for agent
+	// long-polls; when disabled or unavailable they poll the store instead
+	TaskNotifications bool
 }
 
 type CostRates struct {
@@ -35,6 +44,28 @@ type Timeouts struct {
 	AgentPollTimeout  time.Duration
 	TaskAssignTimeout time.Duration
 	HeartbeatInterval time.Duration
+	// ExperimentDeployTimeout is how long a host may take to bring up its
+	// experiment collectors before it is reported as failed
+	ExperimentDeployTimeout time.Duration
+}
+
+// Liveness configures when agents without heartbeats are unreachable or
+// offline, and what happens to the tasks of offline agents
+type Liveness struct {
+	AgentUnreachableAfter time.Duration
+	AgentOfflineAfter     time.Duration
+	// OfflineTaskPolicy is "fail" to dead-letter the unfinished tasks of an
+	// offline agent or "hold" to keep them until it is back
+	OfflineTaskPolicy string
+}
+
+// AgentLogs bounds the collector and agent log lines kept for each host
+type AgentLogs struct {
+	// MaxLinesPerCollector is how many of the latest lines are kept for
+	// each collector of a host, and for the agent's own lines
+	MaxLinesPerCollector int
+	// Retention is how long lines are kept at most
+	Retention time.Duration
 }
 
 func Load() *Config {
@@ -61,8 +92,11 @@ func Load() *Config {
 		PushgatewayURL: getEnv("PUSHGATEWAY_URL", "http://localhost:9091"),
 		JWTSecret:      jwtSecret,
 		Environment:    env,
+
+		ConfigSigningKey: getEnv("CONFIG_SIGNING_KEY", ""),
 		Features: Features{
-			UsePushgateway: getEnvBool("USE_PUSHGATEWAY", false),
+			UsePushgateway:    getEnvBool("USE_PUSHGATEWAY", false),
+			TaskNotifications: getEnvBool("TASK_NOTIFICATIONS", true),
 		},
 		CostRates: CostRates{
 			MetricsIngestionPerMillion: getEnvFloat("COST_METRICS_PER_MILLION", 50.0),
@@ -71,9 +105,19 @@ func Load() *Config {
 			MemoryCostPerGB:            getEnvFloat("COST_MEMORY_PER_GB", 20.0),
 		},
 		Timeouts: Timeouts{
-			AgentPollTimeout:  getEnvDuration("AGENT_POLL_TIMEOUT", 30*time.Second),
-			TaskAssignTimeout: getEnvDuration("TASK_ASSIGN_TIMEOUT", 5*time.Minute),
-			HeartbeatInterval: getEnvDuration("HEARTBEAT_INTERVAL", 1*time.Minute),
+			AgentPollTimeout:        getEnvDuration("AGENT_POLL_TIMEOUT", 30*time.Second),
+			TaskAssignTimeout:       getEnvDuration("TASK_ASSIGN_TIMEOUT", 5*time.Minute),
+			HeartbeatInterval:       getEnvDuration("HEARTBEAT_INTERVAL", 1*time.Minute),
+			ExperimentDeployTimeout: getEnvDuration("EXPERIMENT_DEPLOY_TIMEOUT", 10*time.Minute),
+		},
+		Liveness: Liveness{
+			AgentUnreachableAfter: getEnvDuration("AGENT_UNREACHABLE_AFTER", 2*time.Minute),
+			AgentOfflineAfter:     getEnvDuration("AGENT_OFFLINE_AFTER", 5*time.Minute),
+			OfflineTaskPolicy:     getEnv("AGENT_OFFLINE_TASK_POLICY", "fail"),
+		},
+		AgentLogs: AgentLogs{
+			MaxLinesPerCollector: getEnvPositiveInt("AGENT_LOG_MAX_LINES", 10000),
+			Retention:            getEnvPositiveDuration("AGENT_LOG_RETENTION", 24*time.Hour),
 		},
 	}
 }
@@ -141,3 +185,30 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvPositiveInt is getEnvInt for settings where zero or a negative value
+// makes no sense; those fall back to the default
+func getEnvPositiveInt(key string, defaultValue int) int {
+	if value := getEnvInt(key, defaultValue); value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvPositiveDuration is getEnvDuration for settings where zero or a
+// negative value makes no sense; those fall back to the default
+func getEnvPositiveDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := getEnvDuration(key, defaultValue); value > 0 {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoad_AgentLogBounds(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxLines      string
+		retention     string
+		wantMaxLines  int
+		wantRetention time.Duration
+	}{
+		{"defaults", "", "", 10000, 24 * time.Hour},
+		{"explicit", "500", "2h", 500, 2 * time.Hour},
+		{"zero", "0", "0s", 10000, 24 * time.Hour},
+		{"negative", "-5", "-1h", 10000, 24 * time.Hour},
+		{"unparsable", "many", "forever", 10000, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AGENT_LOG_MAX_LINES", tt.maxLines)
+			t.Setenv("AGENT_LOG_RETENTION", tt.retention)
+
+			logs := Load().AgentLogs
+			if logs.MaxLinesPerCollector != tt.wantMaxLines {
+				t.Errorf("MaxLinesPerCollector = %d, want %d", logs.MaxLinesPerCollector, tt.wantMaxLines)
+			}
+			if logs.Retention != tt.wantRetention {
+				t.Errorf("Retention = %v, want %v", logs.Retention, tt.wantRetention)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	commonModels "github.com/phoenix/platform/pkg/common/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/store"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/tasks"
+	"github.com/rs/zerolog/log"
+)
+
+// What happens to the unfinished tasks of an agent that goes offline
+const (
+	// OfflineTasksFail dead-letters the tasks so they can be requeued once
+	// the agent is back
+	OfflineTasksFail = "fail"
+	// OfflineTasksHold leaves the tasks for the agent to pick up when it
+	// comes back
+	OfflineTasksHold = "hold"
+)
+
+const (
+	// livenessInterval is how often heartbeat ages are checked
+	livenessInterval = 15 * time.Second
+
+	// Resource usage at which a heartbeating agent is degraded
+	degradedCPUPercent    = 90.0
+	degradedMemoryPercent = 90.0
+	degradedDiskPercent   = 95.0
+)
+
+// LivenessPolicy configures when agents are unreachable or offline and what
+// happens to the tasks of offline agents
+type LivenessPolicy struct {
+	// UnreachableAfter is the heartbeat age at which an agent is unreachable
+	UnreachableAfter time.Duration
+	// OfflineAfter is the heartbeat age at which an agent is offline
+	OfflineAfter time.Duration
+	// OfflineTasks is OfflineTasksFail or OfflineTasksHold
+	OfflineTasks string
+}
+
+// LivenessMonitor derives agent states from heartbeats. A heartbeat makes an
+// agent healthy, or degraded when it reports high resource usage; missing
+// heartbeats make it unreachable and then offline. Every state change is
+// recorded as an agent event. When an agent goes offline its unfinished
+// tasks are failed or held by policy, and the experiments and deployments
+// running on its host are flagged.
+type LivenessMonitor struct {
+	store     store.Store
+	taskQueue *tasks.Queue
+	policy    LivenessPolicy
+}
+
+// NewLivenessMonitor creates a liveness monitor applying policy
+func NewLivenessMonitor(store store.Store, taskQueue *tasks.Queue, policy LivenessPolicy) *LivenessMonitor {
+	return &LivenessMonitor{
+		store:     store,
+		taskQueue: taskQueue,
+		policy:    policy,
+	}
+}
+
+// heartbeatState returns the state of an agent that just sent a heartbeat,
+// and why it is degraded
+func heartbeatState(heartbeat *models.AgentHeartbeat) (string, string) {
+	usage := heartbeat.ResourceUsage
+	var reasons []string
+	if usage.CPUPercent >= degradedCPUPercent {
+		reasons = append(reasons, fmt.Sprintf("CPU at %.0f%%", usage.CPUPercent))
+	}
+	if usage.MemoryPercent >= degradedMemoryPercent {
+		reasons = append(reasons, fmt.Sprintf("memory at %.0f%%", usage.MemoryPercent))
+	}
+	if usage.DiskPercent >= degradedDiskPercent {
+		reasons = append(reasons, fmt.Sprintf("disk at %.0f%%", usage.DiskPercent))
+	}
+	// Agents may report themselves degraded or unhealthy
+	if heartbeat.Status == models.AgentDegraded || heartbeat.Status == "unhealthy" {
+		reasons = append(reasons, fmt.Sprintf("agent reports %s", heartbeat.Status))
+	}
+
+	if len(reasons) > 0 {
+		return models.AgentDegraded, strings.Join(reasons, ", ")
+	}
+	return models.AgentHealthy, ""
+}
+
+// silenceState returns the state an agent falls to after not sending a
+// heartbeat for age, or "" while its last heartbeat is recent enough
+func (m *LivenessMonitor) silenceState(age time.Duration) string {
+	switch {
+	case age >= m.policy.OfflineAfter:
+		return models.AgentOffline
+	case age >= m.policy.UnreachableAfter:
+		return models.AgentUnreachable
+	}
+	return ""
+}
+
+// RecordHeartbeat stores a heartbeat with the state it puts the agent in
+func (m *LivenessMonitor) RecordHeartbeat(ctx context.Context, heartbeat *models.AgentHeartbeat) error {
+	previous := ""
+	if agent, err := m.store.GetAgent(ctx, heartbeat.HostID); err == nil {
+		previous = agent.Status
+	}
+
+	state, reason := heartbeatState(heartbeat)
+	heartbeat.Status = state
+	if err := m.store.UpdateAgentHeartbeat(ctx, heartbeat); err != nil {
+		return err
+	}
+
+	// A restarted agent reports the collectors it took over
+	if len(heartbeat.RecoveredCollectors) > 0 {
+		m.reconcileRecovered(ctx, heartbeat.HostID, heartbeat.RecoveredCollectors)
+	}
+
+	if previous == "" || previous == state {
+		return nil
+	}
+
+	message := fmt.Sprintf("Agent is %s", state)
+	if reason != "" {
+		message = fmt.Sprintf("Agent is %s: %s", state, reason)
+	}
+	m.recordEvent(ctx, heartbeat.HostID, previous, state, message, nil)
+
+	// Flagged experiments learn that their host is back
+	if previous == models.AgentOffline {
+		m.flagExperiments(ctx, heartbeat.HostID, "host_recovered",
+			fmt.Sprintf("Host %s is back online", heartbeat.HostID), nil)
+	}
+
+	return nil
+}
+
+// Check moves agents whose heartbeats stopped to unreachable or offline
+func (m *LivenessMonitor) Check(ctx context.Context) {
+	agents, err := m.store.ListAgents(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list agents for liveness check")
+		return
+	}
+
+	now := time.Now()
+	for _, agent := range agents {
+		state := m.silenceState(now.Sub(agent.LastHeartbeat))
+		if state == "" || state == agent.Status {
+			continue
+		}
+		// An agent offline already does not become unreachable again
+		if agent.Status == models.AgentOffline {
+			continue
+		}
+
+		changed, err := m.store.SetAgentState(ctx, agent.HostID, agent.Status, state, agent.LastHeartbeat.Add(time.Nanosecond))
+		if err != nil {
+			log.Error().Err(err).Str("host_id", agent.HostID).Msg("Failed to set agent state")
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		m.recordEvent(ctx, agent.HostID, agent.Status, state,
+			fmt.Sprintf("No heartbeat for %s", now.Sub(agent.LastHeartbeat).Round(time.Second)),
+			map[string]interface{}{"last_heartbeat": agent.LastHeartbeat})
+
+		if state == models.AgentOffline {
+			m.handleOffline(ctx, agent.HostID)
+		}
+	}
+}
+
+// handleOffline applies the task policy to an agent that went offline and
+// flags the experiments and deployments on its host
+func (m *LivenessMonitor) handleOffline(ctx context.Context, hostID string) {
+	logger := log.With().Str("host_id", hostID).Logger()
+	logger.Warn().Str("task_policy", m.policy.OfflineTasks).Msg("Agent went offline")
+
+	metadata := map[string]interface{}{
+		"host_id":     hostID,
+		"task_policy": m.policy.OfflineTasks,
+	}
+
+	message := fmt.Sprintf("Host %s went offline; its tasks are held until it is back", hostID)
+	if m.policy.OfflineTasks != OfflineTasksHold {
+		failed, err := m.taskQueue.FailHostTasks(ctx, hostID, fmt.Sprintf("agent on %s went offline", hostID))
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to fail tasks of offline agent")
+		}
+		ids := make([]string, 0, len(failed))
+		for _, task := range failed {
+			ids = append(ids, task.ID)
+		}
+		metadata["failed_tasks"] = ids
+		message = fmt.Sprintf("Host %s went offline; %d of its tasks failed", hostID, len(failed))
+	}
+
+	m.flagExperiments(ctx, hostID, "host_offline", message, metadata)
+	m.flagDeployments(ctx, hostID)
+}
+
+// flagExperiments records an event on every active experiment running on
+// the host
+func (m *LivenessMonitor) flagExperiments(ctx context.Context, hostID, eventType, message string, metadata map[string]interface{}) {
+	experiments, err := m.store.ListExperiments(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("host_id", hostID).Msg("Failed to list experiments of offline host")
+		return
+	}
+
+	for _, exp := range experiments {
+		if !activePhases[exp.Phase] || !containsHost(deployedHosts(exp), hostID) {
+			continue
+		}
+
+		event := &models.ExperimentEvent{
+			ExperimentID: exp.ID,
+			EventType:    eventType,
+			Phase:        exp.Phase,
+			Message:      message,
+			Metadata:     metadata,
+		}
+		if event.Metadata == nil {
+			event.Metadata = map[string]interface{}{"host_id": hostID}
+		}
+		if err := m.store.CreateExperimentEvent(ctx, event); err != nil {
+			log.Error().Err(err).Str("experiment_id", exp.ID).Msg("Failed to create experiment event")
+		}
+	}
+}
+
+// flagDeployments marks the active pipeline deployments on the host as
+// degraded
+func (m *LivenessMonitor) flagDeployments(ctx context.Context, hostID string) {
+	deployments, _, err := m.store.ListDeployments(ctx, &commonModels.ListDeploymentsRequest{PageSize: 1000})
+	if err != nil {
+		log.Error().Err(err).Str("host_id", hostID).Msg("Failed to list deployments of offline host")
+		return
+	}
+
+	for _, deployment := range deployments {
+		if !deploymentActive(deployment) || !targetsNode(deployment, hostID) {
+			continue
+		}
+
+		update := &commonModels.UpdateDeploymentRequest{
+			Status:        commonModels.DeploymentStatusDegraded,
+			StatusMessage: fmt.Sprintf("host %s is offline", hostID),
+			UpdatedBy:     "liveness-monitor",
+		}
+		if err := m.store.UpdateDeployment(ctx, deployment.ID, update); err != nil {
+			log.Error().Err(err).Str("deployment_id", deployment.ID).Msg("Failed to flag deployment of offline host")
+		}
+	}
+}
+
+func (m *LivenessMonitor) recordEvent(ctx context.Context, hostID, from, to, message string, metadata map[string]interface{}) {
+	event := &models.AgentEvent{
+		HostID:    hostID,
+		EventType: "state_changed",
+		FromState: from,
+		ToState:   to,
+		Message:   message,
+		Metadata:  metadata,
+	}
+	if event.Metadata == nil {
+		event.Metadata = map[string]interface{}{}
+	}
+
+	if err := m.store.CreateAgentEvent(ctx, event); err != nil {
+		log.Error().Err(err).Str("host_id", hostID).Msg("Failed to create agent event")
+	}
+}
+
+func containsHost(hosts []string, hostID string) bool {
+	for _, host := range hosts {
+		if host == hostID {
+			return true
+		}
+	}
+	return false
+}
+
+func targetsNode(deployment *commonModels.PipelineDeployment, hostID string) bool {
+	for _, node := range deployment.TargetNodes {
+		if node == hostID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func TestHeartbeatState(t *testing.T) {
+	tests := []struct {
+		name      string
+		heartbeat models.AgentHeartbeat
+		want      string
+		reason    string
+	}{
+		{
+			name:      "healthy",
+			heartbeat: models.AgentHeartbeat{Status: "healthy", ResourceUsage: models.ResourceUsage{CPUPercent: 40, MemoryPercent: 60}},
+			want:      models.AgentHealthy,
+		},
+		{
+			name:      "high usage",
+			heartbeat: models.AgentHeartbeat{Status: "healthy", ResourceUsage: models.ResourceUsage{CPUPercent: 95, DiskPercent: 97}},
+			want:      models.AgentDegraded,
+			reason:    "CPU at 95%, disk at 97%",
+		},
+		{
+			name:      "reported unhealthy",
+			heartbeat: models.AgentHeartbeat{Status: "unhealthy"},
+			want:      models.AgentDegraded,
+			reason:    "agent reports unhealthy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, reason := heartbeatState(&tt.heartbeat)
+			if state != tt.want || reason != tt.reason {
+				t.Fatalf("got %q (%q), want %q (%q)", state, reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestSilenceState(t *testing.T) {
+	m := NewLivenessMonitor(nil, nil, LivenessPolicy{
+		UnreachableAfter: 2 * time.Minute,
+		OfflineAfter:     5 * time.Minute,
+	})
+
+	for age, want := range map[time.Duration]string{
+		30 * time.Second: "",
+		2 * time.Minute:  models.AgentUnreachable,
+		4 * time.Minute:  models.AgentUnreachable,
+		5 * time.Minute:  models.AgentOffline,
+		time.Hour:        models.AgentOffline,
+	} {
+		if got := m.silenceState(age); got != want {
+			t.Errorf("%s: got %q, want %q", age, got, want)
+		}
+	}
+}
+
+func TestRecordCollectorEvent_UnknownEvent(t *testing.T) {
+	m := NewLivenessMonitor(nil, nil, LivenessPolicy{})
+
+	err := m.RecordCollectorEvent(context.Background(), "host-1", &models.CollectorEvent{CollectorID: "c1", Event: "exploded"})
+	if err == nil {
+		t.Fatal("expected an error for an unknown collector event")
+	}
+}
+
+func TestCollectorEventMessage(t *testing.T) {
+	crashed := collectorEventMessage("host-1", &models.CollectorEvent{
+		CollectorID: "exp-1-candidate", Event: CollectorCrashed, ExitCode: 2, RestartIn: "4s",
+	})
+	if want := "Collector exp-1-candidate on host-1 crashed with exit code 2; restarting in 4s"; crashed != want {
+		t.Errorf("got %q, want %q", crashed, want)
+	}
+
+	loop := collectorEventMessage("host-1", &models.CollectorEvent{
+		CollectorID: "exp-1-candidate", Event: CollectorCrashLoop, ExitCode: 1, Restarts: 5,
+	})
+	if want := "Collector exp-1-candidate on host-1 is crash looping after 5 restarts; last exit code 1"; loop != want {
+		t.Errorf("got %q, want %q", loop, want)
+	}
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+const (
+	// MaxAgentLogBatch is the most lines an agent may ship in one request
+	MaxAgentLogBatch = 1000
+	// maxAgentLogMessage is the longest line stored; longer ones are cut
+	maxAgentLogMessage = 8 << 10
+	// maxAgentLogSkew is how far in the future a line's timestamp may be
+	// before the time it was received is used instead
+	maxAgentLogSkew = time.Minute
+)
+
+// agentLogLevels are the levels of shipped log lines, lowest first
+var agentLogLevels = []string{"debug", "info", "warn", "error", "fatal", "panic"}
+
+// AgentLogBatch is a batch of log lines shipped by an agent
+type AgentLogBatch struct {
+	Logs []models.AgentLogEntry `json:"logs"`
+	// Dropped counts the lines the agent dropped since its last batch
+	Dropped int `json:"dropped"`
+	// TaskID applies to the lines that name no task of their own
+	TaskID string `json:"task_id"`
+}
+
+// PrepareAgentLogs readies a shipped batch for storage: lines are attributed
+// to the host, cut to size and given a known level, source and a plausible
+// timestamp. Lines the agent dropped are recorded as a line of their own.
+func PrepareAgentLogs(hostID string, batch AgentLogBatch, now time.Time) ([]*models.AgentLogEntry, error) {
+	if len(batch.Logs) > MaxAgentLogBatch {
+		return nil, fmt.Errorf("batch has %d log lines, at most %d are accepted", len(batch.Logs), MaxAgentLogBatch)
+	}
+
+	entries := make([]*models.AgentLogEntry, 0, len(batch.Logs)+1)
+	for i := range batch.Logs {
+		entry := batch.Logs[i]
+		entry.ID = 0
+		entry.HostID = hostID
+		entry.Level = normalizeLogLevel(entry.Level)
+		entry.Message = truncateLogMessage(entry.Message)
+		if entry.TaskID == "" {
+			entry.TaskID = batch.TaskID
+		}
+		if entry.Source != "collector" && entry.Source != "agent" {
+			entry.Source = "agent"
+			if entry.CollectorID != "" {
+				entry.Source = "collector"
+			}
+		}
+		if entry.Timestamp.IsZero() || entry.Timestamp.After(now.Add(maxAgentLogSkew)) {
+			entry.Timestamp = now
+		}
+		entries = append(entries, &entry)
+	}
+
+	if batch.Dropped > 0 {
+		entries = append(entries, &models.AgentLogEntry{
+			HostID:    hostID,
+			Source:    "agent",
+			Level:     "warn",
+			Message:   fmt.Sprintf("Agent dropped %d log lines", batch.Dropped),
+			Timestamp: now,
+		})
+	}
+
+	return entries, nil
+}
+
+// AgentLogLevelsFrom returns the given level and every level above it. An
+// empty level returns nil, which matches all lines.
+func AgentLogLevelsFrom(level string) ([]string, error) {
+	if level == "" {
+		return nil, nil
+	}
+
+	i := logLevelIndex(level)
+	if i < 0 {
+		return nil, fmt.Errorf("unknown log level %q, expected one of %s", level, strings.Join(agentLogLevels, ", "))
+	}
+	return agentLogLevels[i:], nil
+}
+
+// normalizeLogLevel maps a level onto the known levels; unknown levels are
+// info
+func normalizeLogLevel(level string) string {
+	if i := logLevelIndex(level); i >= 0 {
+		return agentLogLevels[i]
+	}
+	return "info"
+}
+
+func logLevelIndex(level string) int {
+	level = strings.ToLower(level)
+	if level == "warning" {
+		level = "warn"
+	}
+	for i, known := range agentLogLevels {
+		if known == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// truncateLogMessage cuts a message to maxAgentLogMessage without splitting
+// a character
+func truncateLogMessage(message string) string {
+	if len(message) <= maxAgentLogMessage {
+		return message
+	}
+	cut := maxAgentLogMessage
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut]
+}
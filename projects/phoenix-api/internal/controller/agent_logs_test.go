@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func TestPrepareAgentLogs(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	logged := now.Add(-time.Minute)
+
+	entries, err := PrepareAgentLogs("host-a", AgentLogBatch{
+		TaskID:  "task-1",
+		Dropped: 7,
+		Logs: []models.AgentLogEntry{
+			{HostID: "spoofed", CollectorID: "exp-1-candidate", Level: "ERROR", Message: "Exporting failed", Timestamp: logged},
+			{Source: "agent", TaskID: "task-2", Level: "warning", Message: strings.Repeat("x", 2*maxAgentLogMessage), Timestamp: now.Add(time.Hour)},
+			{Level: "trace", Message: "unknown level"},
+		},
+	}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 3 lines and a dropped line, got %d", len(entries))
+	}
+
+	collector := entries[0]
+	if collector.HostID != "host-a" || collector.Source != "collector" || collector.Level != "error" ||
+		collector.TaskID != "task-1" || !collector.Timestamp.Equal(logged) {
+		t.Fatalf("unexpected collector line %+v", collector)
+	}
+
+	agent := entries[1]
+	if agent.Level != "warn" || agent.TaskID != "task-2" || len(agent.Message) != maxAgentLogMessage || !agent.Timestamp.Equal(now) {
+		t.Fatalf("unexpected agent line: level %s, task %s, %d bytes at %s", agent.Level, agent.TaskID, len(agent.Message), agent.Timestamp)
+	}
+
+	if entries[2].Level != "info" || entries[2].Source != "agent" || !entries[2].Timestamp.Equal(now) {
+		t.Fatalf("unexpected line %+v", entries[2])
+	}
+
+	if entries[3].Message != "Agent dropped 7 log lines" || entries[3].Level != "warn" {
+		t.Fatalf("unexpected dropped line %+v", entries[3])
+	}
+}
+
+func TestPrepareAgentLogs_RejectsLargeBatch(t *testing.T) {
+	_, err := PrepareAgentLogs("host-a", AgentLogBatch{Logs: make([]models.AgentLogEntry, MaxAgentLogBatch+1)}, time.Now())
+	if err == nil {
+		t.Fatal("expected an oversized batch to be rejected")
+	}
+}
+
+func TestAgentLogLevelsFrom(t *testing.T) {
+	levels, err := AgentLogLevelsFrom("Warning")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(levels, ",") != "warn,error,fatal,panic" {
+		t.Fatalf("unexpected levels %v", levels)
+	}
+
+	if levels, _ := AgentLogLevelsFrom(""); levels != nil {
+		t.Fatalf("expected no level filter, got %v", levels)
+	}
+	if _, err := AgentLogLevelsFrom("loud"); err == nil {
+		t.Fatal("expected an unknown level to be rejected")
+	}
+}
+
+func TestTruncateLogMessage(t *testing.T) {
+	message := truncateLogMessage(strings.Repeat("é", maxAgentLogMessage))
+	if len(message) > maxAgentLogMessage || !strings.HasPrefix(strings.Repeat("é", maxAgentLogMessage), message) {
+		t.Fatalf("message cut inside a character: %d bytes", len(message))
+	}
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// reconcileRecovered brings the pipeline records of a restarted agent's
+// host in line with the collectors it recovered. Pipelines of lost
+// collectors fail, and collectors of experiments that ended while the
+// agent was down are stopped.
+func (m *LivenessMonitor) reconcileRecovered(ctx context.Context, hostID string, recovered []models.RecoveredCollector) {
+	counts := map[string]int{}
+	for i := range recovered {
+		collector := &recovered[i]
+		counts[collector.Recovery]++
+
+		switch {
+		case collector.ExperimentID != "":
+			m.reconcileExperimentCollector(ctx, hostID, collector)
+		case collector.DeploymentID != "" && collector.Recovery == models.RecoveryLost:
+			m.flagDeploymentCollector(ctx, hostID, &models.CollectorEvent{
+				CollectorID:  collector.CollectorID,
+				DeploymentID: collector.DeploymentID,
+			}, fmt.Sprintf("Collector %s on %s was lost when the agent restarted: %s",
+				collector.CollectorID, hostID, collector.Error))
+		}
+	}
+
+	event := &models.AgentEvent{
+		HostID:    hostID,
+		EventType: "collectors_recovered",
+		Message: fmt.Sprintf("Agent restarted: %d collectors adopted, %d restarted, %d lost",
+			counts[models.RecoveryAdopted], counts[models.RecoveryRestarted], counts[models.RecoveryLost]),
+		Metadata: map[string]interface{}{"collectors": recovered},
+	}
+	if err := m.store.CreateAgentEvent(ctx, event); err != nil {
+		log.Error().Err(err).Str("host_id", hostID).Msg("Failed to create agent event")
+	}
+}
+
+// reconcileExperimentCollector updates the pipeline record of a recovered
+// experiment collector, or stops it if its experiment is no longer running
+func (m *LivenessMonitor) reconcileExperimentCollector(ctx context.Context, hostID string, collector *models.RecoveredCollector) {
+	logger := log.With().Str("experiment_id", collector.ExperimentID).Str("host_id", hostID).Logger()
+
+	exp, err := m.store.GetExperiment(ctx, collector.ExperimentID)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get experiment of recovered collector")
+		return
+	}
+
+	if !activePhases[exp.Phase] {
+		if collector.Recovery == models.RecoveryLost {
+			return
+		}
+		task := &models.Task{
+			HostID:       hostID,
+			ExperimentID: exp.ID,
+			Type:         "collector",
+			Action:       "stop",
+			Priority:     2,
+			Config: map[string]interface{}{
+				"id":      collector.CollectorID,
+				"variant": collector.Variant,
+			},
+		}
+		if err := m.taskQueue.Enqueue(ctx, task); err != nil {
+			logger.Error().Err(err).Msg("Failed to enqueue stop task for orphaned collector")
+		}
+		return
+	}
+
+	status := "running"
+	processInfo := map[string]interface{}{
+		"pid":      collector.Pid,
+		"recovery": collector.Recovery,
+	}
+	if collector.Recovery == models.RecoveryLost {
+		status = "failed"
+		processInfo = map[string]interface{}{
+			"recovery": collector.Recovery,
+			"error":    collector.Error,
+		}
+
+		event := &models.ExperimentEvent{
+			ExperimentID: exp.ID,
+			EventType:    "collector_lost",
+			Phase:        exp.Phase,
+			Message: fmt.Sprintf("Collector %s on %s was lost when the agent restarted: %s",
+				collector.CollectorID, hostID, collector.Error),
+			Metadata: map[string]interface{}{
+				"host_id":      hostID,
+				"collector_id": collector.CollectorID,
+				"variant":      collector.Variant,
+			},
+		}
+		if err := m.store.CreateExperimentEvent(ctx, event); err != nil {
+			logger.Error().Err(err).Msg("Failed to create experiment event")
+		}
+	}
+
+	if err := m.store.UpdateActivePipelineStatus(ctx, hostID, exp.ID, collector.Variant, status, processInfo); err != nil {
+		logger.Error().Err(err).Msg("Failed to update pipeline status")
+	}
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	commonModels "github.com/phoenix/platform/pkg/common/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// Collector events reported by agents
+const (
+	CollectorCrashed   = "crashed"
+	CollectorCrashLoop = "crash_loop"
+	CollectorExited    = "exited"
+	CollectorRestarted = "restarted"
+)
+
+// collectorEventMessage describes a collector event for people
+func collectorEventMessage(hostID string, event *models.CollectorEvent) string {
+	switch event.Event {
+	case CollectorCrashed:
+		return fmt.Sprintf("Collector %s on %s crashed with exit code %d; restarting in %s",
+			event.CollectorID, hostID, event.ExitCode, event.RestartIn)
+	case CollectorCrashLoop:
+		return fmt.Sprintf("Collector %s on %s is crash looping after %d restarts; last exit code %d",
+			event.CollectorID, hostID, event.Restarts, event.ExitCode)
+	case CollectorRestarted:
+		return fmt.Sprintf("Collector %s on %s restarted (restart %d)", event.CollectorID, hostID, event.Restarts)
+	default:
+		return fmt.Sprintf("Collector %s on %s exited with code %d", event.CollectorID, hostID, event.ExitCode)
+	}
+}
+
+// RecordCollectorEvent records a collector crash or restart reported by the
+// agent on hostID. The experiment or deployment the collector runs for is
+// flagged: a crashing experiment pipeline gets an event and, once it is
+// crash looping, a failed pipeline status; a crashing deployment is marked
+// degraded.
+func (m *LivenessMonitor) RecordCollectorEvent(ctx context.Context, hostID string, event *models.CollectorEvent) error {
+	switch event.Event {
+	case CollectorCrashed, CollectorCrashLoop, CollectorExited, CollectorRestarted:
+	default:
+		return fmt.Errorf("unknown collector event %q", event.Event)
+	}
+
+	message := collectorEventMessage(hostID, event)
+	metadata := map[string]interface{}{
+		"collector_id": event.CollectorID,
+		"variant":      event.Variant,
+		"exit_code":    event.ExitCode,
+		"restarts":     event.Restarts,
+	}
+	if event.LogTail != "" {
+		metadata["log_tail"] = event.LogTail
+	}
+
+	agentEvent := &models.AgentEvent{
+		HostID:    hostID,
+		EventType: "collector_" + event.Event,
+		Message:   message,
+		Metadata:  metadata,
+	}
+	if err := m.store.CreateAgentEvent(ctx, agentEvent); err != nil {
+		log.Error().Err(err).Str("host_id", hostID).Msg("Failed to create agent event")
+	}
+
+	if event.ExperimentID != "" {
+		m.flagExperimentCollector(ctx, hostID, event, message, metadata)
+	}
+	if event.DeploymentID != "" && event.Event != CollectorRestarted {
+		m.flagDeploymentCollector(ctx, hostID, event, message)
+	}
+
+	return nil
+}
+
+// flagExperimentCollector records a collector event on its experiment and
+// keeps the pipeline status in step
+func (m *LivenessMonitor) flagExperimentCollector(ctx context.Context, hostID string, event *models.CollectorEvent, message string, metadata map[string]interface{}) {
+	logger := log.With().Str("experiment_id", event.ExperimentID).Str("host_id", hostID).Logger()
+
+	exp, err := m.store.GetExperiment(ctx, event.ExperimentID)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get experiment of collector event")
+		return
+	}
+
+	status, processInfo := "", map[string]interface{}{"restarts": event.Restarts}
+	switch event.Event {
+	case CollectorCrashLoop, CollectorExited:
+		status = "failed"
+		processInfo["last_exit_code"] = event.ExitCode
+	case CollectorRestarted:
+		status = "running"
+		processInfo["pid"] = event.Pid
+	}
+	if status != "" {
+		if err := m.store.UpdateActivePipelineStatus(ctx, hostID, exp.ID, event.Variant, status, processInfo); err != nil {
+			logger.Error().Err(err).Msg("Failed to update pipeline status")
+		}
+	}
+
+	if !activePhases[exp.Phase] {
+		return
+	}
+
+	expEvent := &models.ExperimentEvent{
+		ExperimentID: exp.ID,
+		EventType:    "collector_" + event.Event,
+		Phase:        exp.Phase,
+		Message:      message,
+		Metadata:     metadata,
+	}
+	expEvent.Metadata["host_id"] = hostID
+	if err := m.store.CreateExperimentEvent(ctx, expEvent); err != nil {
+		logger.Error().Err(err).Msg("Failed to create experiment event")
+	}
+}
+
+// flagDeploymentCollector marks the deployment of a crashing collector as
+// degraded
+func (m *LivenessMonitor) flagDeploymentCollector(ctx context.Context, hostID string, event *models.CollectorEvent, message string) {
+	deployment, err := m.store.GetDeployment(ctx, event.DeploymentID)
+	if err != nil {
+		log.Error().Err(err).Str("deployment_id", event.DeploymentID).Msg("Failed to get deployment of collector event")
+		return
+	}
+	if !deploymentActive(deployment) {
+		return
+	}
+
+	update := &commonModels.UpdateDeploymentRequest{
+		Status:        commonModels.DeploymentStatusDegraded,
+		StatusMessage: message,
+		UpdatedBy:     "liveness-monitor",
+	}
+	if err := m.store.UpdateDeployment(ctx, deployment.ID, update); err != nil {
+		log.Error().Err(err).Str("deployment_id", deployment.ID).Msg("Failed to flag deployment of crashing collector")
+	}
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2024-01-10 is a Wednesday
+	from := time.Date(2024, 1, 10, 12, 30, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 1, 10, 12, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, 1, 11, 3, 0, 0, 0, time.UTC)},
+		{"0 2 * * mon", time.Date(2024, 1, 15, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * 7", time.Date(2024, 1, 14, 2, 0, 0, 0, time.UTC)},
+		{"30 1 1 feb,mar *", time.Date(2024, 2, 1, 1, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2024, 1, 10, 13, 0, 0, 0, time.UTC)},
+		// Day of month and weekday both restricted: either matches
+		{"0 0 20 * fri", time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+	} {
+		cron, err := parseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.expr, err)
+		}
+		if got := cron.next(from); !got.Equal(tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestCronNext_Never(t *testing.T) {
+	cron, err := parseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cron.next(time.Now()); !got.IsZero() {
+		t.Fatalf("expected no run on 30 February, got %v", got)
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	commonModels "github.com/phoenix/platform/pkg/common/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/store"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/tasks"
+	"github.com/rs/zerolog/log"
+)
+
+// desiredPhases are the phases in which all collectors of an experiment
+// should run on its deployed hosts. In rollback only the baseline should.
+var desiredPhases = map[string]bool{
+	"deploying":  true,
+	"running":    true,
+	"monitoring": true,
+	"paused":     true,
+}
+
+// DesiredStates derives the desired state of a host from the experiments
+// and pipeline deployments running on it, and records the drift agents
+// report against it
+type DesiredStates struct {
+	store          store.Store
+	pushgatewayURL string
+	signer         *tasks.ConfigSigner
+}
+
+// NewDesiredStates creates a desired state source. Deployment collectors
+// push their metrics to pushgatewayURL.
+func NewDesiredStates(store store.Store, pushgatewayURL string) *DesiredStates {
+	return &DesiredStates{
+		store:          store,
+		pushgatewayURL: pushgatewayURL,
+	}
+}
+
+// SetConfigSigner makes desired collectors carry a signature of their
+// config digest
+func (d *DesiredStates) SetConfigSigner(signer *tasks.ConfigSigner) {
+	d.signer = signer
+}
+
+// CollectorConfigHash identifies the config of an experiment collector task,
+// leaving out any config_hash already in it
+func CollectorConfigHash(config map[string]interface{}) string {
+	hashed := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		if key != "config_hash" {
+			hashed[key] = value
+		}
+	}
+
+	// Maps marshal with sorted keys, so equal configs hash alike
+	data, err := json.Marshal(hashed)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// PipelineConfigHash identifies a rendered deployment pipeline config
+func PipelineConfigHash(pipelineConfig string) string {
+	sum := sha256.Sum256([]byte(pipelineConfig))
+	return hex.EncodeToString(sum[:])
+}
+
+// For returns the desired state of a host
+func (d *DesiredStates) For(ctx context.Context, hostID string) (*models.DesiredState, error) {
+	state := &models.DesiredState{
+		HostID:      hostID,
+		GeneratedAt: time.Now(),
+		Collectors:  []models.DesiredCollector{},
+	}
+
+	experiments, err := d.store.ListExperiments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list experiments: %w", err)
+	}
+	for _, exp := range experiments {
+		d.addExperiment(state, exp)
+	}
+
+	deployments, _, err := d.store.ListDeployments(ctx, &commonModels.ListDeploymentsRequest{PageSize: 1000})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, deployment := range deployments {
+		if !deploymentActive(deployment) || !targetsNode(deployment, hostID) {
+			continue
+		}
+		if err := d.addDeployment(ctx, state, deployment); err != nil {
+			log.Error().Err(err).Str("deployment_id", deployment.ID).Str("host_id", hostID).Msg("Failed to add deployment to desired state")
+		}
+	}
+
+	sort.Slice(state.Collectors, func(i, j int) bool {
+		return state.Collectors[i].ID < state.Collectors[j].ID
+	})
+	state.Revision = desiredRevision(state)
+	for _, collector := range state.Collectors {
+		d.signer.Sign(collector.Config)
+	}
+
+	return state, nil
+}
+
+// addExperiment adds the collectors and load simulation an experiment runs
+// on the host
+func (d *DesiredStates) addExperiment(state *models.DesiredState, exp *models.Experiment) {
+	if !desiredPhases[exp.Phase] && exp.Phase != "rollback" {
+		return
+	}
+	if !containsHost(deployedHosts(exp), state.HostID) {
+		return
+	}
+
+	for _, variant := range experimentVariants(exp) {
+		// A rolled back experiment keeps only its baseline
+		if exp.Phase == "rollback" && variant.Name != models.BaselineVariant {
+			continue
+		}
+
+		config := collectorTaskConfig(exp, variant)
+		state.Collectors = append(state.Collectors, models.DesiredCollector{
+			ID:           config["id"].(string),
+			Variant:      variant.Name,
+			ExperimentID: exp.ID,
+			ConfigHash:   config["config_hash"].(string),
+			TaskType:     "collector",
+			TaskAction:   "start",
+			Config:       config,
+		})
+	}
+
+	if desiredPhases[exp.Phase] && exp.Config.LoadProfile != "" && state.LoadSimulation == nil {
+		state.LoadSimulation = &models.DesiredLoadSimulation{
+			ExperimentID: exp.ID,
+			Profile:      exp.Config.LoadProfile,
+			Duration:     exp.Config.Duration.String(),
+		}
+	}
+}
+
+// addDeployment adds the collector of a pipeline deployment, running its
+// latest recorded config
+func (d *DesiredStates) addDeployment(ctx context.Context, state *models.DesiredState, deployment *commonModels.PipelineDeployment) error {
+	versions, err := d.store.ListDeploymentVersions(ctx, deployment.ID)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 || versions[0].PipelineConfig == "" {
+		return fmt.Errorf("deployment has no rendered pipeline config")
+	}
+	pipelineConfig := versions[0].PipelineConfig
+	hash := PipelineConfigHash(pipelineConfig)
+
+	state.Collectors = append(state.Collectors, models.DesiredCollector{
+		ID:           fmt.Sprintf("dep-%s-%s", deployment.ID, state.HostID),
+		Variant:      deployment.DeploymentName,
+		DeploymentID: deployment.ID,
+		ConfigHash:   hash,
+		TaskType:     "deployment",
+		TaskAction:   "deploy",
+		Config: map[string]interface{}{
+			"deployment_id":   deployment.ID,
+			"deployment_name": deployment.DeploymentName,
+			"pipeline_name":   deployment.PipelineName,
+			"parameters":      deployment.Parameters,
+			"resources":       deployment.Resources,
+			"pipeline_config": pipelineConfig,
+			"pushgateway_url": d.pushgatewayURL,
+			"config_hash":     hash,
+			"config_sha256":   hash,
+		},
+	})
+	return nil
+}
+
+// desiredRevision hashes what a desired state asks for, so agents can tell
+// whether it changed
+func desiredRevision(state *models.DesiredState) string {
+	h := sha256.New()
+	for _, collector := range state.Collectors {
+		fmt.Fprintf(h, "%s=%s\n", collector.ID, collector.ConfigHash)
+	}
+	if sim := state.LoadSimulation; sim != nil {
+		fmt.Fprintf(h, "loadsim=%s/%s/%s\n", sim.ExperimentID, sim.Profile, sim.Duration)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// RecordReport records the drift an agent found against its desired state.
+// Reports without drift are recorded only when the host comes back in sync.
+func (d *DesiredStates) RecordReport(ctx context.Context, hostID string, report *models.DriftReport) error {
+	message := fmt.Sprintf("Host drifted from desired state revision %s in %d ways", report.Revision, len(report.Drift))
+	eventType := "drift_detected"
+	if report.InSync {
+		message = fmt.Sprintf("Host is in sync with desired state revision %s", report.Revision)
+		eventType = "drift_resolved"
+	}
+
+	event := &models.AgentEvent{
+		HostID:    hostID,
+		EventType: eventType,
+		Message:   message,
+		Metadata: map[string]interface{}{
+			"revision":   report.Revision,
+			"checked_at": report.CheckedAt,
+			"drift":      report.Drift,
+		},
+	}
+	if err := d.store.CreateAgentEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to record drift report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func desiredStateExperiment(phase string) *models.Experiment {
+	return &models.Experiment{
+		ID:    "exp-1",
+		Phase: phase,
+		Config: models.ExperimentConfig{
+			TargetHosts:      []string{"host-1", "host-2"},
+			BaselineTemplate: models.PipelineTemplate{URL: "http://api/configs/baseline.yaml"},
+			Variants: []models.ExperimentVariant{
+				{Name: "topk", Template: models.PipelineTemplate{URL: "http://api/configs/topk.yaml"}},
+			},
+			LoadProfile: "steady",
+			Duration:    30 * time.Minute,
+		},
+	}
+}
+
+func TestCollectorConfigHash(t *testing.T) {
+	config := map[string]interface{}{
+		"id":        "exp-1-baseline",
+		"variant":   "baseline",
+		"configUrl": "http://api/configs/baseline.yaml",
+	}
+	hash := CollectorConfigHash(config)
+
+	config["config_hash"] = hash
+	if got := CollectorConfigHash(config); got != hash {
+		t.Errorf("hash changed by config_hash: %s != %s", got, hash)
+	}
+
+	config["configUrl"] = "http://api/configs/other.yaml"
+	if CollectorConfigHash(config) == hash {
+		t.Error("hash did not change with the config")
+	}
+}
+
+func TestDesiredStateExperiment(t *testing.T) {
+	d := NewDesiredStates(nil, "")
+
+	running := &models.DesiredState{HostID: "host-1"}
+	d.addExperiment(running, desiredStateExperiment("running"))
+	if len(running.Collectors) != 2 {
+		t.Fatalf("got %d collectors, want 2", len(running.Collectors))
+	}
+	if running.Collectors[1].ID != "exp-1-topk" || running.Collectors[1].ConfigHash == "" {
+		t.Errorf("unexpected candidate collector %+v", running.Collectors[1])
+	}
+	if running.LoadSimulation == nil || running.LoadSimulation.Profile != "steady" {
+		t.Errorf("unexpected load simulation %+v", running.LoadSimulation)
+	}
+
+	rollback := &models.DesiredState{HostID: "host-1"}
+	d.addExperiment(rollback, desiredStateExperiment("rollback"))
+	if len(rollback.Collectors) != 1 || rollback.Collectors[0].Variant != models.BaselineVariant {
+		t.Errorf("rolled back experiment should keep only its baseline, got %+v", rollback.Collectors)
+	}
+	if rollback.LoadSimulation != nil {
+		t.Error("rolled back experiment should not run load")
+	}
+
+	for _, tt := range []struct {
+		phase, host string
+	}{
+		{"completed", "host-1"},
+		{"stopping", "host-1"},
+		{"running", "host-3"},
+	} {
+		state := &models.DesiredState{HostID: tt.host}
+		d.addExperiment(state, desiredStateExperiment(tt.phase))
+		if len(state.Collectors) != 0 {
+			t.Errorf("%s on %s: got %d collectors, want none", tt.phase, tt.host, len(state.Collectors))
+		}
+	}
+
+	if desiredRevision(running) == desiredRevision(rollback) {
+		t.Error("revision did not change with the desired collectors")
+	}
+}
+
+func TestCollectorTaskConfigPinsTemplateDigest(t *testing.T) {
+	exp := desiredStateExperiment("running")
+	variants := experimentVariants(exp)
+
+	if _, ok := collectorTaskConfig(exp, variants[0])["config_sha256"]; ok {
+		t.Error("unpinned template should not carry a digest")
+	}
+
+	variants[1].Template.SHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	config := collectorTaskConfig(exp, variants[1])
+	if config["config_sha256"] != variants[1].Template.SHA256 {
+		t.Errorf("got config_sha256 %v, want the template's digest", config["config_sha256"])
+	}
+}
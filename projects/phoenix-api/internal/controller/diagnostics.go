@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+// maxDiagnosticLogLines is the most log lines a log_tail diagnostic may ask
+// for; the agent caps the output size on its side as well
+const maxDiagnosticLogLines = 5000
+
+// DiagnosticCommands are the diagnostics agents run, and whether each acts on
+// a collector. Agents refuse anything else; there is no shell.
+var DiagnosticCommands = map[string]bool{
+	"log_tail":        true,
+	"rendered_config": true,
+	"validate_config": true,
+	"proc_stats":      true,
+	"listening_ports": false,
+	"disk_usage":      false,
+}
+
+// DiagnosticRequest asks an agent to run one of the DiagnosticCommands
+type DiagnosticRequest struct {
+	Command     string `json:"command"`
+	CollectorID string `json:"collector_id,omitempty"`
+	// Lines is how many log lines log_tail returns; zero takes the agent's
+	// default
+	Lines int `json:"lines,omitempty"`
+}
+
+// DiagnosticTask builds the command task that runs a diagnostic on a host
+func DiagnosticTask(hostID string, req DiagnosticRequest) (*models.Task, error) {
+	needsCollector, ok := DiagnosticCommands[req.Command]
+	if !ok {
+		return nil, fmt.Errorf("unknown diagnostic %q, expected one of %v", req.Command, diagnosticNames())
+	}
+	if needsCollector && req.CollectorID == "" {
+		return nil, fmt.Errorf("diagnostic %s needs a collector_id", req.Command)
+	}
+	if req.Lines < 0 || req.Lines > maxDiagnosticLogLines {
+		return nil, fmt.Errorf("lines must be between 0 and %d", maxDiagnosticLogLines)
+	}
+
+	config := map[string]interface{}{}
+	if needsCollector {
+		config["collector_id"] = req.CollectorID
+	}
+	if req.Command == "log_tail" && req.Lines > 0 {
+		config["lines"] = req.Lines
+	}
+
+	return &models.Task{
+		HostID:   hostID,
+		Type:     "command",
+		Action:   req.Command,
+		Priority: 2, // Operators are waiting on the answer
+		Config:   config,
+	}, nil
+}
+
+func diagnosticNames() []string {
+	names := make([]string, 0, len(DiagnosticCommands))
+	for name := range DiagnosticCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
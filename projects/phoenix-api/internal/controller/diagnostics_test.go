@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticTask_CollectorDiagnostic(t *testing.T) {
+	task, err := DiagnosticTask("host-a", DiagnosticRequest{Command: "log_tail", CollectorID: "exp-1-candidate", Lines: 50})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if task.HostID != "host-a" || task.Type != "command" || task.Action != "log_tail" {
+		t.Fatalf("unexpected task %+v", task)
+	}
+	if task.Config["collector_id"] != "exp-1-candidate" || task.Config["lines"] != 50 {
+		t.Fatalf("unexpected config %v", task.Config)
+	}
+}
+
+func TestDiagnosticTask_HostDiagnosticIgnoresCollector(t *testing.T) {
+	task, err := DiagnosticTask("host-a", DiagnosticRequest{Command: "disk_usage", CollectorID: "exp-1-candidate", Lines: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(task.Config) != 0 {
+		t.Fatalf("expected an empty config, got %v", task.Config)
+	}
+}
+
+func TestDiagnosticTask_Rejects(t *testing.T) {
+	cases := map[string]struct {
+		req  DiagnosticRequest
+		want string
+	}{
+		"shell command":     {DiagnosticRequest{Command: "rm -rf /"}, "unknown diagnostic"},
+		"missing collector": {DiagnosticRequest{Command: "proc_stats"}, "needs a collector_id"},
+		"too many lines":    {DiagnosticRequest{Command: "log_tail", CollectorID: "c", Lines: 100000}, "lines must be"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := DiagnosticTask("host-a", tc.req)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -11,103 +11,123 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// statusCheckInterval is how often experiments in a transitional phase are
+// re-evaluated against their active pipelines
+const statusCheckInterval = 10 * time.Second
+
 type ExperimentController struct {
-	store     store.Store
-	taskQueue *tasks.Queue
+	store         store.Store
+	taskQueue     *tasks.Queue
+	deployTimeout time.Duration
 }
 
-func NewExperimentController(store store.Store, taskQueue *tasks.Queue) *ExperimentController {
+// NewExperimentController creates a controller. deployTimeout bounds how long
+// a host may take to bring its collectors up before it is reported as failed.
+func NewExperimentController(store store.Store, taskQueue *tasks.Queue, deployTimeout time.Duration) *ExperimentController {
 	return &ExperimentController{
-		store:     store,
-		taskQueue: taskQueue,
+		store:         store,
+		taskQueue:     taskQueue,
+		deployTimeout: deployTimeout,
 	}
 }
 
 // StartExperiment initiates an experiment by creating tasks for agents
 func (c *ExperimentController) StartExperiment(ctx context.Context, exp *models.Experiment) error {
+	// Starting an experiment that is already underway must not redeploy it
+	// or push its scheduled completion back
+	switch exp.Phase {
+	case "deploying", "running", "monitoring", "paused":
+		log.Info().Str("experiment_id", exp.ID).Str("phase", exp.Phase).Msg("Experiment already started")
+		return nil
+	}
+
 	log.Info().Str("experiment_id", exp.ID).Msg("Starting experiment")
 
+	// Selectors are resolved once; a restarted experiment keeps its hosts
+	if exp.Config.HostSelector != nil && len(exp.Config.TargetHosts) == 0 {
+		if err := c.resolveTargetHosts(ctx, exp); err != nil {
+			return err
+		}
+	}
+
+	// Collectors of two experiments on one host would compete for its
+	// ports, so every host is held by one experiment at a time
+	if err := c.store.AcquireHostLocks(ctx, exp.ID, exp.Config.TargetHosts); err != nil {
+		return fmt.Errorf("failed to lock target hosts: %w", err)
+	}
+
+	// Stop tasks of an earlier run must not stop this one from being stopped
+	c.releaseKeys(ctx, exp, "stop", exp.Config.VariantNames()...)
+
 	// Update experiment phase to deploying
 	if err := c.store.UpdateExperimentPhase(ctx, exp.ID, "deploying"); err != nil {
 		return fmt.Errorf("failed to update experiment phase: %w", err)
 	}
 
-	// Create tasks for each target host
-	for _, host := range exp.Config.TargetHosts {
-		// Baseline collector task
-		baselineTask := &models.Task{
-			HostID:       host,
-			ExperimentID: exp.ID,
-			Type:         "collector",
-			Action:       "start",
-			Priority:     1,
-			Config: map[string]interface{}{
-				"id":        fmt.Sprintf("%s-baseline", exp.ID),
-				"variant":   "baseline",
-				"configUrl": exp.Config.BaselineTemplate.URL,
-				"vars":      exp.Config.BaselineTemplate.Variables,
-			},
-		}
+	exp.Phase = "deploying"
 
-		// Add NRDOT parameters if present in experiment metadata
-		if exp.Metadata != nil {
-			if collectorType, ok := exp.Metadata["collector_type"].(string); ok && collectorType == "nrdot" {
-				baselineTask.Config["collector_type"] = collectorType
-				
-				// Pass through NRDOT-specific parameters
-				for _, key := range []string{"nr_license_key", "nr_otlp_endpoint", "max_cardinality", "reduction_percentage", "pushgateway_url"} {
-					if val, ok := exp.Metadata[key]; ok {
-						baselineTask.Config[key] = val
-					}
-				}
-			}
+	// A rollout plan deploys the first wave only; later waves follow as
+	// their predecessors pass their gates
+	if exp.Config.Rollout != nil {
+		if err := c.startRollout(ctx, exp); err != nil {
+			return err
 		}
+	} else if err := c.deployHosts(ctx, exp, exp.Config.TargetHosts); err != nil {
+		return err
+	}
 
-		if err := c.taskQueue.Enqueue(ctx, baselineTask); err != nil {
-			return fmt.Errorf("failed to enqueue baseline task for host %s: %w", host, err)
-		}
+	// Create experiment event
+	event := &models.ExperimentEvent{
+		ExperimentID: exp.ID,
+		EventType:    "experiment_started",
+		Phase:        "deploying",
+		Message:      fmt.Sprintf("Experiment started with %d hosts", len(exp.Config.TargetHosts)),
+	}
 
-		// Candidate collector task
-		candidateTask := &models.Task{
-			HostID:       host,
-			ExperimentID: exp.ID,
-			Type:         "collector",
-			Action:       "start",
-			Priority:     1,
-			Config: map[string]interface{}{
-				"id":        fmt.Sprintf("%s-candidate", exp.ID),
-				"variant":   "candidate",
-				"configUrl": exp.Config.CandidateTemplate.URL,
-				"vars":      exp.Config.CandidateTemplate.Variables,
-			},
-		}
-		
-		// Add NRDOT parameters for candidate as well
-		if exp.Metadata != nil {
-			if collectorType, ok := exp.Metadata["collector_type"].(string); ok && collectorType == "nrdot" {
-				candidateTask.Config["collector_type"] = collectorType
-				
-				// Pass through NRDOT-specific parameters
-				for _, key := range []string{"nr_license_key", "nr_otlp_endpoint", "max_cardinality", "reduction_percentage", "pushgateway_url"} {
-					if val, ok := exp.Metadata[key]; ok {
-						candidateTask.Config[key] = val
-					}
-				}
+	if err := c.store.CreateExperimentEvent(ctx, event); err != nil {
+		log.Error().Err(err).Msg("Failed to create experiment event")
+	}
+
+	return nil
+}
+
+// deployHosts enqueues the collector and load simulation start tasks of an
+// experiment on the given hosts. Every host runs one collector per variant.
+func (c *ExperimentController) deployHosts(ctx context.Context, exp *models.Experiment, hosts []string) error {
+	variants := experimentVariants(exp)
+
+	for _, host := range hosts {
+		collectorTasks := make([]string, 0, len(variants))
+
+		for _, variant := range variants {
+			task := &models.Task{
+				HostID:       host,
+				ExperimentID: exp.ID,
+				Type:         "collector",
+				Action:       "start",
+				Priority:     1,
+				Config:       collectorTaskConfig(exp, variant),
 			}
-		}
 
-		if err := c.taskQueue.Enqueue(ctx, candidateTask); err != nil {
-			return fmt.Errorf("failed to enqueue candidate task for host %s: %w", host, err)
+			if err := c.taskQueue.Enqueue(ctx, task); err != nil {
+				return fmt.Errorf("failed to enqueue %s task for host %s: %w", variant.Name, host, err)
+			}
+			if task.Status != "completed" {
+				c.recordStarting(ctx, exp.ID, host, variant.Name, variant.Template.URL)
+			}
+			collectorTasks = append(collectorTasks, task.ID)
 		}
 
-		// Load simulation task if configured
+		// Load simulation task if configured. It is held back until every
+		// collector on this host has started, so no load goes unobserved.
 		if exp.Config.LoadProfile != "" {
 			loadTask := &models.Task{
 				HostID:       host,
 				ExperimentID: exp.ID,
 				Type:         "loadsim",
 				Action:       "start",
-				Priority:     0, // Lower priority, run after collectors
+				Priority:     0,
+				DependsOn:    collectorTasks,
 				Config: map[string]interface{}{
 					"profile":  exp.Config.LoadProfile,
 					"duration": exp.Config.Duration.String(),
@@ -120,19 +140,54 @@ func (c *ExperimentController) StartExperiment(ctx context.Context, exp *models.
 		}
 	}
 
-	// Create experiment event
-	event := &models.ExperimentEvent{
-		ExperimentID: exp.ID,
-		EventType:    "experiment_started",
-		Phase:        "deploying",
-		Message:      fmt.Sprintf("Experiment started with %d hosts", len(exp.Config.TargetHosts)),
+	return nil
+}
+
+// collectorTaskConfig returns the config of the task that starts the
+// collector of an experiment variant. It carries the hash desired-state
+// reconciliation compares running collectors by.
+func collectorTaskConfig(exp *models.Experiment, variant models.ExperimentVariant) map[string]interface{} {
+	config := map[string]interface{}{
+		"id":        fmt.Sprintf("%s-%s", exp.ID, variant.Name),
+		"variant":   variant.Name,
+		"configUrl": variant.Template.URL,
+		"vars":      variant.Template.Variables,
 	}
 
-	if err := c.store.CreateExperimentEvent(ctx, event); err != nil {
-		log.Error().Err(err).Msg("Failed to create experiment event")
+	// Agents refuse a downloaded config that does not hash to the digest
+	// the template was pinned to
+	if variant.Template.SHA256 != "" {
+		config["config_sha256"] = variant.Template.SHA256
 	}
 
-	return nil
+	// Add NRDOT parameters if present in experiment metadata
+	if exp.Metadata != nil {
+		if collectorType, ok := exp.Metadata["collector_type"].(string); ok && collectorType == "nrdot" {
+			config["collector_type"] = collectorType
+
+			// Pass through NRDOT-specific parameters
+			for _, key := range []string{"nr_license_key", "nr_otlp_endpoint", "max_cardinality", "reduction_percentage", "pushgateway_url"} {
+				if val, ok := exp.Metadata[key]; ok {
+					config[key] = val
+				}
+			}
+		}
+
+		// Resource limits the agent holds the collectors to
+		if resources, ok := exp.Metadata["resources"]; ok {
+			config["resources"] = resources
+		}
+	}
+
+	config["config_hash"] = CollectorConfigHash(config)
+	return config
+}
+
+// experimentVariants returns the baseline followed by every candidate of an
+// experiment
+func experimentVariants(exp *models.Experiment) []models.ExperimentVariant {
+	baseline := models.ExperimentVariant{Name: models.BaselineVariant, Template: exp.Config.BaselineTemplate}
+	return append([]models.ExperimentVariant{baseline}, exp.Config.Candidates()...)
 }
 
 // StopExperiment stops all tasks related to an experiment
@@ -145,44 +200,59 @@ func (c *ExperimentController) StopExperiment(ctx context.Context, experimentID
 		return fmt.Errorf("failed to get experiment: %w", err)
 	}
 
-	// Create stop tasks for each host
-	for _, host := range exp.Config.TargetHosts {
-		// Stop baseline collector
-		stopBaselineTask := &models.Task{
-			HostID:       host,
-			ExperimentID: experimentID,
-			Type:         "collector",
-			Action:       "stop",
-			Priority:     2, // High priority
-			Config: map[string]interface{}{
-				"id": fmt.Sprintf("%s-baseline", experimentID),
-			},
-		}
+	// Scheduled warmup and completion no longer apply
+	if err := c.store.CancelTransitions(ctx, experimentID); err != nil {
+		log.Error().Err(err).Str("experiment_id", experimentID).Msg("Failed to cancel scheduled transitions")
+	}
 
-		if err := c.taskQueue.Enqueue(ctx, stopBaselineTask); err != nil {
-			log.Error().Err(err).Str("host", host).Msg("Failed to enqueue stop baseline task")
-		}
+	c.stopCollectors(ctx, exp)
 
-		// Stop candidate collector
-		stopCandidateTask := &models.Task{
-			HostID:       host,
-			ExperimentID: experimentID,
-			Type:         "collector",
-			Action:       "stop",
-			Priority:     2,
-			Config: map[string]interface{}{
-				"id": fmt.Sprintf("%s-candidate", experimentID),
-			},
-		}
+	// Update experiment phase to stopping
+	if err := c.store.UpdateExperimentPhase(ctx, experimentID, "stopping"); err != nil {
+		return fmt.Errorf("failed to update experiment phase: %w", err)
+	}
+
+	return nil
+}
 
-		if err := c.taskQueue.Enqueue(ctx, stopCandidateTask); err != nil {
-			log.Error().Err(err).Str("host", host).Msg("Failed to enqueue stop candidate task")
+// stopCollectors cancels the experiment's unfinished tasks and enqueues stop
+// tasks for its collectors and load simulation on every host it was
+// deployed to
+func (c *ExperimentController) stopCollectors(ctx context.Context, exp *models.Experiment) {
+	// Drop start tasks that have not run yet, and abort those agents are
+	// still running, so they cannot bring a collector up after its stop task
+	// has already been handled
+	if err := c.taskQueue.CancelTasksForExperiment(ctx, exp.ID, "experiment is stopping"); err != nil {
+		log.Error().Err(err).Str("experiment_id", exp.ID).Msg("Failed to cancel experiment tasks")
+	}
+
+	// The collectors may be started again once they are stopped
+	c.releaseKeys(ctx, exp, "start", exp.Config.VariantNames()...)
+
+	// Create stop tasks for each host
+	for _, host := range deployedHosts(exp) {
+		for _, variant := range exp.Config.VariantNames() {
+			stopTask := &models.Task{
+				HostID:       host,
+				ExperimentID: exp.ID,
+				Type:         "collector",
+				Action:       "stop",
+				Priority:     2, // High priority
+				Config: map[string]interface{}{
+					"id":      fmt.Sprintf("%s-%s", exp.ID, variant),
+					"variant": variant,
+				},
+			}
+
+			if err := c.taskQueue.Enqueue(ctx, stopTask); err != nil {
+				log.Error().Err(err).Str("host", host).Str("variant", variant).Msg("Failed to enqueue stop collector task")
+			}
 		}
 
 		// Stop load simulation if running
 		stopLoadTask := &models.Task{
 			HostID:       host,
-			ExperimentID: experimentID,
+			ExperimentID: exp.ID,
 			Type:         "loadsim",
 			Action:       "stop",
 			Priority:     2,
@@ -193,18 +263,108 @@ func (c *ExperimentController) StopExperiment(ctx context.Context, experimentID
 			log.Error().Err(err).Str("host", host).Msg("Failed to enqueue stop load task")
 		}
 	}
+}
 
-	// Update experiment phase to stopping
-	if err := c.store.UpdateExperimentPhase(ctx, experimentID, "stopping"); err != nil {
+// CompleteExperiment ends an experiment that has run for its configured
+// duration. Its collectors are stopped and it moves to the completed phase.
+func (c *ExperimentController) CompleteExperiment(ctx context.Context, exp *models.Experiment) error {
+	log.Info().Str("experiment_id", exp.ID).Msg("Completing experiment")
+
+	c.stopCollectors(ctx, exp)
+
+	if err := c.store.UpdateExperimentPhase(ctx, exp.ID, models.PhaseCompleted); err != nil {
 		return fmt.Errorf("failed to update experiment phase: %w", err)
 	}
+	c.releaseHostLocks(ctx, exp.ID)
+
+	event := &models.ExperimentEvent{
+		ExperimentID: exp.ID,
+		EventType:    "experiment_completed",
+		Phase:        models.PhaseCompleted,
+		Message:      fmt.Sprintf("Experiment completed after %s", exp.Config.Duration),
+	}
+
+	if err := c.store.CreateExperimentEvent(ctx, event); err != nil {
+		log.Error().Err(err).Msg("Failed to create experiment event")
+	}
 
 	return nil
 }
 
-// PromoteExperiment promotes the candidate configuration to production
-func (c *ExperimentController) PromoteExperiment(ctx context.Context, experimentID string) error {
-	log.Info().Str("experiment_id", experimentID).Msg("Promoting experiment")
+// RollbackExperiment stops the candidate collectors on every deployed host,
+// leaving the baseline running, and moves the experiment to the rollback
+// phase. reason and metadata are recorded on the rollback event. It returns
+// the number of hosts a stop task was enqueued for.
+func (c *ExperimentController) RollbackExperiment(ctx context.Context, exp *models.Experiment, reason string, metadata map[string]interface{}) (int, error) {
+	log.Info().Str("experiment_id", exp.ID).Str("reason", reason).Msg("Rolling back experiment")
+
+	// A rolled back experiment must not be completed or analyzed on schedule
+	if err := c.store.CancelTransitions(ctx, exp.ID); err != nil {
+		log.Error().Err(err).Str("experiment_id", exp.ID).Msg("Failed to cancel scheduled transitions")
+	}
+
+	candidates := exp.Config.CandidateNames()
+	c.releaseKeys(ctx, exp, "start", candidates...)
+
+	rollbackTasks := 0
+	for _, host := range deployedHosts(exp) {
+		enqueued := false
+		for _, variant := range candidates {
+			task := &models.Task{
+				HostID:       host,
+				ExperimentID: exp.ID,
+				Type:         "collector",
+				Action:       "stop",
+				Priority:     3, // High priority for rollback
+				Config: map[string]interface{}{
+					"id":      fmt.Sprintf("%s-%s", exp.ID, variant),
+					"variant": variant,
+				},
+			}
+
+			if err := c.taskQueue.Enqueue(ctx, task); err != nil {
+				log.Error().Err(err).Str("host", host).Str("variant", variant).Msg("Failed to enqueue rollback task")
+				continue
+			}
+			enqueued = true
+		}
+		if enqueued {
+			rollbackTasks++
+		}
+	}
+
+	if err := c.store.UpdateExperimentPhase(ctx, exp.ID, "rollback"); err != nil {
+		return rollbackTasks, fmt.Errorf("failed to update experiment phase: %w", err)
+	}
+
+	eventMetadata := map[string]interface{}{
+		"hosts_affected": rollbackTasks,
+		"reason":         reason,
+	}
+	for key, value := range metadata {
+		eventMetadata[key] = value
+	}
+
+	event := &models.ExperimentEvent{
+		ExperimentID: exp.ID,
+		EventType:    "experiment_rollback",
+		Phase:        "rollback",
+		Message:      fmt.Sprintf("Rollback initiated for %d hosts", rollbackTasks),
+		Metadata:     eventMetadata,
+	}
+
+	if err := c.store.CreateExperimentEvent(ctx, event); err != nil {
+		log.Error().Err(err).Msg("Failed to create rollback event")
+	}
+
+	return rollbackTasks, nil
+}
+
+// PromoteExperiment promotes the configuration of a candidate variant to
+// production. The variant may be left empty when the experiment has a single
+// candidate.
+func (c *ExperimentController) PromoteExperiment(ctx context.Context, experimentID, variant string) error {
+	log.Info().Str("experiment_id", experimentID).Str("variant", variant).Msg("Promoting experiment")
 
 	// Get experiment
 	exp, err := c.store.GetExperiment(ctx, experimentID)
@@ -217,6 +377,11 @@ func (c *ExperimentController) PromoteExperiment(ctx context.Context, experiment
 		return fmt.Errorf("experiment must be in completed phase to promote")
 	}
 
+	promoted, err := promotedVariant(exp, variant)
+	if err != nil {
+		return err
+	}
+
 	// For MVP, we'll simply record the promotion in the experiment metadata
 	// In the future, this could update an actual pipeline template in a registry
 
@@ -226,9 +391,10 @@ func (c *ExperimentController) PromoteExperiment(ctx context.Context, experiment
 	}
 	exp.Metadata["promoted"] = true
 	exp.Metadata["promoted_at"] = time.Now()
+	exp.Metadata["promoted_variant"] = promoted.Name
 	exp.Metadata["promoted_config"] = map[string]interface{}{
-		"template_url": exp.Config.CandidateTemplate.URL,
-		"variables":    exp.Config.CandidateTemplate.Variables,
+		"template_url": promoted.Template.URL,
+		"variables":    promoted.Template.Variables,
 	}
 
 	// Update experiment status
@@ -244,9 +410,10 @@ func (c *ExperimentController) PromoteExperiment(ctx context.Context, experiment
 		ExperimentID: experimentID,
 		EventType:    "promoted",
 		Phase:        "promoted",
-		Message:      "Experiment promoted to production",
+		Message:      fmt.Sprintf("Variant %s promoted to production", promoted.Name),
 		Metadata: map[string]interface{}{
-			"promoted_template": exp.Config.CandidateTemplate.URL,
+			"promoted_variant":  promoted.Name,
+			"promoted_template": promoted.Template.URL,
 		},
 	}
 
@@ -261,61 +428,261 @@ func (c *ExperimentController) PromoteExperiment(ctx context.Context, experiment
 
 	log.Info().
 		Str("experiment_id", experimentID).
-		Str("template", exp.Config.CandidateTemplate.URL).
+		Str("variant", promoted.Name).
+		Str("template", promoted.Template.URL).
 		Msg("Experiment promoted successfully")
 
 	return nil
 }
 
-// CheckExperimentStatus monitors active pipelines and updates experiment phase
+// promotedVariant returns the variant of exp named variant, or its only
+// candidate when variant is empty. Promoting the baseline keeps the current
+// configuration.
+func promotedVariant(exp *models.Experiment, variant string) (models.ExperimentVariant, error) {
+	candidates := exp.Config.Candidates()
+	if variant == models.BaselineVariant {
+		return models.ExperimentVariant{Name: models.BaselineVariant, Template: exp.Config.BaselineTemplate}, nil
+	}
+	if variant == "" {
+		if len(candidates) > 1 {
+			return models.ExperimentVariant{}, fmt.Errorf("%w: experiment has %d candidates, name the one to promote",
+				ErrUnknownVariant, len(candidates))
+		}
+		return candidates[0], nil
+	}
+
+	for _, candidate := range candidates {
+		if candidate.Name == variant {
+			return candidate, nil
+		}
+	}
+	return models.ExperimentVariant{}, fmt.Errorf("%w: %q", ErrUnknownVariant, variant)
+}
+
+// CheckExperimentStatus derives the experiment phase from the pipelines
+// actually running on its hosts. A deploying experiment moves to running once
+// every host is settled, or to failed if no host came up (a settled rollout
+// wave starts soaking instead); a stopping
+// experiment moves to stopped once nothing runs, or to failed if some
+// collectors could not be stopped.
 func (c *ExperimentController) CheckExperimentStatus(ctx context.Context, experimentID string) error {
-	// TODO: Implement GetActivePipelines in store interface
-	// For now, we'll assume pipelines are running if the experiment is in running phase
 	exp, err := c.store.GetExperiment(ctx, experimentID)
 	if err != nil {
 		return fmt.Errorf("failed to get experiment: %w", err)
 	}
 
-	// For now, we'll assume all pipelines are running if experiment is in appropriate phase
-	// In a real implementation, we would check the actual pipeline status
-	baselineRunning := 0
-	candidateRunning := 0
+	if exp.Phase != "deploying" && exp.Phase != "stopping" {
+		return nil
+	}
 
-	// TODO: When GetActivePipelines is implemented, count actual running pipelines
-	// For now, assume they're running based on experiment phase
-	if exp.Phase == "running" || exp.Phase == "deploying" {
-		baselineRunning = len(exp.Config.TargetHosts)
-		candidateRunning = len(exp.Config.TargetHosts)
+	summary, err := c.summarize(ctx, exp)
+	if err != nil {
+		return err
 	}
 
-	expectedHosts := len(exp.Config.TargetHosts)
+	expectedHosts := len(deployedHosts(exp))
+	rollout := rolloutInProgress(exp)
 
-	// Update phase based on pipeline status
 	switch exp.Phase {
 	case "deploying":
-		if baselineRunning == expectedHosts && candidateRunning == expectedHosts {
-			// All pipelines are running
-			if err := c.store.UpdateExperimentPhase(ctx, experimentID, "running"); err != nil {
-				return fmt.Errorf("failed to update phase to running: %w", err)
+		// A soaking wave is waiting for its gate, not for its hosts
+		if rollout && exp.Config.Rollout.State != models.RolloutDeploying {
+			return nil
+		}
+		if len(summary.Pending) > 0 {
+			return nil
+		}
+
+		if len(summary.Running) == 0 {
+			c.recordEvent(ctx, experimentID, "deployment_failed", "failed",
+				"No host brought up all experiment collectors", summary.Failures)
+			if err := c.store.UpdateExperimentPhase(ctx, experimentID, "failed"); err != nil {
+				return fmt.Errorf("failed to update phase to failed: %w", err)
 			}
+			// Hosts with collectors left running stay locked until stopped
+			if len(summary.PartlyRunning) == 0 {
+				c.releaseHostLocks(ctx, experimentID)
+			}
+			return nil
+		}
 
-			// Start monitoring phase after configured warmup
-			go func() {
-				time.Sleep(exp.Config.WarmupDuration)
-				if err := c.store.UpdateExperimentPhase(context.Background(), experimentID, "monitoring"); err != nil {
-					log.Error().Err(err).Msg("Failed to update phase to monitoring")
-				}
-			}()
+		if len(summary.Running) < expectedHosts {
+			c.recordEvent(ctx, experimentID, "partial_deployment", "running",
+				fmt.Sprintf("%d of %d hosts came up; continuing without %v",
+					len(summary.Running), expectedHosts, summary.FailedHosts()),
+				summary.Failures)
+		}
+
+		if rollout {
+			return c.soakWave(ctx, exp)
+		}
+
+		if err := c.store.UpdateExperimentPhase(ctx, experimentID, "running"); err != nil {
+			return fmt.Errorf("failed to update phase to running: %w", err)
+		}
+
+		// Monitoring starts once warmup ends and the experiment completes after
+		// its configured duration; both survive API restarts
+		if err := c.scheduleRunTransitions(ctx, exp); err != nil {
+			return err
 		}
 
 	case "stopping":
-		if baselineRunning == 0 && candidateRunning == 0 {
-			// All pipelines have stopped
-			if err := c.store.UpdateExperimentPhase(ctx, experimentID, "stopped"); err != nil {
-				return fmt.Errorf("failed to update phase to stopped: %w", err)
+		if len(summary.Pending) > 0 || len(summary.Running) > 0 || len(summary.PartlyRunning) > 0 {
+			return nil
+		}
+
+		if len(summary.StopFailures) > 0 {
+			c.recordEvent(ctx, experimentID, "stop_failed", "failed",
+				"Some collectors could not be stopped", summary.StopFailures)
+			if err := c.store.UpdateExperimentPhase(ctx, experimentID, "failed"); err != nil {
+				return fmt.Errorf("failed to update phase to failed: %w", err)
 			}
+			return nil
 		}
+
+		// All pipelines have stopped
+		if err := c.store.UpdateExperimentPhase(ctx, experimentID, "stopped"); err != nil {
+			return fmt.Errorf("failed to update phase to stopped: %w", err)
+		}
+		c.releaseHostLocks(ctx, experimentID)
 	}
 
 	return nil
 }
+
+// PopulateStatus fills in the host counts and failures of exp.Status from
+// its active pipelines
+func (c *ExperimentController) PopulateStatus(ctx context.Context, exp *models.Experiment) error {
+	summary, err := c.summarize(ctx, exp)
+	if err != nil {
+		return err
+	}
+
+	exp.Status.ActiveHosts = len(summary.Running)
+	exp.Status.FailedHosts = summary.Failures
+	if len(summary.Failures) > 0 {
+		exp.Status.Error = fmt.Sprintf("%d hosts failed: %v", len(summary.FailedHosts()), summary.FailedHosts())
+	}
+
+	return nil
+}
+
+// Run periodically re-evaluates experiments that are deploying or stopping
+// until ctx is cancelled
+func (c *ExperimentController) Run(ctx context.Context) {
+	ticker := time.NewTicker(statusCheckInterval)
+	defer ticker.Stop()
+
+	log.Info().Msg("Experiment status monitor started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Experiment status monitor stopping")
+			return
+
+		case <-ticker.C:
+			experiments, err := c.store.ListExperiments(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to list experiments for status check")
+				continue
+			}
+
+			for _, exp := range experiments {
+				if exp.Phase != "deploying" && exp.Phase != "stopping" {
+					continue
+				}
+				if err := c.CheckExperimentStatus(ctx, exp.ID); err != nil {
+					log.Error().Err(err).Str("experiment_id", exp.ID).Msg("Failed to check experiment status")
+				}
+			}
+		}
+	}
+}
+
+// scheduleRunTransitions persists the warmup end and, when the experiment has
+// a duration, its automatic completion
+func (c *ExperimentController) scheduleRunTransitions(ctx context.Context, exp *models.Experiment) error {
+	if _, err := c.store.ScheduleTransition(ctx, exp.ID, TransitionStartMonitoring, exp.Config.WarmupDuration); err != nil {
+		return fmt.Errorf("failed to schedule monitoring start: %w", err)
+	}
+
+	if exp.Config.Duration > 0 {
+		completeAfter := exp.Config.WarmupDuration + exp.Config.Duration
+		if _, err := c.store.ScheduleTransition(ctx, exp.ID, TransitionComplete, completeAfter); err != nil {
+			return fmt.Errorf("failed to schedule completion: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *ExperimentController) summarize(ctx context.Context, exp *models.Experiment) (hostSummary, error) {
+	pipelines, err := c.store.GetActivePipelines(ctx, exp.ID)
+	if err != nil {
+		return hostSummary{}, fmt.Errorf("failed to get active pipelines: %w", err)
+	}
+
+	return summarizeHosts(deployedHosts(exp), exp.Config.VariantNames(), pipelines, exp.UpdatedAt, c.deployTimeout, time.Now()), nil
+}
+
+// releaseKeys releases the idempotency keys of the experiment's collector
+// tasks for action and the given variants on every target host, along with
+// its load simulation task for action when every variant is released
+func (c *ExperimentController) releaseKeys(ctx context.Context, exp *models.Experiment, action string, variants ...string) {
+	var keys []string
+	for _, host := range exp.Config.TargetHosts {
+		for _, variant := range variants {
+			keys = append(keys, tasks.IdempotencyKey(exp.ID, host, "collector", action, variant))
+		}
+		if len(variants) == len(exp.Config.VariantNames()) {
+			keys = append(keys, tasks.IdempotencyKey(exp.ID, host, "loadsim", action, ""))
+		}
+	}
+
+	if err := c.taskQueue.ReleaseIdempotencyKeys(ctx, keys); err != nil {
+		log.Error().Err(err).Str("experiment_id", exp.ID).Str("action", action).Msg("Failed to release task idempotency keys")
+	}
+}
+
+// releaseHostLocks lets other experiments use the experiment's hosts
+func (c *ExperimentController) releaseHostLocks(ctx context.Context, experimentID string) {
+	if err := c.store.ReleaseHostLocks(ctx, experimentID); err != nil {
+		log.Error().Err(err).Str("experiment_id", experimentID).Msg("Failed to release host locks")
+	}
+}
+
+// recordStarting marks a variant as starting on a host so hosts that never
+// report back can be told apart from hosts that are still coming up
+func (c *ExperimentController) recordStarting(ctx context.Context, experimentID, host, variant, configURL string) {
+	err := c.store.UpsertActivePipeline(ctx, &models.ActivePipeline{
+		HostID:       host,
+		ExperimentID: experimentID,
+		Variant:      variant,
+		ConfigURL:    configURL,
+		ProcessInfo:  map[string]interface{}{},
+		MetricsInfo:  map[string]interface{}{},
+		Status:       "starting",
+		StartedAt:    time.Now(),
+	})
+	if err != nil {
+		log.Error().Err(err).Str("host", host).Str("variant", variant).Msg("Failed to record starting pipeline")
+	}
+}
+
+func (c *ExperimentController) recordEvent(ctx context.Context, experimentID, eventType, phase, message string, failures []models.HostFailure) {
+	event := &models.ExperimentEvent{
+		ExperimentID: experimentID,
+		EventType:    eventType,
+		Phase:        phase,
+		Message:      message,
+		Metadata: map[string]interface{}{
+			"failed_hosts": failures,
+		},
+	}
+
+	if err := c.store.CreateExperimentEvent(ctx, event); err != nil {
+		log.Error().Err(err).Str("experiment_id", experimentID).Msg("Failed to create experiment event")
+	}
+}
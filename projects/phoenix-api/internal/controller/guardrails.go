@@ -0,0 +1,335 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// guardrailInterval is how often guardrails of monitored experiments
+	// are evaluated
+	guardrailInterval = 30 * time.Second
+	// guardrailWindow is the metrics window each evaluation looks at
+	guardrailWindow = 5 * time.Minute
+	// defaultGuardrailBreaches is how many consecutive breaches trigger a
+	// rollback when a guardrail does not say
+	defaultGuardrailBreaches = 3
+)
+
+// GuardrailMetrics supplies the experiment metrics guardrails are evaluated
+// against. It is satisfied by analyzer.KPICalculator.
+type GuardrailMetrics interface {
+	CalculateVariantKPIs(ctx context.Context, expID string, candidates []string, duration time.Duration) (*models.KPIResult, error)
+	GetAdditionalMetrics(ctx context.Context, expID string, variants []string, duration time.Duration) map[string]float64
+}
+
+// guardrailMetric is one metric a guardrail can watch. Metrics measured per
+// variant carry both values; the guardrail applies to the candidate.
+type guardrailMetric struct {
+	Baseline   float64
+	Candidate  float64
+	PerVariant bool
+}
+
+// GuardrailMonitor evaluates the guardrails of experiments in the monitoring
+// phase and rolls the candidates back once a guardrail has been breached by
+// any of them for enough consecutive evaluations. Breach counts are kept in memory, so they
+// restart from zero when scheduler leadership moves to another replica.
+type GuardrailMonitor struct {
+	store      store.Store
+	controller *ExperimentController
+	metrics    GuardrailMetrics
+
+	mu       sync.Mutex
+	breaches map[string]map[int]int
+}
+
+// NewGuardrailMonitor creates a monitor that reads metrics from metrics and
+// rolls experiments back through controller
+func NewGuardrailMonitor(store store.Store, controller *ExperimentController, metrics GuardrailMetrics) *GuardrailMonitor {
+	return &GuardrailMonitor{
+		store:      store,
+		controller: controller,
+		metrics:    metrics,
+		breaches:   make(map[string]map[int]int),
+	}
+}
+
+// ValidateGuardrails checks that every guardrail names a known metric and
+// operator
+func ValidateGuardrails(guardrails []models.Guardrail) error {
+	for i, g := range guardrails {
+		if _, ok := guardrailMetricNames[g.Metric]; !ok {
+			return fmt.Errorf("guardrail %d: unknown metric %q", i, g.Metric)
+		}
+		if _, ok := guardrailOperators[g.Operator]; !ok {
+			return fmt.Errorf("guardrail %d: unknown operator %q", i, g.Operator)
+		}
+		if g.RelativeToBaseline && !guardrailMetricNames[g.Metric] {
+			return fmt.Errorf("guardrail %d: metric %q has no baseline to compare against", i, g.Metric)
+		}
+		if g.Breaches < 0 {
+			return fmt.Errorf("guardrail %d: breaches must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// guardrailMetricNames lists the metrics guardrails can watch and whether
+// each is measured per variant
+var guardrailMetricNames = map[string]bool{
+	"data_accuracy":         false,
+	"cardinality_reduction": false,
+	"cost_reduction":        false,
+	"p99_latency_ms":        false,
+	"pipeline_efficiency":   false,
+	"cpu_usage":             true,
+	"memory_usage":          true,
+	"ingest_rate":           true,
+	"error_rate":            true,
+}
+
+var guardrailOperators = map[string]func(value, threshold float64) bool{
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+}
+
+// Evaluate checks the guardrails of every monitored experiment once
+func (m *GuardrailMonitor) Evaluate(ctx context.Context) {
+	experiments, err := m.store.ListExperiments(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list experiments for guardrail evaluation")
+		return
+	}
+
+	watched := make(map[string]bool)
+	for _, exp := range experiments {
+		if exp.Phase != "monitoring" || len(exp.Config.Guardrails) == 0 {
+			continue
+		}
+		watched[exp.ID] = true
+		m.evaluateExperiment(ctx, exp)
+	}
+
+	// Forget experiments that left the monitoring phase
+	m.mu.Lock()
+	for id := range m.breaches {
+		if !watched[id] {
+			delete(m.breaches, id)
+		}
+	}
+	m.mu.Unlock()
+}
+
+func (m *GuardrailMonitor) evaluateExperiment(ctx context.Context, exp *models.Experiment) {
+	candidates, err := measureCandidates(ctx, m.metrics, exp, guardrailWindow)
+	if err != nil {
+		log.Error().Err(err).Str("experiment_id", exp.ID).Msg("Failed to calculate KPIs for guardrails")
+		return
+	}
+
+	m.mu.Lock()
+	counts := m.breaches[exp.ID]
+	if counts == nil {
+		counts = make(map[int]int)
+		m.breaches[exp.ID] = counts
+	}
+
+	fired, firedBy := -1, candidateValues{}
+	for i, g := range exp.Config.Guardrails {
+		breachedBy, ok := evaluateCandidates(g, candidates)
+		if !ok {
+			// Missing data neither breaks nor extends a streak
+			continue
+		}
+		if breachedBy == nil {
+			counts[i] = 0
+			continue
+		}
+
+		counts[i]++
+		log.Warn().
+			Str("experiment_id", exp.ID).
+			Str("guardrail", guardrailName(g)).
+			Str("variant", breachedBy.variant).
+			Int("breaches", counts[i]).
+			Msg("Guardrail breached")
+
+		if fired < 0 && counts[i] >= requiredBreaches(g) {
+			fired, firedBy = i, *breachedBy
+		}
+	}
+	m.mu.Unlock()
+
+	if fired < 0 {
+		return
+	}
+
+	g := exp.Config.Guardrails[fired]
+	metadata := map[string]interface{}{
+		"guardrail": g,
+		"breaches":  requiredBreaches(g),
+		"variant":   firedBy.variant,
+		"observed":  observedValues(g, firedBy.values),
+	}
+
+	reason := fmt.Sprintf("guardrail %s breached by %s", guardrailName(g), firedBy.variant)
+	if _, err := m.controller.RollbackExperiment(ctx, exp, reason, metadata); err != nil {
+		log.Error().Err(err).Str("experiment_id", exp.ID).Msg("Failed to roll back experiment after guardrail breach")
+		return
+	}
+
+	m.mu.Lock()
+	delete(m.breaches, exp.ID)
+	m.mu.Unlock()
+}
+
+// candidateValues holds the metrics guardrails can watch for one candidate
+type candidateValues struct {
+	variant string
+	values  map[string]guardrailMetric
+}
+
+// measureCandidates collects the guardrail metrics of every candidate of an
+// experiment
+func measureCandidates(ctx context.Context, metrics GuardrailMetrics, exp *models.Experiment, window time.Duration) ([]candidateValues, error) {
+	names := exp.Config.CandidateNames()
+	kpis, err := metrics.CalculateVariantKPIs(ctx, exp.ID, names, window)
+	if err != nil {
+		return nil, err
+	}
+	additional := metrics.GetAdditionalMetrics(ctx, exp.ID, exp.Config.VariantNames(), window)
+
+	candidates := make([]candidateValues, 0, len(kpis.Candidates))
+	for _, candidate := range kpis.Candidates {
+		candidates = append(candidates, candidateValues{
+			variant: candidate.Variant,
+			values:  guardrailValues(candidate, additional),
+		})
+	}
+	return candidates, nil
+}
+
+// evaluateCandidates returns the first candidate that breaches g, or nil if
+// none does. ok is false when g could not be evaluated for any candidate.
+func evaluateCandidates(g models.Guardrail, candidates []candidateValues) (breachedBy *candidateValues, ok bool) {
+	for i := range candidates {
+		breached, measured := evaluateGuardrail(g, candidates[i].values)
+		if !measured {
+			continue
+		}
+		ok = true
+		if breached {
+			return &candidates[i], true
+		}
+	}
+	return nil, ok
+}
+
+// guardrailValues collects the metrics guardrails can watch for one
+// candidate. Metrics whose calculation failed are left out so they are not
+// mistaken for zero.
+func guardrailValues(kpis models.VariantKPIs, additional map[string]float64) map[string]guardrailMetric {
+	failed := func(prefix string) bool {
+		for _, e := range kpis.Errors {
+			if strings.HasPrefix(e, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	values := make(map[string]guardrailMetric)
+	if !failed("accuracy") {
+		values["data_accuracy"] = guardrailMetric{Candidate: kpis.DataAccuracy}
+	}
+	if !failed("cardinality") {
+		values["cardinality_reduction"] = guardrailMetric{Candidate: kpis.CardinalityReduction}
+	}
+	values["cost_reduction"] = guardrailMetric{Candidate: kpis.CostReduction}
+	if !failed("CPU usage") {
+		values["cpu_usage"] = guardrailMetric{Baseline: kpis.CPUUsage.Baseline, Candidate: kpis.CPUUsage.Candidate, PerVariant: true}
+	}
+	if !failed("memory usage") {
+		values["memory_usage"] = guardrailMetric{Baseline: kpis.MemoryUsage.Baseline, Candidate: kpis.MemoryUsage.Candidate, PerVariant: true}
+	}
+	if !failed("ingest rate") {
+		values["ingest_rate"] = guardrailMetric{Baseline: kpis.IngestRate.Baseline, Candidate: kpis.IngestRate.Candidate, PerVariant: true}
+	}
+
+	for _, name := range []string{"p99_latency_ms", "pipeline_efficiency"} {
+		if v, ok := additional[name]; ok {
+			values[name] = guardrailMetric{Candidate: v}
+		}
+	}
+
+	baseline, baselineOK := additional["baseline_error_rate"]
+	candidate, candidateOK := additional[kpis.Variant+"_error_rate"]
+	if baselineOK && candidateOK {
+		values["error_rate"] = guardrailMetric{Baseline: baseline, Candidate: candidate, PerVariant: true}
+	}
+
+	return values
+}
+
+// evaluateGuardrail reports whether g is breached by values. ok is false when
+// the metric it watches could not be measured.
+func evaluateGuardrail(g models.Guardrail, values map[string]guardrailMetric) (breached, ok bool) {
+	metric, ok := values[g.Metric]
+	if !ok {
+		return false, false
+	}
+
+	compare, ok := guardrailOperators[g.Operator]
+	if !ok {
+		return false, false
+	}
+
+	value := metric.Candidate
+	if g.RelativeToBaseline {
+		if !metric.PerVariant {
+			return false, false
+		}
+		value = metric.Candidate - metric.Baseline
+	}
+
+	return compare(value, g.Threshold), true
+}
+
+func observedValues(g models.Guardrail, values map[string]guardrailMetric) map[string]float64 {
+	metric := values[g.Metric]
+	if !metric.PerVariant {
+		return map[string]float64{"value": metric.Candidate}
+	}
+	return map[string]float64{
+		"baseline":   metric.Baseline,
+		"candidate":  metric.Candidate,
+		"difference": metric.Candidate - metric.Baseline,
+	}
+}
+
+func requiredBreaches(g models.Guardrail) int {
+	if g.Breaches > 0 {
+		return g.Breaches
+	}
+	return defaultGuardrailBreaches
+}
+
+func guardrailName(g models.Guardrail) string {
+	if g.Name != "" {
+		return g.Name
+	}
+	if g.RelativeToBaseline {
+		return fmt.Sprintf("%s vs baseline %s %g", g.Metric, g.Operator, g.Threshold)
+	}
+	return fmt.Sprintf("%s %s %g", g.Metric, g.Operator, g.Threshold)
+}
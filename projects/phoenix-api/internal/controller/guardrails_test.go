@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func TestEvaluateGuardrail_Absolute(t *testing.T) {
+	values := map[string]guardrailMetric{
+		"data_accuracy": {Candidate: 97.5},
+	}
+
+	breached, ok := evaluateGuardrail(models.Guardrail{Metric: "data_accuracy", Operator: "<", Threshold: 98}, values)
+	if !ok || !breached {
+		t.Fatalf("expected breach, got breached=%v ok=%v", breached, ok)
+	}
+
+	breached, ok = evaluateGuardrail(models.Guardrail{Metric: "data_accuracy", Operator: "<", Threshold: 95}, values)
+	if !ok || breached {
+		t.Fatalf("expected no breach, got breached=%v ok=%v", breached, ok)
+	}
+}
+
+func TestEvaluateGuardrail_RelativeToBaseline(t *testing.T) {
+	values := map[string]guardrailMetric{
+		"error_rate": {Baseline: 1.0, Candidate: 3.5, PerVariant: true},
+	}
+
+	g := models.Guardrail{Metric: "error_rate", Operator: ">", Threshold: 2, RelativeToBaseline: true}
+	if breached, ok := evaluateGuardrail(g, values); !ok || !breached {
+		t.Fatalf("expected breach, got breached=%v ok=%v", breached, ok)
+	}
+
+	g.Threshold = 3
+	if breached, ok := evaluateGuardrail(g, values); !ok || breached {
+		t.Fatalf("expected no breach, got breached=%v ok=%v", breached, ok)
+	}
+}
+
+func TestEvaluateGuardrail_MissingMetric(t *testing.T) {
+	g := models.Guardrail{Metric: "cpu_usage", Operator: ">", Threshold: 0.5}
+	if _, ok := evaluateGuardrail(g, map[string]guardrailMetric{}); ok {
+		t.Fatal("expected a missing metric to be reported as unavailable")
+	}
+}
+
+func TestGuardrailValues_SkipsFailedCalculations(t *testing.T) {
+	kpis := models.VariantKPIs{
+		Variant:      "candidate",
+		DataAccuracy: 0,
+		Errors:       []string{"accuracy calculation failed: no data"},
+	}
+	kpis.CPUUsage.Baseline = 0.2
+	kpis.CPUUsage.Candidate = 0.3
+
+	values := guardrailValues(kpis, map[string]float64{"candidate_error_rate": 4})
+
+	if _, ok := values["data_accuracy"]; ok {
+		t.Error("expected failed accuracy calculation to be left out")
+	}
+	if _, ok := values["error_rate"]; ok {
+		t.Error("expected error rate without a baseline to be left out")
+	}
+	if cpu := values["cpu_usage"]; cpu.Candidate != 0.3 || !cpu.PerVariant {
+		t.Errorf("unexpected cpu usage: %+v", cpu)
+	}
+}
+
+func TestGuardrailValues_ErrorRatePerVariant(t *testing.T) {
+	additional := map[string]float64{"baseline_error_rate": 1, "a_error_rate": 2, "b_error_rate": 5}
+
+	values := guardrailValues(models.VariantKPIs{Variant: "b"}, additional)
+	if rate := values["error_rate"]; rate.Baseline != 1 || rate.Candidate != 5 {
+		t.Errorf("unexpected error rate for variant b: %+v", rate)
+	}
+}
+
+func TestEvaluateCandidates(t *testing.T) {
+	g := models.Guardrail{Metric: "data_accuracy", Operator: "<", Threshold: 98}
+	candidates := []candidateValues{
+		{variant: "a", values: map[string]guardrailMetric{"data_accuracy": {Candidate: 99}}},
+		{variant: "b", values: map[string]guardrailMetric{}},
+		{variant: "c", values: map[string]guardrailMetric{"data_accuracy": {Candidate: 90}}},
+	}
+
+	breachedBy, ok := evaluateCandidates(g, candidates)
+	if !ok || breachedBy == nil || breachedBy.variant != "c" {
+		t.Fatalf("expected variant c to breach, got %+v ok=%v", breachedBy, ok)
+	}
+
+	breachedBy, ok = evaluateCandidates(g, candidates[:2])
+	if !ok || breachedBy != nil {
+		t.Fatalf("expected no breach, got %+v ok=%v", breachedBy, ok)
+	}
+
+	if _, ok := evaluateCandidates(g, candidates[1:2]); ok {
+		t.Fatal("expected an unmeasured guardrail to be reported as unavailable")
+	}
+}
+
+func TestValidateGuardrails(t *testing.T) {
+	valid := []models.Guardrail{
+		{Metric: "data_accuracy", Operator: "<", Threshold: 98},
+		{Metric: "error_rate", Operator: ">", Threshold: 1, RelativeToBaseline: true, Breaches: 5},
+	}
+	if err := ValidateGuardrails(valid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	invalid := [][]models.Guardrail{
+		{{Metric: "unknown", Operator: "<", Threshold: 1}},
+		{{Metric: "data_accuracy", Operator: "!=", Threshold: 1}},
+		{{Metric: "data_accuracy", Operator: "<", Threshold: 1, RelativeToBaseline: true}},
+		{{Metric: "cpu_usage", Operator: ">", Threshold: 1, Breaches: -1}},
+	}
+	for i, guardrails := range invalid {
+		if err := ValidateGuardrails(guardrails); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	selectorKeyPattern   = `[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?`
+	selectorValuePattern = `[A-Za-z0-9._-]*`
+)
+
+var (
+	existsRequirement = regexp.MustCompile(`^(!?)\s*(` + selectorKeyPattern + `)$`)
+	setRequirement    = regexp.MustCompile(`^(` + selectorKeyPattern + `)\s+(in|notin)\s*\((.*)\)$`)
+	equalRequirement  = regexp.MustCompile(`^(` + selectorKeyPattern + `)\s*(==|=|!=)\s*(` + selectorValuePattern + `)$`)
+	selectorKey       = regexp.MustCompile(`^` + selectorKeyPattern + `$`)
+	selectorValue     = regexp.MustCompile(`^` + selectorValuePattern + `$`)
+)
+
+// labelRequirement is one comma-separated term of a label selector
+type labelRequirement struct {
+	key    string
+	op     string // "exists", "!exists", "=", "!=", "in" or "notin"
+	values []string
+}
+
+// parseLabelSelector parses a selector such as
+// env=prod,role!=db,region in (us-east-1,us-west-2),!canary
+func parseLabelSelector(selector string) ([]labelRequirement, error) {
+	terms, err := splitSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	requirements := make([]labelRequirement, 0, len(terms))
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("empty requirement in selector %q", selector)
+		}
+
+		if m := setRequirement.FindStringSubmatch(term); m != nil {
+			var values []string
+			for _, v := range strings.Split(m[4], ",") {
+				v = strings.TrimSpace(v)
+				if !selectorValue.MatchString(v) {
+					return nil, fmt.Errorf("invalid value %q in %q", v, term)
+				}
+				values = append(values, v)
+			}
+			requirements = append(requirements, labelRequirement{key: m[1], op: m[3], values: values})
+			continue
+		}
+
+		if m := equalRequirement.FindStringSubmatch(term); m != nil {
+			op := m[3]
+			if op == "==" {
+				op = "="
+			}
+			requirements = append(requirements, labelRequirement{key: m[1], op: op, values: []string{m[4]}})
+			continue
+		}
+
+		if m := existsRequirement.FindStringSubmatch(term); m != nil {
+			op := "exists"
+			if m[1] == "!" {
+				op = "!exists"
+			}
+			requirements = append(requirements, labelRequirement{key: m[2], op: op})
+			continue
+		}
+
+		return nil, fmt.Errorf("invalid requirement %q", term)
+	}
+
+	return requirements, nil
+}
+
+// splitSelector splits a selector on the commas that are not inside a value
+// list
+func splitSelector(selector string) ([]string, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+			if depth > 1 {
+				return nil, fmt.Errorf("nested parentheses in selector %q", selector)
+			}
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in selector %q", selector)
+			}
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in selector %q", selector)
+	}
+	return append(terms, selector[start:]), nil
+}
+
+func (r labelRequirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.key]
+	switch r.op {
+	case "exists":
+		return ok
+	case "!exists":
+		return !ok
+	case "=":
+		return ok && value == r.values[0]
+	case "!=":
+		return !ok || value != r.values[0]
+	case "in":
+		return ok && containsString(r.values, value)
+	case "notin":
+		return !ok || !containsString(r.values, value)
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateHostSelector checks that a host selector parses and asks for a
+// sensible sample
+func ValidateHostSelector(selector *models.HostSelector) error {
+	if selector == nil {
+		return nil
+	}
+	if strings.TrimSpace(selector.Selector) == "" {
+		return fmt.Errorf("host selector: selector is required")
+	}
+	if _, err := parseLabelSelector(selector.Selector); err != nil {
+		return fmt.Errorf("host selector: %w", err)
+	}
+	if selector.SampleSize < 0 {
+		return fmt.Errorf("host selector: sample_size must not be negative")
+	}
+	if selector.SamplePercent < 0 || selector.SamplePercent > 100 {
+		return fmt.Errorf("host selector: sample_percent must be between 0 and 100")
+	}
+	if selector.SampleSize > 0 && selector.SamplePercent > 0 {
+		return fmt.Errorf("host selector: sample_size and sample_percent are mutually exclusive")
+	}
+	return nil
+}
+
+// ValidateLabels checks that agent labels can be matched by a host selector
+func ValidateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if !selectorKey.MatchString(k) {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+		if !selectorValue.MatchString(v) {
+			return fmt.Errorf("invalid value %q for label %q", v, k)
+		}
+	}
+	return nil
+}
+
+// selectHosts returns the hosts of agents whose labels match the selector,
+// sampled down to the requested size. Unreachable and offline agents are
+// never selected.
+// Sampling ranks hosts by a hash of the experiment ID and host ID, so the
+// same experiment and fleet always yield the same sample.
+func selectHosts(experimentID string, selector *models.HostSelector, agents []*models.AgentStatus) ([]string, int, error) {
+	requirements, err := parseLabelSelector(selector.Selector)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var matched []string
+	for _, agent := range agents {
+		if agent.Status == models.AgentOffline || agent.Status == models.AgentUnreachable {
+			continue
+		}
+		labels := agent.EffectiveLabels()
+		ok := true
+		for _, r := range requirements {
+			if !r.matches(labels) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, agent.HostID)
+		}
+	}
+
+	count := len(matched)
+	switch {
+	case selector.SampleSize > 0 && selector.SampleSize < count:
+		count = selector.SampleSize
+	case selector.SamplePercent > 0:
+		count = int(math.Ceil(float64(len(matched)) * selector.SamplePercent / 100))
+	}
+
+	rank := make(map[string]string, len(matched))
+	for _, host := range matched {
+		sum := sha256.Sum256([]byte(experimentID + "/" + host))
+		rank[host] = hex.EncodeToString(sum[:])
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return rank[matched[i]] < rank[matched[j]]
+	})
+
+	hosts := matched[:count]
+	sort.Strings(hosts)
+	return hosts, len(matched), nil
+}
+
+// resolveTargetHosts fills in an experiment's target hosts from its host
+// selector and saves them, so later starts reuse the same hosts
+func (c *ExperimentController) resolveTargetHosts(ctx context.Context, exp *models.Experiment) error {
+	agents, err := c.store.ListAgents(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list agents: %w", err)
+	}
+
+	// Hosts another experiment holds are left for it
+	locks, err := c.store.GetHostLocks(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	selector := exp.Config.HostSelector
+	hosts, matched, err := selectHosts(exp.ID, selector, unlockedAgents(agents, locks, exp.ID))
+	if err != nil {
+		return fmt.Errorf("invalid host selector: %w", err)
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("host selector %q matched no hosts", selector.Selector)
+	}
+
+	now := time.Now()
+	selector.ResolvedAt = &now
+	selector.MatchedHosts = matched
+	exp.Config.TargetHosts = hosts
+
+	if err := c.store.UpdateExperiment(ctx, exp); err != nil {
+		return fmt.Errorf("failed to save resolved hosts: %w", err)
+	}
+
+	event := &models.ExperimentEvent{
+		ExperimentID: exp.ID,
+		EventType:    "hosts_resolved",
+		Phase:        exp.Phase,
+		Message:      fmt.Sprintf("Host selector %q matched %d hosts, selected %d", selector.Selector, matched, len(hosts)),
+		Metadata: map[string]interface{}{
+			"selector":      selector.Selector,
+			"matched_hosts": matched,
+			"target_hosts":  hosts,
+		},
+	}
+	if err := c.store.CreateExperimentEvent(ctx, event); err != nil {
+		log.Error().Err(err).Msg("Failed to create experiment event")
+	}
+
+	log.Info().
+		Str("experiment_id", exp.ID).
+		Str("selector", selector.Selector).
+		Int("matched", matched).
+		Int("selected", len(hosts)).
+		Msg("Resolved experiment hosts")
+
+	return nil
+}
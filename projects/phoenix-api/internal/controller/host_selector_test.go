@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	requirements, err := parseLabelSelector("env=prod, role!=db,region in (us-east-1, us-west-2),tier notin (batch),canary,!legacy")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []labelRequirement{
+		{key: "env", op: "=", values: []string{"prod"}},
+		{key: "role", op: "!=", values: []string{"db"}},
+		{key: "region", op: "in", values: []string{"us-east-1", "us-west-2"}},
+		{key: "tier", op: "notin", values: []string{"batch"}},
+		{key: "canary", op: "exists"},
+		{key: "legacy", op: "!exists"},
+	}
+	if !reflect.DeepEqual(requirements, want) {
+		t.Fatalf("got %+v, want %+v", requirements, want)
+	}
+}
+
+func TestParseLabelSelector_Invalid(t *testing.T) {
+	for _, selector := range []string{
+		"",
+		"env=prod,",
+		"region in (us-east-1",
+		"region in ((a))",
+		"env=prod value",
+		"env in (a b)",
+	} {
+		if _, err := parseLabelSelector(selector); err == nil {
+			t.Errorf("%q: expected error", selector)
+		}
+	}
+}
+
+func TestSelectHosts(t *testing.T) {
+	agents := []*models.AgentStatus{
+		{HostID: "web-1", Status: "healthy", Labels: map[string]string{"env": "prod", "role": "web", "region": "us-east-1"}},
+		{HostID: "web-2", Status: "healthy", Labels: map[string]string{"env": "prod", "role": "web", "region": "eu-west-1"}},
+		{HostID: "web-3", Status: "offline", Labels: map[string]string{"env": "prod", "role": "web", "region": "us-east-1"}},
+		{HostID: "web-4", Status: "healthy", Labels: map[string]string{"env": "staging", "role": "web", "region": "us-east-1"},
+			AssignedLabels: map[string]string{"env": "prod"}},
+		{HostID: "db-1", Status: "healthy", Labels: map[string]string{"env": "prod", "role": "db", "region": "us-east-1"}},
+	}
+
+	selector := &models.HostSelector{Selector: "env=prod,role=web,region in (us-east-1)"}
+	hosts, matched, err := selectHosts("exp-1", selector, agents)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if matched != 2 || !reflect.DeepEqual(hosts, []string{"web-1", "web-4"}) {
+		t.Fatalf("got hosts %v (matched %d)", hosts, matched)
+	}
+}
+
+func TestSelectHosts_SampleIsStable(t *testing.T) {
+	var agents []*models.AgentStatus
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		agents = append(agents, &models.AgentStatus{HostID: id, Labels: map[string]string{"role": "web"}})
+	}
+
+	selector := &models.HostSelector{Selector: "role=web", SamplePercent: 25}
+	first, matched, err := selectHosts("exp-1", selector, agents)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if matched != 10 || len(first) != 3 {
+		t.Fatalf("expected 3 of 10 hosts, got %v (matched %d)", first, matched)
+	}
+
+	// Reversing the fleet order must not change the sample
+	reversed := make([]*models.AgentStatus, len(agents))
+	for i, agent := range agents {
+		reversed[len(agents)-1-i] = agent
+	}
+	second, _, _ := selectHosts("exp-1", selector, reversed)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("sample changed: %v vs %v", first, second)
+	}
+
+	selector = &models.HostSelector{Selector: "role=web", SampleSize: 20}
+	if all, _, _ := selectHosts("exp-1", selector, agents); len(all) != 10 {
+		t.Fatalf("expected a sample larger than the fleet to select every host, got %v", all)
+	}
+}
+
+func TestValidateHostSelector(t *testing.T) {
+	if err := ValidateHostSelector(&models.HostSelector{Selector: "env=prod", SamplePercent: 50}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, selector := range []*models.HostSelector{
+		{Selector: ""},
+		{Selector: "env=a=b"},
+		{Selector: "env=prod", SampleSize: -1},
+		{Selector: "env=prod", SamplePercent: 150},
+		{Selector: "env=prod", SampleSize: 2, SamplePercent: 50},
+	} {
+		if err := ValidateHostSelector(selector); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+// hostSummary is the experiment-wide view of active pipeline records
+type hostSummary struct {
+	// Running hosts have every variant up
+	Running []string
+	// PartlyRunning hosts have some variants up and the rest failed
+	PartlyRunning []string
+	// Pending hosts are still bringing variants up or down
+	Pending []string
+	// Stopped hosts have every variant cleanly stopped
+	Stopped []string
+	// Failures lists variants that failed or did not come up in time
+	Failures []models.HostFailure
+	// StopFailures lists variants whose collector could not be stopped
+	StopFailures []models.HostFailure
+}
+
+// FailedHosts returns the distinct hosts with at least one failed variant
+func (s hostSummary) FailedHosts() []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, failure := range s.Failures {
+		if !seen[failure.HostID] {
+			seen[failure.HostID] = true
+			hosts = append(hosts, failure.HostID)
+		}
+	}
+	return hosts
+}
+
+// summarizeHosts classifies each target host from its pipeline records for
+// the given variants. A variant still starting after deployTimeout counts as
+// failed, as does one that was never recorded at all once the deadline has
+// passed.
+func summarizeHosts(targetHosts, variants []string, pipelines []*models.ActivePipeline, deployStarted time.Time, deployTimeout time.Duration, now time.Time) hostSummary {
+	byHost := make(map[string]map[string]*models.ActivePipeline)
+	for _, pipeline := range pipelines {
+		if byHost[pipeline.HostID] == nil {
+			byHost[pipeline.HostID] = make(map[string]*models.ActivePipeline)
+		}
+		byHost[pipeline.HostID][pipeline.Variant] = pipeline
+	}
+
+	var summary hostSummary
+	for _, host := range targetHosts {
+		running, pending := 0, 0
+		failuresBefore := len(summary.Failures)
+
+		for _, variant := range variants {
+			pipeline := byHost[host][variant]
+
+			switch {
+			case pipeline == nil:
+				if deployTimeout > 0 && now.Sub(deployStarted) > deployTimeout {
+					summary.Failures = append(summary.Failures, models.HostFailure{
+						HostID:  host,
+						Variant: variant,
+						Reason:  "collector was never started",
+					})
+				} else {
+					pending++
+				}
+
+			case pipeline.Status == "running":
+				running++
+
+			case pipeline.Status == "stopped":
+
+			case pipeline.Status == "failed":
+				failure := models.HostFailure{HostID: host, Variant: variant, Reason: "collector failed"}
+				if stopErr, ok := pipeline.ProcessInfo["stop_error"].(string); ok {
+					failure.Reason = fmt.Sprintf("collector could not be stopped: %s", stopErr)
+					summary.StopFailures = append(summary.StopFailures, failure)
+				} else if startErr, ok := pipeline.ProcessInfo["error"].(string); ok {
+					failure.Reason = fmt.Sprintf("collector failed to start: %s", startErr)
+				}
+				summary.Failures = append(summary.Failures, failure)
+
+			case pipeline.Status == "starting":
+				if deployTimeout > 0 && now.Sub(pipeline.StartedAt) > deployTimeout {
+					summary.Failures = append(summary.Failures, models.HostFailure{
+						HostID:  host,
+						Variant: variant,
+						Reason:  fmt.Sprintf("collector did not start within %s", deployTimeout),
+					})
+				} else {
+					pending++
+				}
+
+			default:
+				// Stopping or any other transitional state
+				pending++
+			}
+		}
+
+		switch {
+		case pending > 0:
+			summary.Pending = append(summary.Pending, host)
+		case running == len(variants):
+			summary.Running = append(summary.Running, host)
+		case running > 0:
+			summary.PartlyRunning = append(summary.PartlyRunning, host)
+		case len(summary.Failures) == failuresBefore:
+			summary.Stopped = append(summary.Stopped, host)
+		}
+	}
+
+	return summary
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+var twoWay = []string{"baseline", "candidate"}
+
+func pipeline(host, variant, status string, startedAt time.Time) *models.ActivePipeline {
+	return &models.ActivePipeline{
+		HostID:      host,
+		Variant:     variant,
+		Status:      status,
+		StartedAt:   startedAt,
+		ProcessInfo: map[string]interface{}{},
+	}
+}
+
+func TestSummarizeHosts_AllRunning(t *testing.T) {
+	now := time.Now()
+	pipelines := []*models.ActivePipeline{
+		pipeline("host-a", "baseline", "running", now),
+		pipeline("host-a", "candidate", "running", now),
+	}
+
+	summary := summarizeHosts([]string{"host-a"}, twoWay, pipelines, now, 10*time.Minute, now)
+
+	if len(summary.Running) != 1 || len(summary.Pending) != 0 || len(summary.Failures) != 0 {
+		t.Fatalf("expected host-a running, got %+v", summary)
+	}
+}
+
+func TestSummarizeHosts_PartialFailure(t *testing.T) {
+	now := time.Now()
+	failed := pipeline("host-b", "candidate", "failed", now)
+	failed.ProcessInfo["error"] = "failed to download config"
+
+	pipelines := []*models.ActivePipeline{
+		pipeline("host-a", "baseline", "running", now),
+		pipeline("host-a", "candidate", "running", now),
+		pipeline("host-b", "baseline", "running", now),
+		failed,
+	}
+
+	summary := summarizeHosts([]string{"host-a", "host-b"}, twoWay, pipelines, now, 10*time.Minute, now)
+
+	if len(summary.Running) != 1 || summary.Running[0] != "host-a" {
+		t.Fatalf("expected only host-a running, got %v", summary.Running)
+	}
+	if len(summary.PartlyRunning) != 1 || summary.PartlyRunning[0] != "host-b" {
+		t.Fatalf("expected host-b partly running, got %v", summary.PartlyRunning)
+	}
+	if hosts := summary.FailedHosts(); len(hosts) != 1 || hosts[0] != "host-b" {
+		t.Fatalf("expected host-b reported as failed, got %v", hosts)
+	}
+}
+
+func TestSummarizeHosts_StuckHostTimesOut(t *testing.T) {
+	started := time.Now().Add(-15 * time.Minute)
+	pipelines := []*models.ActivePipeline{
+		pipeline("host-a", "baseline", "starting", started),
+		pipeline("host-a", "candidate", "running", started),
+	}
+
+	summary := summarizeHosts([]string{"host-a", "host-b"}, twoWay, pipelines, started, 10*time.Minute, time.Now())
+
+	if len(summary.Pending) != 0 {
+		t.Fatalf("expected no pending hosts past the deadline, got %v", summary.Pending)
+	}
+	if len(summary.Failures) != 3 {
+		t.Fatalf("expected stuck baseline and both unrecorded host-b variants to fail, got %+v", summary.Failures)
+	}
+}
+
+func TestSummarizeHosts_StartingWithinDeadlineIsPending(t *testing.T) {
+	now := time.Now()
+	pipelines := []*models.ActivePipeline{
+		pipeline("host-a", "baseline", "starting", now.Add(-time.Minute)),
+		pipeline("host-a", "candidate", "running", now),
+	}
+
+	summary := summarizeHosts([]string{"host-a"}, twoWay, pipelines, now, 10*time.Minute, now)
+
+	if len(summary.Pending) != 1 || len(summary.Failures) != 0 {
+		t.Fatalf("expected host-a pending, got %+v", summary)
+	}
+}
+
+func TestSummarizeHosts_StopFailure(t *testing.T) {
+	now := time.Now()
+	stuck := pipeline("host-a", "baseline", "failed", now)
+	stuck.ProcessInfo["stop_error"] = "permission denied"
+
+	pipelines := []*models.ActivePipeline{
+		stuck,
+		pipeline("host-a", "candidate", "stopped", now),
+	}
+
+	summary := summarizeHosts([]string{"host-a"}, twoWay, pipelines, now, 10*time.Minute, now)
+
+	if len(summary.StopFailures) != 1 {
+		t.Fatalf("expected one stop failure, got %+v", summary.StopFailures)
+	}
+	if len(summary.Stopped) != 0 {
+		t.Fatalf("host with a stop failure should not count as cleanly stopped")
+	}
+}
+
+func TestSummarizeHosts_MultipleCandidates(t *testing.T) {
+	now := time.Now()
+	pipelines := []*models.ActivePipeline{
+		pipeline("host-a", "baseline", "running", now),
+		pipeline("host-a", "sampled", "running", now),
+		pipeline("host-a", "filtered", "running", now),
+		pipeline("host-b", "baseline", "running", now),
+		pipeline("host-b", "sampled", "running", now),
+		pipeline("host-b", "filtered", "failed", now),
+	}
+
+	summary := summarizeHosts([]string{"host-a", "host-b"}, []string{"baseline", "sampled", "filtered"}, pipelines, now, 10*time.Minute, now)
+
+	if len(summary.Running) != 1 || summary.Running[0] != "host-a" {
+		t.Fatalf("expected host-a running, got %+v", summary.Running)
+	}
+	if len(summary.PartlyRunning) != 1 || summary.PartlyRunning[0] != "host-b" {
+		t.Fatalf("expected host-b partly running, got %+v", summary.PartlyRunning)
+	}
+	if len(summary.Failures) != 1 || summary.Failures[0].Variant != "filtered" {
+		t.Fatalf("expected the filtered variant to fail, got %+v", summary.Failures)
+	}
+}
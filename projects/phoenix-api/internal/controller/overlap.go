@@ -0,0 +1,307 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	commonModels "github.com/phoenix/platform/pkg/common/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+// activePhases are the phases in which an experiment may have collectors
+// running on its hosts
+var activePhases = map[string]bool{
+	"deploying":  true,
+	"running":    true,
+	"monitoring": true,
+	"paused":     true,
+	"rollback":   true,
+	"stopping":   true,
+}
+
+// waitingPhases are the phases of experiments that have not started yet but
+// will run on their target hosts once they do
+var waitingPhases = map[string]bool{
+	"created":           true,
+	models.PhasePending: true,
+}
+
+// overlapInputs is what an experiment's hosts are already used by
+type overlapInputs struct {
+	experiments []*models.Experiment
+	deployments []*commonModels.PipelineDeployment
+	loadTasks   []*models.Task
+	locks       map[string]string
+}
+
+// CheckOverlap reports the experiments, pipeline deployments and load
+// simulations exp would share its hosts with. For an experiment whose hosts
+// are picked by a selector, every matching host is considered, except those
+// locked by other experiments, which the selector skips.
+func (c *ExperimentController) CheckOverlap(ctx context.Context, exp *models.Experiment) (*models.OverlapResult, error) {
+	hosts := exp.Config.TargetHosts
+	if len(hosts) == 0 && exp.Config.HostSelector != nil {
+		agents, err := c.store.ListAgents(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list agents: %w", err)
+		}
+		locks, err := c.store.GetHostLocks(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		all := *exp.Config.HostSelector
+		all.SampleSize, all.SamplePercent = 0, 0
+		if hosts, _, err = selectHosts(exp.ID, &all, unlockedAgents(agents, locks, exp.ID)); err != nil {
+			return nil, fmt.Errorf("invalid host selector: %w", err)
+		}
+	}
+
+	var in overlapInputs
+	var err error
+	if in.experiments, err = c.store.ListExperiments(ctx); err != nil {
+		return nil, fmt.Errorf("failed to list experiments: %w", err)
+	}
+	deployments, _, err := c.store.ListDeployments(ctx, &commonModels.ListDeploymentsRequest{PageSize: 1000})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	in.deployments = deployments
+	if in.locks, err = c.store.GetHostLocks(ctx, hosts); err != nil {
+		return nil, err
+	}
+	for _, host := range hosts {
+		tasks, err := c.store.GetActiveTasks(ctx, "active", host, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tasks of host %s: %w", host, err)
+		}
+		for _, task := range tasks {
+			if task.Type == "loadsim" && task.Action == "start" {
+				in.loadTasks = append(in.loadTasks, task)
+			}
+		}
+	}
+
+	return detectOverlap(exp, hosts, in), nil
+}
+
+// detectOverlap compares the hosts of exp with what they are already used
+// by. Another experiment holding or running on a host blocks exp, since
+// their collectors would compete for ports; other overlaps are warnings.
+func detectOverlap(exp *models.Experiment, hosts []string, in overlapInputs) *models.OverlapResult {
+	targets := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		targets[host] = true
+	}
+
+	var conflicts []models.OverlapConflict
+	add := func(conflict models.OverlapConflict) {
+		conflicts = append(conflicts, conflict)
+	}
+
+	locked := make(map[string]bool)
+	for host, holder := range in.locks {
+		if targets[host] && holder != exp.ID {
+			locked[host+"/"+holder] = true
+			add(models.OverlapConflict{
+				Type:         models.OverlapHost,
+				Severity:     models.OverlapSeverityBlocking,
+				HostID:       host,
+				ExperimentID: holder,
+				Message:      fmt.Sprintf("host %s is locked by experiment %s", host, holder),
+			})
+		}
+	}
+
+	for _, other := range in.experiments {
+		if other.ID == exp.ID {
+			continue
+		}
+		severity := ""
+		switch {
+		case activePhases[other.Phase]:
+			severity = models.OverlapSeverityBlocking
+		case waitingPhases[other.Phase]:
+			severity = models.OverlapSeverityWarning
+		default:
+			continue
+		}
+
+		for _, host := range other.Config.TargetHosts {
+			if !targets[host] || locked[host+"/"+other.ID] {
+				continue
+			}
+			add(models.OverlapConflict{
+				Type:         models.OverlapHost,
+				Severity:     severity,
+				HostID:       host,
+				ExperimentID: other.ID,
+				Message:      fmt.Sprintf("experiment %s (%s) targets host %s", other.ID, other.Phase, host),
+			})
+		}
+	}
+
+	for _, deployment := range in.deployments {
+		if !deploymentActive(deployment) {
+			continue
+		}
+		for _, host := range deployment.TargetNodes {
+			if !targets[host] {
+				continue
+			}
+			add(models.OverlapConflict{
+				Type:         models.OverlapPipeline,
+				Severity:     models.OverlapSeverityWarning,
+				HostID:       host,
+				DeploymentID: deployment.ID,
+				Message: fmt.Sprintf("pipeline %s of deployment %s runs on host %s",
+					deployment.PipelineName, deployment.DeploymentName, host),
+			})
+		}
+	}
+
+	for _, task := range in.loadTasks {
+		if !targets[task.HostID] || (task.ExperimentID == exp.ID && exp.ID != "") {
+			continue
+		}
+		message := fmt.Sprintf("a load simulation is %s on host %s", task.Status, task.HostID)
+		if task.ExperimentID != "" {
+			message = fmt.Sprintf("a load simulation of experiment %s is %s on host %s", task.ExperimentID, task.Status, task.HostID)
+		}
+		add(models.OverlapConflict{
+			Type:         models.OverlapLoadSimulation,
+			Severity:     models.OverlapSeverityWarning,
+			HostID:       task.HostID,
+			ExperimentID: task.ExperimentID,
+			Message:      message,
+		})
+	}
+
+	return summarizeOverlap(conflicts, exp.Config.LoadProfile != "")
+}
+
+// deploymentActive reports whether a pipeline deployment may have a
+// collector running
+func deploymentActive(deployment *commonModels.PipelineDeployment) bool {
+	if deployment.DeletedAt != nil {
+		return false
+	}
+	switch deployment.Status {
+	case commonModels.DeploymentStatusFailed, commonModels.DeploymentStatusDeleting:
+		return false
+	}
+	return deployment.Phase != commonModels.DeploymentPhaseTerminating
+}
+
+func summarizeOverlap(conflicts []models.OverlapConflict, generatesLoad bool) *models.OverlapResult {
+	result := &models.OverlapResult{
+		ConflictingExpIDs: []string{},
+		AffectedNodes:     []string{},
+		Severity:          models.OverlapSeverityNone,
+		Message:           "No overlapping experiments, deployments or load simulations",
+		Suggestions:       []string{},
+		Conflicts:         conflicts,
+	}
+	if len(conflicts) == 0 {
+		return result
+	}
+	result.HasOverlap = true
+
+	experiments := make(map[string]bool)
+	deployments := make(map[string]bool)
+	nodes := make(map[string]bool)
+	types := make(map[string]bool)
+	for _, conflict := range conflicts {
+		nodes[conflict.HostID] = true
+		types[conflict.Type] = true
+		if conflict.ExperimentID != "" {
+			experiments[conflict.ExperimentID] = true
+		}
+		if conflict.DeploymentID != "" {
+			deployments[conflict.DeploymentID] = true
+		}
+		if result.OverlapType == "" || overlapRank(conflict) > overlapRank(models.OverlapConflict{Type: result.OverlapType, Severity: result.Severity}) {
+			result.OverlapType = conflict.Type
+			result.Severity = conflict.Severity
+		}
+	}
+	result.ConflictingExpIDs = sortedKeys(experiments)
+	result.ConflictingDeploymentIDs = sortedKeys(deployments)
+	result.AffectedNodes = sortedKeys(nodes)
+
+	var parts []string
+	if types[models.OverlapHost] {
+		parts = append(parts, "other experiments")
+	}
+	if types[models.OverlapPipeline] {
+		parts = append(parts, "pipeline deployments")
+	}
+	if types[models.OverlapLoadSimulation] {
+		parts = append(parts, "load simulations")
+	}
+	result.Message = fmt.Sprintf("%d of the experiment's hosts are shared with %s",
+		len(result.AffectedNodes), strings.Join(parts, " and "))
+
+	if types[models.OverlapHost] {
+		if result.Severity == models.OverlapSeverityBlocking {
+			result.Suggestions = append(result.Suggestions,
+				"Wait for the conflicting experiments to finish, or stop them first")
+		}
+		result.Suggestions = append(result.Suggestions,
+			"Exclude the affected hosts from the target hosts or narrow the selector")
+	}
+	if types[models.OverlapPipeline] {
+		result.Suggestions = append(result.Suggestions,
+			"Metrics of the deployed pipelines will mix with the experiment's; undeploy them from the affected hosts for a clean comparison")
+	}
+	if types[models.OverlapLoadSimulation] {
+		suggestion := "Stop the running load simulations, as they inflate the experiment's metrics"
+		if generatesLoad {
+			suggestion = "Stop the running load simulations or drop the experiment's load profile, as both would load the same hosts"
+		}
+		result.Suggestions = append(result.Suggestions, suggestion)
+	}
+
+	return result
+}
+
+// overlapRank orders conflicts by severity, then by type, so the reported
+// overlap type is that of the most severe conflict
+func overlapRank(conflict models.OverlapConflict) int {
+	rank := 0
+	if conflict.Severity == models.OverlapSeverityBlocking {
+		rank = 10
+	}
+	switch conflict.Type {
+	case models.OverlapHost:
+		rank += 3
+	case models.OverlapPipeline:
+		rank += 2
+	case models.OverlapLoadSimulation:
+		rank++
+	}
+	return rank
+}
+
+// unlockedAgents leaves out the agents whose host is locked by an
+// experiment other than experimentID
+func unlockedAgents(agents []*models.AgentStatus, locks map[string]string, experimentID string) []*models.AgentStatus {
+	free := make([]*models.AgentStatus, 0, len(agents))
+	for _, agent := range agents {
+		if holder, ok := locks[agent.HostID]; ok && holder != experimentID {
+			continue
+		}
+		free = append(free, agent)
+	}
+	return free
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	commonModels "github.com/phoenix/platform/pkg/common/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func TestDetectOverlap_None(t *testing.T) {
+	exp := &models.Experiment{ID: "exp-1", Config: models.ExperimentConfig{TargetHosts: []string{"web-1"}}}
+	in := overlapInputs{
+		experiments: []*models.Experiment{
+			exp,
+			{ID: "exp-2", Phase: "running", Config: models.ExperimentConfig{TargetHosts: []string{"web-2"}}},
+			{ID: "exp-3", Phase: "completed", Config: models.ExperimentConfig{TargetHosts: []string{"web-1"}}},
+		},
+		locks: map[string]string{"web-1": "exp-1"},
+	}
+
+	result := detectOverlap(exp, exp.Config.TargetHosts, in)
+	if result.HasOverlap || result.Severity != models.OverlapSeverityNone || len(result.Conflicts) != 0 {
+		t.Fatalf("unexpected overlap: %+v", result)
+	}
+}
+
+func TestDetectOverlap_Blocking(t *testing.T) {
+	exp := &models.Experiment{Config: models.ExperimentConfig{TargetHosts: []string{"web-1", "web-2", "web-3"}}}
+	in := overlapInputs{
+		experiments: []*models.Experiment{
+			{ID: "exp-2", Phase: "running", Config: models.ExperimentConfig{TargetHosts: []string{"web-2", "web-9"}}},
+			{ID: "exp-3", Phase: "created", Config: models.ExperimentConfig{TargetHosts: []string{"web-3"}}},
+		},
+		deployments: []*commonModels.PipelineDeployment{
+			{ID: "dep-1", Status: commonModels.DeploymentStatusActive, TargetNodes: map[string]string{"a": "web-1"}},
+			{ID: "dep-2", Status: commonModels.DeploymentStatusFailed, TargetNodes: map[string]string{"a": "web-3"}},
+		},
+		// The lock and the running experiment are one conflict
+		locks: map[string]string{"web-2": "exp-2"},
+	}
+
+	result := detectOverlap(exp, exp.Config.TargetHosts, in)
+	if !result.HasOverlap || result.Severity != models.OverlapSeverityBlocking || result.OverlapType != models.OverlapHost {
+		t.Fatalf("got severity %q type %q", result.Severity, result.OverlapType)
+	}
+	if len(result.Conflicts) != 3 {
+		t.Fatalf("got %d conflicts: %+v", len(result.Conflicts), result.Conflicts)
+	}
+	if !reflect.DeepEqual(result.ConflictingExpIDs, []string{"exp-2", "exp-3"}) {
+		t.Errorf("got conflicting experiments %v", result.ConflictingExpIDs)
+	}
+	if !reflect.DeepEqual(result.ConflictingDeploymentIDs, []string{"dep-1"}) {
+		t.Errorf("got conflicting deployments %v", result.ConflictingDeploymentIDs)
+	}
+	if !reflect.DeepEqual(result.AffectedNodes, []string{"web-1", "web-2", "web-3"}) {
+		t.Errorf("got affected nodes %v", result.AffectedNodes)
+	}
+	if len(result.Suggestions) != 3 {
+		t.Errorf("got suggestions %v", result.Suggestions)
+	}
+}
+
+func TestDetectOverlap_LoadSimulation(t *testing.T) {
+	exp := &models.Experiment{ID: "exp-1", Config: models.ExperimentConfig{TargetHosts: []string{"web-1"}, LoadProfile: "high"}}
+	in := overlapInputs{
+		loadTasks: []*models.Task{
+			{HostID: "web-1", ExperimentID: "exp-1", Type: "loadsim", Action: "start", Status: "running"},
+			{HostID: "web-1", Type: "loadsim", Action: "start", Status: "pending"},
+			{HostID: "web-2", ExperimentID: "exp-2", Type: "loadsim", Action: "start", Status: "running"},
+		},
+	}
+
+	result := detectOverlap(exp, exp.Config.TargetHosts, in)
+	if result.Severity != models.OverlapSeverityWarning || result.OverlapType != models.OverlapLoadSimulation {
+		t.Fatalf("got severity %q type %q", result.Severity, result.OverlapType)
+	}
+	if len(result.Conflicts) != 1 || len(result.ConflictingExpIDs) != 0 {
+		t.Fatalf("got conflicts %+v", result.Conflicts)
+	}
+}
+
+func TestUnlockedAgents(t *testing.T) {
+	agents := []*models.AgentStatus{{HostID: "web-1"}, {HostID: "web-2"}, {HostID: "web-3"}}
+	locks := map[string]string{"web-1": "exp-1", "web-2": "exp-2"}
+
+	var hosts []string
+	for _, agent := range unlockedAgents(agents, locks, "exp-1") {
+		hosts = append(hosts, agent.HostID)
+	}
+	if !reflect.DeepEqual(hosts, []string{"web-1", "web-3"}) {
+		t.Fatalf("got hosts %v", hosts)
+	}
+}
@@ -0,0 +1,387 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultMinHostSuccessPercent is the share of deployed hosts that must run
+// every variant when a rollout gate does not say
+const defaultMinHostSuccessPercent = 100
+
+var (
+	// ErrNoRollout is returned for rollout operations on an experiment
+	// without a rollout plan
+	ErrNoRollout = errors.New("experiment has no rollout plan")
+	// ErrRolloutNotPaused is returned when resuming a rollout that is not
+	// paused
+	ErrRolloutNotPaused = errors.New("rollout is not paused")
+	// ErrRolloutNotActive is returned when aborting a rollout that is not
+	// in progress
+	ErrRolloutNotActive = errors.New("rollout is not in progress")
+)
+
+// ValidateRolloutPlan checks that every wave has a size and that gates are
+// well formed
+func ValidateRolloutPlan(plan *models.RolloutPlan) error {
+	if plan == nil {
+		return nil
+	}
+	if len(plan.Waves) == 0 {
+		return fmt.Errorf("rollout: at least one wave is required")
+	}
+
+	for i, wave := range plan.Waves {
+		switch {
+		case wave.Hosts < 0 || wave.Percent < 0:
+			return fmt.Errorf("rollout wave %d: size must not be negative", i)
+		case wave.Hosts > 0 && wave.Percent > 0:
+			return fmt.Errorf("rollout wave %d: hosts and percent are mutually exclusive", i)
+		case wave.Hosts == 0 && wave.Percent == 0:
+			return fmt.Errorf("rollout wave %d: hosts or percent is required", i)
+		case wave.Percent > 100:
+			return fmt.Errorf("rollout wave %d: percent must not exceed 100", i)
+		case wave.SoakTime < 0:
+			return fmt.Errorf("rollout wave %d: soak_time must not be negative", i)
+		}
+
+		if wave.Gate == nil {
+			continue
+		}
+		if wave.Gate.MinHostSuccessPercent < 0 || wave.Gate.MinHostSuccessPercent > 100 {
+			return fmt.Errorf("rollout wave %d: min_host_success_percent must be between 0 and 100", i)
+		}
+		if err := ValidateGuardrails(wave.Gate.KPIs); err != nil {
+			return fmt.Errorf("rollout wave %d gate: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// rolloutWaves splits hosts into the waves of a rollout plan, in order. The
+// last wave takes every remaining host; waves left without hosts are dropped.
+func rolloutWaves(hosts []string, waves []models.RolloutWave) [][]string {
+	var result [][]string
+	next := 0
+	for i, wave := range waves {
+		if next >= len(hosts) {
+			break
+		}
+
+		size := wave.Hosts
+		if wave.Percent > 0 {
+			size = int(math.Ceil(float64(len(hosts)) * wave.Percent / 100))
+		}
+		if size < 1 {
+			size = 1
+		}
+
+		end := next + size
+		if i == len(waves)-1 || end > len(hosts) {
+			end = len(hosts)
+		}
+		result = append(result, hosts[next:end])
+		next = end
+	}
+	return result
+}
+
+// rolloutInProgress reports whether exp is being deployed wave by wave
+func rolloutInProgress(exp *models.Experiment) bool {
+	r := exp.Config.Rollout
+	if r == nil {
+		return false
+	}
+	switch r.State {
+	case models.RolloutDeploying, models.RolloutSoaking, models.RolloutPaused:
+		return true
+	}
+	return false
+}
+
+// deployedHosts returns the target hosts collectors were started on: the
+// waves reached so far for an unfinished rollout, every host otherwise
+func deployedHosts(exp *models.Experiment) []string {
+	r := exp.Config.Rollout
+	if r == nil || (!rolloutInProgress(exp) && r.State != models.RolloutAborted) {
+		return exp.Config.TargetHosts
+	}
+
+	var hosts []string
+	for i, wave := range rolloutWaves(exp.Config.TargetHosts, r.Waves) {
+		if i > r.CurrentWave {
+			break
+		}
+		hosts = append(hosts, wave...)
+	}
+	return hosts
+}
+
+// startRollout deploys the first wave of an experiment's rollout plan
+func (c *ExperimentController) startRollout(ctx context.Context, exp *models.Experiment) error {
+	r := exp.Config.Rollout
+	r.CurrentWave = 0
+	r.State = models.RolloutDeploying
+	r.PausedReason = ""
+
+	if err := c.store.UpdateExperiment(ctx, exp); err != nil {
+		return fmt.Errorf("failed to save rollout state: %w", err)
+	}
+
+	return c.deployWave(ctx, exp)
+}
+
+// deployWave starts the collectors of the rollout's current wave
+func (c *ExperimentController) deployWave(ctx context.Context, exp *models.Experiment) error {
+	r := exp.Config.Rollout
+	waves := rolloutWaves(exp.Config.TargetHosts, r.Waves)
+	hosts := waves[r.CurrentWave]
+
+	if err := c.deployHosts(ctx, exp, hosts); err != nil {
+		return err
+	}
+
+	c.recordRolloutEvent(ctx, exp, "rollout_wave_started",
+		fmt.Sprintf("Wave %d of %d started on %d hosts", r.CurrentWave+1, len(waves), len(hosts)),
+		map[string]interface{}{"hosts": hosts})
+
+	return nil
+}
+
+// soakWave lets a deployed wave run for its soak time before its gate is
+// checked
+func (c *ExperimentController) soakWave(ctx context.Context, exp *models.Experiment) error {
+	r := exp.Config.Rollout
+	r.State = models.RolloutSoaking
+
+	if err := c.store.UpdateExperiment(ctx, exp); err != nil {
+		return fmt.Errorf("failed to save rollout state: %w", err)
+	}
+
+	soakTime := r.Waves[r.CurrentWave].SoakTime
+	if _, err := c.store.ScheduleTransition(ctx, exp.ID, TransitionRolloutGate, soakTime); err != nil {
+		return fmt.Errorf("failed to schedule rollout gate: %w", err)
+	}
+
+	c.recordRolloutEvent(ctx, exp, "rollout_wave_deployed",
+		fmt.Sprintf("Wave %d deployed, soaking for %s", r.CurrentWave+1, soakTime), nil)
+
+	return nil
+}
+
+// EvaluateRolloutGate checks the gate of a soaked wave. The rollout moves on
+// to the next wave when it passes and pauses when it fails. metrics may be
+// nil, in which case gates with KPI conditions fail.
+func (c *ExperimentController) EvaluateRolloutGate(ctx context.Context, exp *models.Experiment, metrics GuardrailMetrics) error {
+	r := exp.Config.Rollout
+	wave := r.Waves[r.CurrentWave]
+
+	reasons, err := c.checkGate(ctx, exp, wave, metrics)
+	if err != nil {
+		return err
+	}
+
+	if len(reasons) > 0 {
+		return c.pauseRollout(ctx, exp, reasons)
+	}
+
+	c.recordRolloutEvent(ctx, exp, "rollout_gate_passed",
+		fmt.Sprintf("Gate of wave %d passed", r.CurrentWave+1), nil)
+
+	return c.advanceRollout(ctx, exp)
+}
+
+// checkGate returns why the current wave fails its gate, if it does
+func (c *ExperimentController) checkGate(ctx context.Context, exp *models.Experiment, wave models.RolloutWave, metrics GuardrailMetrics) ([]string, error) {
+	gate := wave.Gate
+	if gate == nil {
+		gate = &models.RolloutGate{}
+	}
+
+	var reasons []string
+
+	summary, err := c.summarize(ctx, exp)
+	if err != nil {
+		return nil, err
+	}
+
+	minSuccess := gate.MinHostSuccessPercent
+	if minSuccess == 0 {
+		minSuccess = defaultMinHostSuccessPercent
+	}
+	deployed := len(deployedHosts(exp))
+	if deployed > 0 {
+		success := float64(len(summary.Running)) / float64(deployed) * 100
+		if success < minSuccess {
+			reasons = append(reasons, fmt.Sprintf("%d of %d hosts running (%.0f%%), gate requires %.0f%%",
+				len(summary.Running), deployed, success, minSuccess))
+		}
+	}
+
+	if len(gate.KPIs) == 0 {
+		return reasons, nil
+	}
+	if metrics == nil {
+		return append(reasons, "no metrics available to check KPIs"), nil
+	}
+
+	window := wave.SoakTime
+	if window <= 0 {
+		window = guardrailWindow
+	}
+	candidates, err := measureCandidates(ctx, metrics, exp, window)
+	if err != nil {
+		return append(reasons, fmt.Sprintf("KPIs could not be calculated: %v", err)), nil
+	}
+
+	// Every candidate has to pass. A KPI that cannot be measured fails the
+	// gate rather than waving the rollout through.
+	for _, kpi := range gate.KPIs {
+		for _, candidate := range candidates {
+			breached, ok := evaluateGuardrail(kpi, candidate.values)
+			switch {
+			case !ok:
+				reasons = append(reasons, fmt.Sprintf("KPI %s could not be measured for %s", guardrailName(kpi), candidate.variant))
+			case breached:
+				reasons = append(reasons, fmt.Sprintf("KPI %s breached by %s", guardrailName(kpi), candidate.variant))
+			}
+		}
+	}
+
+	return reasons, nil
+}
+
+// advanceRollout deploys the next wave, or finishes the rollout after the
+// last one and lets the experiment run
+func (c *ExperimentController) advanceRollout(ctx context.Context, exp *models.Experiment) error {
+	r := exp.Config.Rollout
+	waves := rolloutWaves(exp.Config.TargetHosts, r.Waves)
+
+	if r.CurrentWave+1 < len(waves) {
+		r.CurrentWave++
+		r.State = models.RolloutDeploying
+		exp.Phase = "deploying"
+
+		if err := c.store.UpdateExperiment(ctx, exp); err != nil {
+			return fmt.Errorf("failed to save rollout state: %w", err)
+		}
+		return c.deployWave(ctx, exp)
+	}
+
+	r.State = models.RolloutCompleted
+	exp.Phase = "running"
+
+	if err := c.store.UpdateExperiment(ctx, exp); err != nil {
+		return fmt.Errorf("failed to save rollout state: %w", err)
+	}
+
+	c.recordRolloutEvent(ctx, exp, "rollout_completed",
+		fmt.Sprintf("Rollout completed on %d hosts", len(exp.Config.TargetHosts)), nil)
+
+	return c.scheduleRunTransitions(ctx, exp)
+}
+
+// pauseRollout halts a rollout whose gate failed until it is resumed or
+// aborted
+func (c *ExperimentController) pauseRollout(ctx context.Context, exp *models.Experiment, reasons []string) error {
+	r := exp.Config.Rollout
+	r.State = models.RolloutPaused
+	r.PausedReason = strings.Join(reasons, "; ")
+	exp.Phase = "paused"
+
+	if err := c.store.UpdateExperiment(ctx, exp); err != nil {
+		return fmt.Errorf("failed to save rollout state: %w", err)
+	}
+
+	log.Warn().
+		Str("experiment_id", exp.ID).
+		Int("wave", r.CurrentWave+1).
+		Strs("reasons", reasons).
+		Msg("Rollout gate failed, pausing rollout")
+
+	c.recordRolloutEvent(ctx, exp, "rollout_paused",
+		fmt.Sprintf("Gate of wave %d failed: %s", r.CurrentWave+1, r.PausedReason),
+		map[string]interface{}{"reasons": reasons})
+
+	return nil
+}
+
+// ResumeRollout continues a paused rollout past the wave whose gate failed
+func (c *ExperimentController) ResumeRollout(ctx context.Context, experimentID string) error {
+	exp, err := c.store.GetExperiment(ctx, experimentID)
+	if err != nil {
+		return fmt.Errorf("failed to get experiment: %w", err)
+	}
+
+	r := exp.Config.Rollout
+	if r == nil {
+		return ErrNoRollout
+	}
+	if exp.Phase != "paused" || r.State != models.RolloutPaused {
+		return ErrRolloutNotPaused
+	}
+
+	c.recordRolloutEvent(ctx, exp, "rollout_resumed",
+		fmt.Sprintf("Rollout resumed after wave %d", r.CurrentWave+1),
+		map[string]interface{}{"paused_reason": r.PausedReason})
+	r.PausedReason = ""
+
+	return c.advanceRollout(ctx, exp)
+}
+
+// AbortRollout ends an unfinished rollout and stops the experiment on the
+// hosts it has reached
+func (c *ExperimentController) AbortRollout(ctx context.Context, experimentID string) error {
+	exp, err := c.store.GetExperiment(ctx, experimentID)
+	if err != nil {
+		return fmt.Errorf("failed to get experiment: %w", err)
+	}
+
+	r := exp.Config.Rollout
+	if r == nil {
+		return ErrNoRollout
+	}
+	if !rolloutInProgress(exp) {
+		return ErrRolloutNotActive
+	}
+
+	r.State = models.RolloutAborted
+	if err := c.store.UpdateExperiment(ctx, exp); err != nil {
+		return fmt.Errorf("failed to save rollout state: %w", err)
+	}
+
+	c.recordRolloutEvent(ctx, exp, "rollout_aborted",
+		fmt.Sprintf("Rollout aborted at wave %d", r.CurrentWave+1), nil)
+
+	return c.StopExperiment(ctx, experimentID)
+}
+
+func (c *ExperimentController) recordRolloutEvent(ctx context.Context, exp *models.Experiment, eventType, message string, metadata map[string]interface{}) {
+	r := exp.Config.Rollout
+	eventMetadata := map[string]interface{}{
+		"wave":  r.CurrentWave + 1,
+		"state": r.State,
+	}
+	for key, value := range metadata {
+		eventMetadata[key] = value
+	}
+
+	event := &models.ExperimentEvent{
+		ExperimentID: exp.ID,
+		EventType:    eventType,
+		Phase:        exp.Phase,
+		Message:      message,
+		Metadata:     eventMetadata,
+	}
+
+	if err := c.store.CreateExperimentEvent(ctx, event); err != nil {
+		log.Error().Err(err).Str("experiment_id", exp.ID).Msg("Failed to create experiment event")
+	}
+}
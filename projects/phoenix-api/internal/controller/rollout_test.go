@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func TestRolloutWaves(t *testing.T) {
+	hosts := []string{"h1", "h2", "h3", "h4", "h5", "h6", "h7", "h8", "h9", "h10"}
+
+	waves := rolloutWaves(hosts, []models.RolloutWave{
+		{Hosts: 1},
+		{Percent: 25},
+		{Percent: 50},
+	})
+	want := [][]string{
+		{"h1"},
+		{"h2", "h3", "h4"},
+		{"h5", "h6", "h7", "h8", "h9", "h10"},
+	}
+	if !reflect.DeepEqual(waves, want) {
+		t.Fatalf("got %v, want %v", waves, want)
+	}
+
+	// Waves beyond the last host are dropped
+	waves = rolloutWaves(hosts[:2], []models.RolloutWave{{Hosts: 2}, {Hosts: 5}})
+	if !reflect.DeepEqual(waves, [][]string{{"h1", "h2"}}) {
+		t.Fatalf("got %v", waves)
+	}
+}
+
+func TestDeployedHosts(t *testing.T) {
+	exp := &models.Experiment{
+		Config: models.ExperimentConfig{
+			TargetHosts: []string{"h1", "h2", "h3", "h4"},
+			Rollout: &models.RolloutPlan{
+				Waves:       []models.RolloutWave{{Hosts: 1}, {Hosts: 1}, {Percent: 100}},
+				CurrentWave: 1,
+				State:       models.RolloutPaused,
+			},
+		},
+	}
+
+	if hosts := deployedHosts(exp); !reflect.DeepEqual(hosts, []string{"h1", "h2"}) {
+		t.Fatalf("paused rollout: got %v", hosts)
+	}
+
+	exp.Config.Rollout.State = models.RolloutAborted
+	if hosts := deployedHosts(exp); !reflect.DeepEqual(hosts, []string{"h1", "h2"}) {
+		t.Fatalf("aborted rollout: got %v", hosts)
+	}
+
+	exp.Config.Rollout.State = models.RolloutCompleted
+	if hosts := deployedHosts(exp); len(hosts) != 4 {
+		t.Fatalf("completed rollout: got %v", hosts)
+	}
+}
+
+func TestValidateRolloutPlan(t *testing.T) {
+	plan := &models.RolloutPlan{
+		Waves: []models.RolloutWave{
+			{Hosts: 1, SoakTime: 10 * time.Minute, Gate: &models.RolloutGate{
+				MinHostSuccessPercent: 90,
+				KPIs:                  []models.Guardrail{{Metric: "cpu_usage", Operator: ">", Threshold: 80}},
+			}},
+			{Percent: 100},
+		},
+	}
+	if err := ValidateRolloutPlan(plan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, plan := range []*models.RolloutPlan{
+		{},
+		{Waves: []models.RolloutWave{{}}},
+		{Waves: []models.RolloutWave{{Hosts: 1, Percent: 10}}},
+		{Waves: []models.RolloutWave{{Hosts: -1}}},
+		{Waves: []models.RolloutWave{{Percent: 120}}},
+		{Waves: []models.RolloutWave{{Hosts: 1, SoakTime: -time.Minute}}},
+		{Waves: []models.RolloutWave{{Hosts: 1, Gate: &models.RolloutGate{MinHostSuccessPercent: 101}}}},
+		{Waves: []models.RolloutWave{{Hosts: 1, Gate: &models.RolloutGate{KPIs: []models.Guardrail{{}}}}}},
+	} {
+		if err := ValidateRolloutPlan(plan); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// maxDueSchedules bounds the schedules started per scheduler tick
+const maxDueSchedules = 10
+
+// ValidateSchedule checks that a schedule has a start time or a valid cron
+// expression and a known time zone
+func ValidateSchedule(schedule *models.ExperimentSchedule) error {
+	if schedule == nil {
+		return nil
+	}
+	if schedule.StartAt == nil && schedule.Cron == "" {
+		return fmt.Errorf("schedule: start_at or cron is required")
+	}
+	if schedule.Cron != "" {
+		if _, err := parseCron(schedule.Cron); err != nil {
+			return fmt.Errorf("schedule: %w", err)
+		}
+	}
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("schedule: unknown timezone %q", schedule.Timezone)
+	}
+	return nil
+}
+
+// NextScheduleRun returns when a schedule should next start a run after the
+// given time, or nil if it should not run again. A schedule without a cron
+// expression runs once, at StartAt.
+func NextScheduleRun(schedule *models.ExperimentSchedule, after time.Time) (*time.Time, error) {
+	if schedule.Cron == "" {
+		if schedule.RunCount > 0 || schedule.StartAt == nil {
+			return nil, nil
+		}
+		startAt := *schedule.StartAt
+		return &startAt, nil
+	}
+
+	cron, err := parseCron(schedule.Cron)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	// A run may fall exactly on StartAt
+	if schedule.StartAt != nil && schedule.StartAt.After(after) {
+		after = schedule.StartAt.Add(-time.Nanosecond)
+	}
+
+	next := cron.next(after.In(loc))
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// newScheduledRun copies a scheduled experiment into a new experiment for
+// one run. Hosts chosen by a selector are picked afresh for every run.
+func newScheduledRun(template *models.Experiment, schedule *models.ExperimentSchedule, runNumber int) (*models.Experiment, error) {
+	data, err := json.Marshal(template.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy experiment config: %w", err)
+	}
+	var config models.ExperimentConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to copy experiment config: %w", err)
+	}
+
+	if config.HostSelector != nil {
+		config.TargetHosts = nil
+		config.HostSelector.ResolvedAt = nil
+		config.HostSelector.MatchedHosts = 0
+	}
+	if config.Rollout != nil {
+		config.Rollout.CurrentWave = 0
+		config.Rollout.State = ""
+		config.Rollout.PausedReason = ""
+	}
+
+	metadata := make(map[string]interface{}, len(template.Metadata)+3)
+	for key, value := range template.Metadata {
+		metadata[key] = value
+	}
+	metadata["scheduled_from"] = template.ID
+	metadata["schedule_id"] = schedule.ID
+	metadata["schedule_run"] = runNumber
+
+	return &models.Experiment{
+		ID:          fmt.Sprintf("%s-run-%d", template.ID, runNumber),
+		Name:        fmt.Sprintf("%s (run %d)", template.Name, runNumber),
+		Description: template.Description,
+		Phase:       "created",
+		Config:      config,
+		Metadata:    metadata,
+	}, nil
+}
+
+// StartScheduledRun creates a new run of a scheduled experiment and starts
+// it. The run is returned even if starting it fails.
+func (c *ExperimentController) StartScheduledRun(ctx context.Context, template *models.Experiment, schedule *models.ExperimentSchedule, runNumber int) (*models.Experiment, error) {
+	run, err := newScheduledRun(template, schedule, runNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.store.CreateExperiment(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to create experiment run: %w", err)
+	}
+
+	if err := c.StartExperiment(ctx, run); err != nil {
+		return run, err
+	}
+
+	return run, nil
+}
+
+// processDueSchedules starts a run for every schedule that is due
+func (s *Scheduler) processDueSchedules(ctx context.Context) {
+	schedules, err := s.store.GetDueSchedules(ctx, maxDueSchedules)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get due experiment schedules")
+		return
+	}
+
+	for _, schedule := range schedules {
+		s.runSchedule(ctx, schedule)
+	}
+}
+
+// runSchedule starts the due run of a schedule and records it in the
+// schedule's history. Runs missed while no scheduler was running are not
+// made up; the schedule moves on to its next time after now.
+func (s *Scheduler) runSchedule(ctx context.Context, schedule *models.ExperimentSchedule) {
+	dueAt := *schedule.NextRunAt
+	logger := log.With().Str("experiment_id", schedule.ExperimentID).Str("schedule_id", schedule.ID).Logger()
+
+	var next *time.Time
+	if schedule.Cron != "" {
+		var err error
+		if next, err = NextScheduleRun(schedule, time.Now()); err != nil {
+			logger.Error().Err(err).Msg("Invalid experiment schedule")
+		}
+	}
+
+	runNumber, claimed, err := s.store.ClaimScheduleRun(ctx, schedule.ID, dueAt, next)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to claim experiment schedule run")
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	record := &models.ExperimentScheduleRun{
+		ScheduleID:   schedule.ID,
+		RunNumber:    runNumber,
+		ScheduledFor: dueAt,
+		Status:       "started",
+	}
+
+	template, err := s.store.GetExperiment(ctx, schedule.ExperimentID)
+	var run *models.Experiment
+	if err == nil {
+		run, err = s.controller.StartScheduledRun(ctx, template, schedule, runNumber)
+	}
+	if run != nil {
+		record.ExperimentID = run.ID
+	}
+	if err != nil {
+		record.Status = "failed"
+		record.Error = err.Error()
+		logger.Error().Err(err).Int("run", runNumber).Msg("Failed to start scheduled experiment run")
+	}
+
+	if err := s.store.CreateScheduleRun(ctx, record); err != nil {
+		logger.Error().Err(err).Msg("Failed to record schedule run")
+	}
+
+	metadata := map[string]interface{}{
+		"run":           runNumber,
+		"run_id":        record.ExperimentID,
+		"scheduled_for": dueAt,
+	}
+	if next != nil {
+		metadata["next_run_at"] = *next
+	}
+
+	if record.Status == "failed" {
+		s.recordEvent(ctx, schedule.ExperimentID, "schedule_run_failed", "scheduled",
+			fmt.Sprintf("Scheduled run %d failed to start: %s", runNumber, record.Error), metadata)
+		return
+	}
+
+	s.recordEvent(ctx, schedule.ExperimentID, "schedule_run_started", "scheduled",
+		fmt.Sprintf("Scheduled run %d started as %s", runNumber, record.ExperimentID), metadata)
+	logger.Info().Int("run", runNumber).Str("run_id", record.ExperimentID).Msg("Started scheduled experiment run")
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func TestNextScheduleRun(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 30, 0, 0, time.UTC)
+	startAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	// A one-off schedule runs at StartAt, once
+	once := &models.ExperimentSchedule{StartAt: &startAt}
+	if next, _ := NextScheduleRun(once, now); next == nil || !next.Equal(startAt) {
+		t.Fatalf("one-off schedule: got %v", next)
+	}
+	once.RunCount = 1
+	if next, _ := NextScheduleRun(once, now); next != nil {
+		t.Fatalf("one-off schedule ran twice: %v", next)
+	}
+
+	// A cron schedule does not fire before StartAt, but may fire on it
+	weekly := &models.ExperimentSchedule{Cron: "0 0 * * thu", StartAt: &startAt}
+	if next, _ := NextScheduleRun(weekly, now); next == nil || !next.Equal(startAt) {
+		t.Fatalf("weekly schedule: got %v", next)
+	}
+
+	// Cron expressions are evaluated in the schedule's time zone
+	nightly := &models.ExperimentSchedule{Cron: "0 2 * * *", Timezone: "America/New_York"}
+	want := time.Date(2024, 1, 11, 7, 0, 0, 0, time.UTC)
+	if next, _ := NextScheduleRun(nightly, now); next == nil || !next.Equal(want) {
+		t.Fatalf("nightly schedule: got %v, want %v", next, want)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	if err := ValidateSchedule(&models.ExperimentSchedule{Cron: "0 3 * * 1", Timezone: "Europe/Berlin"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, schedule := range []*models.ExperimentSchedule{
+		{},
+		{Cron: "0 3 * *"},
+		{Cron: "0 3 * * *", Timezone: "Mars/Olympus"},
+	} {
+		if err := ValidateSchedule(schedule); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+}
+
+func TestNewScheduledRun(t *testing.T) {
+	resolved := time.Now()
+	template := &models.Experiment{
+		ID:    "exp-1",
+		Name:  "weekly-topk",
+		Phase: "scheduled",
+		Config: models.ExperimentConfig{
+			TargetHosts:  []string{"web-1"},
+			HostSelector: &models.HostSelector{Selector: "role=web", ResolvedAt: &resolved, MatchedHosts: 1},
+			Rollout:      &models.RolloutPlan{Waves: []models.RolloutWave{{Hosts: 1}}, CurrentWave: 1, State: models.RolloutAborted},
+		},
+		Metadata: map[string]interface{}{"namespace": "default"},
+	}
+	schedule := &models.ExperimentSchedule{ID: "sched-1", ExperimentID: "exp-1"}
+
+	run, err := newScheduledRun(template, schedule, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if run.ID != "exp-1-run-3" || run.Name != "weekly-topk (run 3)" || run.Phase != "created" {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+	if run.Config.TargetHosts != nil || run.Config.HostSelector.ResolvedAt != nil {
+		t.Fatalf("selector was not reset: %+v", run.Config)
+	}
+	if run.Config.Rollout.State != "" || run.Config.Rollout.CurrentWave != 0 {
+		t.Fatalf("rollout was not reset: %+v", run.Config.Rollout)
+	}
+	if run.Metadata["scheduled_from"] != "exp-1" || run.Metadata["schedule_run"] != 3 || run.Metadata["namespace"] != "default" {
+		t.Fatalf("unexpected metadata: %v", run.Metadata)
+	}
+
+	// The template is left untouched
+	if template.Config.HostSelector.ResolvedAt == nil || len(template.Config.TargetHosts) != 1 {
+		t.Fatalf("template was modified: %+v", template.Config)
+	}
+}
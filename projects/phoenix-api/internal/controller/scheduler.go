@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// Scheduled transition kinds
+const (
+	// TransitionStartMonitoring ends warmup and moves a running experiment
+	// to monitoring
+	TransitionStartMonitoring = "start_monitoring"
+	// TransitionComplete ends an experiment after its configured duration
+	// and analyzes the results
+	TransitionComplete = "complete"
+	// TransitionRolloutGate checks the gate of a rollout wave once it has
+	// soaked
+	TransitionRolloutGate = "rollout_gate"
+)
+
+const (
+	// schedulerLockKey identifies the advisory lock that elects the single
+	// API replica executing scheduled transitions
+	schedulerLockKey int64 = 0x70686f656e6978
+
+	schedulerInterval      = 5 * time.Second
+	maxDueTransitions      = 20
+	maxTransitionAttempts  = 5
+	transitionRetryBackoff = 30 * time.Second
+)
+
+// ExperimentAnalyzer produces the final analysis of a completed experiment
+type ExperimentAnalyzer interface {
+	AnalyzeExperiment(ctx context.Context, experimentID string) (*models.KPIResult, error)
+	GetRecommendation(result *models.KPIResult) string
+}
+
+// Scheduler executes persisted experiment phase transitions once they fall
+// due. Every API replica runs one, but only the replica holding the scheduler
+// advisory lock acts, so each transition fires once even across restarts.
+// The leader also evaluates experiment guardrails, starts scheduled runs and
+// checks agent liveness.
+type Scheduler struct {
+	store      store.Store
+	controller *ExperimentController
+	analyzer   ExperimentAnalyzer
+	guardrails *GuardrailMonitor
+	liveness   *LivenessMonitor
+	lock       *store.AdvisoryLock
+}
+
+// NewScheduler creates a scheduler that completes experiments through
+// controller, analyzes them with analyzer, evaluates their guardrails with
+// guardrails and checks agent heartbeats with liveness
+func NewScheduler(store store.Store, controller *ExperimentController, analyzer ExperimentAnalyzer, guardrails *GuardrailMonitor, liveness *LivenessMonitor) *Scheduler {
+	return &Scheduler{
+		store:      store,
+		controller: controller,
+		analyzer:   analyzer,
+		guardrails: guardrails,
+		liveness:   liveness,
+	}
+}
+
+// Run executes due transitions and starts due experiment schedules until ctx
+// is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	guardrailTicker := time.NewTicker(guardrailInterval)
+	defer guardrailTicker.Stop()
+
+	livenessTicker := time.NewTicker(livenessInterval)
+	defer livenessTicker.Stop()
+
+	log.Info().Msg("Experiment scheduler started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			if s.lock != nil {
+				if err := s.lock.Release(context.Background()); err != nil {
+					log.Warn().Err(err).Msg("Failed to release scheduler lock")
+				}
+			}
+			log.Info().Msg("Experiment scheduler stopping")
+			return
+
+		case <-ticker.C:
+			if !s.ensureLeader(ctx) {
+				continue
+			}
+			s.processDue(ctx)
+			s.processDueSchedules(ctx)
+
+		case <-guardrailTicker.C:
+			if s.guardrails == nil || !s.ensureLeader(ctx) {
+				continue
+			}
+			s.guardrails.Evaluate(ctx)
+
+		case <-livenessTicker.C:
+			if s.liveness == nil || !s.ensureLeader(ctx) {
+				continue
+			}
+			s.liveness.Check(ctx)
+		}
+	}
+}
+
+// ensureLeader reports whether this replica holds the scheduler lock,
+// acquiring it if it is free
+func (s *Scheduler) ensureLeader(ctx context.Context) bool {
+	if s.lock != nil {
+		if err := s.lock.Check(ctx); err == nil {
+			return true
+		}
+		log.Warn().Msg("Lost scheduler lock connection, re-electing")
+		s.lock.Release(ctx)
+		s.lock = nil
+	}
+
+	lock, err := s.store.TryAdvisoryLock(ctx, schedulerLockKey)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to acquire scheduler lock")
+		return false
+	}
+	if lock == nil {
+		return false
+	}
+
+	log.Info().Msg("Acquired scheduler lock, executing scheduled transitions")
+	s.lock = lock
+	return true
+}
+
+func (s *Scheduler) processDue(ctx context.Context) {
+	transitions, err := s.store.GetDueTransitions(ctx, maxDueTransitions)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get due transitions")
+		return
+	}
+
+	for _, t := range transitions {
+		status, err := s.execute(ctx, t)
+		if err == nil {
+			if err := s.store.FinishTransition(ctx, t.ID, status, ""); err != nil {
+				log.Error().Err(err).Str("transition_id", t.ID).Msg("Failed to record transition")
+			}
+			continue
+		}
+
+		log.Error().
+			Err(err).
+			Str("experiment_id", t.ExperimentID).
+			Str("transition", t.Transition).
+			Int("attempt", t.Attempts+1).
+			Msg("Scheduled transition failed")
+
+		if t.Attempts+1 >= maxTransitionAttempts {
+			if err := s.store.FinishTransition(ctx, t.ID, "failed", err.Error()); err != nil {
+				log.Error().Err(err).Str("transition_id", t.ID).Msg("Failed to record transition")
+			}
+			continue
+		}
+
+		if err := s.store.RetryTransition(ctx, t.ID, err.Error(), transitionRetryBackoff); err != nil {
+			log.Error().Err(err).Str("transition_id", t.ID).Msg("Failed to reschedule transition")
+		}
+	}
+}
+
+// execute applies a single transition and returns its final status. A
+// transition that no longer fits the experiment's phase is skipped.
+func (s *Scheduler) execute(ctx context.Context, t *models.ScheduledTransition) (string, error) {
+	exp, err := s.store.GetExperiment(ctx, t.ExperimentID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get experiment: %w", err)
+	}
+
+	switch t.Transition {
+	case TransitionStartMonitoring:
+		if exp.Phase != "running" {
+			return "skipped", nil
+		}
+
+		if err := s.store.UpdateExperimentPhase(ctx, exp.ID, "monitoring"); err != nil {
+			return "", fmt.Errorf("failed to update phase to monitoring: %w", err)
+		}
+
+		s.recordEvent(ctx, exp.ID, "monitoring_started", "monitoring",
+			fmt.Sprintf("Warmup of %s ended, monitoring started", exp.Config.WarmupDuration), nil)
+
+	case TransitionComplete:
+		if exp.Phase != "running" && exp.Phase != "monitoring" {
+			return "skipped", nil
+		}
+
+		if err := s.controller.CompleteExperiment(ctx, exp); err != nil {
+			return "", err
+		}
+
+		s.analyze(ctx, exp.ID)
+
+	case TransitionRolloutGate:
+		if exp.Phase != "deploying" || exp.Config.Rollout == nil || exp.Config.Rollout.State != models.RolloutSoaking {
+			return "skipped", nil
+		}
+
+		var metrics GuardrailMetrics
+		if s.guardrails != nil {
+			metrics = s.guardrails.metrics
+		}
+		if err := s.controller.EvaluateRolloutGate(ctx, exp, metrics); err != nil {
+			return "", err
+		}
+
+	default:
+		return "", fmt.Errorf("unknown transition: %s", t.Transition)
+	}
+
+	log.Info().
+		Str("experiment_id", t.ExperimentID).
+		Str("transition", t.Transition).
+		Msg("Scheduled transition executed")
+
+	return "completed", nil
+}
+
+// analyze runs the final analysis of a completed experiment and records the
+// recommendation. Failures are recorded as events; the experiment stays
+// completed and can be analyzed again on demand.
+func (s *Scheduler) analyze(ctx context.Context, experimentID string) {
+	result, err := s.analyzer.AnalyzeExperiment(ctx, experimentID)
+	if err != nil {
+		log.Error().Err(err).Str("experiment_id", experimentID).Msg("Failed to analyze completed experiment")
+		s.recordEvent(ctx, experimentID, "analysis_failed", models.PhaseCompleted,
+			fmt.Sprintf("Automatic analysis failed: %v", err), nil)
+		return
+	}
+
+	recommendation := s.analyzer.GetRecommendation(result)
+	s.recordEvent(ctx, experimentID, "recommendation", models.PhaseCompleted, recommendation,
+		map[string]interface{}{
+			"recommendation":        recommendation,
+			"cardinality_reduction": result.CardinalityReduction,
+			"cost_reduction":        result.CostReduction,
+			"data_accuracy":         result.DataAccuracy,
+			"errors":                result.Errors,
+		})
+}
+
+func (s *Scheduler) recordEvent(ctx context.Context, experimentID, eventType, phase, message string, metadata map[string]interface{}) {
+	event := &models.ExperimentEvent{
+		ExperimentID: experimentID,
+		EventType:    eventType,
+		Phase:        phase,
+		Message:      message,
+		Metadata:     metadata,
+	}
+
+	if err := s.store.CreateExperimentEvent(ctx, event); err != nil {
+		log.Error().Err(err).Str("experiment_id", experimentID).Msg("Failed to create experiment event")
+	}
+}
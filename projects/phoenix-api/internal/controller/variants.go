@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+// ErrUnknownVariant is returned when promoting a variant the experiment does
+// not have, or when no variant is named for an experiment with several
+var ErrUnknownVariant = errors.New("unknown variant")
+
+// variantName matches names that can be used in collector IDs and metric
+// labels
+var variantName = regexp.MustCompile(`^[a-z0-9]([a-z0-9_-]*[a-z0-9])?$`)
+
+// ValidateVariants checks that the candidates of an A/B/n experiment have
+// unique, well-formed names and a pipeline template each
+func ValidateVariants(variants []models.ExperimentVariant) error {
+	seen := make(map[string]bool, len(variants))
+	for i, variant := range variants {
+		if !variantName.MatchString(variant.Name) {
+			return fmt.Errorf("variant %d: invalid name %q", i, variant.Name)
+		}
+		if variant.Name == models.BaselineVariant {
+			return fmt.Errorf("variant %d: %q is reserved for the baseline", i, variant.Name)
+		}
+		if seen[variant.Name] {
+			return fmt.Errorf("variant %d: duplicate name %q", i, variant.Name)
+		}
+		seen[variant.Name] = true
+
+		if variant.Template.URL == "" && variant.Template.Name == "" {
+			return fmt.Errorf("variant %s: template is required", variant.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func TestValidateVariants(t *testing.T) {
+	template := models.PipelineTemplate{Name: "process-topk-v1"}
+
+	valid := []models.ExperimentVariant{
+		{Name: "topk", Template: template},
+		{Name: "adaptive-filter", Template: template},
+	}
+	if err := ValidateVariants(valid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	invalid := [][]models.ExperimentVariant{
+		{{Name: "", Template: template}},
+		{{Name: "Top K", Template: template}},
+		{{Name: "baseline", Template: template}},
+		{{Name: "topk", Template: template}, {Name: "topk", Template: template}},
+		{{Name: "topk"}},
+	}
+	for i, variants := range invalid {
+		if err := ValidateVariants(variants); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+}
+
+func TestPromotedVariant(t *testing.T) {
+	exp := &models.Experiment{
+		Config: models.ExperimentConfig{
+			BaselineTemplate: models.PipelineTemplate{Name: "process-baseline-v1"},
+			Variants: []models.ExperimentVariant{
+				{Name: "topk", Template: models.PipelineTemplate{Name: "process-topk-v1"}},
+				{Name: "sampled", Template: models.PipelineTemplate{Name: "process-sampled-v1"}},
+			},
+		},
+	}
+
+	variant, err := promotedVariant(exp, "sampled")
+	if err != nil || variant.Template.Name != "process-sampled-v1" {
+		t.Fatalf("expected sampled variant, got %+v, %v", variant, err)
+	}
+
+	if variant, err := promotedVariant(exp, "baseline"); err != nil || variant.Template.Name != "process-baseline-v1" {
+		t.Fatalf("expected baseline variant, got %+v, %v", variant, err)
+	}
+
+	if _, err := promotedVariant(exp, ""); !errors.Is(err, ErrUnknownVariant) {
+		t.Fatalf("expected an unnamed variant to be rejected, got %v", err)
+	}
+	if _, err := promotedVariant(exp, "missing"); !errors.Is(err, ErrUnknownVariant) {
+		t.Fatalf("expected an unknown variant to be rejected, got %v", err)
+	}
+
+	exp.Config.Variants = nil
+	exp.Config.CandidateTemplate = models.PipelineTemplate{Name: "process-topk-v1"}
+	if variant, err := promotedVariant(exp, ""); err != nil || variant.Name != "candidate" {
+		t.Fatalf("expected the only candidate, got %+v, %v", variant, err)
+	}
+}
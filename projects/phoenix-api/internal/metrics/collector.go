@@ -30,8 +30,9 @@ func NewCollector(promURL string) (*Collector, error) {
 	}, nil
 }
 
-// CollectExperimentMetrics collects all metrics for an experiment
-func (c *Collector) CollectExperimentMetrics(ctx context.Context, experimentID string, timeRange time.Duration) (*ExperimentMetrics, error) {
+// CollectExperimentMetrics collects all metrics for an experiment: those of
+// the baseline and of each of the given candidate variants
+func (c *Collector) CollectExperimentMetrics(ctx context.Context, experimentID string, candidates []string, timeRange time.Duration) (*ExperimentMetrics, error) {
 	endTime := time.Now()
 	startTime := endTime.Add(-timeRange)
 
@@ -40,7 +41,7 @@ func (c *Collector) CollectExperimentMetrics(ctx context.Context, experimentID s
 		StartTime:    startTime,
 		EndTime:      endTime,
 		Baseline:     &PipelineMetrics{},
-		Candidate:    &PipelineMetrics{},
+		Candidates:   make(map[string]*PipelineMetrics, len(candidates)),
 	}
 
 	// Collect baseline metrics
@@ -49,8 +50,15 @@ func (c *Collector) CollectExperimentMetrics(ctx context.Context, experimentID s
 	}
 
 	// Collect candidate metrics
-	if err := c.collectPipelineMetrics(ctx, experimentID, "candidate", startTime, endTime, metrics.Candidate); err != nil {
-		return nil, fmt.Errorf("failed to collect candidate metrics: %w", err)
+	for _, variant := range candidates {
+		pm := &PipelineMetrics{}
+		if err := c.collectPipelineMetrics(ctx, experimentID, variant, startTime, endTime, pm); err != nil {
+			return nil, fmt.Errorf("failed to collect %s metrics: %w", variant, err)
+		}
+		metrics.Candidates[variant] = pm
+		if metrics.Candidate == nil {
+			metrics.Candidate = pm
+		}
 	}
 
 	return metrics, nil
@@ -288,7 +296,11 @@ type ExperimentMetrics struct {
 	StartTime    time.Time        `json:"start_time"`
 	EndTime      time.Time        `json:"end_time"`
 	Baseline     *PipelineMetrics `json:"baseline"`
-	Candidate    *PipelineMetrics `json:"candidate"`
+
+	// Candidate is the first candidate; Candidates holds every candidate
+	// by variant name
+	Candidate  *PipelineMetrics            `json:"candidate"`
+	Candidates map[string]*PipelineMetrics `json:"candidates,omitempty"`
 }
 
 // PipelineMetrics contains metrics for a single pipeline variant
@@ -15,6 +15,9 @@ type Experiment struct {
 	Metadata    map[string]interface{} `json:"metadata" db:"metadata"`
 	CreatedAt   time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at" db:"updated_at"`
+	// Schedule starts runs of this experiment at a set time or on a cron
+	// schedule; it is stored separately from the experiment
+	Schedule *ExperimentSchedule `json:"schedule,omitempty" db:"-"`
 }
 
 // ExperimentConfig contains the configuration for an experiment
@@ -26,6 +29,123 @@ type ExperimentConfig struct {
 	Duration          time.Duration    `json:"duration"`
 	WarmupDuration    time.Duration    `json:"warmup_duration"`
 	CriticalProcesses []string         `json:"critical_processes,omitempty"`
+	Guardrails        []Guardrail      `json:"guardrails,omitempty"`
+	// Variants lists the candidates of an A/B/n experiment, each compared
+	// against the baseline. When empty, CandidateTemplate is the only
+	// candidate, named "candidate".
+	Variants []ExperimentVariant `json:"variants,omitempty"`
+	// HostSelector picks TargetHosts by agent labels when the experiment
+	// starts. The resolved hosts are kept in TargetHosts, so restarting the
+	// experiment reuses them.
+	HostSelector *HostSelector `json:"host_selector,omitempty"`
+	// Rollout deploys the experiment to its hosts in waves instead of all
+	// at once
+	Rollout *RolloutPlan `json:"rollout,omitempty"`
+}
+
+// BaselineVariant is the name of the variant every candidate is compared
+// against
+const BaselineVariant = "baseline"
+
+// ExperimentVariant is a named candidate pipeline
+type ExperimentVariant struct {
+	Name     string           `json:"name"`
+	Template PipelineTemplate `json:"template"`
+}
+
+// Candidates returns the experiment's candidate variants
+func (c ExperimentConfig) Candidates() []ExperimentVariant {
+	if len(c.Variants) > 0 {
+		return c.Variants
+	}
+	return []ExperimentVariant{{Name: "candidate", Template: c.CandidateTemplate}}
+}
+
+// CandidateNames returns the names of the experiment's candidate variants
+func (c ExperimentConfig) CandidateNames() []string {
+	var names []string
+	for _, v := range c.Candidates() {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// VariantNames returns the baseline followed by every candidate; each runs
+// as its own collector on every target host
+func (c ExperimentConfig) VariantNames() []string {
+	return append([]string{BaselineVariant}, c.CandidateNames()...)
+}
+
+// Rollout states
+const (
+	RolloutDeploying = "deploying"
+	RolloutSoaking   = "soaking"
+	RolloutPaused    = "paused"
+	RolloutCompleted = "completed"
+	RolloutAborted   = "aborted"
+)
+
+// RolloutPlan splits an experiment's target hosts into waves. Each wave is
+// deployed, left to soak, and has to pass its gate before the next one
+// starts. The last wave takes every host not covered by earlier waves.
+type RolloutPlan struct {
+	Waves []RolloutWave `json:"waves"`
+
+	// The rollout's progress, maintained by the controller
+	CurrentWave  int    `json:"current_wave"`
+	State        string `json:"state,omitempty"`
+	PausedReason string `json:"paused_reason,omitempty"`
+}
+
+// RolloutWave is one step of a rollout plan
+type RolloutWave struct {
+	// Hosts or Percent of the target hosts are added in this wave
+	Hosts   int     `json:"hosts,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+	// SoakTime is how long the wave runs before its gate is checked
+	SoakTime time.Duration `json:"soak_time,omitempty"`
+	Gate     *RolloutGate  `json:"gate,omitempty"`
+}
+
+// RolloutGate decides whether a rollout may continue past a wave
+type RolloutGate struct {
+	// MinHostSuccessPercent of the hosts deployed so far must run every
+	// variant. Defaults to 100.
+	MinHostSuccessPercent float64 `json:"min_host_success_percent,omitempty"`
+	// KPIs are conditions on the candidate's metrics, written like
+	// guardrails, that fail the gate when they hold
+	KPIs []Guardrail `json:"kpis,omitempty"`
+}
+
+// HostSelector selects an experiment's hosts from the agents whose labels
+// match Selector, for example env=prod,role=web,region in (us-east-1)
+type HostSelector struct {
+	Selector string `json:"selector"`
+	// SampleSize limits the selection to this many matching hosts
+	SampleSize int `json:"sample_size,omitempty"`
+	// SamplePercent limits the selection to this percentage of the
+	// matching hosts
+	SamplePercent float64 `json:"sample_percent,omitempty"`
+	// ResolvedAt is when the selector was resolved into TargetHosts
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	// MatchedHosts is how many hosts matched before sampling
+	MatchedHosts int `json:"matched_hosts,omitempty"`
+}
+
+// Guardrail is a condition on the candidate's metrics that rolls the
+// candidate back automatically once it holds for Breaches consecutive
+// evaluations, for example data_accuracy < 98
+type Guardrail struct {
+	Name      string  `json:"name,omitempty"`
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+	// RelativeToBaseline compares the candidate's difference from the
+	// baseline (candidate - baseline) instead of its absolute value
+	RelativeToBaseline bool `json:"relative_to_baseline,omitempty"`
+	// Breaches is the number of consecutive breached evaluations that
+	// trigger a rollback. Defaults to 3.
+	Breaches int `json:"breaches,omitempty"`
 }
 
 // ExperimentStatus represents the current status of an experiment
@@ -35,6 +155,50 @@ type ExperimentStatus struct {
 	KPIs        map[string]float64 `json:"kpis,omitempty"`
 	Error       string             `json:"error,omitempty"`
 	ActiveHosts int                `json:"active_hosts"`
+	// FailedHosts lists hosts whose pipelines failed or never came up
+	FailedHosts []HostFailure `json:"failed_hosts,omitempty"`
+}
+
+// HostFailure describes why an experiment variant is not running on a host
+type HostFailure struct {
+	HostID  string `json:"host_id"`
+	Variant string `json:"variant"`
+	Reason  string `json:"reason"`
+}
+
+// Overlap types and severities
+const (
+	OverlapHost           = "host"
+	OverlapPipeline       = "pipeline"
+	OverlapLoadSimulation = "load_simulation"
+
+	OverlapSeverityNone     = "none"
+	OverlapSeverityWarning  = "warning"
+	OverlapSeverityBlocking = "blocking"
+)
+
+// OverlapResult reports what an experiment would share its hosts with.
+// OverlapType and Severity are those of the most severe conflict.
+type OverlapResult struct {
+	HasOverlap               bool              `json:"has_overlap"`
+	ConflictingExpIDs        []string          `json:"conflicting_exp_ids"`
+	ConflictingDeploymentIDs []string          `json:"conflicting_deployment_ids,omitempty"`
+	AffectedNodes            []string          `json:"affected_nodes"`
+	OverlapType              string            `json:"overlap_type"`
+	Severity                 string            `json:"severity"`
+	Message                  string            `json:"message"`
+	Suggestions              []string          `json:"suggestions"`
+	Conflicts                []OverlapConflict `json:"conflicts,omitempty"`
+}
+
+// OverlapConflict is one thing an experiment would share a host with
+type OverlapConflict struct {
+	Type         string `json:"type"`
+	Severity     string `json:"severity"`
+	HostID       string `json:"host_id"`
+	ExperimentID string `json:"experiment_id,omitempty"`
+	DeploymentID string `json:"deployment_id,omitempty"`
+	Message      string `json:"message"`
 }
 
 // ExperimentEvent represents an event in the experiment lifecycle
@@ -59,6 +223,9 @@ type PipelineTemplate struct {
 	Metadata    map[string]interface{} `json:"metadata" db:"metadata"`
 	CreatedAt   time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at" db:"updated_at"`
+	// SHA256 pins the content of the config at URL; agents refuse a config
+	// that hashes differently
+	SHA256 string `json:"sha256,omitempty"`
 }
 
 // Task represents a unit of work for an agent
@@ -77,8 +244,117 @@ type Task struct {
 	Result       map[string]interface{} `json:"result,omitempty" db:"result"`
 	ErrorMessage string                 `json:"error_message,omitempty" db:"error_message"`
 	RetryCount   int                    `json:"retry_count" db:"retry_count"`
-	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time              `json:"updated_at" db:"updated_at"`
+	// LeaseToken identifies the claim an agent holds on the task. Status
+	// updates must present it and are rejected once LeaseExpiresAt passes.
+	LeaseToken     string     `json:"lease_token,omitempty" db:"lease_token"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty" db:"lease_expires_at"`
+	// NotBefore holds a retry back until its backoff delay has elapsed
+	NotBefore *time.Time `json:"not_before,omitempty" db:"not_before"`
+	// OriginalTaskID links a retry to the first attempt of the same work
+	OriginalTaskID   string     `json:"original_task_id,omitempty" db:"original_task_id"`
+	DeadLetterReason string     `json:"dead_letter_reason,omitempty" db:"dead_letter_reason"`
+	DeadLetteredAt   *time.Time `json:"dead_lettered_at,omitempty" db:"dead_lettered_at"`
+	// DependsOn lists tasks that must complete before this one is released
+	// to an agent. A retry of a dependency satisfies it as well.
+	DependsOn []string `json:"depends_on,omitempty" db:"depends_on"`
+	// IdempotencyKey identifies the work a task performs. Enqueueing a task
+	// whose key is held by a live or completed task returns that task.
+	IdempotencyKey string `json:"idempotency_key,omitempty" db:"idempotency_key"`
+	// CancelRequestedAt is set when a running task has been asked to stop.
+	// The agent running it learns about the request from its heartbeat.
+	CancelRequestedAt *time.Time `json:"cancel_requested_at,omitempty" db:"cancel_requested_at"`
+	CancelReason      string     `json:"cancel_reason,omitempty" db:"cancel_reason"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// TaskCancellation asks an agent to abort a task it is running
+type TaskCancellation struct {
+	TaskID string `json:"task_id"`
+	Reason string `json:"reason"`
+}
+
+// HeartbeatResponse is returned to an agent for each heartbeat
+type HeartbeatResponse struct {
+	CancelTasks []TaskCancellation `json:"cancel_tasks"`
+}
+
+// DesiredState is what an agent's host should be running. Agents converge
+// on it alongside the tasks they are handed.
+type DesiredState struct {
+	HostID string `json:"host_id"`
+	// Revision changes whenever the collectors or load simulation change
+	Revision       string                 `json:"revision"`
+	GeneratedAt    time.Time              `json:"generated_at"`
+	Collectors     []DesiredCollector     `json:"collectors"`
+	LoadSimulation *DesiredLoadSimulation `json:"load_simulation,omitempty"`
+}
+
+// DesiredCollector is a collector that should be running, with the task
+// that starts it
+type DesiredCollector struct {
+	ID           string `json:"id"`
+	Variant      string `json:"variant"`
+	ExperimentID string `json:"experiment_id,omitempty"`
+	DeploymentID string `json:"deployment_id,omitempty"`
+	// ConfigHash identifies the collector's config; a running collector
+	// started with another hash has drifted
+	ConfigHash string                 `json:"config_hash"`
+	TaskType   string                 `json:"task_type"`
+	TaskAction string                 `json:"task_action"`
+	Config     map[string]interface{} `json:"config"`
+}
+
+// DesiredLoadSimulation is the load simulation an experiment runs on the
+// host
+type DesiredLoadSimulation struct {
+	ExperimentID string `json:"experiment_id"`
+	Profile      string `json:"profile"`
+	Duration     string `json:"duration"`
+}
+
+// Kinds of drift between a host's desired and actual state
+const (
+	DriftMissing        = "missing"
+	DriftUnexpected     = "unexpected"
+	DriftConfigChanged  = "config_changed"
+	DriftNotRunning     = "not_running"
+	DriftLoadSimMissing = "load_simulation_missing"
+	DriftLoadSimExtra   = "load_simulation_unexpected"
+)
+
+// DriftReport is what an agent found and did when converging on its
+// desired state
+type DriftReport struct {
+	Revision  string       `json:"revision"`
+	CheckedAt time.Time    `json:"checked_at"`
+	InSync    bool         `json:"in_sync"`
+	Drift     []StateDrift `json:"drift"`
+}
+
+// StateDrift is one difference between desired and actual state
+type StateDrift struct {
+	Kind         string `json:"kind"`
+	CollectorID  string `json:"collector_id,omitempty"`
+	ExperimentID string `json:"experiment_id,omitempty"`
+	DeploymentID string `json:"deployment_id,omitempty"`
+	// Action is what the agent did about it: started, stopped, restarted
+	// or none
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// IdempotencyRecord remembers the response to an API request sent with an
+// Idempotency-Key header. CompletedAt is nil while the request is in progress.
+type IdempotencyRecord struct {
+	Key          string     `json:"key" db:"key"`
+	Method       string     `json:"method" db:"method"`
+	Path         string     `json:"path" db:"path"`
+	StatusCode   int        `json:"status_code" db:"status_code"`
+	ContentType  string     `json:"content_type" db:"content_type"`
+	ResponseBody []byte     `json:"-" db:"response_body"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty" db:"completed_at"`
 }
 
 // AgentStatus represents the current status of an agent
@@ -94,8 +370,91 @@ type AgentStatus struct {
 	ActiveTasks   []string               `json:"active_tasks" db:"active_tasks"`
 	ResourceUsage ResourceUsage          `json:"resource_usage" db:"resource_usage"`
 	Metadata      map[string]interface{} `json:"metadata" db:"metadata"`
-	CreatedAt     time.Time              `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time              `json:"updated_at" db:"updated_at"`
+	// Labels are reported by the agent in its heartbeat
+	Labels map[string]string `json:"labels" db:"labels"`
+	// AssignedLabels are set through the API and override reported labels
+	// with the same key
+	AssignedLabels map[string]string `json:"assigned_labels" db:"assigned_labels"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// Agent liveness states, derived from heartbeat age and resource usage
+const (
+	AgentHealthy     = "healthy"
+	AgentDegraded    = "degraded"
+	AgentUnreachable = "unreachable"
+	AgentOffline     = "offline"
+)
+
+// AgentEvent records a change of an agent's liveness state
+type AgentEvent struct {
+	ID        int                    `json:"id" db:"id"`
+	HostID    string                 `json:"host_id" db:"host_id"`
+	EventType string                 `json:"event_type" db:"event_type"`
+	FromState string                 `json:"from_state" db:"from_state"`
+	ToState   string                 `json:"to_state" db:"to_state"`
+	Message   string                 `json:"message" db:"message"`
+	Metadata  map[string]interface{} `json:"metadata" db:"metadata"`
+	CreatedAt time.Time              `json:"created_at" db:"created_at"`
+}
+
+// AgentLogEntry is a line of a collector's output or of an agent's own log,
+// shipped by the agent. CollectorID is empty for agent lines that concern
+// no collector.
+type AgentLogEntry struct {
+	ID          int64     `json:"id" db:"id"`
+	HostID      string    `json:"host_id" db:"host_id"`
+	CollectorID string    `json:"collector_id,omitempty" db:"collector_id"`
+	TaskID      string    `json:"task_id,omitempty" db:"task_id"`
+	Source      string    `json:"source" db:"source"`
+	Level       string    `json:"level" db:"level"`
+	Message     string    `json:"message" db:"message"`
+	Timestamp   time.Time `json:"timestamp" db:"logged_at"`
+}
+
+// AgentLogQuery selects the shipped log lines of a host. Empty fields match
+// everything.
+type AgentLogQuery struct {
+	HostID      string
+	CollectorID string
+	TaskID      string
+	// Levels are the levels to return
+	Levels []string
+	Since  time.Time
+	// AfterID returns only lines stored after the line with this ID
+	AfterID int64
+	// Limit is the most lines returned; the latest ones are kept
+	Limit int
+}
+
+// CollectorEvent is reported by an agent when a collector it supervises
+// crashes, is restarted or is given up on
+type CollectorEvent struct {
+	CollectorID  string    `json:"collector_id"`
+	ExperimentID string    `json:"experiment_id,omitempty"`
+	DeploymentID string    `json:"deployment_id,omitempty"`
+	Variant      string    `json:"variant"`
+	Event        string    `json:"event"`
+	ExitCode     int       `json:"exit_code"`
+	Restarts     int       `json:"restarts"`
+	RestartIn    string    `json:"restart_in,omitempty"`
+	Pid          int       `json:"pid,omitempty"`
+	LogTail      string    `json:"log_tail,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// EffectiveLabels returns the agent's reported labels overlaid with its
+// assigned labels
+func (a *AgentStatus) EffectiveLabels() map[string]string {
+	labels := make(map[string]string, len(a.Labels)+len(a.AssignedLabels))
+	for k, v := range a.Labels {
+		labels[k] = v
+	}
+	for k, v := range a.AssignedLabels {
+		labels[k] = v
+	}
+	return labels
 }
 
 // AgentHeartbeat represents a heartbeat from an agent
@@ -105,7 +464,33 @@ type AgentHeartbeat struct {
 	Status        string        `json:"status"`
 	ActiveTasks   []string      `json:"active_tasks"`
 	ResourceUsage ResourceUsage `json:"resource_usage"`
-	LastHeartbeat time.Time     `json:"-"`
+	// Labels replace the agent's reported labels when present
+	Labels map[string]string `json:"labels,omitempty"`
+	// RecoveredCollectors is sent by a restarted agent until a heartbeat
+	// is accepted
+	RecoveredCollectors []RecoveredCollector `json:"recovered_collectors,omitempty"`
+	LastHeartbeat       time.Time            `json:"-"`
+}
+
+// How a restarted agent recovered a collector
+const (
+	RecoveryAdopted   = "adopted"
+	RecoveryRestarted = "restarted"
+	RecoveryLost      = "lost"
+)
+
+// RecoveredCollector is a collector a restarted agent found in its state
+// file, and whether it was adopted, restarted or lost
+type RecoveredCollector struct {
+	CollectorID  string `json:"collector_id"`
+	Variant      string `json:"variant"`
+	ConfigHash   string `json:"config_hash"`
+	Pid          int    `json:"pid,omitempty"`
+	TaskID       string `json:"task_id,omitempty"`
+	ExperimentID string `json:"experiment_id,omitempty"`
+	DeploymentID string `json:"deployment_id,omitempty"`
+	Recovery     string `json:"recovery"`
+	Error        string `json:"error,omitempty"`
 }
 
 // ResourceUsage represents resource usage metrics
@@ -134,6 +519,52 @@ type ActivePipeline struct {
 	UpdatedAt    time.Time              `json:"updated_at" db:"updated_at"`
 }
 
+// ExperimentSchedule starts copies of an experiment, called runs, at StartAt
+// or on a cron schedule. With only StartAt the experiment runs once; with
+// Cron it recurs, starting no earlier than StartAt.
+type ExperimentSchedule struct {
+	ID           string     `json:"id" db:"id"`
+	ExperimentID string     `json:"experiment_id" db:"experiment_id"`
+	StartAt      *time.Time `json:"start_at,omitempty" db:"start_at"`
+	// Cron is a five-field cron expression (minute hour day-of-month month
+	// day-of-week) evaluated in Timezone, UTC by default
+	Cron      string     `json:"cron,omitempty" db:"cron"`
+	Timezone  string     `json:"timezone,omitempty" db:"timezone"`
+	Enabled   bool       `json:"enabled" db:"enabled"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty" db:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty" db:"last_run_at"`
+	RunCount  int        `json:"run_count" db:"run_count"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ExperimentScheduleRun links an experiment run to the schedule that started
+// it
+type ExperimentScheduleRun struct {
+	ID           string    `json:"id" db:"id"`
+	ScheduleID   string    `json:"schedule_id" db:"schedule_id"`
+	ExperimentID string    `json:"experiment_id,omitempty" db:"experiment_id"`
+	RunNumber    int       `json:"run_number" db:"run_number"`
+	ScheduledFor time.Time `json:"scheduled_for" db:"scheduled_for"`
+	Status       string    `json:"status" db:"status"` // "started" or "failed"
+	Error        string    `json:"error,omitempty" db:"error"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// ScheduledTransition is a persisted experiment phase change that fires at DueAt
+type ScheduledTransition struct {
+	ID           string     `json:"id" db:"id"`
+	ExperimentID string     `json:"experiment_id" db:"experiment_id"`
+	Transition   string     `json:"transition" db:"transition"`
+	DueAt        time.Time  `json:"due_at" db:"due_at"`
+	Status       string     `json:"status" db:"status"`
+	Attempts     int        `json:"attempts" db:"attempts"`
+	LastError    string     `json:"last_error,omitempty" db:"last_error"`
+	ExecutedAt   *time.Time `json:"executed_at,omitempty" db:"executed_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
 // MetricCache represents cached metrics for faster queries
 type MetricCache struct {
 	ID           int               `json:"id" db:"id"`
@@ -149,27 +580,39 @@ type MetricCache struct {
 
 // KPIResult represents the calculated KPIs for an experiment
 type KPIResult struct {
-	ExperimentID         string    `json:"experiment_id"`
-	CalculatedAt         time.Time `json:"calculated_at"`
-	CardinalityReduction float64   `json:"cardinality_reduction"`
-	CostReduction        float64   `json:"cost_reduction"`
-	CPUUsage             struct {
-		Baseline  float64 `json:"baseline"`
-		Candidate float64 `json:"candidate"`
-		Reduction float64 `json:"reduction"`
-	} `json:"cpu_usage"`
-	MemoryUsage struct {
-		Baseline  float64 `json:"baseline"`
-		Candidate float64 `json:"candidate"`
-		Reduction float64 `json:"reduction"`
-	} `json:"memory_usage"`
-	IngestRate struct {
-		Baseline  float64 `json:"baseline"`
-		Candidate float64 `json:"candidate"`
-		Reduction float64 `json:"reduction"`
-	} `json:"ingest_rate"`
-	DataAccuracy float64  `json:"data_accuracy"`
-	Errors       []string `json:"errors,omitempty"`
+	ExperimentID         string        `json:"experiment_id"`
+	CalculatedAt         time.Time     `json:"calculated_at"`
+	CardinalityReduction float64       `json:"cardinality_reduction"`
+	CostReduction        float64       `json:"cost_reduction"`
+	CPUUsage             KPIComparison `json:"cpu_usage"`
+	MemoryUsage          KPIComparison `json:"memory_usage"`
+	IngestRate           KPIComparison `json:"ingest_rate"`
+	DataAccuracy         float64       `json:"data_accuracy"`
+	Errors               []string      `json:"errors,omitempty"`
+	// Candidates holds the KPIs of every candidate variant, best first. The
+	// fields above are those of the best candidate, BestCandidate.
+	Candidates    []VariantKPIs `json:"candidates,omitempty"`
+	BestCandidate string        `json:"best_candidate,omitempty"`
+}
+
+// KPIComparison is a metric of a candidate next to the baseline's
+type KPIComparison struct {
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+	Reduction float64 `json:"reduction"`
+}
+
+// VariantKPIs are the KPIs of one candidate variant against the baseline
+type VariantKPIs struct {
+	Variant              string        `json:"variant"`
+	Rank                 int           `json:"rank"`
+	CardinalityReduction float64       `json:"cardinality_reduction"`
+	CostReduction        float64       `json:"cost_reduction"`
+	CPUUsage             KPIComparison `json:"cpu_usage"`
+	MemoryUsage          KPIComparison `json:"memory_usage"`
+	IngestRate           KPIComparison `json:"ingest_rate"`
+	DataAccuracy         float64       `json:"data_accuracy"`
+	Errors               []string      `json:"errors,omitempty"`
 }
 
 // Metric represents a generic metric
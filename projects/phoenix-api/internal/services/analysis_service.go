@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/phoenix/platform/projects/phoenix-api/internal/analyzer"
 	"github.com/phoenix/platform/projects/phoenix-api/internal/metrics"
 	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
 	"github.com/phoenix/platform/projects/phoenix-api/internal/store"
@@ -47,112 +48,131 @@ func (s *AnalysisService) AnalyzeExperiment(ctx context.Context, experimentID st
 	}
 
 	// Collect metrics
-	expMetrics, err := s.collector.CollectExperimentMetrics(ctx, experimentID, timeRange)
+	candidates := exp.Config.CandidateNames()
+	expMetrics, err := s.collector.CollectExperimentMetrics(ctx, experimentID, candidates, timeRange)
 	if err != nil {
 		return nil, fmt.Errorf("failed to collect metrics: %w", err)
 	}
 
-	// Calculate KPIs
+	// Calculate KPIs for each candidate and rank them
 	result := &models.KPIResult{
 		ExperimentID: experimentID,
 		CalculatedAt: time.Now(),
 		Errors:       []string{},
 	}
 
+	kpis := make([]models.VariantKPIs, 0, len(candidates))
+	for _, variant := range candidates {
+		kpis = append(kpis, s.analyzeCandidate(ctx, exp, expMetrics.Baseline, expMetrics.Candidates[variant], variant))
+	}
+	analyzer.RankCandidates(result, kpis)
+
+	// Store results
+	if err := s.storeResults(ctx, exp, result); err != nil {
+		log.Error().Err(err).Msg("Failed to store analysis results")
+	}
+
+	// Create experiment event
+	event := &models.ExperimentEvent{
+		ExperimentID: experimentID,
+		EventType:    "analysis_completed",
+		Phase:        "analyzing",
+		Message: fmt.Sprintf("Analysis completed: %.1f%% cardinality reduction, %.1f%% cost savings",
+			result.CardinalityReduction, result.CostReduction),
+		Metadata: map[string]interface{}{
+			"cardinality_reduction": result.CardinalityReduction,
+			"cost_reduction":        result.CostReduction,
+			"data_accuracy":         result.DataAccuracy,
+			"best_candidate":        result.BestCandidate,
+			"candidates":            result.Candidates,
+		},
+	}
+
+	if err := s.store.CreateExperimentEvent(ctx, event); err != nil {
+		log.Error().Err(err).Msg("Failed to create analysis event")
+	}
+
+	return result, nil
+}
+
+// analyzeCandidate calculates the KPIs of one candidate against the baseline
+func (s *AnalysisService) analyzeCandidate(ctx context.Context, exp *models.Experiment, baseline, candidate *metrics.PipelineMetrics, variant string) models.VariantKPIs {
+	kpis := models.VariantKPIs{
+		Variant: variant,
+		Errors:  []string{},
+	}
+
 	// Cardinality reduction
-	if expMetrics.Baseline.Cardinality > 0 {
-		result.CardinalityReduction = float64(expMetrics.Baseline.Cardinality-expMetrics.Candidate.Cardinality) /
-			float64(expMetrics.Baseline.Cardinality) * 100
+	if baseline.Cardinality > 0 {
+		kpis.CardinalityReduction = float64(baseline.Cardinality-candidate.Cardinality) /
+			float64(baseline.Cardinality) * 100
 	}
 
 	// CPU usage
-	result.CPUUsage.Baseline = expMetrics.Baseline.CPUUsage
-	result.CPUUsage.Candidate = expMetrics.Candidate.CPUUsage
-	if expMetrics.Baseline.CPUUsage > 0 {
-		result.CPUUsage.Reduction = (expMetrics.Baseline.CPUUsage - expMetrics.Candidate.CPUUsage) /
-			expMetrics.Baseline.CPUUsage * 100
+	kpis.CPUUsage.Baseline = baseline.CPUUsage
+	kpis.CPUUsage.Candidate = candidate.CPUUsage
+	if baseline.CPUUsage > 0 {
+		kpis.CPUUsage.Reduction = (baseline.CPUUsage - candidate.CPUUsage) /
+			baseline.CPUUsage * 100
 	}
 
 	// Memory usage
-	result.MemoryUsage.Baseline = expMetrics.Baseline.MemoryUsageMB
-	result.MemoryUsage.Candidate = expMetrics.Candidate.MemoryUsageMB
-	if expMetrics.Baseline.MemoryUsageMB > 0 {
-		result.MemoryUsage.Reduction = (expMetrics.Baseline.MemoryUsageMB - expMetrics.Candidate.MemoryUsageMB) /
-			expMetrics.Baseline.MemoryUsageMB * 100
+	kpis.MemoryUsage.Baseline = baseline.MemoryUsageMB
+	kpis.MemoryUsage.Candidate = candidate.MemoryUsageMB
+	if baseline.MemoryUsageMB > 0 {
+		kpis.MemoryUsage.Reduction = (baseline.MemoryUsageMB - candidate.MemoryUsageMB) /
+			baseline.MemoryUsageMB * 100
 	}
 
 	// Ingest rate
-	result.IngestRate.Baseline = expMetrics.Baseline.IngestRate
-	result.IngestRate.Candidate = expMetrics.Candidate.IngestRate
-	if expMetrics.Baseline.IngestRate > 0 {
-		result.IngestRate.Reduction = (expMetrics.Baseline.IngestRate - expMetrics.Candidate.IngestRate) /
-			expMetrics.Baseline.IngestRate * 100
+	kpis.IngestRate.Baseline = baseline.IngestRate
+	kpis.IngestRate.Candidate = candidate.IngestRate
+	if baseline.IngestRate > 0 {
+		kpis.IngestRate.Reduction = (baseline.IngestRate - candidate.IngestRate) /
+			baseline.IngestRate * 100
 	}
 
 	// Calculate cost reduction
-	result.CostReduction = s.costModel.CalculateCostReduction(
-		expMetrics.Baseline.Cardinality,
-		expMetrics.Candidate.Cardinality,
-		result.CPUUsage.Reduction,
-		result.MemoryUsage.Reduction,
+	kpis.CostReduction = s.costModel.CalculateCostReduction(
+		baseline.Cardinality,
+		candidate.Cardinality,
+		kpis.CPUUsage.Reduction,
+		kpis.MemoryUsage.Reduction,
 	)
 
 	// Check critical metrics if specified
 	if len(exp.Config.CriticalProcesses) > 0 {
 		criticalCheck, err := s.collector.CheckCriticalMetrics(
-			ctx, experimentID, "candidate",
+			ctx, exp.ID, variant,
 			exp.Config.CriticalProcesses, time.Now(),
 		)
 		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("critical metrics check failed: %v", err))
+			kpis.Errors = append(kpis.Errors, fmt.Sprintf("critical metrics check failed: %v", err))
 		} else {
 			allPresent := true
 			for process, present := range criticalCheck {
 				if !present {
 					allPresent = false
-					result.Errors = append(result.Errors,
-						fmt.Sprintf("critical process '%s' metrics missing in candidate", process))
+					kpis.Errors = append(kpis.Errors,
+						fmt.Sprintf("critical process '%s' metrics missing in %s", process, variant))
 				}
 			}
 			if allPresent {
-				result.DataAccuracy = 100.0
+				kpis.DataAccuracy = 100.0
 			} else {
-				result.DataAccuracy = 90.0 // Penalize for missing critical metrics
+				kpis.DataAccuracy = 90.0 // Penalize for missing critical metrics
 			}
 		}
 	} else {
 		// Default accuracy based on error rate
-		if expMetrics.Candidate.ErrorRate < 1.0 {
-			result.DataAccuracy = 99.0
+		if candidate.ErrorRate < 1.0 {
+			kpis.DataAccuracy = 99.0
 		} else {
-			result.DataAccuracy = 100.0 - expMetrics.Candidate.ErrorRate
+			kpis.DataAccuracy = 100.0 - candidate.ErrorRate
 		}
 	}
 
-	// Store results
-	if err := s.storeResults(ctx, exp, result); err != nil {
-		log.Error().Err(err).Msg("Failed to store analysis results")
-	}
-
-	// Create experiment event
-	event := &models.ExperimentEvent{
-		ExperimentID: experimentID,
-		EventType:    "analysis_completed",
-		Phase:        "analyzing",
-		Message: fmt.Sprintf("Analysis completed: %.1f%% cardinality reduction, %.1f%% cost savings",
-			result.CardinalityReduction, result.CostReduction),
-		Metadata: map[string]interface{}{
-			"cardinality_reduction": result.CardinalityReduction,
-			"cost_reduction":        result.CostReduction,
-			"data_accuracy":         result.DataAccuracy,
-		},
-	}
-
-	if err := s.store.CreateExperimentEvent(ctx, event); err != nil {
-		log.Error().Err(err).Msg("Failed to create analysis event")
-	}
-
-	return result, nil
+	return kpis
 }
 
 // storeResults stores the analysis results
@@ -195,11 +215,17 @@ func (s *AnalysisService) GetRecommendation(result *models.KPIResult) string {
 		return "LIMITED BENEFIT: Cost savings below target. May not justify deployment effort."
 	}
 
+	recommendation := "RECOMMEND: Candidate pipeline meets success criteria. Ready for promotion."
 	if result.CardinalityReduction > 50 && result.DataAccuracy >= 99 {
-		return "STRONGLY RECOMMEND: Excellent cardinality reduction with high data accuracy."
+		recommendation = "STRONGLY RECOMMEND: Excellent cardinality reduction with high data accuracy."
+	}
+
+	// With several candidates, name the one the recommendation is for
+	if len(result.Candidates) > 1 {
+		recommendation = fmt.Sprintf("%s Best candidate: %s.", recommendation, result.BestCandidate)
 	}
 
-	return "RECOMMEND: Candidate pipeline meets success criteria. Ready for promotion."
+	return recommendation
 }
 
 // CostModel calculates cost based on metrics
@@ -280,7 +280,16 @@ func (mc *MetricsCollector) storeMetrics(ctx context.Context, metrics *Collected
 
 // calculateAndStoreKPIs calculates KPIs for an experiment and stores them
 func (mc *MetricsCollector) calculateAndStoreKPIs(ctx context.Context, experimentID string) {
-	kpis, err := mc.kpiCalc.CalculateExperimentKPIs(ctx, experimentID, 5*time.Minute)
+	exp, err := mc.store.GetExperiment(ctx, experimentID)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("experiment_id", experimentID).
+			Msg("Failed to get experiment for KPIs")
+		return
+	}
+
+	kpis, err := mc.kpiCalc.CalculateVariantKPIs(ctx, experimentID, exp.Config.CandidateNames(), 5*time.Minute)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -292,6 +301,7 @@ func (mc *MetricsCollector) calculateAndStoreKPIs(ctx context.Context, experimen
 	// Store KPIs in the database (you might want to add a KPI table)
 	log.Info().
 		Str("experiment_id", experimentID).
+		Str("best_candidate", kpis.BestCandidate).
 		Float64("cardinality_reduction", kpis.CardinalityReduction).
 		Float64("cost_reduction", kpis.CostReduction).
 		Float64("cpu_reduction", kpis.CPUUsage.Reduction).
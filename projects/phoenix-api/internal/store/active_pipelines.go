@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/phoenix/platform/pkg/database"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+// UpsertActivePipeline records the state of one experiment variant on a host,
+// replacing any earlier record for the same host, experiment and variant
+func (s *CompositeStore) UpsertActivePipeline(ctx context.Context, pipeline *models.ActivePipeline) error {
+	processInfoJSON, err := json.Marshal(pipeline.ProcessInfo)
+	if err != nil {
+		return fmt.Errorf("failed to marshal process_info: %w", err)
+	}
+
+	metricsInfoJSON, err := json.Marshal(pipeline.MetricsInfo)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metrics_info: %w", err)
+	}
+
+	query := `
+		INSERT INTO active_pipelines (
+			host_id, experiment_id, variant, config_url, config_hash,
+			process_info, metrics_info, status, started_at, stopped_at
+		) VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8, $9, $10)
+		ON CONFLICT (host_id, experiment_id, variant) DO UPDATE SET
+			config_url = EXCLUDED.config_url,
+			config_hash = EXCLUDED.config_hash,
+			process_info = EXCLUDED.process_info,
+			metrics_info = EXCLUDED.metrics_info,
+			status = EXCLUDED.status,
+			started_at = EXCLUDED.started_at,
+			stopped_at = EXCLUDED.stopped_at,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at, updated_at
+	`
+
+	err = s.pipelineStore.db.DB().QueryRowContext(ctx, query,
+		pipeline.HostID, pipeline.ExperimentID, pipeline.Variant,
+		pipeline.ConfigURL, pipeline.ConfigHash,
+		string(processInfoJSON), string(metricsInfoJSON),
+		pipeline.Status, pipeline.StartedAt, pipeline.StoppedAt,
+	).Scan(&pipeline.ID, &pipeline.CreatedAt, &pipeline.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert active pipeline: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateActivePipelineStatus moves an existing pipeline record to a new
+// status. processInfo is merged into the stored process info. Moving to
+// "stopped" or "failed" also records when the pipeline stopped.
+func (s *CompositeStore) UpdateActivePipelineStatus(ctx context.Context, hostID, experimentID, variant, status string, processInfo map[string]interface{}) error {
+	if processInfo == nil {
+		processInfo = map[string]interface{}{}
+	}
+
+	processInfoJSON, err := json.Marshal(processInfo)
+	if err != nil {
+		return fmt.Errorf("failed to marshal process_info: %w", err)
+	}
+
+	query := `
+		UPDATE active_pipelines SET
+			status = $4,
+			process_info = COALESCE(process_info, '{}'::jsonb) || $5::jsonb,
+			stopped_at = CASE WHEN $4 IN ('stopped', 'failed') THEN CURRENT_TIMESTAMP ELSE stopped_at END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE host_id = $1 AND experiment_id = $2 AND variant = $3
+	`
+
+	_, err = s.pipelineStore.db.DB().ExecContext(ctx, query,
+		hostID, experimentID, variant, status, string(processInfoJSON))
+	if err != nil {
+		return fmt.Errorf("failed to update active pipeline status: %w", err)
+	}
+
+	return nil
+}
+
+// GetActivePipelines returns every pipeline recorded for an experiment
+func (s *CompositeStore) GetActivePipelines(ctx context.Context, experimentID string) ([]*models.ActivePipeline, error) {
+	query := `
+		SELECT id, host_id, experiment_id, variant, COALESCE(config_url, ''),
+		       config_hash, process_info, metrics_info, status,
+		       started_at, stopped_at, created_at, updated_at
+		FROM active_pipelines
+		WHERE experiment_id = $1
+		ORDER BY host_id, variant
+	`
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, experimentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active pipelines: %w", err)
+	}
+	defer rows.Close()
+
+	var pipelines []*models.ActivePipeline
+	for rows.Next() {
+		var pipeline models.ActivePipeline
+		var configHash database.NullString
+		var processInfoJSON, metricsInfoJSON []byte
+		var stoppedAt database.NullTime
+
+		err := rows.Scan(
+			&pipeline.ID, &pipeline.HostID, &pipeline.ExperimentID, &pipeline.Variant,
+			&pipeline.ConfigURL, &configHash, &processInfoJSON, &metricsInfoJSON,
+			&pipeline.Status, &pipeline.StartedAt, &stoppedAt,
+			&pipeline.CreatedAt, &pipeline.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan active pipeline: %w", err)
+		}
+
+		if configHash.Valid {
+			pipeline.ConfigHash = configHash.String
+		}
+		if stoppedAt.Valid {
+			pipeline.StoppedAt = &stoppedAt.Time
+		}
+		if len(processInfoJSON) > 0 {
+			json.Unmarshal(processInfoJSON, &pipeline.ProcessInfo)
+		}
+		if len(metricsInfoJSON) > 0 {
+			json.Unmarshal(metricsInfoJSON, &pipeline.MetricsInfo)
+		}
+
+		pipelines = append(pipelines, &pipeline)
+	}
+
+	return pipelines, rows.Err()
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// SetAgentState moves an agent from one liveness state to another. The
+// change only applies while the agent is still in the from state and has not
+// sent a heartbeat since heartbeatBefore, so a heartbeat arriving while the
+// state is being changed wins. It reports whether the state changed.
+func (s *CompositeStore) SetAgentState(ctx context.Context, hostID, from, to string, heartbeatBefore time.Time) (bool, error) {
+	query := `
+		UPDATE agents SET
+			status = $3,
+			state_changed_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE host_id = $1 AND status = $2 AND last_heartbeat < $4
+	`
+
+	result, err := s.pipelineStore.db.DB().ExecContext(ctx, query, hostID, from, to, heartbeatBefore)
+	if err != nil {
+		return false, fmt.Errorf("failed to set agent state: %w", err)
+	}
+
+	changed, _ := result.RowsAffected()
+	return changed > 0, nil
+}
+
+// CreateAgentEvent records an agent event
+func (s *CompositeStore) CreateAgentEvent(ctx context.Context, event *models.AgentEvent) error {
+	metadataJSON, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	query := `
+		INSERT INTO agent_events (
+			host_id, event_type, from_state, to_state, message, metadata
+		) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	err = s.pipelineStore.db.DB().QueryRowContext(ctx, query,
+		event.HostID, event.EventType, event.FromState, event.ToState,
+		event.Message, string(metadataJSON),
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create agent event: %w", err)
+	}
+
+	return nil
+}
+
+// ListAgentEvents returns the latest events of an agent, newest first
+func (s *CompositeStore) ListAgentEvents(ctx context.Context, hostID string, limit int) ([]*models.AgentEvent, error) {
+	query := `
+		SELECT id, host_id, event_type, COALESCE(from_state, ''), COALESCE(to_state, ''),
+		       COALESCE(message, ''), metadata, created_at
+		FROM agent_events
+		WHERE host_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, hostID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agent events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*models.AgentEvent{}
+	for rows.Next() {
+		var event models.AgentEvent
+		var metadataJSON string
+		err := rows.Scan(
+			&event.ID, &event.HostID, &event.EventType, &event.FromState,
+			&event.ToState, &event.Message, &metadataJSON, &event.CreatedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan agent event row")
+			continue
+		}
+		if err := json.Unmarshal([]byte(metadataJSON), &event.Metadata); err != nil {
+			event.Metadata = make(map[string]interface{})
+		}
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+// FailHostTasks fails every pending, assigned and running task of a host
+// and returns the failed tasks
+func (s *CompositeStore) FailHostTasks(ctx context.Context, hostID, reason string) ([]*models.Task, error) {
+	query := `
+		UPDATE tasks SET
+			status = 'failed',
+			error_message = $2,
+			completed_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE host_id = $1 AND status IN ('pending', 'assigned', 'running')
+		RETURNING ` + taskColumns
+
+	tasks, err := s.queryTasks(ctx, query, hostID, reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fail host tasks: %w", err)
+	}
+
+	return tasks, nil
+}
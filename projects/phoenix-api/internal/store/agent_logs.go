@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// AppendAgentLogs stores log lines shipped by an agent. Afterwards only the
+// latest keep lines of each collector the lines belong to are left, so every
+// host holds a bounded window per collector.
+func (s *CompositeStore) AppendAgentLogs(ctx context.Context, entries []*models.AgentLogEntry, keep int) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tx, err := s.pipelineStore.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin agent log transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO agent_logs (
+			host_id, collector_id, task_id, source, level, message, logged_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare agent log insert: %w", err)
+	}
+	defer stmt.Close()
+
+	type window struct{ host, collector string }
+	windows := make(map[window]bool)
+	for _, entry := range entries {
+		_, err := stmt.ExecContext(ctx,
+			entry.HostID, entry.CollectorID, entry.TaskID, entry.Source,
+			entry.Level, entry.Message, entry.Timestamp,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert agent log: %w", err)
+		}
+		windows[window{entry.HostID, entry.CollectorID}] = true
+	}
+
+	// Everything older than the keep-th latest line of a window goes
+	prune := `
+		DELETE FROM agent_logs
+		WHERE host_id = $1 AND collector_id = $2 AND id <= (
+			SELECT id FROM agent_logs
+			WHERE host_id = $1 AND collector_id = $2
+			ORDER BY id DESC
+			OFFSET $3 LIMIT 1
+		)
+	`
+	for w := range windows {
+		if _, err := tx.ExecContext(ctx, prune, w.host, w.collector, keep); err != nil {
+			return fmt.Errorf("failed to prune agent logs: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit agent logs: %w", err)
+	}
+	return nil
+}
+
+// ListAgentLogs returns the latest log lines matching a query, oldest first
+func (s *CompositeStore) ListAgentLogs(ctx context.Context, q models.AgentLogQuery) ([]*models.AgentLogEntry, error) {
+	query := `
+		SELECT id, host_id, collector_id, task_id, source, level, message, logged_at
+		FROM agent_logs
+		WHERE host_id = $1`
+	args := []interface{}{q.HostID}
+	argCount := 1
+
+	if q.CollectorID != "" {
+		argCount++
+		query += fmt.Sprintf(" AND collector_id = $%d", argCount)
+		args = append(args, q.CollectorID)
+	}
+
+	if q.TaskID != "" {
+		argCount++
+		query += fmt.Sprintf(" AND task_id = $%d", argCount)
+		args = append(args, q.TaskID)
+	}
+
+	if len(q.Levels) > 0 {
+		argCount++
+		query += fmt.Sprintf(" AND level = ANY($%d)", argCount)
+		args = append(args, pq.Array(q.Levels))
+	}
+
+	if !q.Since.IsZero() {
+		argCount++
+		query += fmt.Sprintf(" AND logged_at >= $%d", argCount)
+		args = append(args, q.Since)
+	}
+
+	if q.AfterID > 0 {
+		argCount++
+		query += fmt.Sprintf(" AND id > $%d", argCount)
+		args = append(args, q.AfterID)
+	}
+
+	argCount++
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", argCount)
+	args = append(args, q.Limit)
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agent logs: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.AgentLogEntry{}
+	for rows.Next() {
+		var entry models.AgentLogEntry
+		err := rows.Scan(
+			&entry.ID, &entry.HostID, &entry.CollectorID, &entry.TaskID,
+			&entry.Source, &entry.Level, &entry.Message, &entry.Timestamp,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan agent log row")
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The latest lines were selected; they read oldest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// CleanupExpiredAgentLogs removes log lines stored longer than maxAge
+func (s *CompositeStore) CleanupExpiredAgentLogs(ctx context.Context, maxAge time.Duration) error {
+	query := `
+		DELETE FROM agent_logs
+		WHERE created_at < NOW() - $1::float8 * INTERVAL '1 second'
+	`
+
+	result, err := s.pipelineStore.db.DB().ExecContext(ctx, query, maxAge.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to cleanup expired agent logs: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected > 0 {
+		log.Info().Int64("count", rowsAffected).Msg("Cleaned up expired agent logs")
+	}
+
+	return nil
+}
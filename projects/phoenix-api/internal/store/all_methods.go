@@ -3,71 +3,77 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/phoenix/platform/pkg/database"
 
 	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
 	"github.com/rs/zerolog/log"
 )
 
-// Task operations
-func (s *CompositeStore) CreateTask(ctx context.Context, task *models.Task) error {
-	configJSON, err := json.Marshal(task.Config)
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	query := `
-		INSERT INTO tasks (
-			host_id, experiment_id, task_type, action, config,
-			priority, status, retry_count
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, created_at, updated_at
-	`
-
-	err = s.pipelineStore.db.DB().QueryRowContext(ctx, query,
-		task.HostID, task.ExperimentID, task.Type, task.Action,
-		string(configJSON), task.Priority, task.Status, task.RetryCount,
-	).Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt)
-
-	if err != nil {
-		return fmt.Errorf("failed to create task: %w", err)
-	}
-
-	return nil
-}
+var (
+	// ErrTaskLeaseMismatch is returned when a status update presents a lease
+	// token other than the one currently held on the task
+	ErrTaskLeaseMismatch = errors.New("task lease token does not match")
+	// ErrTaskLeaseExpired is returned when the lease on a task has expired
+	ErrTaskLeaseExpired = errors.New("task lease has expired")
+	// ErrTaskNotFound is returned when no task exists with the requested ID
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrDuplicateTask is returned when a live or completed task already
+	// holds the idempotency key of a task being created
+	ErrDuplicateTask = errors.New("task with the same idempotency key already exists")
+	// ErrTaskCancelled is returned when an agent reports on a task that was
+	// cancelled before it started running
+	ErrTaskCancelled = errors.New("task was cancelled")
+	// ErrTaskFinished is returned when an agent reports on a task that has
+	// already completed, failed or been dead-lettered, for example by the
+	// stale task sweep
+	ErrTaskFinished = errors.New("task has already finished")
+
+	// ErrAgentNotFound is returned when no agent is registered for a host
+	ErrAgentNotFound = errors.New("agent not found")
+)
 
-func (s *CompositeStore) GetTask(ctx context.Context, taskID string) (*models.Task, error) {
-	query := `
-		SELECT id, host_id, experiment_id, task_type, action, config,
+// taskColumns lists the columns read by every task query, in scanTask order
+const taskColumns = `id, host_id, experiment_id, task_type, action, config,
 		       priority, status, assigned_at, started_at, completed_at,
-		       result, error_message, retry_count, created_at, updated_at
-		FROM tasks WHERE id = $1
-	`
-
-	row := s.pipelineStore.db.DB().QueryRowContext(ctx, query, taskID)
+		       result, error_message, retry_count, lease_token, lease_expires_at,
+		       not_before, original_task_id, dead_letter_reason, dead_lettered_at,
+		       depends_on, idempotency_key, cancel_requested_at, cancel_reason,
+		       created_at, updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+// scanTask reads a single task selected with taskColumns
+func scanTask(row rowScanner) (*models.Task, error) {
 	var task models.Task
 	var configJSON string
 	var resultJSON database.NullString
-	var assignedAt, startedAt, completedAt database.NullTime
-	var errorMessage database.NullString
+	var assignedAt, startedAt, completedAt, leaseExpiresAt database.NullTime
+	var notBefore, deadLetteredAt database.NullTime
+	var errorMessage, leaseToken, originalTaskID, deadLetterReason database.NullString
+	var idempotencyKey, cancelReason database.NullString
+	var cancelRequestedAt database.NullTime
 
 	err := row.Scan(
 		&task.ID, &task.HostID, &task.ExperimentID, &task.Type, &task.Action,
 		&configJSON, &task.Priority, &task.Status,
 		&assignedAt, &startedAt, &completedAt,
 		&resultJSON, &errorMessage, &task.RetryCount,
+		&leaseToken, &leaseExpiresAt,
+		&notBefore, &originalTaskID, &deadLetterReason, &deadLetteredAt,
+		pq.Array(&task.DependsOn), &idempotencyKey, &cancelRequestedAt, &cancelReason,
 		&task.CreatedAt, &task.UpdatedAt,
 	)
-
-	if err == database.ErrNoRows {
-		return nil, fmt.Errorf("task not found: %s", taskID)
-	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get task: %w", err)
+		return nil, err
 	}
 
 	// Handle nullable fields
@@ -83,6 +89,33 @@ func (s *CompositeStore) GetTask(ctx context.Context, taskID string) (*models.Ta
 	if errorMessage.Valid {
 		task.ErrorMessage = errorMessage.String
 	}
+	if leaseToken.Valid {
+		task.LeaseToken = leaseToken.String
+	}
+	if leaseExpiresAt.Valid {
+		task.LeaseExpiresAt = &leaseExpiresAt.Time
+	}
+	if notBefore.Valid {
+		task.NotBefore = &notBefore.Time
+	}
+	if originalTaskID.Valid {
+		task.OriginalTaskID = originalTaskID.String
+	}
+	if deadLetterReason.Valid {
+		task.DeadLetterReason = deadLetterReason.String
+	}
+	if deadLetteredAt.Valid {
+		task.DeadLetteredAt = &deadLetteredAt.Time
+	}
+	if idempotencyKey.Valid {
+		task.IdempotencyKey = idempotencyKey.String
+	}
+	if cancelRequestedAt.Valid {
+		task.CancelRequestedAt = &cancelRequestedAt.Time
+	}
+	if cancelReason.Valid {
+		task.CancelReason = cancelReason.String
+	}
 
 	// Unmarshal JSON fields
 	if err := json.Unmarshal([]byte(configJSON), &task.Config); err != nil {
@@ -97,14 +130,181 @@ func (s *CompositeStore) GetTask(ctx context.Context, taskID string) (*models.Ta
 	return &task, nil
 }
 
-func (s *CompositeStore) ListTasks(ctx context.Context, filters map[string]interface{}) ([]*models.Task, error) {
+// queryTasks runs a task query and scans every row, skipping rows that fail to scan
+func (s *CompositeStore) queryTasks(ctx context.Context, query string, args ...interface{}) ([]*models.Task, error) {
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*models.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan task row")
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// Task operations
+func (s *CompositeStore) CreateTask(ctx context.Context, task *models.Task) error {
+	configJSON, err := json.Marshal(task.Config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
 	query := `
-		SELECT id, host_id, experiment_id, task_type, action, config,
-		       priority, status, assigned_at, started_at, completed_at,
-		       result, error_message, retry_count, created_at, updated_at
-		FROM tasks WHERE 1=1
+		INSERT INTO tasks (
+			host_id, experiment_id, task_type, action, config,
+			priority, status, retry_count, not_before, original_task_id,
+			depends_on, idempotency_key
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, NULLIF($12, ''))
+		ON CONFLICT (idempotency_key)
+			WHERE idempotency_key IS NOT NULL
+			AND status IN ('pending', 'assigned', 'running', 'completed')
+		DO NOTHING
+		RETURNING id, created_at, updated_at
+	`
+
+	err = s.pipelineStore.db.DB().QueryRowContext(ctx, query,
+		task.HostID, task.ExperimentID, task.Type, task.Action,
+		string(configJSON), task.Priority, task.Status, task.RetryCount,
+		task.NotBefore, task.OriginalTaskID, pq.Array(task.DependsOn),
+		task.IdempotencyKey,
+	).Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt)
+
+	if err == database.ErrNoRows {
+		return fmt.Errorf("%w: %s", ErrDuplicateTask, task.IdempotencyKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return nil
+}
+
+// CancelQueuedTask cancels a task that has not started running yet. It
+// reports false when the task is no longer pending or assigned.
+func (s *CompositeStore) CancelQueuedTask(ctx context.Context, taskID, reason string) (bool, error) {
+	query := `
+		UPDATE tasks SET
+			status = 'cancelled',
+			error_message = $2,
+			completed_at = NOW(),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status IN ('pending', 'assigned')
 	`
 
+	result, err := s.pipelineStore.db.DB().ExecContext(ctx, query, taskID, reason)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel task: %w", err)
+	}
+
+	cancelled, _ := result.RowsAffected()
+	return cancelled > 0, nil
+}
+
+// RequestTaskCancellation records that a running task should be aborted by
+// the agent running it. It reports false when the task is no longer running.
+func (s *CompositeStore) RequestTaskCancellation(ctx context.Context, taskID, reason string) (bool, error) {
+	query := `
+		UPDATE tasks SET
+			cancel_requested_at = COALESCE(cancel_requested_at, NOW()),
+			cancel_reason = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running'
+	`
+
+	result, err := s.pipelineStore.db.DB().ExecContext(ctx, query, taskID, reason)
+	if err != nil {
+		return false, fmt.Errorf("failed to request task cancellation: %w", err)
+	}
+
+	requested, _ := result.RowsAffected()
+	return requested > 0, nil
+}
+
+// GetTaskCancellations returns the cancellation requests for tasks a host
+// is still running
+func (s *CompositeStore) GetTaskCancellations(ctx context.Context, hostID string) ([]models.TaskCancellation, error) {
+	query := `
+		SELECT id, COALESCE(cancel_reason, '')
+		FROM tasks
+		WHERE host_id = $1 AND status = 'running' AND cancel_requested_at IS NOT NULL
+	`
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task cancellations: %w", err)
+	}
+	defer rows.Close()
+
+	cancellations := []models.TaskCancellation{}
+	for rows.Next() {
+		var c models.TaskCancellation
+		if err := rows.Scan(&c.TaskID, &c.Reason); err != nil {
+			return nil, fmt.Errorf("failed to scan task cancellation: %w", err)
+		}
+		cancellations = append(cancellations, c)
+	}
+
+	return cancellations, rows.Err()
+}
+
+// GetTaskByIdempotencyKey returns the live or completed task holding key
+func (s *CompositeStore) GetTaskByIdempotencyKey(ctx context.Context, key string) (*models.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks
+		WHERE idempotency_key = $1
+		AND status IN ('pending', 'assigned', 'running', 'completed')`
+
+	task, err := scanTask(s.pipelineStore.db.DB().QueryRowContext(ctx, query, key))
+	if err == database.ErrNoRows {
+		return nil, fmt.Errorf("%w: idempotency key %s", ErrTaskNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task by idempotency key: %w", err)
+	}
+
+	return task, nil
+}
+
+// ReleaseTaskIdempotencyKeys clears the given keys from the tasks holding
+// them so the same work can be enqueued again
+func (s *CompositeStore) ReleaseTaskIdempotencyKeys(ctx context.Context, keys []string) error {
+	query := `
+		UPDATE tasks SET
+			idempotency_key = NULL
+		WHERE idempotency_key = ANY($1)
+	`
+
+	if _, err := s.pipelineStore.db.DB().ExecContext(ctx, query, pq.Array(keys)); err != nil {
+		return fmt.Errorf("failed to release task idempotency keys: %w", err)
+	}
+	return nil
+}
+
+func (s *CompositeStore) GetTask(ctx context.Context, taskID string) (*models.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id = $1`
+
+	task, err := scanTask(s.pipelineStore.db.DB().QueryRowContext(ctx, query, taskID))
+	if err == database.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	return task, nil
+}
+
+func (s *CompositeStore) ListTasks(ctx context.Context, filters map[string]interface{}) ([]*models.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE 1=1`
+
 	var args []interface{}
 	argCount := 0
 
@@ -143,60 +343,10 @@ func (s *CompositeStore) ListTasks(ctx context.Context, filters map[string]inter
 		args = append(args, limit)
 	}
 
-	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, args...)
+	tasks, err := s.queryTasks(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list tasks: %w", err)
 	}
-	defer rows.Close()
-
-	var tasks []*models.Task
-
-	for rows.Next() {
-		var task models.Task
-		var configJSON string
-		var resultJSON database.NullString
-		var assignedAt, startedAt, completedAt database.NullTime
-		var errorMessage database.NullString
-
-		err := rows.Scan(
-			&task.ID, &task.HostID, &task.ExperimentID, &task.Type, &task.Action,
-			&configJSON, &task.Priority, &task.Status,
-			&assignedAt, &startedAt, &completedAt,
-			&resultJSON, &errorMessage, &task.RetryCount,
-			&task.CreatedAt, &task.UpdatedAt,
-		)
-
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to scan task row")
-			continue
-		}
-
-		// Handle nullable fields
-		if assignedAt.Valid {
-			task.AssignedAt = &assignedAt.Time
-		}
-		if startedAt.Valid {
-			task.StartedAt = &startedAt.Time
-		}
-		if completedAt.Valid {
-			task.CompletedAt = &completedAt.Time
-		}
-		if errorMessage.Valid {
-			task.ErrorMessage = errorMessage.String
-		}
-
-		// Unmarshal JSON fields
-		if err := json.Unmarshal([]byte(configJSON), &task.Config); err != nil {
-			task.Config = make(map[string]interface{})
-		}
-		if resultJSON.Valid {
-			if err := json.Unmarshal([]byte(resultJSON.String), &task.Result); err != nil {
-				task.Result = make(map[string]interface{})
-			}
-		}
-
-		tasks = append(tasks, &task)
-	}
 
 	return tasks, nil
 }
@@ -239,15 +389,194 @@ func (s *CompositeStore) UpdateTask(ctx context.Context, task *models.Task) erro
 	return nil
 }
 
-func (s *CompositeStore) GetPendingTasksForHost(ctx context.Context, hostID string) ([]*models.Task, error) {
-	// Get up to 10 pending tasks for the host, ordered by priority
-	filters := map[string]interface{}{
-		"host_id": hostID,
-		"status":  "pending",
-		"limit":   10,
+// UpdateLeasedTask updates a task on behalf of the agent holding its lease.
+// The update only applies while leaseToken matches and the lease is unexpired;
+// a positive extendBy pushes the lease expiry out from now. Tasks without a
+// lease were never claimed by an agent and cannot be updated this way, and
+// neither can tasks that are no longer assigned or running.
+func (s *CompositeStore) UpdateLeasedTask(ctx context.Context, task *models.Task, leaseToken string, extendBy time.Duration) error {
+	resultJSON, err := json.Marshal(task.Result)
+	if err != nil {
+		resultJSON = []byte("null")
 	}
 
-	return s.ListTasks(ctx, filters)
+	query := `
+		UPDATE tasks SET
+			status = $2,
+			started_at = $3,
+			completed_at = $4,
+			result = $5,
+			error_message = $6,
+			lease_expires_at = CASE
+				WHEN $7::float8 > 0 THEN NOW() + $7::float8 * INTERVAL '1 second'
+				ELSE lease_expires_at
+			END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		AND status IN ('assigned', 'running')
+		AND lease_token = $8
+		AND lease_expires_at >= NOW()
+		RETURNING lease_expires_at
+	`
+
+	var leaseExpiresAt database.NullTime
+	err = s.pipelineStore.db.DB().QueryRowContext(ctx, query,
+		task.ID, task.Status, task.StartedAt, task.CompletedAt,
+		string(resultJSON), task.ErrorMessage, extendBy.Seconds(), leaseToken,
+	).Scan(&leaseExpiresAt)
+
+	if err == database.ErrNoRows {
+		return s.classifyLeaseFailure(ctx, task.ID, leaseToken)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update leased task: %w", err)
+	}
+
+	if leaseExpiresAt.Valid {
+		task.LeaseExpiresAt = &leaseExpiresAt.Time
+	}
+
+	return nil
+}
+
+// RenewTaskLeases pushes out the lease expiry of every task a host holds an
+// unexpired lease on, so tasks that run longer than one lease are not
+// re-claimed while the agent is still alive. Expired leases are left alone;
+// those tasks may already have been handed out again.
+func (s *CompositeStore) RenewTaskLeases(ctx context.Context, hostID string, leaseDuration time.Duration) error {
+	query := `
+		UPDATE tasks SET
+			lease_expires_at = NOW() + $2::float8 * INTERVAL '1 second'
+		WHERE host_id = $1
+		AND status IN ('assigned', 'running')
+		AND lease_token IS NOT NULL
+		AND lease_expires_at >= NOW()
+	`
+
+	_, err := s.pipelineStore.db.DB().ExecContext(ctx, query, hostID, leaseDuration.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to renew task leases: %w", err)
+	}
+
+	return nil
+}
+
+// classifyLeaseFailure explains why a leased update matched no rows
+func (s *CompositeStore) classifyLeaseFailure(ctx context.Context, taskID, leaseToken string) error {
+	query := `
+		SELECT status, COALESCE(lease_token, ''), COALESCE(lease_expires_at >= NOW(), false)
+		FROM tasks WHERE id = $1
+	`
+
+	var status, currentToken string
+	var unexpired bool
+	err := s.pipelineStore.db.DB().QueryRowContext(ctx, query, taskID).Scan(&status, &currentToken, &unexpired)
+	if err == database.ErrNoRows {
+		return fmt.Errorf("task not found: %s", taskID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check task lease: %w", err)
+	}
+
+	if status == "cancelled" {
+		return ErrTaskCancelled
+	}
+	if status != "assigned" && status != "running" {
+		return fmt.Errorf("%w: %s is %s", ErrTaskFinished, taskID, status)
+	}
+
+	if currentToken != leaseToken {
+		return ErrTaskLeaseMismatch
+	}
+	if !unexpired {
+		return ErrTaskLeaseExpired
+	}
+
+	return fmt.Errorf("failed to update leased task: %s", taskID)
+}
+
+// ClaimPendingTasksForHost atomically leases up to limit tasks for a host.
+// Candidate rows are locked with FOR UPDATE SKIP LOCKED so concurrent API
+// replicas (or overlapping long-polls) never hand out the same task twice.
+// Assigned tasks whose lease expired before the agent acknowledged them are
+// eligible to be claimed again.
+func (s *CompositeStore) ClaimPendingTasksForHost(ctx context.Context, hostID string, leaseDuration time.Duration, limit int) ([]*models.Task, error) {
+	tx, err := s.pipelineStore.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin claim transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	selectQuery := `
+		SELECT ` + taskColumns + `
+		FROM tasks
+		WHERE host_id = $1
+		AND (status = 'pending' OR (status = 'assigned' AND lease_expires_at < NOW()))
+		AND (not_before IS NULL OR not_before <= NOW())
+		AND NOT EXISTS (
+			SELECT 1 FROM unnest(tasks.depends_on) AS dep(id)
+			WHERE NOT EXISTS (
+				SELECT 1 FROM tasks parent
+				WHERE (parent.id = dep.id OR parent.original_task_id = dep.id)
+				AND parent.status = 'completed'
+			)
+		)
+		ORDER BY priority DESC, created_at ASC
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := tx.QueryContext(ctx, selectQuery, hostID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select claimable tasks: %w", err)
+	}
+
+	var tasks []*models.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan claimable task: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate claimable tasks: %w", err)
+	}
+
+	claimQuery := `
+		UPDATE tasks SET
+			status = 'assigned',
+			assigned_at = NOW(),
+			lease_token = $2,
+			lease_expires_at = NOW() + $3::float8 * INTERVAL '1 second',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING assigned_at, lease_expires_at
+	`
+
+	for _, task := range tasks {
+		var assignedAt, leaseExpiresAt time.Time
+		token := uuid.NewString()
+
+		err := tx.QueryRowContext(ctx, claimQuery, task.ID, token, leaseDuration.Seconds()).
+			Scan(&assignedAt, &leaseExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lease task %s: %w", task.ID, err)
+		}
+
+		task.Status = "assigned"
+		task.AssignedAt = &assignedAt
+		task.LeaseToken = token
+		task.LeaseExpiresAt = &leaseExpiresAt
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit task claim: %w", err)
+	}
+
+	return tasks, nil
 }
 
 func (s *CompositeStore) GetTasksByExperiment(ctx context.Context, experimentID string) ([]*models.Task, error) {
@@ -258,6 +587,73 @@ func (s *CompositeStore) GetTasksByExperiment(ctx context.Context, experimentID
 	return s.ListTasks(ctx, filters)
 }
 
+// MoveTaskToDeadLetter parks a failed task in the dead-letter state
+func (s *CompositeStore) MoveTaskToDeadLetter(ctx context.Context, taskID, reason string) error {
+	query := `
+		UPDATE tasks SET
+			status = 'dead_letter',
+			dead_letter_reason = $2,
+			dead_lettered_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'failed'
+	`
+
+	result, err := s.pipelineStore.db.DB().ExecContext(ctx, query, taskID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter task: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed task not found: %s", taskID)
+	}
+
+	return nil
+}
+
+// CancelDependentTasks cancels every pending task that depends on taskID,
+// directly or through other dependents, and returns the cancelled task IDs
+func (s *CompositeStore) CancelDependentTasks(ctx context.Context, taskID, reason string) ([]string, error) {
+	query := `
+		WITH RECURSIVE dependents AS (
+			SELECT id FROM tasks
+			WHERE $1 = ANY(depends_on) AND status = 'pending'
+			UNION
+			SELECT t.id FROM tasks t
+			JOIN dependents d ON d.id = ANY(t.depends_on)
+			WHERE t.status = 'pending'
+		)
+		UPDATE tasks SET
+			status = 'cancelled',
+			error_message = $2,
+			completed_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (SELECT id FROM dependents)
+		RETURNING id
+	`
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, taskID, reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel dependent tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var cancelled []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan cancelled task: %w", err)
+		}
+		cancelled = append(cancelled, id)
+	}
+
+	return cancelled, rows.Err()
+}
+
 func (s *CompositeStore) GetTaskStats(ctx context.Context) (map[string]interface{}, error) {
 	query := `
 		SELECT 
@@ -267,6 +663,7 @@ func (s *CompositeStore) GetTaskStats(ctx context.Context) (map[string]interface
 			COUNT(CASE WHEN status = 'running' THEN 1 END) as running,
 			COUNT(CASE WHEN status = 'completed' THEN 1 END) as completed,
 			COUNT(CASE WHEN status = 'failed' THEN 1 END) as failed,
+			COUNT(CASE WHEN status = 'dead_letter' THEN 1 END) as dead_letter,
 			COUNT(DISTINCT host_id) as unique_hosts,
 			COUNT(DISTINCT experiment_id) as unique_experiments
 		FROM tasks
@@ -280,13 +677,14 @@ func (s *CompositeStore) GetTaskStats(ctx context.Context) (map[string]interface
 		Running           int
 		Completed         int
 		Failed            int
+		DeadLetter        int
 		UniqueHosts       int
 		UniqueExperiments int
 	}
 
 	err := s.pipelineStore.db.DB().QueryRowContext(ctx, query).Scan(
 		&stats.Total, &stats.Pending, &stats.Assigned, &stats.Running,
-		&stats.Completed, &stats.Failed, &stats.UniqueHosts, &stats.UniqueExperiments,
+		&stats.Completed, &stats.Failed, &stats.DeadLetter, &stats.UniqueHosts, &stats.UniqueExperiments,
 	)
 
 	if err != nil {
@@ -300,6 +698,7 @@ func (s *CompositeStore) GetTaskStats(ctx context.Context) (map[string]interface
 		"running":            stats.Running,
 		"completed":          stats.Completed,
 		"failed":             stats.Failed,
+		"dead_letter":        stats.DeadLetter,
 		"unique_hosts":       stats.UniqueHosts,
 		"unique_experiments": stats.UniqueExperiments,
 	}, nil
@@ -330,9 +729,7 @@ func (s *CompositeStore) GetActiveTasks(ctx context.Context, status, hostID stri
 // getActiveTasksSpecial handles the special "active" status query
 func (s *CompositeStore) getActiveTasksSpecial(ctx context.Context, hostID string, limit int) ([]*models.Task, error) {
 	query := `
-		SELECT id, host_id, experiment_id, task_type, action, config,
-		       priority, status, assigned_at, started_at, completed_at,
-		       result, error_message, retry_count, created_at, updated_at
+		SELECT ` + taskColumns + `
 		FROM tasks 
 		WHERE status IN ('pending', 'assigned', 'running')
 	`
@@ -354,60 +751,10 @@ func (s *CompositeStore) getActiveTasksSpecial(ctx context.Context, hostID strin
 		args = append(args, limit)
 	}
 
-	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, args...)
+	tasks, err := s.queryTasks(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active tasks: %w", err)
 	}
-	defer rows.Close()
-
-	var tasks []*models.Task
-
-	for rows.Next() {
-		var task models.Task
-		var configJSON string
-		var resultJSON database.NullString
-		var assignedAt, startedAt, completedAt database.NullTime
-		var errorMessage database.NullString
-
-		err := rows.Scan(
-			&task.ID, &task.HostID, &task.ExperimentID, &task.Type, &task.Action,
-			&configJSON, &task.Priority, &task.Status,
-			&assignedAt, &startedAt, &completedAt,
-			&resultJSON, &errorMessage, &task.RetryCount,
-			&task.CreatedAt, &task.UpdatedAt,
-		)
-
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to scan task row")
-			continue
-		}
-
-		// Handle nullable fields
-		if assignedAt.Valid {
-			task.AssignedAt = &assignedAt.Time
-		}
-		if startedAt.Valid {
-			task.StartedAt = &startedAt.Time
-		}
-		if completedAt.Valid {
-			task.CompletedAt = &completedAt.Time
-		}
-		if errorMessage.Valid {
-			task.ErrorMessage = errorMessage.String
-		}
-
-		// Unmarshal JSON fields
-		if err := json.Unmarshal([]byte(configJSON), &task.Config); err != nil {
-			task.Config = make(map[string]interface{})
-		}
-		if resultJSON.Valid {
-			if err := json.Unmarshal([]byte(resultJSON.String), &task.Result); err != nil {
-				task.Result = make(map[string]interface{})
-			}
-		}
-
-		tasks = append(tasks, &task)
-	}
 
 	return tasks, nil
 }
@@ -434,12 +781,18 @@ func (s *CompositeStore) UpsertAgent(ctx context.Context, agent *models.AgentSta
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
+	labelsJSON, err := marshalLabels(agent.Labels)
+	if err != nil {
+		return err
+	}
+
+	// Assigned labels are only changed through SetAgentLabels
 	query := `
 		INSERT INTO agents (
 			host_id, hostname, ip_address, agent_version, started_at,
 			last_heartbeat, status, capabilities, active_tasks,
-			resource_usage, metadata
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			resource_usage, metadata, labels
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		ON CONFLICT (host_id) DO UPDATE SET
 			hostname = EXCLUDED.hostname,
 			ip_address = EXCLUDED.ip_address,
@@ -450,6 +803,7 @@ func (s *CompositeStore) UpsertAgent(ctx context.Context, agent *models.AgentSta
 			active_tasks = EXCLUDED.active_tasks,
 			resource_usage = EXCLUDED.resource_usage,
 			metadata = EXCLUDED.metadata,
+			labels = EXCLUDED.labels,
 			updated_at = CURRENT_TIMESTAMP
 	`
 
@@ -457,7 +811,7 @@ func (s *CompositeStore) UpsertAgent(ctx context.Context, agent *models.AgentSta
 		agent.HostID, agent.Hostname, agent.IPAddress, agent.AgentVersion,
 		agent.StartedAt, agent.LastHeartbeat, agent.Status,
 		string(capabilitiesJSON), string(activeTasksJSON),
-		string(resourceUsageJSON), string(metadataJSON),
+		string(resourceUsageJSON), string(metadataJSON), labelsJSON,
 	)
 
 	if err != nil {
@@ -471,7 +825,8 @@ func (s *CompositeStore) GetAgent(ctx context.Context, hostID string) (*models.A
 	query := `
 		SELECT host_id, hostname, ip_address, agent_version, started_at,
 		       last_heartbeat, status, capabilities, active_tasks,
-		       resource_usage, metadata, created_at, updated_at
+		       resource_usage, metadata, labels, assigned_labels,
+		       created_at, updated_at
 		FROM agents WHERE host_id = $1
 	`
 
@@ -479,17 +834,19 @@ func (s *CompositeStore) GetAgent(ctx context.Context, hostID string) (*models.A
 
 	var agent models.AgentStatus
 	var capabilitiesJSON, activeTasksJSON, resourceUsageJSON, metadataJSON string
+	var labelsJSON, assignedLabelsJSON string
 	var startedAt database.NullTime
 
 	err := row.Scan(
 		&agent.HostID, &agent.Hostname, &agent.IPAddress, &agent.AgentVersion,
 		&startedAt, &agent.LastHeartbeat, &agent.Status,
 		&capabilitiesJSON, &activeTasksJSON, &resourceUsageJSON, &metadataJSON,
+		&labelsJSON, &assignedLabelsJSON,
 		&agent.CreatedAt, &agent.UpdatedAt,
 	)
 
 	if err == database.ErrNoRows {
-		return nil, fmt.Errorf("agent not found: %s", hostID)
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, hostID)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get agent: %w", err)
@@ -512,6 +869,8 @@ func (s *CompositeStore) GetAgent(ctx context.Context, hostID string) (*models.A
 	if err := json.Unmarshal([]byte(metadataJSON), &agent.Metadata); err != nil {
 		agent.Metadata = make(map[string]interface{})
 	}
+	agent.Labels = unmarshalLabels(labelsJSON)
+	agent.AssignedLabels = unmarshalLabels(assignedLabelsJSON)
 
 	return &agent, nil
 }
@@ -520,7 +879,8 @@ func (s *CompositeStore) ListAgents(ctx context.Context) ([]*models.AgentStatus,
 	query := `
 		SELECT host_id, hostname, ip_address, agent_version, started_at,
 		       last_heartbeat, status, capabilities, active_tasks,
-		       resource_usage, metadata, created_at, updated_at
+		       resource_usage, metadata, labels, assigned_labels,
+		       created_at, updated_at
 		FROM agents ORDER BY hostname
 	`
 
@@ -535,12 +895,14 @@ func (s *CompositeStore) ListAgents(ctx context.Context) ([]*models.AgentStatus,
 	for rows.Next() {
 		var agent models.AgentStatus
 		var capabilitiesJSON, activeTasksJSON, resourceUsageJSON, metadataJSON string
+		var labelsJSON, assignedLabelsJSON string
 		var startedAt database.NullTime
 
 		err := rows.Scan(
 			&agent.HostID, &agent.Hostname, &agent.IPAddress, &agent.AgentVersion,
 			&startedAt, &agent.LastHeartbeat, &agent.Status,
 			&capabilitiesJSON, &activeTasksJSON, &resourceUsageJSON, &metadataJSON,
+			&labelsJSON, &assignedLabelsJSON,
 			&agent.CreatedAt, &agent.UpdatedAt,
 		)
 
@@ -566,6 +928,8 @@ func (s *CompositeStore) ListAgents(ctx context.Context) ([]*models.AgentStatus,
 		if err := json.Unmarshal([]byte(metadataJSON), &agent.Metadata); err != nil {
 			agent.Metadata = make(map[string]interface{})
 		}
+		agent.Labels = unmarshalLabels(labelsJSON)
+		agent.AssignedLabels = unmarshalLabels(assignedLabelsJSON)
 
 		agents = append(agents, &agent)
 	}
@@ -594,6 +958,7 @@ func (s *CompositeStore) UpdateAgentHeartbeat(ctx context.Context, heartbeat *mo
 			ResourceUsage: heartbeat.ResourceUsage,
 			Capabilities:  make(map[string]interface{}),
 			Metadata:      make(map[string]interface{}),
+			Labels:        heartbeat.Labels,
 		}
 		return s.UpsertAgent(ctx, agent)
 	}
@@ -609,13 +974,24 @@ func (s *CompositeStore) UpdateAgentHeartbeat(ctx context.Context, heartbeat *mo
 		return fmt.Errorf("failed to marshal resource_usage: %w", err)
 	}
 
+	// Heartbeats without labels keep the labels reported earlier
+	var labelsJSON interface{}
+	if heartbeat.Labels != nil {
+		labelsJSON, err = marshalLabels(heartbeat.Labels)
+		if err != nil {
+			return err
+		}
+	}
+
 	query := `
 		UPDATE agents SET
 			agent_version = $2,
 			last_heartbeat = $3,
+			state_changed_at = CASE WHEN status IS DISTINCT FROM $4 THEN CURRENT_TIMESTAMP ELSE state_changed_at END,
 			status = $4,
 			active_tasks = $5,
 			resource_usage = $6,
+			labels = COALESCE($7::jsonb, labels),
 			updated_at = CURRENT_TIMESTAMP
 		WHERE host_id = $1
 	`
@@ -623,6 +999,7 @@ func (s *CompositeStore) UpdateAgentHeartbeat(ctx context.Context, heartbeat *mo
 	_, err = s.pipelineStore.db.DB().ExecContext(ctx, query,
 		heartbeat.HostID, heartbeat.AgentVersion, heartbeat.LastHeartbeat,
 		heartbeat.Status, string(activeTasksJSON), string(resourceUsageJSON),
+		labelsJSON,
 	)
 
 	if err != nil {
@@ -632,6 +1009,55 @@ func (s *CompositeStore) UpdateAgentHeartbeat(ctx context.Context, heartbeat *mo
 	return nil
 }
 
+// SetAgentLabels replaces the labels assigned to an agent through the API
+func (s *CompositeStore) SetAgentLabels(ctx context.Context, hostID string, labels map[string]string) error {
+	labelsJSON, err := marshalLabels(labels)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE agents SET
+			assigned_labels = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE host_id = $1
+	`
+
+	result, err := s.pipelineStore.db.DB().ExecContext(ctx, query, hostID, labelsJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set agent labels: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to set agent labels: %w", err)
+	}
+	if rows == 0 {
+		return ErrAgentNotFound
+	}
+
+	return nil
+}
+
+func marshalLabels(labels map[string]string) (string, error) {
+	if labels == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal labels: %w", err)
+	}
+	return string(data), nil
+}
+
+func unmarshalLabels(data string) map[string]string {
+	labels := make(map[string]string)
+	if err := json.Unmarshal([]byte(data), &labels); err != nil || labels == nil {
+		return make(map[string]string)
+	}
+	return labels
+}
+
 func (s *CompositeStore) GetAllAgents(ctx context.Context) ([]*models.AgentStatus, error) {
 	return s.ListAgents(ctx)
 }
@@ -703,75 +1129,25 @@ func (s *CompositeStore) CacheMetric(ctx context.Context, hostID string, metric
 	return nil
 }
 
-// GetStaleTasks returns tasks that have been assigned but not updated within the threshold
+// GetStaleTasks returns assigned or running tasks whose lease lapsed more
+// than threshold ago. Agents renew the leases of the tasks they work on, so
+// a long-running task is only stale once its agent stops renewing.
 func (s *CompositeStore) GetStaleTasks(ctx context.Context, threshold time.Duration) ([]*models.Task, error) {
 	query := `
-		SELECT id, host_id, experiment_id, task_type, action, config,
-		       priority, status, assigned_at, started_at, completed_at,
-		       result, error_message, retry_count, created_at, updated_at
+		SELECT ` + taskColumns + `
 		FROM tasks 
 		WHERE status IN ('assigned', 'running')
-		AND assigned_at IS NOT NULL
-		AND assigned_at < $1
-		ORDER BY assigned_at ASC
+		AND lease_expires_at IS NOT NULL
+		AND lease_expires_at < $1
+		ORDER BY lease_expires_at ASC
 	`
 
 	cutoffTime := time.Now().Add(-threshold)
 
-	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, cutoffTime)
+	tasks, err := s.queryTasks(ctx, query, cutoffTime)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get stale tasks: %w", err)
 	}
-	defer rows.Close()
-
-	var tasks []*models.Task
-
-	for rows.Next() {
-		var task models.Task
-		var configJSON string
-		var resultJSON database.NullString
-		var assignedAt, startedAt, completedAt database.NullTime
-		var errorMessage database.NullString
-
-		err := rows.Scan(
-			&task.ID, &task.HostID, &task.ExperimentID, &task.Type, &task.Action,
-			&configJSON, &task.Priority, &task.Status,
-			&assignedAt, &startedAt, &completedAt,
-			&resultJSON, &errorMessage, &task.RetryCount,
-			&task.CreatedAt, &task.UpdatedAt,
-		)
-
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to scan stale task row")
-			continue
-		}
-
-		// Handle nullable fields
-		if assignedAt.Valid {
-			task.AssignedAt = &assignedAt.Time
-		}
-		if startedAt.Valid {
-			task.StartedAt = &startedAt.Time
-		}
-		if completedAt.Valid {
-			task.CompletedAt = &completedAt.Time
-		}
-		if errorMessage.Valid {
-			task.ErrorMessage = errorMessage.String
-		}
-
-		// Unmarshal JSON fields
-		if err := json.Unmarshal([]byte(configJSON), &task.Config); err != nil {
-			task.Config = make(map[string]interface{})
-		}
-		if resultJSON.Valid {
-			if err := json.Unmarshal([]byte(resultJSON.String), &task.Result); err != nil {
-				task.Result = make(map[string]interface{})
-			}
-		}
-
-		tasks = append(tasks, &task)
-	}
 
 	return tasks, nil
 }
@@ -781,7 +1157,7 @@ func (s *CompositeStore) DeleteOldTasks(ctx context.Context, before time.Time) e
 	query := `
 		DELETE FROM tasks 
 		WHERE created_at < $1
-		AND status IN ('completed', 'failed')
+		AND status IN ('completed', 'failed', 'requeued', 'discarded')
 	`
 
 	result, err := s.pipelineStore.db.DB().ExecContext(ctx, query, before)
@@ -46,7 +46,10 @@ func (s *CompositeStore) CreateExperiment(ctx context.Context, experiment *inter
 		commonExp.ID = fmt.Sprintf("exp-%d", time.Now().Unix())
 		experiment.ID = commonExp.ID
 	}
-	return s.postgresStore.CreateExperiment(ctx, commonExp)
+	if err := s.postgresStore.CreateExperiment(ctx, commonExp); err != nil {
+		return err
+	}
+	return s.saveExperimentDetails(ctx, experiment)
 }
 func (s *CompositeStore) GetExperiment(ctx context.Context, experimentID string) (*internalModels.Experiment, error) {
 	commonExp, err := s.postgresStore.GetExperiment(ctx, experimentID)
@@ -59,7 +62,7 @@ func (s *CompositeStore) GetExperiment(ctx context.Context, experimentID string)
 	for _, host := range commonExp.TargetNodes {
 		targetHosts = append(targetHosts, host)
 	}
-	return &internalModels.Experiment{
+	exp := &internalModels.Experiment{
 		ID:          commonExp.ID,
 		Name:        commonExp.Name,
 		Description: commonExp.Description,
@@ -77,7 +80,11 @@ func (s *CompositeStore) GetExperiment(ctx context.Context, experimentID string)
 		Metadata:  map[string]interface{}{},
 		CreatedAt: commonExp.CreatedAt,
 		UpdatedAt: commonExp.UpdatedAt,
-	}, nil
+	}
+	if err := s.loadExperimentDetails(ctx, exp); err != nil {
+		return nil, err
+	}
+	return exp, nil
 }
 func (s *CompositeStore) ListExperiments(ctx context.Context) ([]*internalModels.Experiment, error) {
 	commonExps, err := s.postgresStore.ListExperiments(ctx, 100, 0)
@@ -91,7 +98,7 @@ func (s *CompositeStore) ListExperiments(ctx context.Context) ([]*internalModels
 		for _, host := range commonExp.TargetNodes {
 			targetHosts = append(targetHosts, host)
 		}
-		experiments = append(experiments, &internalModels.Experiment{
+		exp := &internalModels.Experiment{
 			ID:          commonExp.ID,
 			Name:        commonExp.Name,
 			Description: commonExp.Description,
@@ -109,7 +116,11 @@ func (s *CompositeStore) ListExperiments(ctx context.Context) ([]*internalModels
 			Metadata:  map[string]interface{}{},
 			CreatedAt: commonExp.CreatedAt,
 			UpdatedAt: commonExp.UpdatedAt,
-		})
+		}
+		if err := s.loadExperimentDetails(ctx, exp); err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, exp)
 	}
 	return experiments, nil
 }
@@ -125,7 +136,54 @@ func (s *CompositeStore) UpdateExperiment(ctx context.Context, experiment *inter
 		CreatedAt:         experiment.CreatedAt,
 		UpdatedAt:         time.Now(),
 	}
-	return s.postgresStore.UpdateExperiment(ctx, commonExp)
+	if err := s.postgresStore.UpdateExperiment(ctx, commonExp); err != nil {
+		return err
+	}
+	return s.saveExperimentDetails(ctx, experiment)
+}
+
+// saveExperimentDetails persists the parts of an experiment the common
+// experiment store has no columns for: the full config and metadata
+func (s *CompositeStore) saveExperimentDetails(ctx context.Context, experiment *internalModels.Experiment) error {
+	configJSON, err := json.Marshal(experiment.Config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal experiment config: %w", err)
+	}
+	metadataJSON, err := json.Marshal(experiment.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal experiment metadata: %w", err)
+	}
+	query := `UPDATE experiments SET config = $2, metadata = $3 WHERE id = $1`
+	if _, err := s.pipelineStore.db.DB().ExecContext(ctx, query,
+		experiment.ID, string(configJSON), string(metadataJSON)); err != nil {
+		return fmt.Errorf("failed to save experiment config: %w", err)
+	}
+	return nil
+}
+
+// loadExperimentDetails fills in the config and metadata saved by
+// saveExperimentDetails. Target hosts stay as read from the common store.
+func (s *CompositeStore) loadExperimentDetails(ctx context.Context, experiment *internalModels.Experiment) error {
+	var configJSON, metadataJSON []byte
+	query := `SELECT COALESCE(config, '{}'), COALESCE(metadata, '{}') FROM experiments WHERE id = $1`
+	err := s.pipelineStore.db.DB().QueryRowContext(ctx, query, experiment.ID).Scan(&configJSON, &metadataJSON)
+	if err != nil {
+		return fmt.Errorf("failed to load experiment config: %w", err)
+	}
+
+	targetHosts := experiment.Config.TargetHosts
+	if err := json.Unmarshal(configJSON, &experiment.Config); err != nil {
+		return fmt.Errorf("failed to unmarshal experiment config: %w", err)
+	}
+	experiment.Config.TargetHosts = targetHosts
+
+	if err := json.Unmarshal(metadataJSON, &experiment.Metadata); err != nil {
+		return fmt.Errorf("failed to unmarshal experiment metadata: %w", err)
+	}
+	if experiment.Metadata == nil {
+		experiment.Metadata = map[string]interface{}{}
+	}
+	return nil
 }
 func (s *CompositeStore) UpdateExperimentPhase(ctx context.Context, experimentID string, phase string) error {
 	exp, err := s.postgresStore.GetExperiment(ctx, experimentID)
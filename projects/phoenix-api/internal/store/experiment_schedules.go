@@ -0,0 +1,228 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/phoenix/platform/pkg/database"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+// ErrScheduleNotFound is returned when an experiment has no schedule
+var ErrScheduleNotFound = errors.New("experiment schedule not found")
+
+const scheduleColumns = `
+	id, experiment_id, start_at, cron, timezone, enabled, next_run_at,
+	last_run_at, run_count, created_at, updated_at`
+
+// CreateExperimentSchedule stores the schedule of an experiment. Times are
+// stored in UTC.
+func (s *CompositeStore) CreateExperimentSchedule(ctx context.Context, schedule *models.ExperimentSchedule) error {
+	query := `
+		INSERT INTO experiment_schedules (experiment_id, start_at, cron, timezone, enabled, next_run_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := s.pipelineStore.db.DB().QueryRowContext(ctx, query,
+		schedule.ExperimentID, utcTime(schedule.StartAt), schedule.Cron, schedule.Timezone,
+		schedule.Enabled, utcTime(schedule.NextRunAt),
+	).Scan(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create experiment schedule: %w", err)
+	}
+
+	return nil
+}
+
+// GetExperimentSchedule returns the schedule of an experiment
+func (s *CompositeStore) GetExperimentSchedule(ctx context.Context, experimentID string) (*models.ExperimentSchedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM experiment_schedules WHERE experiment_id = $1`
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, experimentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get experiment schedule: %w", err)
+	}
+	defer rows.Close()
+
+	schedules, err := scanSchedules(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, ErrScheduleNotFound
+	}
+	return schedules[0], nil
+}
+
+// GetDueSchedules returns enabled schedules whose next run is due, oldest
+// first
+func (s *CompositeStore) GetDueSchedules(ctx context.Context, limit int) ([]*models.ExperimentSchedule, error) {
+	query := `
+		SELECT ` + scheduleColumns + `
+		FROM experiment_schedules
+		WHERE enabled AND next_run_at <= (NOW() AT TIME ZONE 'UTC')
+		ORDER BY next_run_at ASC
+		LIMIT $1
+	`
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due schedules: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSchedules(rows)
+}
+
+// ClaimScheduleRun advances a schedule whose run was due at dueAt to
+// nextRunAt and returns the number of the claimed run. A nil nextRunAt
+// disables the schedule. It reports false if the run was already claimed.
+func (s *CompositeStore) ClaimScheduleRun(ctx context.Context, scheduleID string, dueAt time.Time, nextRunAt *time.Time) (int, bool, error) {
+	query := `
+		UPDATE experiment_schedules SET
+			next_run_at = $3,
+			enabled = $3 IS NOT NULL,
+			last_run_at = (NOW() AT TIME ZONE 'UTC'),
+			run_count = run_count + 1,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND enabled AND next_run_at = $2
+		RETURNING run_count
+	`
+
+	var runNumber int
+	err := s.pipelineStore.db.DB().QueryRowContext(ctx, query, scheduleID, dueAt.UTC(), utcTime(nextRunAt)).Scan(&runNumber)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to claim schedule run: %w", err)
+	}
+
+	return runNumber, true, nil
+}
+
+// DisableExperimentSchedule stops an experiment's schedule from starting
+// further runs
+func (s *CompositeStore) DisableExperimentSchedule(ctx context.Context, experimentID string) error {
+	query := `
+		UPDATE experiment_schedules SET
+			enabled = FALSE,
+			next_run_at = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE experiment_id = $1
+	`
+
+	result, err := s.pipelineStore.db.DB().ExecContext(ctx, query, experimentID)
+	if err != nil {
+		return fmt.Errorf("failed to disable experiment schedule: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// CreateScheduleRun records a run started by a schedule
+func (s *CompositeStore) CreateScheduleRun(ctx context.Context, run *models.ExperimentScheduleRun) error {
+	query := `
+		INSERT INTO experiment_schedule_runs (schedule_id, experiment_id, run_number, scheduled_for, status, error)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, NULLIF($6, ''))
+		RETURNING id, created_at
+	`
+
+	err := s.pipelineStore.db.DB().QueryRowContext(ctx, query,
+		run.ScheduleID, run.ExperimentID, run.RunNumber, run.ScheduledFor.UTC(), run.Status, run.Error,
+	).Scan(&run.ID, &run.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record schedule run: %w", err)
+	}
+
+	return nil
+}
+
+// ListScheduleRuns returns the runs the schedule of an experiment has
+// started, newest first
+func (s *CompositeStore) ListScheduleRuns(ctx context.Context, experimentID string) ([]*models.ExperimentScheduleRun, error) {
+	query := `
+		SELECT r.id, r.schedule_id, r.experiment_id, r.run_number, r.scheduled_for,
+		       r.status, r.error, r.created_at
+		FROM experiment_schedule_runs r
+		JOIN experiment_schedules s ON s.id = r.schedule_id
+		WHERE s.experiment_id = $1
+		ORDER BY r.run_number DESC
+	`
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, experimentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.ExperimentScheduleRun
+	for rows.Next() {
+		var run models.ExperimentScheduleRun
+		var runExperimentID, runError database.NullString
+
+		err := rows.Scan(&run.ID, &run.ScheduleID, &runExperimentID, &run.RunNumber, &run.ScheduledFor,
+			&run.Status, &runError, &run.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule run: %w", err)
+		}
+
+		if runExperimentID.Valid {
+			run.ExperimentID = runExperimentID.String
+		}
+		if runError.Valid {
+			run.Error = runError.String
+		}
+
+		runs = append(runs, &run)
+	}
+
+	return runs, rows.Err()
+}
+
+func scanSchedules(rows *sql.Rows) ([]*models.ExperimentSchedule, error) {
+	var schedules []*models.ExperimentSchedule
+	for rows.Next() {
+		var sch models.ExperimentSchedule
+		var cron, timezone database.NullString
+		var startAt, nextRunAt, lastRunAt database.NullTime
+
+		err := rows.Scan(
+			&sch.ID, &sch.ExperimentID, &startAt, &cron, &timezone, &sch.Enabled, &nextRunAt,
+			&lastRunAt, &sch.RunCount, &sch.CreatedAt, &sch.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan experiment schedule: %w", err)
+		}
+
+		sch.Cron = cron.String
+		sch.Timezone = timezone.String
+		if startAt.Valid {
+			sch.StartAt = &startAt.Time
+		}
+		if nextRunAt.Valid {
+			sch.NextRunAt = &nextRunAt.Time
+		}
+		if lastRunAt.Valid {
+			sch.LastRunAt = &lastRunAt.Time
+		}
+
+		schedules = append(schedules, &sch)
+	}
+
+	return schedules, rows.Err()
+}
+
+// utcTime converts an optional time to UTC for a TIMESTAMP column
+func utcTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
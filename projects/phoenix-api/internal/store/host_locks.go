@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ErrHostLocked is returned when a host is held by another experiment
+var ErrHostLocked = errors.New("host is locked by another experiment")
+
+// AcquireHostLocks locks every given host for an experiment. Either all
+// hosts are locked or none is; if another experiment holds any of them,
+// ErrHostLocked names the first such host. Locks the experiment already
+// holds are kept.
+func (s *CompositeStore) AcquireHostLocks(ctx context.Context, experimentID string, hosts []string) error {
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	tx, err := s.pipelineStore.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin host lock transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO host_locks (host_id, experiment_id)
+		SELECT unnest($2::text[]), $1
+		ON CONFLICT (host_id) DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, query, experimentID, pq.Array(hosts)); err != nil {
+		return fmt.Errorf("failed to acquire host locks: %w", err)
+	}
+
+	// On conflict the insert waits for a concurrent transaction locking the
+	// same host, so this check sees the lock it committed
+	var host, holder string
+	err = tx.QueryRowContext(ctx, `
+		SELECT host_id, experiment_id FROM host_locks
+		WHERE host_id = ANY($2) AND experiment_id <> $1
+		ORDER BY host_id
+		LIMIT 1
+	`, experimentID, pq.Array(hosts)).Scan(&host, &holder)
+	if err == nil {
+		return fmt.Errorf("%w: %s is held by %s", ErrHostLocked, host, holder)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check host locks: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit host locks: %w", err)
+	}
+	return nil
+}
+
+// ReleaseHostLocks releases every host lock an experiment holds
+func (s *CompositeStore) ReleaseHostLocks(ctx context.Context, experimentID string) error {
+	query := `DELETE FROM host_locks WHERE experiment_id = $1`
+	if _, err := s.pipelineStore.db.DB().ExecContext(ctx, query, experimentID); err != nil {
+		return fmt.Errorf("failed to release host locks: %w", err)
+	}
+	return nil
+}
+
+// GetHostLocks returns the experiment holding each of the given hosts. Hosts
+// that are not locked are left out. With no hosts, every lock is returned.
+func (s *CompositeStore) GetHostLocks(ctx context.Context, hosts []string) (map[string]string, error) {
+	query := `SELECT host_id, experiment_id FROM host_locks`
+	var args []interface{}
+	if len(hosts) > 0 {
+		query += ` WHERE host_id = ANY($1)`
+		args = append(args, pq.Array(hosts))
+	}
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host locks: %w", err)
+	}
+	defer rows.Close()
+
+	locks := make(map[string]string)
+	for rows.Next() {
+		var host, experimentID string
+		if err := rows.Scan(&host, &experimentID); err != nil {
+			return nil, fmt.Errorf("failed to scan host lock: %w", err)
+		}
+		locks[host] = experimentID
+	}
+	return locks, rows.Err()
+}
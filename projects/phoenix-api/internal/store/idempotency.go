@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/phoenix/platform/pkg/database"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// ReserveIdempotencyKey claims key for a request. It returns nil and true when
+// the key was free, or the existing record and false when the key has been
+// used before.
+func (s *CompositeStore) ReserveIdempotencyKey(ctx context.Context, key, method, path string) (*models.IdempotencyRecord, bool, error) {
+	insert := `
+		INSERT INTO idempotency_keys (key, method, path)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO NOTHING
+	`
+
+	result, err := s.pipelineStore.db.DB().ExecContext(ctx, insert, key, method, path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted > 0 {
+		return nil, true, nil
+	}
+
+	query := `
+		SELECT key, method, path, status_code, content_type, response_body,
+		       created_at, completed_at
+		FROM idempotency_keys
+		WHERE key = $1
+	`
+
+	var record models.IdempotencyRecord
+	var statusCode database.NullInt64
+	var contentType database.NullString
+	var completedAt database.NullTime
+
+	err = s.pipelineStore.db.DB().QueryRowContext(ctx, query, key).Scan(
+		&record.Key, &record.Method, &record.Path, &statusCode, &contentType,
+		&record.ResponseBody, &record.CreatedAt, &completedAt,
+	)
+	if err == database.ErrNoRows {
+		// Released between the insert and the lookup; let the caller retry
+		return nil, false, fmt.Errorf("idempotency key %s was released concurrently", key)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	if statusCode.Valid {
+		record.StatusCode = int(statusCode.Int64)
+	}
+	if contentType.Valid {
+		record.ContentType = contentType.String
+	}
+	if completedAt.Valid {
+		record.CompletedAt = &completedAt.Time
+	}
+
+	return &record, false, nil
+}
+
+// CompleteIdempotencyKey stores the response to the request holding key so
+// retries can be answered with it
+func (s *CompositeStore) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	query := `
+		UPDATE idempotency_keys SET
+			status_code = $2,
+			content_type = $3,
+			response_body = $4,
+			completed_at = CURRENT_TIMESTAMP
+		WHERE key = $1
+	`
+
+	if _, err := s.pipelineStore.db.DB().ExecContext(ctx, query, key, statusCode, contentType, body); err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets key so the request can be retried from scratch
+func (s *CompositeStore) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	if _, err := s.pipelineStore.db.DB().ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// CleanupExpiredIdempotencyKeys removes idempotency keys older than maxAge
+func (s *CompositeStore) CleanupExpiredIdempotencyKeys(ctx context.Context, maxAge time.Duration) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE created_at < NOW() - $1::float8 * INTERVAL '1 second'
+	`
+
+	result, err := s.pipelineStore.db.DB().ExecContext(ctx, query, maxAge.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to cleanup expired idempotency keys: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected > 0 {
+		log.Info().Int64("count", rowsAffected).Msg("Cleaned up expired idempotency keys")
+	}
+
+	return nil
+}
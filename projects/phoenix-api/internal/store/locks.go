@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// AdvisoryLock is a session-level Postgres advisory lock. It is held for as
+// long as its dedicated connection stays open, so a crashed holder releases
+// it automatically.
+type AdvisoryLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// TryAdvisoryLock attempts to take the advisory lock identified by key
+// without blocking. It returns nil and no error when another session holds it.
+func (s *CompositeStore) TryAdvisoryLock(ctx context.Context, key int64) (*AdvisoryLock, error) {
+	conn, err := s.pipelineStore.db.DB().Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lock connection: %w", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire advisory lock: %w", err)
+	}
+
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+
+	return &AdvisoryLock{conn: conn, key: key}, nil
+}
+
+// Check verifies the connection holding the lock is still alive. An error
+// means the lock may have been lost and should be re-acquired.
+func (l *AdvisoryLock) Check(ctx context.Context) error {
+	return l.conn.PingContext(ctx)
+}
+
+// Release gives up the lock and closes its connection
+func (l *AdvisoryLock) Release(ctx context.Context) error {
+	defer l.conn.Close()
+
+	if _, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		return fmt.Errorf("failed to release advisory lock: %w", err)
+	}
+	return nil
+}
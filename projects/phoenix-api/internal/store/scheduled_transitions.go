@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/phoenix/platform/pkg/database"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+// ScheduleTransition schedules a transition for an experiment to fire after
+// delay. An already pending transition of the same kind is rescheduled rather
+// than duplicated.
+func (s *CompositeStore) ScheduleTransition(ctx context.Context, experimentID, transition string, delay time.Duration) (*models.ScheduledTransition, error) {
+	query := `
+		INSERT INTO scheduled_transitions (experiment_id, transition, due_at)
+		VALUES ($1, $2, NOW() + $3::float8 * INTERVAL '1 second')
+		ON CONFLICT (experiment_id, transition) WHERE status = 'pending'
+		DO UPDATE SET due_at = EXCLUDED.due_at, attempts = 0, last_error = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id, due_at, status, created_at, updated_at
+	`
+
+	t := &models.ScheduledTransition{
+		ExperimentID: experimentID,
+		Transition:   transition,
+	}
+
+	err := s.pipelineStore.db.DB().QueryRowContext(ctx, query, experimentID, transition, delay.Seconds()).
+		Scan(&t.ID, &t.DueAt, &t.Status, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule transition: %w", err)
+	}
+
+	return t, nil
+}
+
+// GetDueTransitions returns pending transitions whose due time has passed,
+// oldest first
+func (s *CompositeStore) GetDueTransitions(ctx context.Context, limit int) ([]*models.ScheduledTransition, error) {
+	query := `
+		SELECT id, experiment_id, transition, due_at, status, attempts,
+		       last_error, executed_at, created_at, updated_at
+		FROM scheduled_transitions
+		WHERE status = 'pending' AND due_at <= NOW()
+		ORDER BY due_at ASC
+		LIMIT $1
+	`
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due transitions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanTransitions(rows)
+}
+
+// ListTransitions returns every transition scheduled for an experiment
+func (s *CompositeStore) ListTransitions(ctx context.Context, experimentID string) ([]*models.ScheduledTransition, error) {
+	query := `
+		SELECT id, experiment_id, transition, due_at, status, attempts,
+		       last_error, executed_at, created_at, updated_at
+		FROM scheduled_transitions
+		WHERE experiment_id = $1
+		ORDER BY due_at ASC
+	`
+
+	rows, err := s.pipelineStore.db.DB().QueryContext(ctx, query, experimentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transitions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanTransitions(rows)
+}
+
+// FinishTransition records the final outcome of a pending transition:
+// "completed", "skipped" or "failed"
+func (s *CompositeStore) FinishTransition(ctx context.Context, id, status, lastError string) error {
+	query := `
+		UPDATE scheduled_transitions SET
+			status = $2,
+			last_error = NULLIF($3, ''),
+			attempts = attempts + 1,
+			executed_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'pending'
+	`
+
+	if _, err := s.pipelineStore.db.DB().ExecContext(ctx, query, id, status, lastError); err != nil {
+		return fmt.Errorf("failed to finish transition: %w", err)
+	}
+	return nil
+}
+
+// RetryTransition records a failed attempt and pushes the transition back by
+// delay
+func (s *CompositeStore) RetryTransition(ctx context.Context, id, lastError string, delay time.Duration) error {
+	query := `
+		UPDATE scheduled_transitions SET
+			attempts = attempts + 1,
+			last_error = $2,
+			due_at = NOW() + $3::float8 * INTERVAL '1 second',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'pending'
+	`
+
+	if _, err := s.pipelineStore.db.DB().ExecContext(ctx, query, id, lastError, delay.Seconds()); err != nil {
+		return fmt.Errorf("failed to reschedule transition: %w", err)
+	}
+	return nil
+}
+
+// CancelTransitions cancels every pending transition of an experiment
+func (s *CompositeStore) CancelTransitions(ctx context.Context, experimentID string) error {
+	query := `
+		UPDATE scheduled_transitions SET
+			status = 'cancelled',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE experiment_id = $1 AND status = 'pending'
+	`
+
+	if _, err := s.pipelineStore.db.DB().ExecContext(ctx, query, experimentID); err != nil {
+		return fmt.Errorf("failed to cancel transitions: %w", err)
+	}
+	return nil
+}
+
+func scanTransitions(rows *sql.Rows) ([]*models.ScheduledTransition, error) {
+	var transitions []*models.ScheduledTransition
+	for rows.Next() {
+		var t models.ScheduledTransition
+		var lastError database.NullString
+		var executedAt database.NullTime
+
+		err := rows.Scan(
+			&t.ID, &t.ExperimentID, &t.Transition, &t.DueAt, &t.Status, &t.Attempts,
+			&lastError, &executedAt, &t.CreatedAt, &t.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transition: %w", err)
+		}
+
+		if lastError.Valid {
+			t.LastError = lastError.String
+		}
+		if executedAt.Valid {
+			t.ExecutedAt = &executedAt.Time
+		}
+
+		transitions = append(transitions, &t)
+	}
+
+	return transitions, rows.Err()
+}
@@ -31,19 +31,72 @@ type Store interface {
 	GetTask(ctx context.Context, taskID string) (*internalModels.Task, error)
 	ListTasks(ctx context.Context, filters map[string]interface{}) ([]*internalModels.Task, error)
 	UpdateTask(ctx context.Context, task *internalModels.Task) error
-	GetPendingTasksForHost(ctx context.Context, hostID string) ([]*internalModels.Task, error)
+	UpdateLeasedTask(ctx context.Context, task *internalModels.Task, leaseToken string, extendBy time.Duration) error
+	ClaimPendingTasksForHost(ctx context.Context, hostID string, leaseDuration time.Duration, limit int) ([]*internalModels.Task, error)
+	RenewTaskLeases(ctx context.Context, hostID string, leaseDuration time.Duration) error
 	GetTasksByExperiment(ctx context.Context, experimentID string) ([]*internalModels.Task, error)
 	GetTaskStats(ctx context.Context) (map[string]interface{}, error)
 	GetStaleTasks(ctx context.Context, threshold time.Duration) ([]*internalModels.Task, error)
 	DeleteOldTasks(ctx context.Context, before time.Time) error
+	MoveTaskToDeadLetter(ctx context.Context, taskID, reason string) error
+	CancelDependentTasks(ctx context.Context, taskID, reason string) ([]string, error)
+	GetTaskByIdempotencyKey(ctx context.Context, key string) (*internalModels.Task, error)
+	ReleaseTaskIdempotencyKeys(ctx context.Context, keys []string) error
+	CancelQueuedTask(ctx context.Context, taskID, reason string) (bool, error)
+	RequestTaskCancellation(ctx context.Context, taskID, reason string) (bool, error)
+	GetTaskCancellations(ctx context.Context, hostID string) ([]internalModels.TaskCancellation, error)
+	FailHostTasks(ctx context.Context, hostID, reason string) ([]*internalModels.Task, error)
 
 	// Agent operations
 	UpsertAgent(ctx context.Context, agent *internalModels.AgentStatus) error
 	GetAgent(ctx context.Context, hostID string) (*internalModels.AgentStatus, error)
 	ListAgents(ctx context.Context) ([]*internalModels.AgentStatus, error)
 	UpdateAgentHeartbeat(ctx context.Context, heartbeat *internalModels.AgentHeartbeat) error
+	SetAgentLabels(ctx context.Context, hostID string, labels map[string]string) error
+	SetAgentState(ctx context.Context, hostID, from, to string, heartbeatBefore time.Time) (bool, error)
+	CreateAgentEvent(ctx context.Context, event *internalModels.AgentEvent) error
+	ListAgentEvents(ctx context.Context, hostID string, limit int) ([]*internalModels.AgentEvent, error)
+	AppendAgentLogs(ctx context.Context, entries []*internalModels.AgentLogEntry, keep int) error
+	ListAgentLogs(ctx context.Context, query internalModels.AgentLogQuery) ([]*internalModels.AgentLogEntry, error)
+	CleanupExpiredAgentLogs(ctx context.Context, maxAge time.Duration) error
 	CacheMetric(ctx context.Context, hostID string, metric map[string]interface{}) error
 
+	// Active pipeline operations
+	UpsertActivePipeline(ctx context.Context, pipeline *internalModels.ActivePipeline) error
+	UpdateActivePipelineStatus(ctx context.Context, hostID, experimentID, variant, status string, processInfo map[string]interface{}) error
+	GetActivePipelines(ctx context.Context, experimentID string) ([]*internalModels.ActivePipeline, error)
+
+	// Scheduled transition operations
+	ScheduleTransition(ctx context.Context, experimentID, transition string, delay time.Duration) (*internalModels.ScheduledTransition, error)
+	GetDueTransitions(ctx context.Context, limit int) ([]*internalModels.ScheduledTransition, error)
+	ListTransitions(ctx context.Context, experimentID string) ([]*internalModels.ScheduledTransition, error)
+	FinishTransition(ctx context.Context, id, status, lastError string) error
+	RetryTransition(ctx context.Context, id, lastError string, delay time.Duration) error
+	CancelTransitions(ctx context.Context, experimentID string) error
+
+	// Experiment schedule operations
+	CreateExperimentSchedule(ctx context.Context, schedule *internalModels.ExperimentSchedule) error
+	GetExperimentSchedule(ctx context.Context, experimentID string) (*internalModels.ExperimentSchedule, error)
+	GetDueSchedules(ctx context.Context, limit int) ([]*internalModels.ExperimentSchedule, error)
+	ClaimScheduleRun(ctx context.Context, scheduleID string, dueAt time.Time, nextRunAt *time.Time) (int, bool, error)
+	DisableExperimentSchedule(ctx context.Context, experimentID string) error
+	CreateScheduleRun(ctx context.Context, run *internalModels.ExperimentScheduleRun) error
+	ListScheduleRuns(ctx context.Context, experimentID string) ([]*internalModels.ExperimentScheduleRun, error)
+
+	// Host lock operations
+	AcquireHostLocks(ctx context.Context, experimentID string, hosts []string) error
+	ReleaseHostLocks(ctx context.Context, experimentID string) error
+	GetHostLocks(ctx context.Context, hosts []string) (map[string]string, error)
+
+	// Idempotency key operations
+	ReserveIdempotencyKey(ctx context.Context, key, method, path string) (*internalModels.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, contentType string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	CleanupExpiredIdempotencyKeys(ctx context.Context, maxAge time.Duration) error
+
+	// Coordination between API replicas
+	TryAdvisoryLock(ctx context.Context, key int64) (*AdvisoryLock, error)
+
 	// Event operations
 	CreateExperimentEvent(ctx context.Context, event *internalModels.ExperimentEvent) error
 	ListExperimentEvents(ctx context.Context, experimentID string) ([]*internalModels.ExperimentEvent, error)
@@ -0,0 +1,36 @@
+package tasks
+
+import (
+	"context"
+	"strings"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+// IdempotencyKey identifies the work of an experiment task: one action of
+// one task type for a variant on a host. variant is empty for tasks that do
+// not belong to a single variant.
+func IdempotencyKey(experimentID, hostID, taskType, action, variant string) string {
+	return strings.Join([]string{experimentID, hostID, taskType, action, variant}, "/")
+}
+
+// taskIdempotencyKey returns the key a task is enqueued under. Tasks outside
+// an experiment are not deduplicated unless they carry their own key.
+func taskIdempotencyKey(task *models.Task) string {
+	if task.IdempotencyKey != "" || task.ExperimentID == "" {
+		return task.IdempotencyKey
+	}
+
+	variant, _ := task.Config["variant"].(string)
+	return IdempotencyKey(task.ExperimentID, task.HostID, task.Type, task.Action, variant)
+}
+
+// ReleaseIdempotencyKeys frees keys held by earlier tasks so the same work
+// can be enqueued again, for example starting a collector after it was
+// stopped
+func (q *Queue) ReleaseIdempotencyKeys(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return q.store.ReleaseTaskIdempotencyKeys(ctx, keys)
+}
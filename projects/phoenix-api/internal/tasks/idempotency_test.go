@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"testing"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+)
+
+func TestTaskIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name string
+		task *models.Task
+		want string
+	}{
+		{
+			name: "collector variant",
+			task: &models.Task{
+				ExperimentID: "exp-1", HostID: "host-a", Type: "collector", Action: "start",
+				Config: map[string]interface{}{"variant": "candidate"},
+			},
+			want: "exp-1/host-a/collector/start/candidate",
+		},
+		{
+			name: "task without variant",
+			task: &models.Task{
+				ExperimentID: "exp-1", HostID: "host-a", Type: "loadsim", Action: "stop",
+				Config: map[string]interface{}{},
+			},
+			want: "exp-1/host-a/loadsim/stop/",
+		},
+		{
+			name: "explicit key is kept",
+			task: &models.Task{
+				ExperimentID: "exp-1", HostID: "host-a", Type: "collector", Action: "start",
+				IdempotencyKey: "custom",
+			},
+			want: "custom",
+		},
+		{
+			name: "task outside an experiment",
+			task: &models.Task{HostID: "host-a", Type: "loadsim", Action: "start"},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := taskIdempotencyKey(tt.task); got != tt.want {
+				t.Errorf("taskIdempotencyKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
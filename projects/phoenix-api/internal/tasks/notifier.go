@@ -0,0 +1,140 @@
+package tasks
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// TaskReadyChannel is the Postgres NOTIFY channel the tasks table trigger
+// publishes on whenever a task becomes pending. The payload is the host ID.
+const TaskReadyChannel = "phoenix_task_ready"
+
+// Notifier wakes parked long-polls when a task for their host arrives.
+// Enqueue on this replica notifies directly; tasks enqueued by other API
+// replicas arrive through Postgres LISTEN/NOTIFY once Listen is running.
+type Notifier struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+	listening   atomic.Bool
+}
+
+// NewNotifier creates an in-process notifier with no Postgres listener attached
+func NewNotifier() *Notifier {
+	return &Notifier{
+		subscribers: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe registers interest in tasks for hostID. The returned channel
+// receives at most one pending wakeup at a time; call the returned function
+// to unsubscribe.
+func (n *Notifier) Subscribe(hostID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	n.mu.Lock()
+	if n.subscribers[hostID] == nil {
+		n.subscribers[hostID] = make(map[chan struct{}]struct{})
+	}
+	n.subscribers[hostID][ch] = struct{}{}
+	n.mu.Unlock()
+
+	return ch, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		delete(n.subscribers[hostID], ch)
+		if len(n.subscribers[hostID]) == 0 {
+			delete(n.subscribers, hostID)
+		}
+	}
+}
+
+// Notify wakes every long-poll waiting on hostID
+func (n *Notifier) Notify(hostID string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for ch := range n.subscribers[hostID] {
+		wake(ch)
+	}
+}
+
+// NotifyAll wakes every parked long-poll. It is used after the Postgres
+// listener reconnects, since notifications sent while disconnected are lost.
+func (n *Notifier) NotifyAll() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, subs := range n.subscribers {
+		for ch := range subs {
+			wake(ch)
+		}
+	}
+}
+
+// Listening reports whether cross-replica notifications are currently being
+// received. Long-polls fall back to frequent store polling when it is false.
+func (n *Notifier) Listening() bool {
+	return n.listening.Load()
+}
+
+// Listen forwards Postgres notifications on TaskReadyChannel to local
+// subscribers until ctx is cancelled. The listener reconnects on its own;
+// while it is down Listening reports false.
+func (n *Notifier) Listen(ctx context.Context, databaseURL string) {
+	listener := pq.NewListener(databaseURL, 1*time.Second, 30*time.Second, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventConnected:
+			n.listening.Store(true)
+			log.Info().Str("channel", TaskReadyChannel).Msg("Task notification listener connected")
+		case pq.ListenerEventReconnected:
+			n.listening.Store(true)
+			log.Info().Str("channel", TaskReadyChannel).Msg("Task notification listener reconnected")
+		case pq.ListenerEventDisconnected:
+			n.listening.Store(false)
+			log.Warn().Err(err).Msg("Task notification listener disconnected, falling back to polling")
+		case pq.ListenerEventConnectionAttemptFailed:
+			n.listening.Store(false)
+			log.Warn().Err(err).Msg("Task notification listener connection attempt failed")
+		}
+	})
+	defer listener.Close()
+	defer n.listening.Store(false)
+
+	if err := listener.Listen(TaskReadyChannel); err != nil {
+		log.Error().Err(err).Str("channel", TaskReadyChannel).Msg("Failed to listen for task notifications, using polling")
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case notification := <-listener.Notify:
+			// A nil notification follows a reconnect; anything could have been missed
+			if notification == nil {
+				n.NotifyAll()
+				continue
+			}
+			n.Notify(notification.Extra)
+
+		case <-time.After(90 * time.Second):
+			// Detect silently dropped connections
+			go listener.Ping()
+		}
+	}
+}
+
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+		// A wakeup is already pending
+	}
+}
@@ -0,0 +1,75 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotifier_WakesOnlyMatchingHost(t *testing.T) {
+	n := NewNotifier()
+
+	hostA, unsubscribeA := n.Subscribe("host-a")
+	defer unsubscribeA()
+	hostB, unsubscribeB := n.Subscribe("host-b")
+	defer unsubscribeB()
+
+	n.Notify("host-a")
+
+	select {
+	case <-hostA:
+	case <-time.After(time.Second):
+		t.Fatal("expected host-a subscriber to be woken")
+	}
+
+	select {
+	case <-hostB:
+		t.Fatal("host-b subscriber should not be woken")
+	default:
+	}
+}
+
+func TestNotifier_CoalescesWakeups(t *testing.T) {
+	n := NewNotifier()
+
+	wakeup, unsubscribe := n.Subscribe("host-a")
+	defer unsubscribe()
+
+	// Repeated notifications must never block the notifier
+	for i := 0; i < 5; i++ {
+		n.Notify("host-a")
+	}
+
+	<-wakeup
+	select {
+	case <-wakeup:
+		t.Fatal("expected wakeups to be coalesced into one")
+	default:
+	}
+}
+
+func TestNotifier_NotifyAllAndUnsubscribe(t *testing.T) {
+	n := NewNotifier()
+
+	hostA, unsubscribeA := n.Subscribe("host-a")
+	hostB, unsubscribeB := n.Subscribe("host-b")
+	defer unsubscribeB()
+
+	unsubscribeA()
+	n.NotifyAll()
+
+	select {
+	case <-hostA:
+		t.Fatal("unsubscribed channel should not be woken")
+	default:
+	}
+
+	select {
+	case <-hostB:
+	case <-time.After(time.Second):
+		t.Fatal("expected NotifyAll to wake host-b")
+	}
+
+	if n.Listening() {
+		t.Fatal("notifier without a Postgres listener should not report listening")
+	}
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// recordPipelineState keeps active_pipelines in step with collector task
+// results so experiment phases can be derived from what actually runs on
+// each host. Failed attempts that will be retried leave the record alone.
+func (q *Queue) recordPipelineState(ctx context.Context, task *models.Task) {
+	if task.Type != "collector" || task.ExperimentID == "" {
+		return
+	}
+
+	variant, _ := task.Config["variant"].(string)
+	if variant == "" {
+		return
+	}
+
+	var err error
+	switch {
+	case (task.Action == "start" || task.Action == "update") && task.Status == "completed":
+		configURL, _ := task.Config["configUrl"].(string)
+		configHash, _ := task.Result["config_hash"].(string)
+
+		err = q.store.UpsertActivePipeline(ctx, &models.ActivePipeline{
+			HostID:       task.HostID,
+			ExperimentID: task.ExperimentID,
+			Variant:      variant,
+			ConfigURL:    configURL,
+			ConfigHash:   configHash,
+			ProcessInfo: map[string]interface{}{
+				"pid":     task.Result["pid"],
+				"task_id": task.ID,
+			},
+			MetricsInfo: map[string]interface{}{},
+			Status:      "running",
+			StartedAt:   time.Now(),
+		})
+
+	case (task.Action == "start" || task.Action == "update") && task.Status == "cancelled":
+		// The agent tears down a collector whose start it aborted
+		err = q.store.UpdateActivePipelineStatus(ctx, task.HostID, task.ExperimentID, variant, "stopped", nil)
+
+	case task.Action == "start" && task.Status == "dead_letter":
+		err = q.store.UpdateActivePipelineStatus(ctx, task.HostID, task.ExperimentID, variant, "failed",
+			map[string]interface{}{"error": task.ErrorMessage})
+
+	case task.Action == "stop" && task.Status == "completed":
+		err = q.store.UpdateActivePipelineStatus(ctx, task.HostID, task.ExperimentID, variant, "stopped", nil)
+
+	case task.Action == "stop" && task.Status == "dead_letter":
+		// A collector the agent does not know about is already stopped
+		if strings.Contains(task.ErrorMessage, "not found") {
+			err = q.store.UpdateActivePipelineStatus(ctx, task.HostID, task.ExperimentID, variant, "stopped", nil)
+		} else {
+			err = q.store.UpdateActivePipelineStatus(ctx, task.HostID, task.ExperimentID, variant, "failed",
+				map[string]interface{}{"stop_error": task.ErrorMessage})
+		}
+
+	default:
+		return
+	}
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("task_id", task.ID).
+			Str("experiment_id", task.ExperimentID).
+			Str("variant", variant).
+			Msg("Failed to record pipeline state")
+	}
+}
@@ -2,6 +2,7 @@ package tasks
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,26 +11,95 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+var (
+	// ErrNotDeadLettered is returned when a dead-letter operation targets a
+	// task that is not in the dead-letter queue
+	ErrNotDeadLettered = errors.New("task is not dead-lettered")
+	// ErrTaskFinished is returned when cancelling a task that already
+	// reached a final status
+	ErrTaskFinished = errors.New("task already finished")
+)
+
+const (
+	// maxTasksPerClaim bounds how many tasks a single agent poll can lease
+	maxTasksPerClaim = 10
+
+	// fallbackPollInterval is how often a parked long-poll re-checks the store
+	// when cross-replica notifications are unavailable
+	fallbackPollInterval = 1 * time.Second
+
+	// staleLeaseGrace is how long after its lease lapsed an unfinished
+	// task is failed as stale
+	staleLeaseGrace = 5 * time.Minute
+
+	// safetyPollInterval is how often a parked long-poll re-checks the store
+	// while notifications are flowing. It picks up expired leases, which
+	// become claimable without any notification.
+	safetyPollInterval = 15 * time.Second
+)
+
 type Queue struct {
-	store store.Store
+	store         store.Store
+	notifier      *Notifier
+	retryPolicies *RetryPolicies
+	leaseDuration time.Duration
+	// signer signs the config digests of claimed tasks; nil leaves them
+	// unsigned
+	signer *ConfigSigner
 }
 
-func NewQueue(store store.Store) *Queue {
+// NewQueue creates a task queue. leaseDuration is how long an agent may hold a
+// claimed task before it has to report progress; once it lapses the task can
+// be claimed again and updates carrying the old lease are rejected.
+func NewQueue(store store.Store, leaseDuration time.Duration) *Queue {
 	return &Queue{
-		store: store,
+		store:         store,
+		notifier:      NewNotifier(),
+		retryPolicies: DefaultRetryPolicies(),
+		leaseDuration: leaseDuration,
 	}
 }
 
-// Enqueue adds a new task to the queue
+// Listen subscribes to task notifications published through Postgres so
+// long-polls wake for tasks enqueued on other API replicas. It blocks until
+// ctx is cancelled.
+func (q *Queue) Listen(ctx context.Context, databaseURL string) {
+	q.notifier.Listen(ctx, databaseURL)
+}
+
+// Enqueue adds a new task to the queue. When a live or completed task already
+// holds the task's idempotency key, nothing is enqueued and task is filled in
+// with the existing task instead.
 func (q *Queue) Enqueue(ctx context.Context, task *models.Task) error {
 	task.Status = "pending"
 	task.CreatedAt = time.Now()
 	task.UpdatedAt = time.Now()
+	task.IdempotencyKey = taskIdempotencyKey(task)
+
+	err := q.store.CreateTask(ctx, task)
+	if errors.Is(err, store.ErrDuplicateTask) {
+		// The same work is already queued or done; hand back that task
+		existing, err := q.store.GetTaskByIdempotencyKey(ctx, task.IdempotencyKey)
+		if err != nil {
+			return fmt.Errorf("failed to get existing task: %w", err)
+		}
+		*task = *existing
 
-	if err := q.store.CreateTask(ctx, task); err != nil {
+		log.Info().
+			Str("task_id", task.ID).
+			Str("idempotency_key", task.IdempotencyKey).
+			Str("status", task.Status).
+			Msg("Task already enqueued")
+		return nil
+	}
+	if err != nil {
 		return fmt.Errorf("failed to create task: %w", err)
 	}
 
+	// Wake long-polls on this replica right away; other replicas hear about
+	// the task through the tasks table NOTIFY trigger
+	q.notifier.Notify(task.HostID)
+
 	log.Info().
 		Str("task_id", task.ID).
 		Str("host_id", task.HostID).
@@ -40,39 +110,56 @@ func (q *Queue) Enqueue(ctx context.Context, task *models.Task) error {
 	return nil
 }
 
-// GetPendingTasks retrieves pending tasks for a specific host with long polling
-func (q *Queue) GetPendingTasks(ctx context.Context, hostID string) ([]*models.Task, error) {
-	// Try to get tasks immediately
-	tasks, err := q.store.GetPendingTasksForHost(ctx, hostID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get pending tasks: %w", err)
-	}
-
-	// If we have tasks, return them immediately
-	if len(tasks) > 0 {
-		return tasks, nil
-	}
+// SetConfigSigner makes claimed tasks carry a signature of their config
+// digest
+func (q *Queue) SetConfigSigner(signer *ConfigSigner) {
+	q.signer = signer
+}
 
-	// Otherwise, implement long polling
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+// ClaimTasks leases pending tasks for a specific host with long polling.
+// Returned tasks are already marked assigned and carry the lease token the
+// agent must present when reporting status. A parked poll wakes as soon as a
+// task for its host is enqueued and otherwise re-checks the store only as a
+// safety net, or every second when notifications are unavailable.
+func (q *Queue) ClaimTasks(ctx context.Context, hostID string) ([]*models.Task, error) {
+	// Subscribe before the first claim so a task enqueued in between still wakes us
+	wakeup, unsubscribe := q.notifier.Subscribe(hostID)
+	defer unsubscribe()
 
 	for {
+		tasks, err := q.store.ClaimPendingTasksForHost(ctx, hostID, q.leaseDuration, maxTasksPerClaim)
+		if err != nil {
+			if ctx.Err() != nil {
+				// Context cancelled (timeout or client disconnect)
+				return []*models.Task{}, nil
+			}
+			return nil, fmt.Errorf("failed to claim tasks: %w", err)
+		}
+
+		if len(tasks) > 0 {
+			// Signed on the way out so a rotated key covers queued tasks
+			for _, task := range tasks {
+				q.signer.Sign(task.Config)
+			}
+			return tasks, nil
+		}
+
+		interval := fallbackPollInterval
+		if q.notifier.Listening() {
+			interval = safetyPollInterval
+		}
+
+		timer := time.NewTimer(interval)
 		select {
 		case <-ctx.Done():
 			// Context cancelled (timeout or client disconnect)
+			timer.Stop()
 			return []*models.Task{}, nil
 
-		case <-ticker.C:
-			// Check for new tasks
-			tasks, err := q.store.GetPendingTasksForHost(ctx, hostID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get pending tasks: %w", err)
-			}
+		case <-wakeup:
+			timer.Stop()
 
-			if len(tasks) > 0 {
-				return tasks, nil
-			}
+		case <-timer.C:
 		}
 	}
 }
@@ -117,13 +204,64 @@ func (q *Queue) UpdateTaskStatus(ctx context.Context, taskID string, status stri
 	return nil
 }
 
-// UpdateTaskStatusWithResult updates task status with result data
+// UpdateTaskStatusWithResult updates task status with result data. It is used
+// for server-side transitions and does not check the task's lease.
 func (q *Queue) UpdateTaskStatusWithResult(ctx context.Context, taskID string, status string, result map[string]interface{}, errorMessage string) error {
 	task, err := q.store.GetTask(ctx, taskID)
 	if err != nil {
 		return fmt.Errorf("failed to get task: %w", err)
 	}
 
+	applyStatus(task, status, result, errorMessage)
+
+	if err := q.store.UpdateTask(ctx, task); err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	q.afterStatusUpdate(ctx, task)
+
+	return nil
+}
+
+// ReportTaskStatus records a status update sent by the agent holding the
+// task's lease. Updates with a stale or foreign lease token fail with
+// store.ErrTaskLeaseExpired or store.ErrTaskLeaseMismatch, and reports on a
+// task that already finished fail with store.ErrTaskFinished. Every report that
+// leaves the task unfinished renews the lease so long-running tasks keep
+// their claim.
+func (q *Queue) ReportTaskStatus(ctx context.Context, taskID, leaseToken, status string, result map[string]interface{}, errorMessage string) error {
+	task, err := q.store.GetTask(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+
+	applyStatus(task, status, result, errorMessage)
+
+	var extendBy time.Duration
+	switch status {
+	case "completed", "failed", "cancelled":
+	default:
+		extendBy = q.leaseDuration
+	}
+
+	if err := q.store.UpdateLeasedTask(ctx, task, leaseToken, extendBy); err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	q.afterStatusUpdate(ctx, task)
+
+	return nil
+}
+
+// RenewLeases extends the leases a host holds on its unfinished tasks. The
+// agent heartbeat calls it so a task outlives its first lease for as long as
+// the agent running it stays alive.
+func (q *Queue) RenewLeases(ctx context.Context, hostID string) error {
+	return q.store.RenewTaskLeases(ctx, hostID, q.leaseDuration)
+}
+
+// applyStatus sets the status fields and lifecycle timestamps on a task
+func applyStatus(task *models.Task, status string, result map[string]interface{}, errorMessage string) {
 	task.Status = status
 	task.Result = result
 	task.ErrorMessage = errorMessage
@@ -132,45 +270,206 @@ func (q *Queue) UpdateTaskStatusWithResult(ctx context.Context, taskID string, s
 	switch status {
 	case "running":
 		task.StartedAt = &task.UpdatedAt
-	case "completed", "failed":
+	case "completed", "failed", "cancelled":
 		task.CompletedAt = &task.UpdatedAt
 	}
+}
 
-	if err := q.store.UpdateTask(ctx, task); err != nil {
-		return fmt.Errorf("failed to update task: %w", err)
+// afterStatusUpdate schedules a retry for failed tasks, or dead-letters them
+// once their retry policy gives up, cancels the dependents of tasks that will
+// never complete, records collector state and logs the update
+func (q *Queue) afterStatusUpdate(ctx context.Context, task *models.Task) {
+	if task.Status == "failed" {
+		q.retryOrDeadLetter(ctx, task)
 	}
 
-	// If task failed and has retries left, create a new task
-	if status == "failed" && task.RetryCount < 3 {
-		retryTask := &models.Task{
-			HostID:       task.HostID,
-			ExperimentID: task.ExperimentID,
-			Type:         task.Type,
-			Action:       task.Action,
-			Config:       task.Config,
-			Priority:     task.Priority - 1, // Slightly lower priority
-			RetryCount:   task.RetryCount + 1,
+	if task.Status == "dead_letter" || task.Status == "cancelled" {
+		q.cancelDependents(ctx, task)
+	}
+
+	q.recordPipelineState(ctx, task)
+
+	log.Info().
+		Str("task_id", task.ID).
+		Str("status", task.Status).
+		Bool("has_error", task.ErrorMessage != "").
+		Msg("Task completed with result")
+}
+
+// retryOrDeadLetter enqueues the next attempt of a failed task after its
+// backoff delay, or moves it to the dead-letter queue when the retry policy
+// for its type and action is exhausted or the error is not retryable
+func (q *Queue) retryOrDeadLetter(ctx context.Context, task *models.Task) {
+	policy := q.retryPolicies.For(task.Type, task.Action)
+
+	if !policy.ShouldRetry(task.RetryCount, task.ErrorMessage) {
+		reason := fmt.Sprintf("non-retryable error: %s", task.ErrorMessage)
+		if task.RetryCount+1 >= policy.MaxAttempts {
+			reason = fmt.Sprintf("retry policy exhausted after %d attempts: %s", task.RetryCount+1, task.ErrorMessage)
 		}
 
-		if err := q.Enqueue(ctx, retryTask); err != nil {
-			log.Error().Err(err).Str("task_id", taskID).Msg("Failed to enqueue retry task")
-		} else {
-			log.Info().
-				Str("original_task_id", taskID).
-				Int("retry_count", retryTask.RetryCount).
-				Msg("Retry task enqueued")
+		if err := q.store.MoveTaskToDeadLetter(ctx, task.ID, reason); err != nil {
+			log.Error().Err(err).Str("task_id", task.ID).Msg("Failed to dead-letter task")
+			return
 		}
+
+		task.Status = "dead_letter"
+		task.DeadLetterReason = reason
+
+		log.Warn().
+			Str("task_id", task.ID).
+			Str("host_id", task.HostID).
+			Str("type", task.Type).
+			Str("action", task.Action).
+			Str("reason", reason).
+			Msg("Task moved to dead-letter queue")
+		return
+	}
+
+	originalTaskID := task.OriginalTaskID
+	if originalTaskID == "" {
+		originalTaskID = task.ID
+	}
+
+	delay := policy.Delay(task.RetryCount)
+	notBefore := time.Now().Add(delay)
+
+	retryTask := &models.Task{
+		HostID:         task.HostID,
+		ExperimentID:   task.ExperimentID,
+		Type:           task.Type,
+		Action:         task.Action,
+		Config:         task.Config,
+		Priority:       task.Priority,
+		RetryCount:     task.RetryCount + 1,
+		NotBefore:      &notBefore,
+		OriginalTaskID: originalTaskID,
+		DependsOn:      task.DependsOn,
+		IdempotencyKey: task.IdempotencyKey,
+	}
+
+	if err := q.Enqueue(ctx, retryTask); err != nil {
+		log.Error().Err(err).Str("task_id", task.ID).Msg("Failed to enqueue retry task")
+		return
 	}
 
 	log.Info().
-		Str("task_id", taskID).
-		Str("status", status).
-		Bool("has_error", errorMessage != "").
-		Msg("Task completed with result")
+		Str("original_task_id", originalTaskID).
+		Str("failed_task_id", task.ID).
+		Int("retry_count", retryTask.RetryCount).
+		Dur("delay", delay).
+		Msg("Retry task enqueued")
+}
+
+// cancelDependents cancels pending tasks that depend on a task which will
+// never complete. Dependents reference the first attempt, so the lookup uses
+// the original task ID for retries.
+func (q *Queue) cancelDependents(ctx context.Context, task *models.Task) {
+	parentID := task.OriginalTaskID
+	if parentID == "" {
+		parentID = task.ID
+	}
+
+	reason := fmt.Sprintf("dependency %s %s", parentID, task.Status)
+	cancelled, err := q.store.CancelDependentTasks(ctx, parentID, reason)
+	if err != nil {
+		log.Error().Err(err).Str("task_id", task.ID).Msg("Failed to cancel dependent tasks")
+		return
+	}
+
+	if len(cancelled) > 0 {
+		log.Warn().
+			Str("task_id", task.ID).
+			Strs("cancelled_task_ids", cancelled).
+			Msg("Cancelled tasks depending on unfinished task")
+	}
+}
+
+// ListDeadLetterTasks returns dead-lettered tasks matching the filters
+func (q *Queue) ListDeadLetterTasks(ctx context.Context, filters map[string]interface{}) ([]*models.Task, error) {
+	filters["status"] = "dead_letter"
+
+	tasks, err := q.store.ListTasks(ctx, filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead-letter tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// RequeueDeadLetterTask enqueues a fresh copy of a dead-lettered task with
+// its retry budget reset and marks the original as requeued
+func (q *Queue) RequeueDeadLetterTask(ctx context.Context, taskID string) (*models.Task, error) {
+	task, err := q.getDeadLetterTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	originalTaskID := task.OriginalTaskID
+	if originalTaskID == "" {
+		originalTaskID = task.ID
+	}
+
+	newTask := &models.Task{
+		HostID:         task.HostID,
+		ExperimentID:   task.ExperimentID,
+		Type:           task.Type,
+		Action:         task.Action,
+		Config:         task.Config,
+		Priority:       task.Priority,
+		OriginalTaskID: originalTaskID,
+		DependsOn:      task.DependsOn,
+		IdempotencyKey: task.IdempotencyKey,
+	}
+
+	if err := q.Enqueue(ctx, newTask); err != nil {
+		return nil, err
+	}
+
+	task.Status = "requeued"
+	task.UpdatedAt = time.Now()
+	if err := q.store.UpdateTask(ctx, task); err != nil {
+		return nil, fmt.Errorf("failed to mark task requeued: %w", err)
+	}
+
+	log.Info().
+		Str("task_id", task.ID).
+		Str("new_task_id", newTask.ID).
+		Msg("Dead-letter task requeued")
+
+	return newTask, nil
+}
+
+// DiscardDeadLetterTask drops a dead-lettered task without retrying it
+func (q *Queue) DiscardDeadLetterTask(ctx context.Context, taskID string) error {
+	task, err := q.getDeadLetterTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+
+	task.Status = "discarded"
+	task.UpdatedAt = time.Now()
+	if err := q.store.UpdateTask(ctx, task); err != nil {
+		return fmt.Errorf("failed to discard task: %w", err)
+	}
+
+	log.Info().Str("task_id", task.ID).Msg("Dead-letter task discarded")
 
 	return nil
 }
 
+func (q *Queue) getDeadLetterTask(ctx context.Context, taskID string) (*models.Task, error) {
+	task, err := q.store.GetTask(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	if task.Status != "dead_letter" {
+		return nil, fmt.Errorf("%w: task %s is %s", ErrNotDeadLettered, taskID, task.Status)
+	}
+
+	return task, nil
+}
+
 // GetTasksForExperiment retrieves all tasks for a specific experiment
 func (q *Queue) GetTasksForExperiment(ctx context.Context, experimentID string) ([]*models.Task, error) {
 	tasks, err := q.store.GetTasksByExperiment(ctx, experimentID)
@@ -180,24 +479,74 @@ func (q *Queue) GetTasksForExperiment(ctx context.Context, experimentID string)
 	return tasks, nil
 }
 
-// CancelTasksForExperiment cancels all pending tasks for an experiment
-func (q *Queue) CancelTasksForExperiment(ctx context.Context, experimentID string) error {
+// CancelTasksForExperiment cancels every unfinished task of an experiment,
+// including tasks agents are already running
+func (q *Queue) CancelTasksForExperiment(ctx context.Context, experimentID, reason string) error {
 	tasks, err := q.GetTasksForExperiment(ctx, experimentID)
 	if err != nil {
 		return err
 	}
 
 	for _, task := range tasks {
-		if task.Status == "pending" || task.Status == "assigned" {
-			if err := q.UpdateTaskStatus(ctx, task.ID, "cancelled"); err != nil {
-				log.Error().Err(err).Str("task_id", task.ID).Msg("Failed to cancel task")
-			}
+		switch task.Status {
+		case "pending", "assigned", "running":
+		default:
+			continue
+		}
+
+		if _, err := q.CancelTask(ctx, task.ID, reason); err != nil && !errors.Is(err, ErrTaskFinished) {
+			log.Error().Err(err).Str("task_id", task.ID).Msg("Failed to cancel task")
 		}
 	}
 
 	return nil
 }
 
+// CancelTask cancels a task and returns it as it stands afterwards. A queued
+// task is cancelled at once. A task an agent is already running is only
+// flagged; the agent picks the request up from its heartbeat, aborts the
+// task and reports it as cancelled.
+func (q *Queue) CancelTask(ctx context.Context, taskID, reason string) (*models.Task, error) {
+	cancelled, err := q.store.CancelQueuedTask(ctx, taskID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	requested := false
+	if !cancelled {
+		requested, err = q.store.RequestTaskCancellation(ctx, taskID, reason)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	task, err := q.store.GetTask(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	switch {
+	case cancelled:
+		q.afterStatusUpdate(ctx, task)
+	case requested:
+		log.Info().
+			Str("task_id", task.ID).
+			Str("host_id", task.HostID).
+			Str("reason", reason).
+			Msg("Cancellation requested for running task")
+	default:
+		return task, fmt.Errorf("%w: task %s is %s", ErrTaskFinished, taskID, task.Status)
+	}
+
+	return task, nil
+}
+
+// GetTaskCancellations returns the cancellation requests pending for the
+// tasks a host is running
+func (q *Queue) GetTaskCancellations(ctx context.Context, hostID string) ([]models.TaskCancellation, error) {
+	return q.store.GetTaskCancellations(ctx, hostID)
+}
+
 // GetTaskStats returns statistics about tasks in the queue
 func (q *Queue) GetTaskStats(ctx context.Context) (map[string]interface{}, error) {
 	stats, err := q.store.GetTaskStats(ctx)
@@ -234,15 +583,62 @@ func (q *Queue) Run(ctx context.Context) {
 	}
 }
 
-// processStaleTask marks assigned tasks that haven't been updated as failed
+// FailHostTasks fails the unfinished tasks of a host whose agent went
+// offline. They are dead-lettered rather than retried, since a retry would
+// only be queued for the same host; once the agent is back they can be
+// requeued from the dead-letter queue. It returns the failed tasks.
+func (q *Queue) FailHostTasks(ctx context.Context, hostID, reason string) ([]*models.Task, error) {
+	failed, err := q.store.FailHostTasks(ctx, hostID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, task := range failed {
+		if err := q.store.MoveTaskToDeadLetter(ctx, task.ID, reason); err != nil {
+			log.Error().Err(err).Str("task_id", task.ID).Msg("Failed to dead-letter task")
+		} else {
+			task.Status = "dead_letter"
+			task.DeadLetterReason = reason
+		}
+
+		q.cancelDependents(ctx, task)
+		q.recordPipelineState(ctx, task)
+	}
+
+	if len(failed) > 0 {
+		log.Warn().
+			Str("host_id", hostID).
+			Int("tasks", len(failed)).
+			Str("reason", reason).
+			Msg("Failed tasks of offline host")
+	}
+
+	return failed, nil
+}
+
+// processStaleTask fails assigned and running tasks whose lease lapsed
+// without the agent renewing it.
+// Tasks of unreachable and offline agents are left to the agent liveness
+// monitor, as retrying them would only queue them for the same host.
 func (q *Queue) processStaleTask(ctx context.Context) error {
-	// Get tasks that have been assigned for more than 5 minutes without update
-	staleTasks, err := q.store.GetStaleTasks(ctx, 5*time.Minute)
+	// Leave a lapsed lease a while to be re-claimed or renewed first
+	staleTasks, err := q.store.GetStaleTasks(ctx, staleLeaseGrace)
 	if err != nil {
 		return fmt.Errorf("failed to get stale tasks: %w", err)
 	}
 
+	lost := make(map[string]bool)
 	for _, task := range staleTasks {
+		gone, checked := lost[task.HostID]
+		if !checked {
+			agent, err := q.store.GetAgent(ctx, task.HostID)
+			gone = err == nil && (agent.Status == models.AgentUnreachable || agent.Status == models.AgentOffline)
+			lost[task.HostID] = gone
+		}
+		if gone {
+			continue
+		}
+
 		log.Warn().
 			Str("task_id", task.ID).
 			Str("host_id", task.HostID).
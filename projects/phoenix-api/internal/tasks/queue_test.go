@@ -0,0 +1,314 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-api/internal/models"
+	"github.com/phoenix/platform/projects/phoenix-api/internal/store"
+)
+
+// leaseStore keeps tasks in memory and applies the same lease conditions as
+// the Postgres store. Methods the tests do not need panic through the nil
+// embedded store.
+type leaseStore struct {
+	store.Store
+
+	mu    sync.Mutex
+	tasks map[string]*models.Task
+	seq   int
+}
+
+func newLeaseStore() *leaseStore {
+	return &leaseStore{tasks: make(map[string]*models.Task)}
+}
+
+func isLive(status string) bool {
+	return status == "pending" || status == "assigned" || status == "running"
+}
+
+func (s *leaseStore) CreateTask(ctx context.Context, task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.tasks {
+		if task.IdempotencyKey != "" && existing.IdempotencyKey == task.IdempotencyKey && isLive(existing.Status) {
+			return fmt.Errorf("%w: %s", store.ErrDuplicateTask, task.IdempotencyKey)
+		}
+	}
+
+	s.seq++
+	task.ID = fmt.Sprintf("task-%d", s.seq)
+	stored := *task
+	s.tasks[task.ID] = &stored
+	return nil
+}
+
+func (s *leaseStore) GetTask(ctx context.Context, taskID string) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", store.ErrTaskNotFound, taskID)
+	}
+	copied := *task
+	return &copied, nil
+}
+
+func (s *leaseStore) UpdateTask(ctx context.Context, task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *task
+	s.tasks[task.ID] = &stored
+	return nil
+}
+
+func (s *leaseStore) UpdateLeasedTask(ctx context.Context, task *models.Task, leaseToken string, extendBy time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.tasks[task.ID]
+	switch {
+	case current.Status == "cancelled":
+		return store.ErrTaskCancelled
+	case current.Status != "assigned" && current.Status != "running":
+		return store.ErrTaskFinished
+	case current.LeaseToken != leaseToken:
+		return store.ErrTaskLeaseMismatch
+	case current.LeaseExpiresAt == nil || current.LeaseExpiresAt.Before(time.Now()):
+		return store.ErrTaskLeaseExpired
+	}
+
+	stored := *task
+	stored.LeaseToken = current.LeaseToken
+	stored.LeaseExpiresAt = current.LeaseExpiresAt
+	if extendBy > 0 {
+		expires := time.Now().Add(extendBy)
+		stored.LeaseExpiresAt = &expires
+	}
+	s.tasks[task.ID] = &stored
+	return nil
+}
+
+func (s *leaseStore) RenewTaskLeases(ctx context.Context, hostID string, leaseDuration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, task := range s.tasks {
+		if task.HostID != hostID || (task.Status != "assigned" && task.Status != "running") {
+			continue
+		}
+		if task.LeaseToken == "" || task.LeaseExpiresAt == nil || task.LeaseExpiresAt.Before(now) {
+			continue
+		}
+		expires := now.Add(leaseDuration)
+		task.LeaseExpiresAt = &expires
+	}
+	return nil
+}
+
+func (s *leaseStore) GetStaleTasks(ctx context.Context, threshold time.Duration) ([]*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := time.Now().Add(-threshold)
+	var stale []*models.Task
+	for _, task := range s.tasks {
+		if task.Status != "assigned" && task.Status != "running" {
+			continue
+		}
+		if task.LeaseExpiresAt != nil && task.LeaseExpiresAt.Before(cutoff) {
+			copied := *task
+			stale = append(stale, &copied)
+		}
+	}
+	return stale, nil
+}
+
+func (s *leaseStore) GetAgent(ctx context.Context, hostID string) (*models.AgentStatus, error) {
+	return &models.AgentStatus{HostID: hostID, Status: models.AgentHealthy}, nil
+}
+
+func (s *leaseStore) GetTaskByIdempotencyKey(ctx context.Context, key string) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, task := range s.tasks {
+		if task.IdempotencyKey == key && isLive(task.Status) {
+			copied := *task
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: idempotency key %s", store.ErrTaskNotFound, key)
+}
+
+func (s *leaseStore) MoveTaskToDeadLetter(ctx context.Context, taskID, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tasks[taskID].Status = "dead_letter"
+	s.tasks[taskID].DeadLetterReason = reason
+	return nil
+}
+
+func (s *leaseStore) CancelDependentTasks(ctx context.Context, taskID, reason string) ([]string, error) {
+	return nil, nil
+}
+
+// lease puts a running loadsim start for experimentID on host-a under a
+// lease that expires at expiresAt
+func (s *leaseStore) lease(t *testing.T, experimentID string, expiresAt time.Time) *models.Task {
+	t.Helper()
+
+	task := &models.Task{
+		HostID:         "host-a",
+		ExperimentID:   experimentID,
+		Type:           "loadsim",
+		Action:         "start",
+		Status:         "running",
+		LeaseToken:     "token-1",
+		LeaseExpiresAt: &expiresAt,
+		IdempotencyKey: experimentID + "/host-a/loadsim/start/",
+	}
+	if err := s.CreateTask(context.Background(), task); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	return task
+}
+
+func (s *leaseStore) status(taskID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tasks[taskID].Status
+}
+
+func TestQueue_StaleSweepSkipsRenewedLeases(t *testing.T) {
+	ctx := context.Background()
+	st := newLeaseStore()
+	queue := NewQueue(st, 5*time.Minute)
+
+	// Claimed long ago, but the agent keeps its lease alive
+	renewed := st.lease(t, "exp-1", time.Now().Add(time.Minute))
+	st.tasks[renewed.ID].AssignedAt = timePtr(time.Now().Add(-time.Hour))
+	if err := queue.RenewLeases(ctx, "host-a"); err != nil {
+		t.Fatalf("RenewLeases: %v", err)
+	}
+
+	// The agent stopped renewing this one well past the grace period
+	abandoned := st.lease(t, "exp-2", time.Now().Add(-staleLeaseGrace-time.Minute))
+
+	if err := queue.processStaleTask(ctx); err != nil {
+		t.Fatalf("processStaleTask: %v", err)
+	}
+
+	if got := st.status(renewed.ID); got != "running" {
+		t.Errorf("renewed task status = %q, want running", got)
+	}
+	if got := st.status(abandoned.ID); got != "failed" {
+		t.Errorf("abandoned task status = %q, want failed", got)
+	}
+}
+
+func TestQueue_LateReportAfterSweepIsRejected(t *testing.T) {
+	ctx := context.Background()
+	st := newLeaseStore()
+	queue := NewQueue(st, 5*time.Minute)
+
+	task := st.lease(t, "exp-1", time.Now().Add(-staleLeaseGrace-time.Minute))
+	if err := queue.processStaleTask(ctx); err != nil {
+		t.Fatalf("processStaleTask: %v", err)
+	}
+	if got := st.status(task.ID); got != "failed" {
+		t.Fatalf("swept task status = %q, want failed", got)
+	}
+
+	// The sweep queued a retry holding the same idempotency key
+	var retry *models.Task
+	for _, candidate := range st.tasks {
+		if candidate.OriginalTaskID == task.ID {
+			retry = candidate
+		}
+	}
+	if retry == nil {
+		t.Fatal("no retry was enqueued for the swept task")
+	}
+
+	// Give the old lease a fresh expiry so only the status can reject it
+	expires := time.Now().Add(time.Minute)
+	st.tasks[task.ID].LeaseExpiresAt = &expires
+
+	err := queue.ReportTaskStatus(ctx, task.ID, "token-1", "running", nil, "")
+	if !errors.Is(err, store.ErrTaskFinished) {
+		t.Fatalf("late report error = %v, want ErrTaskFinished", err)
+	}
+	if got := st.status(task.ID); got != "failed" {
+		t.Errorf("swept task status after late report = %q, want failed", got)
+	}
+	if got := st.status(retry.ID); got != "pending" {
+		t.Errorf("retry status after late report = %q, want pending", got)
+	}
+}
+
+func TestQueue_ReportRenewsLease(t *testing.T) {
+	ctx := context.Background()
+	st := newLeaseStore()
+	queue := NewQueue(st, 5*time.Minute)
+
+	task := st.lease(t, "exp-1", time.Now().Add(10*time.Second))
+
+	if err := queue.ReportTaskStatus(ctx, task.ID, "token-1", "running", nil, ""); err != nil {
+		t.Fatalf("ReportTaskStatus: %v", err)
+	}
+	if expires := st.tasks[task.ID].LeaseExpiresAt; expires.Before(time.Now().Add(4 * time.Minute)) {
+		t.Errorf("lease expires at %v, want it renewed by the report", expires)
+	}
+
+	if err := queue.ReportTaskStatus(ctx, task.ID, "other-token", "running", nil, ""); !errors.Is(err, store.ErrTaskLeaseMismatch) {
+		t.Errorf("report with a foreign token error = %v, want ErrTaskLeaseMismatch", err)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestQueue_LongRunningLoadSimStartIsNotSwept(t *testing.T) {
+	ctx := context.Background()
+	st := newLeaseStore()
+	queue := NewQueue(st, 5*time.Minute)
+
+	// A load simulation claimed half an hour ago that is still running
+	task := st.lease(t, "exp-1", time.Now().Add(time.Minute))
+	st.tasks[task.ID].AssignedAt = timePtr(time.Now().Add(-30 * time.Minute))
+
+	// Heartbeats keep renewing the lease between sweeps
+	for i := 0; i < 3; i++ {
+		if err := queue.RenewLeases(ctx, "host-a"); err != nil {
+			t.Fatalf("RenewLeases: %v", err)
+		}
+		if err := queue.processStaleTask(ctx); err != nil {
+			t.Fatalf("processStaleTask: %v", err)
+		}
+		if got := st.status(task.ID); got != "running" {
+			t.Fatalf("sweep %d: task status = %q, want running", i, got)
+		}
+	}
+
+	result := map[string]interface{}{"status": "finished"}
+	if err := queue.ReportTaskStatus(ctx, task.ID, "token-1", "completed", result, ""); err != nil {
+		t.Fatalf("ReportTaskStatus: %v", err)
+	}
+	if got := st.status(task.ID); got != "completed" {
+		t.Errorf("task status = %q, want completed", got)
+	}
+	if len(st.tasks) != 1 {
+		t.Errorf("store holds %d tasks, want no retry of the simulation", len(st.tasks))
+	}
+}
@@ -0,0 +1,184 @@
+package tasks
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/phoenix/platform/pkg/common/interfaces"
+)
+
+// RetryPolicy decides whether a failed task is attempted again and how long
+// to wait before the next attempt
+type RetryPolicy struct {
+	interfaces.RetryPolicy
+
+	// Jitter is the fraction of each backoff delay that is randomized, so
+	// tasks failing together do not retry in lockstep. 0 disables jitter.
+	Jitter float64
+
+	// NonRetryableErrors lists error message substrings that are never
+	// retried, typically configuration mistakes that would fail again
+	NonRetryableErrors []string
+
+	// RetryableErrors, when set, restricts retries to errors containing one
+	// of these substrings. Empty means any error not listed as
+	// non-retryable is retried.
+	RetryableErrors []string
+}
+
+// ShouldRetry reports whether a task that has already been retried
+// retryCount times and failed with errorMessage gets another attempt
+func (p RetryPolicy) ShouldRetry(retryCount int, errorMessage string) bool {
+	if retryCount+1 >= p.MaxAttempts {
+		return false
+	}
+
+	message := strings.ToLower(errorMessage)
+	for _, pattern := range p.NonRetryableErrors {
+		if strings.Contains(message, strings.ToLower(pattern)) {
+			return false
+		}
+	}
+
+	if len(p.RetryableErrors) == 0 {
+		return true
+	}
+	for _, pattern := range p.RetryableErrors {
+		if strings.Contains(message, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns how long to wait before the retry following retryCount
+// earlier retries
+func (p RetryPolicy) Delay(retryCount int) time.Duration {
+	backoff := p.Backoff
+	delay := float64(backoff.InitialDelay)
+
+	switch backoff.Type {
+	case "linear":
+		delay *= float64(retryCount + 1)
+	case "exponential":
+		multiplier := backoff.Multiplier
+		if multiplier <= 1 {
+			multiplier = 2
+		}
+		delay *= math.Pow(multiplier, float64(retryCount))
+	}
+
+	if backoff.MaxDelay > 0 && delay > float64(backoff.MaxDelay) {
+		delay = float64(backoff.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay = delay*(1-jitter) + rand.Float64()*delay*jitter
+	}
+
+	return time.Duration(delay)
+}
+
+// RetryPolicies resolves the retry policy for a task type and action. A
+// "type/action" entry takes precedence over a "type" entry, which takes
+// precedence over the default.
+type RetryPolicies struct {
+	Default  RetryPolicy
+	Policies map[string]RetryPolicy
+}
+
+// For returns the policy governing tasks of the given type and action
+func (r *RetryPolicies) For(taskType, action string) RetryPolicy {
+	if policy, ok := r.Policies[taskType+"/"+action]; ok {
+		return policy
+	}
+	if policy, ok := r.Policies[taskType]; ok {
+		return policy
+	}
+	return r.Default
+}
+
+// DefaultRetryPolicies returns the built-in policies. Errors caused by bad
+// task configuration are not retried since a new attempt would fail the same
+// way; transient failures back off exponentially.
+func DefaultRetryPolicies() *RetryPolicies {
+	exponential := func(initial, max time.Duration) interfaces.BackoffPolicy {
+		return interfaces.BackoffPolicy{
+			Type:         "exponential",
+			InitialDelay: initial,
+			MaxDelay:     max,
+			Multiplier:   2,
+		}
+	}
+
+	// Phrases the agent uses when a task's type, action or config is wrong.
+	// They are specific enough not to match transient failures such as
+	// "invalid response from upstream" or "unknown host".
+	configErrors := []string{
+		"unknown task type:",
+		"unknown collector action:",
+		"unknown loadsim action:",
+		"unknown deployment action:",
+		"unknown diagnostic:",
+		"unknown restart_policy",
+		"missing collector id in config",
+		"missing collector_id in config",
+		"missing variant in config",
+		"missing configUrl in config",
+		"missing profile in config",
+		"missing deployment_id in config",
+		"missing deployment_name in config",
+		"missing or empty pipeline_config in config",
+		"invalid duration:",
+		"invalid cpu limit:",
+		"invalid memory limit:",
+		"invalid pids limit:",
+		"is required when using",
+	}
+
+	return &RetryPolicies{
+		Default: RetryPolicy{
+			RetryPolicy: interfaces.RetryPolicy{
+				MaxAttempts: 4,
+				Backoff:     exponential(5*time.Second, 5*time.Minute),
+			},
+			Jitter:             0.2,
+			NonRetryableErrors: configErrors,
+		},
+		Policies: map[string]RetryPolicy{
+			"collector/start": {
+				RetryPolicy: interfaces.RetryPolicy{
+					MaxAttempts: 4,
+					Backoff:     exponential(5*time.Second, 2*time.Minute),
+				},
+				Jitter:             0.2,
+				NonRetryableErrors: append([]string{"already running"}, configErrors...),
+			},
+			"collector/stop": {
+				RetryPolicy: interfaces.RetryPolicy{
+					MaxAttempts: 3,
+					Backoff:     exponential(2*time.Second, 30*time.Second),
+				},
+				Jitter: 0.2,
+				// The collector is already gone; nothing left to stop
+				NonRetryableErrors: append([]string{"not found"}, configErrors...),
+			},
+			"loadsim/start": {
+				RetryPolicy: interfaces.RetryPolicy{
+					MaxAttempts: 2,
+					Backoff:     exponential(10*time.Second, time.Minute),
+				},
+				Jitter:             0.2,
+				NonRetryableErrors: append([]string{"already running", "unknown profile:"}, configErrors...),
+			},
+			"command": {
+				RetryPolicy: interfaces.RetryPolicy{
+					MaxAttempts: 1,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,123 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phoenix/platform/pkg/common/interfaces"
+)
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	policy := RetryPolicy{
+		RetryPolicy:        interfaces.RetryPolicy{MaxAttempts: 3},
+		NonRetryableErrors: []string{"invalid config"},
+	}
+
+	tests := []struct {
+		name         string
+		retryCount   int
+		errorMessage string
+		want         bool
+	}{
+		{"first failure", 0, "connection refused", true},
+		{"second failure", 1, "connection refused", true},
+		{"attempts exhausted", 2, "connection refused", false},
+		{"non-retryable error", 0, "Invalid Config: missing receivers", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.ShouldRetry(tt.retryCount, tt.errorMessage); got != tt.want {
+				t.Errorf("ShouldRetry(%d, %q) = %v, want %v", tt.retryCount, tt.errorMessage, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_RetryableErrorsAllowList(t *testing.T) {
+	policy := RetryPolicy{
+		RetryPolicy:     interfaces.RetryPolicy{MaxAttempts: 5},
+		RetryableErrors: []string{"timeout"},
+	}
+
+	if !policy.ShouldRetry(0, "Task timed out waiting for timeout") {
+		t.Error("expected allow-listed error to be retried")
+	}
+	if policy.ShouldRetry(0, "permission denied") {
+		t.Error("expected error outside the allow-list not to be retried")
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{
+		RetryPolicy: interfaces.RetryPolicy{
+			MaxAttempts: 10,
+			Backoff: interfaces.BackoffPolicy{
+				Type:         "exponential",
+				InitialDelay: time.Second,
+				MaxDelay:     10 * time.Second,
+				Multiplier:   2,
+			},
+		},
+	}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second}
+	for retryCount, expected := range want {
+		if got := policy.Delay(retryCount); got != expected {
+			t.Errorf("Delay(%d) = %v, want %v", retryCount, got, expected)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		if got := policy.Delay(2); got < 2*time.Second || got > 4*time.Second {
+			t.Fatalf("jittered Delay(2) = %v, want within [2s, 4s]", got)
+		}
+	}
+}
+
+func TestDefaultRetryPolicies_ClassifiesAgentErrors(t *testing.T) {
+	policies := DefaultRetryPolicies()
+
+	tests := []struct {
+		taskType     string
+		action       string
+		errorMessage string
+		want         bool
+	}{
+		// Transient failures that merely mention a config-ish word
+		{"collector", "start", "failed to download config: invalid response from upstream", true},
+		{"collector", "start", "failed to download config: dial tcp: lookup api: unknown host", true},
+		{"deployment", "deploy", "failed to deploy pipeline: required lock is held", true},
+		{"loadsim", "start", "failed to start load simulation: missing output from child", true},
+
+		// Errors the agent reports for a broken task
+		{"collector", "start", "missing configUrl in config", false},
+		{"collector", "restart", "unknown collector action: restart", false},
+		{"deployment", "deploy", "missing or empty pipeline_config in config", false},
+		{"loadsim", "start", "failed to start load simulation: unknown profile: spiky", false},
+		{"loadsim", "start", "failed to start load simulation: invalid duration: time: invalid duration \"x\"", false},
+		{"widget", "start", "unknown task type: widget", false},
+	}
+
+	for _, tt := range tests {
+		policy := policies.For(tt.taskType, tt.action)
+		if got := policy.ShouldRetry(0, tt.errorMessage); got != tt.want {
+			t.Errorf("%s/%s ShouldRetry(0, %q) = %v, want %v", tt.taskType, tt.action, tt.errorMessage, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicies_For(t *testing.T) {
+	policies := DefaultRetryPolicies()
+
+	if got := policies.For("collector", "start").MaxAttempts; got != 4 {
+		t.Errorf("collector/start MaxAttempts = %d, want 4", got)
+	}
+	if got := policies.For("command", "run").MaxAttempts; got != 1 {
+		t.Errorf("command MaxAttempts = %d, want 1", got)
+	}
+	if got := policies.For("pipeline", "deploy").MaxAttempts; got != policies.Default.MaxAttempts {
+		t.Errorf("unknown type MaxAttempts = %d, want default %d", got, policies.Default.MaxAttempts)
+	}
+}
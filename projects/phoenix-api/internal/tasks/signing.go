@@ -0,0 +1,57 @@
+package tasks
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// ConfigSigner signs the config digests of collector tasks, so agents that
+// hold the public key only run configs this API vouches for
+type ConfigSigner struct {
+	key ed25519.PrivateKey
+}
+
+// NewConfigSigner reads a base64 Ed25519 private key or 32-byte seed. An
+// empty key returns a nil signer, which signs nothing.
+func NewConfigSigner(key string) (*ConfigSigner, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("config signing key is not base64: %w", err)
+	}
+
+	switch len(data) {
+	case ed25519.SeedSize:
+		return &ConfigSigner{key: ed25519.NewKeyFromSeed(data)}, nil
+	case ed25519.PrivateKeySize:
+		return &ConfigSigner{key: ed25519.PrivateKey(data)}, nil
+	default:
+		return nil, fmt.Errorf("config signing key is %d bytes, not an Ed25519 seed or private key", len(data))
+	}
+}
+
+// PublicKey is the base64 key agents verify signatures with
+func (s *ConfigSigner) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Sign adds config_signature, the signature of its config_sha256 digest, to
+// a task config. Configs without a valid digest are left unsigned.
+func (s *ConfigSigner) Sign(config map[string]interface{}) {
+	if s == nil || config == nil {
+		return
+	}
+
+	sum, _ := config["config_sha256"].(string)
+	digest, err := hex.DecodeString(sum)
+	if err != nil || len(digest) != sha256.Size {
+		return
+	}
+	config["config_signature"] = base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, digest))
+}
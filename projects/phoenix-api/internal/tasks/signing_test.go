@@ -0,0 +1,59 @@
+package tasks
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestConfigSigner_SignsDigest(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	signer, err := NewConfigSigner(base64.StdEncoding.EncodeToString(seed))
+	if err != nil {
+		t.Fatalf("NewConfigSigner: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("receivers: {}\n"))
+	config := map[string]interface{}{"config_sha256": hex.EncodeToString(sum[:])}
+	signer.Sign(config)
+
+	signature, err := base64.StdEncoding.DecodeString(config["config_signature"].(string))
+	if err != nil {
+		t.Fatalf("signature is not base64: %v", err)
+	}
+	public, _ := base64.StdEncoding.DecodeString(signer.PublicKey())
+	if !ed25519.Verify(public, sum[:], signature) {
+		t.Error("signature does not verify against the digest")
+	}
+}
+
+func TestConfigSigner_LeavesConfigsWithoutDigest(t *testing.T) {
+	signer, err := NewConfigSigner(base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize)))
+	if err != nil {
+		t.Fatalf("NewConfigSigner: %v", err)
+	}
+
+	config := map[string]interface{}{"config_sha256": "not-a-digest"}
+	signer.Sign(config)
+	if _, ok := config["config_signature"]; ok {
+		t.Error("config without a valid digest was signed")
+	}
+
+	// Without a key nothing is signed
+	var unsigned *ConfigSigner
+	unsigned.Sign(config)
+}
+
+func TestNewConfigSigner_RejectsBadKeys(t *testing.T) {
+	if signer, err := NewConfigSigner(""); signer != nil || err != nil {
+		t.Errorf("empty key: got %v, %v", signer, err)
+	}
+	if _, err := NewConfigSigner("not base64!"); err == nil {
+		t.Error("expected an error for a key that is not base64")
+	}
+	if _, err := NewConfigSigner(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("expected an error for a key of the wrong size")
+	}
+}
@@ -0,0 +1,7 @@
+-- Remove task lease tracking
+DROP INDEX IF EXISTS idx_tasks_lease_expires;
+DROP INDEX IF EXISTS idx_tasks_claim;
+
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS lease_expires_at,
+DROP COLUMN IF EXISTS lease_token;
@@ -0,0 +1,10 @@
+-- Add lease tracking to tasks so multiple API replicas can hand out work safely.
+-- A task is claimed by setting a random lease token and an expiry; agents must
+-- present the token when reporting status.
+ALTER TABLE tasks
+ADD COLUMN IF NOT EXISTS lease_token VARCHAR(64),
+ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP;
+
+-- Speeds up the claim query (host, status, priority ordering)
+CREATE INDEX IF NOT EXISTS idx_tasks_claim ON tasks(host_id, status, priority DESC, created_at ASC);
+CREATE INDEX IF NOT EXISTS idx_tasks_lease_expires ON tasks(lease_expires_at) WHERE status = 'assigned';
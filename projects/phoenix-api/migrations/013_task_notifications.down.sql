@@ -0,0 +1,3 @@
+-- Remove task ready notifications
+DROP TRIGGER IF EXISTS tasks_notify_ready ON tasks;
+DROP FUNCTION IF EXISTS notify_task_ready();
@@ -0,0 +1,17 @@
+-- Publish a notification whenever a task becomes pending so parked agent
+-- long-polls on every API replica wake immediately instead of polling.
+-- The payload is the host the task is targeted at.
+CREATE OR REPLACE FUNCTION notify_task_ready()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('phoenix_task_ready', NEW.host_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tasks_notify_ready ON tasks;
+CREATE TRIGGER tasks_notify_ready
+    AFTER INSERT OR UPDATE OF status ON tasks
+    FOR EACH ROW
+    WHEN (NEW.status = 'pending')
+    EXECUTE FUNCTION notify_task_ready();
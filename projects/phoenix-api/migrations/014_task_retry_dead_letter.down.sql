@@ -0,0 +1,9 @@
+-- Remove retry scheduling and dead-letter support
+DROP INDEX IF EXISTS idx_tasks_dead_letter;
+DROP INDEX IF EXISTS idx_tasks_original;
+
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS dead_lettered_at,
+DROP COLUMN IF EXISTS dead_letter_reason,
+DROP COLUMN IF EXISTS original_task_id,
+DROP COLUMN IF EXISTS not_before;
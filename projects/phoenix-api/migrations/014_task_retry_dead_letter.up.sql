@@ -0,0 +1,12 @@
+-- Retry scheduling and dead-letter support for agent tasks.
+-- not_before delays a retry until its backoff has elapsed, original_task_id
+-- links every retry back to the first attempt, and tasks that exhaust their
+-- retry policy are parked in the 'dead_letter' status for manual handling.
+ALTER TABLE tasks
+ADD COLUMN IF NOT EXISTS not_before TIMESTAMP,
+ADD COLUMN IF NOT EXISTS original_task_id VARCHAR(255),
+ADD COLUMN IF NOT EXISTS dead_letter_reason TEXT,
+ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_original ON tasks(original_task_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_dead_letter ON tasks(dead_lettered_at DESC) WHERE status = 'dead_letter';
@@ -0,0 +1,20 @@
+-- Remove task dependencies and restore pending-only notifications
+CREATE OR REPLACE FUNCTION notify_task_ready()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('phoenix_task_ready', NEW.host_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tasks_notify_ready ON tasks;
+CREATE TRIGGER tasks_notify_ready
+    AFTER INSERT OR UPDATE OF status ON tasks
+    FOR EACH ROW
+    WHEN (NEW.status = 'pending')
+    EXECUTE FUNCTION notify_task_ready();
+
+DROP INDEX IF EXISTS idx_tasks_depends_on;
+
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS depends_on;
@@ -0,0 +1,32 @@
+-- Task dependencies. A pending task is only released to agents once every
+-- task listed in depends_on (or a retry of it) has completed.
+ALTER TABLE tasks
+ADD COLUMN IF NOT EXISTS depends_on TEXT[];
+
+CREATE INDEX IF NOT EXISTS idx_tasks_depends_on ON tasks USING GIN (depends_on);
+
+-- Also wake the hosts of dependent tasks when a parent completes, since that
+-- can make their tasks claimable without any task becoming pending
+CREATE OR REPLACE FUNCTION notify_task_ready()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status = 'pending' THEN
+        PERFORM pg_notify('phoenix_task_ready', NEW.host_id);
+    ELSIF NEW.status = 'completed' THEN
+        PERFORM pg_notify('phoenix_task_ready', dependents.host_id)
+        FROM (
+            SELECT DISTINCT host_id FROM tasks
+            WHERE status = 'pending'
+            AND (NEW.id = ANY(depends_on) OR NEW.original_task_id = ANY(depends_on))
+        ) dependents;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tasks_notify_ready ON tasks;
+CREATE TRIGGER tasks_notify_ready
+    AFTER INSERT OR UPDATE OF status ON tasks
+    FOR EACH ROW
+    WHEN (NEW.status IN ('pending', 'completed'))
+    EXECUTE FUNCTION notify_task_ready();
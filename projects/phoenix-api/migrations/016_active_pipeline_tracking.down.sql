@@ -0,0 +1,2 @@
+-- Remove active pipeline tracking index
+DROP INDEX IF EXISTS idx_active_pipelines_host_experiment_variant;
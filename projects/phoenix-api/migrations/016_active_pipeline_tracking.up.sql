@@ -0,0 +1,6 @@
+-- active_pipelines is maintained from collector task results, upserting one
+-- row per host/experiment/variant. The UNIQUE(host_id, experiment_id, variant)
+-- constraint from 001_core_tables already backs that upsert; this index
+-- duplicates it under a name of its own.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_active_pipelines_host_experiment_variant
+    ON active_pipelines(host_id, experiment_id, variant);
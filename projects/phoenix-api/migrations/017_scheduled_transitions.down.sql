@@ -0,0 +1,2 @@
+-- Remove scheduled experiment transitions
+DROP TABLE IF EXISTS scheduled_transitions;
@@ -0,0 +1,35 @@
+-- Experiment config and metadata are stored alongside the experiment so the
+-- durations that drive scheduled transitions survive restarts. The columns
+-- belong to 000_initial_schema, so the down migration leaves them in place.
+ALTER TABLE experiments
+ADD COLUMN IF NOT EXISTS config JSONB DEFAULT '{}',
+ADD COLUMN IF NOT EXISTS metadata JSONB DEFAULT '{}';
+
+-- Durable experiment phase transitions. The API replica holding the scheduler
+-- advisory lock executes pending transitions once due_at has passed.
+CREATE TABLE IF NOT EXISTS scheduled_transitions (
+    id VARCHAR(255) PRIMARY KEY DEFAULT gen_random_uuid(),
+    experiment_id VARCHAR(255) NOT NULL,
+    transition VARCHAR(50) NOT NULL,
+    due_at TIMESTAMP NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    executed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_experiment_transition
+        FOREIGN KEY(experiment_id)
+        REFERENCES experiments(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_transitions_due
+    ON scheduled_transitions(due_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_scheduled_transitions_experiment
+    ON scheduled_transitions(experiment_id);
+
+-- At most one pending transition of each kind per experiment
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scheduled_transitions_pending
+    ON scheduled_transitions(experiment_id, transition) WHERE status = 'pending';
@@ -0,0 +1,7 @@
+-- Remove idempotent task enqueueing and experiment actions
+DROP TABLE IF EXISTS idempotency_keys;
+
+DROP INDEX IF EXISTS idx_tasks_idempotency_key;
+
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS idempotency_key;
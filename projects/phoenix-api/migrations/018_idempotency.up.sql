@@ -0,0 +1,27 @@
+-- Idempotent task enqueueing and experiment actions.
+-- A task's idempotency key identifies the work it performs (experiment, host,
+-- type, action and variant). Only one task per key may be live or completed;
+-- failed, cancelled and dead-lettered tasks release their key so the work can
+-- be retried.
+ALTER TABLE tasks
+ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(512);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_idempotency_key
+    ON tasks(idempotency_key)
+    WHERE idempotency_key IS NOT NULL
+      AND status IN ('pending', 'assigned', 'running', 'completed');
+
+-- Responses of API requests sent with an Idempotency-Key header, replayed
+-- when a client retries the same request
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    method VARCHAR(16) NOT NULL,
+    path TEXT NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
@@ -0,0 +1,6 @@
+-- Remove in-flight task cancellation
+DROP INDEX IF EXISTS idx_tasks_cancel_requested;
+
+ALTER TABLE tasks
+DROP COLUMN IF EXISTS cancel_reason,
+DROP COLUMN IF EXISTS cancel_requested_at;
@@ -0,0 +1,11 @@
+-- In-flight task cancellation. Tasks already running on an agent cannot be
+-- cancelled in place; instead a cancellation request is recorded and handed
+-- to the agent in its next heartbeat reply. The agent then aborts the task
+-- and reports it as cancelled.
+ALTER TABLE tasks
+ADD COLUMN IF NOT EXISTS cancel_requested_at TIMESTAMP,
+ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_cancel_requested
+    ON tasks(host_id)
+    WHERE cancel_requested_at IS NOT NULL AND status = 'running';
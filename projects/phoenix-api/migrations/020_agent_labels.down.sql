@@ -0,0 +1,4 @@
+-- Remove agent labels
+ALTER TABLE agents
+DROP COLUMN IF EXISTS assigned_labels,
+DROP COLUMN IF EXISTS labels;
@@ -0,0 +1,6 @@
+-- Agent labels used by experiment host selectors. Agents report labels in
+-- their heartbeat; assigned labels are set through the API and take
+-- precedence over reported labels with the same key.
+ALTER TABLE agents
+ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}',
+ADD COLUMN IF NOT EXISTS assigned_labels JSONB NOT NULL DEFAULT '{}';
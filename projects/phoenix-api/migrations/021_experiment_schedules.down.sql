@@ -0,0 +1,3 @@
+-- Remove experiment schedules and their run history
+DROP TABLE IF EXISTS experiment_schedule_runs;
+DROP TABLE IF EXISTS experiment_schedules;
@@ -0,0 +1,48 @@
+-- Schedules that start runs of an experiment at a set time or on a cron
+-- expression. Each run is a copy of the scheduled experiment.
+CREATE TABLE IF NOT EXISTS experiment_schedules (
+    id VARCHAR(255) PRIMARY KEY DEFAULT gen_random_uuid(),
+    experiment_id VARCHAR(255) NOT NULL UNIQUE,
+    start_at TIMESTAMP,
+    cron VARCHAR(255),
+    timezone VARCHAR(100),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP,
+    last_run_at TIMESTAMP,
+    run_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_experiment_schedule
+        FOREIGN KEY(experiment_id)
+        REFERENCES experiments(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiment_schedules_due
+    ON experiment_schedules(next_run_at) WHERE enabled;
+
+-- History of the runs each schedule started. A run outlives the experiment
+-- it created so the history stays complete.
+CREATE TABLE IF NOT EXISTS experiment_schedule_runs (
+    id VARCHAR(255) PRIMARY KEY DEFAULT gen_random_uuid(),
+    schedule_id VARCHAR(255) NOT NULL,
+    experiment_id VARCHAR(255),
+    run_number INT NOT NULL,
+    scheduled_for TIMESTAMP NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_schedule_run_schedule
+        FOREIGN KEY(schedule_id)
+        REFERENCES experiment_schedules(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_schedule_run_experiment
+        FOREIGN KEY(experiment_id)
+        REFERENCES experiments(id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiment_schedule_runs_schedule
+    ON experiment_schedule_runs(schedule_id, run_number);
@@ -0,0 +1,2 @@
+-- Remove per-host experiment locks
+DROP TABLE IF EXISTS host_locks;
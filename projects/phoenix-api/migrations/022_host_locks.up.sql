@@ -0,0 +1,14 @@
+-- Exclusive per-host locks held by running experiments, so two experiments
+-- never run collectors on the same host
+CREATE TABLE IF NOT EXISTS host_locks (
+    host_id VARCHAR(255) PRIMARY KEY,
+    experiment_id VARCHAR(255) NOT NULL,
+    acquired_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_host_lock_experiment
+        FOREIGN KEY(experiment_id)
+        REFERENCES experiments(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_host_locks_experiment ON host_locks(experiment_id);
@@ -0,0 +1,5 @@
+-- Remove agent liveness events
+DROP TABLE IF EXISTS agent_events;
+
+ALTER TABLE agents
+DROP COLUMN IF EXISTS state_changed_at;
@@ -0,0 +1,18 @@
+-- Agent liveness. The status of an agent is derived by the API from its
+-- heartbeat age and resource usage: healthy, degraded, unreachable or
+-- offline. Every change of state is recorded as an agent event.
+ALTER TABLE agents
+ADD COLUMN IF NOT EXISTS state_changed_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS agent_events (
+    id SERIAL PRIMARY KEY,
+    host_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    from_state VARCHAR(50),
+    to_state VARCHAR(50),
+    message TEXT,
+    metadata JSONB DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_events_host ON agent_events(host_id, created_at DESC);
@@ -0,0 +1,2 @@
+-- Remove shipped agent logs
+DROP TABLE IF EXISTS agent_logs;
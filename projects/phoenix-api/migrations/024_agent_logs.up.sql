@@ -0,0 +1,17 @@
+-- Collector output and agent log lines shipped by agents. Each host keeps a
+-- bounded window of lines per collector, and lines past the retention age
+-- are pruned.
+CREATE TABLE IF NOT EXISTS agent_logs (
+    id BIGSERIAL PRIMARY KEY,
+    host_id VARCHAR(255) NOT NULL,
+    collector_id VARCHAR(255) NOT NULL DEFAULT '',
+    task_id VARCHAR(255) NOT NULL DEFAULT '',
+    source VARCHAR(50) NOT NULL,
+    level VARCHAR(20) NOT NULL,
+    message TEXT NOT NULL,
+    logged_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_logs_host_collector ON agent_logs(host_id, collector_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_agent_logs_created ON agent_logs(created_at);
@@ -0,0 +1,9 @@
+-- Restore the baseline/candidate variant check and the required config URL.
+-- Rows recorded for other variants are kept; the check only applies to new
+-- rows.
+UPDATE active_pipelines SET config_url = '' WHERE config_url IS NULL;
+
+ALTER TABLE active_pipelines
+ALTER COLUMN config_url SET NOT NULL,
+ADD CONSTRAINT active_pipelines_variant_check
+    CHECK (variant IN ('baseline', 'candidate')) NOT VALID;
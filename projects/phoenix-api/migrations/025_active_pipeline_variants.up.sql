@@ -0,0 +1,6 @@
+-- Experiments may run any number of named variants, so active_pipelines can
+-- no longer be limited to baseline and candidate. Collector results do not
+-- always carry a config URL either.
+ALTER TABLE active_pipelines
+DROP CONSTRAINT IF EXISTS active_pipelines_variant_check,
+ALTER COLUMN config_url DROP NOT NULL;
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// agentCmd represents the agent command group
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Inspect Phoenix agents",
+	Long: `Inspect the Phoenix agents running on fleet hosts.
+
+Agents are addressed by the host ID they registered with.`,
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-cli/internal/client"
+	"github.com/phoenix/platform/projects/phoenix-cli/internal/config"
+	"github.com/phoenix/platform/projects/phoenix-cli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diagnoseCollector string
+	diagnoseLines     int
+	diagnoseNoWait    bool
+	diagnoseTimeout   time.Duration
+)
+
+// diagnosticPollInterval is how often a waiting diagnose polls for the result
+var diagnosticPollInterval = 2 * time.Second
+
+// agentDiagnoseCmd represents the agent diagnose command
+var agentDiagnoseCmd = &cobra.Command{
+	Use:   "diagnose [host-id] [diagnostic]",
+	Short: "Run a built-in diagnostic on an agent",
+	Long: `Run one of the agent's built-in diagnostics and print its result.
+
+Agents only run these diagnostics, never arbitrary commands:
+  log_tail         Last lines of a collector's log (--collector, --lines)
+  rendered_config  A collector's config as it runs, with secrets redacted (--collector)
+  validate_config  Run otelcol validate on a collector's config (--collector)
+  proc_stats       /proc stats of a collector's process (--collector)
+  listening_ports  Ports listening on the host
+  disk_usage       Disk and inode usage of the agent's config directory
+
+Output is capped in size; a truncated result says so.
+
+Examples:
+  # Tail a collector's log
+  phoenix agent diagnose host-1 log_tail --collector exp-123-candidate --lines 500
+
+  # Check disk usage without waiting for the result
+  phoenix agent diagnose host-1 disk_usage --no-wait
+
+  # Fetch the result of an earlier diagnostic
+  phoenix agent diagnostic host-1 42`,
+	Args: cobra.ExactArgs(2),
+	RunE: runAgentDiagnose,
+}
+
+// agentDiagnosticCmd represents the agent diagnostic command
+var agentDiagnosticCmd = &cobra.Command{
+	Use:   "diagnostic [host-id] [task-id]",
+	Short: "Show the result of a diagnostic",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := newAgentClient()
+		if err != nil {
+			return err
+		}
+
+		diagnostic, err := apiClient.GetAgentDiagnostic(args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("failed to get diagnostic: %w", err)
+		}
+
+		return printDiagnostic(cmd, diagnostic)
+	},
+}
+
+func init() {
+	agentCmd.AddCommand(agentDiagnoseCmd)
+	agentCmd.AddCommand(agentDiagnosticCmd)
+
+	agentDiagnoseCmd.Flags().StringVarP(&diagnoseCollector, "collector", "c", "", "Collector to diagnose")
+	agentDiagnoseCmd.Flags().IntVarP(&diagnoseLines, "lines", "n", 0, "Log lines for log_tail (default: agent's default)")
+	agentDiagnoseCmd.Flags().BoolVar(&diagnoseNoWait, "no-wait", false, "Print the diagnostic's task ID instead of waiting for the result")
+	agentDiagnoseCmd.Flags().DurationVar(&diagnoseTimeout, "timeout", 2*time.Minute, "How long to wait for the result")
+}
+
+func runAgentDiagnose(cmd *cobra.Command, args []string) error {
+	hostID := args[0]
+
+	apiClient, err := newAgentClient()
+	if err != nil {
+		return err
+	}
+
+	diagnostic, err := apiClient.RunAgentDiagnostic(hostID, client.AgentDiagnosticRequest{
+		Command:     args[1],
+		CollectorID: diagnoseCollector,
+		Lines:       diagnoseLines,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run diagnostic: %w", err)
+	}
+
+	if diagnoseNoWait {
+		output.PrintSuccess(fmt.Sprintf("Diagnostic %s queued", diagnostic.ID))
+		fmt.Printf("\nTo see the result, run:\n  phoenix agent diagnostic %s %s\n", hostID, diagnostic.ID)
+		return nil
+	}
+
+	deadline := time.Now().Add(diagnoseTimeout)
+	for !diagnosticDone(diagnostic.Status) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("diagnostic %s is still %s after %s; run 'phoenix agent diagnostic %s %s' later",
+				diagnostic.ID, diagnostic.Status, diagnoseTimeout, hostID, diagnostic.ID)
+		}
+		time.Sleep(diagnosticPollInterval)
+
+		diagnostic, err = apiClient.GetAgentDiagnostic(hostID, diagnostic.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get diagnostic: %w", err)
+		}
+	}
+
+	return printDiagnostic(cmd, diagnostic)
+}
+
+func newAgentClient() (*client.APIClient, error) {
+	cfg := config.New()
+	token := cfg.GetToken()
+	if token == "" {
+		return nil, fmt.Errorf("not authenticated. Please run: phoenix auth login")
+	}
+	return client.NewAPIClient(cfg.GetAPIEndpoint(), token), nil
+}
+
+func diagnosticDone(status string) bool {
+	switch status {
+	case "completed", "failed", "cancelled":
+		return true
+	}
+	return false
+}
+
+// printDiagnostic prints a diagnostic's text output as is and its other
+// fields as a table, or the whole diagnostic as JSON or YAML
+func printDiagnostic(cmd *cobra.Command, diagnostic *client.AgentDiagnostic) error {
+	switch outputFormat {
+	case "json":
+		return output.PrintJSON(cmd.OutOrStdout(), diagnostic)
+	case "yaml":
+		return output.PrintYAML(cmd.OutOrStdout(), diagnostic)
+	}
+
+	switch diagnostic.Status {
+	case "completed":
+	case "failed", "cancelled":
+		return fmt.Errorf("diagnostic %s %s: %s", diagnostic.ID, diagnostic.Status, diagnostic.ErrorMessage)
+	default:
+		output.Info(fmt.Sprintf("Diagnostic %s is %s", diagnostic.ID, diagnostic.Status))
+		return nil
+	}
+
+	out := cmd.OutOrStdout()
+
+	// Scalars go in a table; text and nested results follow it in full
+	var fields [][]string
+	var sections []string
+	keys := make([]string, 0, len(diagnostic.Result))
+	for key := range diagnostic.Result {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch value := diagnostic.Result[key].(type) {
+		case string:
+			if key != "output" {
+				fields = append(fields, []string{key, value})
+			}
+		case float64, bool, nil:
+			fields = append(fields, []string{key, fmt.Sprintf("%v", value)})
+		default:
+			sections = append(sections, key)
+		}
+	}
+	output.Table([]string{"Field", "Value"}, fields)
+
+	for _, key := range sections {
+		fmt.Fprintf(out, "\n%s:\n", output.Bold(key))
+		printDiagnosticSection(out, diagnostic.Result[key])
+	}
+
+	if text, ok := diagnostic.Result["output"].(string); ok {
+		fmt.Fprintln(out)
+		printDiagnosticText(out, text)
+	}
+	if truncated, _ := diagnostic.Result["truncated"].(bool); truncated {
+		output.PrintWarning("Output was truncated by the agent")
+	}
+	return nil
+}
+
+// printDiagnosticSection prints a nested result: the named texts of
+// proc_stats one after another, anything else as JSON
+func printDiagnosticSection(out io.Writer, value interface{}) {
+	if texts, ok := value.(map[string]interface{}); ok {
+		names := make([]string, 0, len(texts))
+		for name, text := range texts {
+			if _, ok := text.(string); !ok {
+				names = nil
+				break
+			}
+			names = append(names, name)
+		}
+		if names != nil {
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(out, "--- %s\n", name)
+				printDiagnosticText(out, texts[name].(string))
+			}
+			return
+		}
+	}
+
+	output.PrintJSON(out, value)
+	fmt.Fprintln(out)
+}
+
+func printDiagnosticText(out io.Writer, text string) {
+	fmt.Fprint(out, text)
+	if !strings.HasSuffix(text, "\n") {
+		fmt.Fprintln(out)
+	}
+}
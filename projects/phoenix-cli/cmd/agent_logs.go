@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/phoenix/platform/projects/phoenix-cli/internal/client"
+	"github.com/phoenix/platform/projects/phoenix-cli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	agentLogsCollector string
+	agentLogsTask      string
+	agentLogsLevel     string
+	agentLogsSince     string
+	agentLogsLimit     int
+	agentLogsFollow    bool
+)
+
+// agentLogsPollInterval is how often --follow asks for new lines
+var agentLogsPollInterval = 2 * time.Second
+
+// agentLogsCmd represents the agent logs command
+var agentLogsCmd = &cobra.Command{
+	Use:   "logs [host-id]",
+	Short: "Show collector output and agent logs shipped by an agent",
+	Long: `Show the latest log lines an agent shipped to the API, oldest first.
+
+Agents ship the output of every collector they supervise and their own log
+lines at or above their LOG_SHIP_LEVEL. The API keeps a bounded window of
+lines per host and collector.
+
+Examples:
+  # Why did a candidate crash?
+  phoenix agent logs host-1 --collector exp-123-candidate --level warn
+
+  # Everything from the last 15 minutes
+  phoenix agent logs host-1 --since 15m
+
+  # Follow new lines
+  phoenix agent logs host-1 --collector exp-123-candidate --follow`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAgentLogs,
+}
+
+func init() {
+	agentCmd.AddCommand(agentLogsCmd)
+
+	agentLogsCmd.Flags().StringVarP(&agentLogsCollector, "collector", "c", "", "Only lines of this collector")
+	agentLogsCmd.Flags().StringVar(&agentLogsTask, "task", "", "Only lines of this task")
+	agentLogsCmd.Flags().StringVarP(&agentLogsLevel, "level", "l", "", "Lowest level shown (debug, info, warn, error)")
+	agentLogsCmd.Flags().StringVar(&agentLogsSince, "since", "", "Only lines since an RFC 3339 time or a duration ago, such as 1h")
+	agentLogsCmd.Flags().IntVarP(&agentLogsLimit, "limit", "n", 200, "Most lines shown")
+	agentLogsCmd.Flags().BoolVarP(&agentLogsFollow, "follow", "f", false, "Keep showing new lines")
+}
+
+func runAgentLogs(cmd *cobra.Command, args []string) error {
+	hostID := args[0]
+
+	apiClient, err := newAgentClient()
+	if err != nil {
+		return err
+	}
+
+	req := client.AgentLogsRequest{
+		CollectorID: agentLogsCollector,
+		TaskID:      agentLogsTask,
+		Level:       agentLogsLevel,
+		Since:       agentLogsSince,
+		Limit:       agentLogsLimit,
+	}
+	entries, err := apiClient.GetAgentLogs(hostID, req)
+	if err != nil {
+		return fmt.Errorf("failed to get agent logs: %w", err)
+	}
+
+	if !agentLogsFollow {
+		switch outputFormat {
+		case "json":
+			return output.PrintJSON(cmd.OutOrStdout(), entries)
+		case "yaml":
+			return output.PrintYAML(cmd.OutOrStdout(), entries)
+		}
+		if len(entries) == 0 {
+			output.Info("No log lines found")
+			return nil
+		}
+	}
+
+	for {
+		for _, entry := range entries {
+			printAgentLogEntry(cmd.OutOrStdout(), entry)
+			// Only lines stored after those shown are asked for next
+			req.AfterID = entry.ID
+		}
+
+		if !agentLogsFollow {
+			return nil
+		}
+		time.Sleep(agentLogsPollInterval)
+
+		entries, err = apiClient.GetAgentLogs(hostID, req)
+		if err != nil {
+			return fmt.Errorf("failed to get agent logs: %w", err)
+		}
+	}
+}
+
+func printAgentLogEntry(out io.Writer, entry client.AgentLogEntry) {
+	source := entry.Source
+	if entry.CollectorID != "" {
+		source = entry.CollectorID
+	}
+	fmt.Fprintf(out, "%s %-5s [%s] %s\n",
+		entry.Timestamp.Local().Format("2006-01-02 15:04:05.000"),
+		strings.ToUpper(entry.Level), source, entry.Message)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,28 +13,38 @@ import (
 )
 
 var (
-	expName           string
-	expDescription    string
-	baselinePipeline  string
-	candidatePipeline string
-	targetSelector    map[string]string
-	duration          time.Duration
-	criticalProcesses []string
-	topK              int
-	checkOverlap      bool
-	force             bool
-	useNRDOT          bool
-	nrLicenseKey      string
-	nrEndpoint        string
-	maxCardinality    int
-	reductionPercent  int
+	expName            string
+	expDescription     string
+	baselinePipeline   string
+	candidatePipelines []string
+	targetSelector     map[string]string
+	hostSelector       string
+	sampleSize         int
+	samplePercent      float64
+	rolloutWaves       []string
+	soakTime           time.Duration
+	gateMinSuccess     float64
+	schedule           string
+	scheduleTimezone   string
+	duration           time.Duration
+	criticalProcesses  []string
+	topK               int
+	checkOverlap       bool
+	force              bool
+	useNRDOT           bool
+	nrLicenseKey       string
+	nrEndpoint         string
+	maxCardinality     int
+	reductionPercent   int
 )
 
 // createExperimentCmd represents the create experiment command
 var createExperimentCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a new experiment",
-	Long: `Create a new A/B experiment to test pipeline optimizations.
+	Long: `Create a new A/B experiment to test pipeline optimizations. Give --candidate
+more than once to compare several candidate pipelines against the baseline in
+an A/B/n experiment; analysis then ranks the candidates.
 
 Examples:
   # Create a simple experiment
@@ -42,6 +53,45 @@ Examples:
     --candidate process-topk-v1 \
     --target-selector "app=webserver"
 
+  # Compare two candidates against the baseline
+  phoenix experiment create --name "topk-vs-sampling" \
+    --baseline process-baseline-v1 \
+    --candidate process-topk-v1 \
+    --candidate process-sampled-v1 \
+    --target-selector "app=webserver"
+
+  # Select hosts by agent labels, sampling 10% of the matching hosts
+  phoenix experiment create --name "web-canary" \
+    --baseline process-baseline-v1 \
+    --candidate process-topk-v1 \
+    --selector "env=prod,role=web,region in (us-east-1)" \
+    --sample-percent 10
+
+  # Roll out to one host, then 25% and then the rest, soaking each wave
+  # for 15 minutes and requiring 95% of hosts to run both collectors
+  phoenix experiment create --name "staged-topk" \
+    --baseline process-baseline-v1 \
+    --candidate process-topk-v1 \
+    --selector "env=prod" \
+    --waves "1,25%,100%" \
+    --soak-time 15m \
+    --gate-min-success 95
+
+  # Re-validate a candidate every Monday at 03:00 Berlin time
+  phoenix experiment create --name "weekly-topk" \
+    --baseline process-baseline-v1 \
+    --candidate process-topk-v1 \
+    --selector "env=prod" \
+    --schedule "0 3 * * mon" \
+    --timezone Europe/Berlin
+
+  # Run once during a low-traffic window
+  phoenix experiment create --name "night-topk" \
+    --baseline process-baseline-v1 \
+    --candidate process-topk-v1 \
+    --target-selector "app=webserver" \
+    --schedule 2024-06-01T02:00:00Z
+
   # Create experiment with critical processes
   phoenix experiment create --name "priority-filter-test" \
     --baseline process-baseline-v1 \
@@ -74,13 +124,15 @@ func init() {
 	// Required flags
 	createExperimentCmd.Flags().StringVarP(&expName, "name", "n", "", "Experiment name (required)")
 	createExperimentCmd.Flags().StringVar(&baselinePipeline, "baseline", "", "Baseline pipeline template (required)")
-	createExperimentCmd.Flags().StringVar(&candidatePipeline, "candidate", "", "Candidate pipeline template (required)")
-	createExperimentCmd.Flags().StringToStringVar(&targetSelector, "target-selector", nil, "Target node selector labels (required)")
+	createExperimentCmd.Flags().StringSliceVar(&candidatePipelines, "candidate", nil, "Candidate pipeline template; repeat for an A/B/n experiment (required)")
+	createExperimentCmd.Flags().StringToStringVar(&targetSelector, "target-selector", nil, "Target node selector labels")
+	createExperimentCmd.Flags().StringVar(&hostSelector, "selector", "", "Agent label selector resolved to hosts when the experiment starts, e.g. \"env=prod,region in (us-east-1)\"")
 
 	createExperimentCmd.MarkFlagRequired("name")
 	createExperimentCmd.MarkFlagRequired("baseline")
 	createExperimentCmd.MarkFlagRequired("candidate")
-	createExperimentCmd.MarkFlagRequired("target-selector")
+	createExperimentCmd.MarkFlagsOneRequired("target-selector", "selector")
+	createExperimentCmd.MarkFlagsMutuallyExclusive("target-selector", "selector")
 
 	// Optional flags
 	createExperimentCmd.Flags().StringVarP(&expDescription, "description", "d", "", "Experiment description")
@@ -89,6 +141,14 @@ func init() {
 	createExperimentCmd.Flags().IntVar(&topK, "top-k", 10, "Number of top processes to keep (for topk pipeline)")
 	createExperimentCmd.Flags().BoolVar(&checkOverlap, "check-overlap", false, "Check for overlapping experiments")
 	createExperimentCmd.Flags().BoolVarP(&force, "force", "f", false, "Force creation even with warnings")
+	createExperimentCmd.Flags().IntVar(&sampleSize, "sample-size", 0, "Number of hosts to sample from those matching --selector")
+	createExperimentCmd.Flags().Float64Var(&samplePercent, "sample-percent", 0, "Percentage of hosts to sample from those matching --selector")
+	createExperimentCmd.MarkFlagsMutuallyExclusive("sample-size", "sample-percent")
+	createExperimentCmd.Flags().StringSliceVar(&rolloutWaves, "waves", nil, "Roll out in waves of host counts or percentages, e.g. \"1,10%,100%\"")
+	createExperimentCmd.Flags().DurationVar(&soakTime, "soak-time", 0, "Time each rollout wave runs before its gate is checked")
+	createExperimentCmd.Flags().StringVar(&schedule, "schedule", "", "Start runs on a cron expression, e.g. \"0 3 * * mon\", or once at an RFC 3339 time")
+	createExperimentCmd.Flags().StringVar(&scheduleTimezone, "timezone", "", "Time zone of the --schedule cron expression (default UTC)")
+	createExperimentCmd.Flags().Float64Var(&gateMinSuccess, "gate-min-success", 0, "Percentage of deployed hosts that must be running for a wave to pass its gate (default 100)")
 
 	// NRDOT flags
 	createExperimentCmd.Flags().BoolVar(&useNRDOT, "use-nrdot", false, "Use NRDOT collector instead of standard OTel")
@@ -109,6 +169,8 @@ func runCreateExperiment(cmd *cobra.Command, args []string) error {
 	// Create API client
 	apiClient := client.NewAPIClient(cfg.GetAPIEndpoint(), token)
 
+	candidatePipeline := candidatePipelines[0]
+
 	// Prepare experiment request
 	req := client.CreateExperimentRequest{
 		Name:              expName,
@@ -116,10 +178,33 @@ func runCreateExperiment(cmd *cobra.Command, args []string) error {
 		BaselinePipeline:  baselinePipeline,
 		CandidatePipeline: candidatePipeline,
 		TargetNodes:       targetSelector,
+		Selector:          hostSelector,
+		SampleSize:        sampleSize,
+		SamplePercent:     samplePercent,
 		Duration:          duration,
 		Parameters:        make(map[string]interface{}),
 	}
 
+	if len(candidatePipelines) > 1 {
+		req.CandidatePipelines = candidatePipelines
+	}
+
+	if len(rolloutWaves) > 0 {
+		plan, err := parseRolloutWaves(rolloutWaves, soakTime, gateMinSuccess)
+		if err != nil {
+			return err
+		}
+		req.Rollout = plan
+	} else if cmd.Flags().Changed("soak-time") || cmd.Flags().Changed("gate-min-success") {
+		return fmt.Errorf("--soak-time and --gate-min-success require --waves")
+	}
+
+	if schedule != "" {
+		req.Schedule = parseSchedule(schedule, scheduleTimezone)
+	} else if cmd.Flags().Changed("timezone") {
+		return fmt.Errorf("--timezone requires --schedule")
+	}
+
 	// Add pipeline-specific parameters
 	if len(criticalProcesses) > 0 {
 		req.Parameters["critical_processes"] = criticalProcesses
@@ -191,8 +276,54 @@ func runCreateExperiment(cmd *cobra.Command, args []string) error {
 	output.PrintExperiment(experiment, outputFormat)
 
 	fmt.Println("\n✓ Experiment created successfully!")
+	if experiment.Schedule != nil {
+		if experiment.Schedule.NextRunAt != nil {
+			fmt.Printf("\nThe first run starts at %s.\n", experiment.Schedule.NextRunAt.Local().Format(time.RFC1123))
+		}
+		fmt.Printf("\nEach run is created as a new experiment named after this one.\n")
+		return nil
+	}
 	fmt.Printf("\nTo start the experiment, run:\n  phoenix experiment start %s\n", experiment.ID)
 	fmt.Printf("\nTo monitor status, run:\n  phoenix experiment status %s --follow\n", experiment.ID)
 
 	return nil
 }
+
+// parseRolloutWaves builds a rollout plan from wave sizes such as "1" (hosts)
+// or "10%" (percentage of target hosts)
+func parseRolloutWaves(waves []string, soak time.Duration, minSuccess float64) (*client.RolloutPlan, error) {
+	plan := &client.RolloutPlan{}
+	for _, size := range waves {
+		size = strings.TrimSpace(size)
+		wave := client.RolloutWave{SoakTime: soak}
+
+		if percent, ok := strings.CutSuffix(size, "%"); ok {
+			value, err := strconv.ParseFloat(percent, 64)
+			if err != nil || value <= 0 || value > 100 {
+				return nil, fmt.Errorf("invalid rollout wave %q: percentage must be between 0 and 100", size)
+			}
+			wave.Percent = value
+		} else {
+			value, err := strconv.Atoi(size)
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("invalid rollout wave %q: host count must be a positive number", size)
+			}
+			wave.Hosts = value
+		}
+
+		if minSuccess > 0 {
+			wave.Gate = &client.RolloutGate{MinHostSuccessPercent: minSuccess}
+		}
+		plan.Waves = append(plan.Waves, wave)
+	}
+	return plan, nil
+}
+
+// parseSchedule reads --schedule as an RFC 3339 start time, or otherwise as a
+// cron expression, which the API validates
+func parseSchedule(value, timezone string) *client.ExperimentSchedule {
+	if startAt, err := time.Parse(time.RFC3339, value); err == nil {
+		return &client.ExperimentSchedule{StartAt: &startAt, Timezone: timezone}
+	}
+	return &client.ExperimentSchedule{Cron: value, Timezone: timezone}
+}
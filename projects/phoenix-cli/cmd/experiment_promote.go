@@ -27,6 +27,9 @@ Examples:
   # Promote the candidate variant
   phoenix experiment promote exp-123 --variant candidate
 
+  # Promote one candidate of an A/B/n experiment
+  phoenix experiment promote exp-123 --variant process-sampled-v1
+
   # Promote the baseline (rollback)
   phoenix experiment promote exp-123 --variant baseline
 
@@ -39,7 +42,7 @@ Examples:
 func init() {
 	experimentCmd.AddCommand(promoteExperimentCmd)
 
-	promoteExperimentCmd.Flags().StringVarP(&promoteVariant, "variant", "v", "", "Variant to promote (baseline or candidate)")
+	promoteExperimentCmd.Flags().StringVarP(&promoteVariant, "variant", "v", "", "Variant to promote (baseline, candidate, or an A/B/n candidate's name)")
 	promoteExperimentCmd.Flags().BoolVarP(&promoteForce, "force", "f", false, "Force promotion without confirmation")
 
 	promoteExperimentCmd.MarkFlagRequired("variant")
@@ -48,9 +51,9 @@ func init() {
 func runExperimentPromote(cmd *cobra.Command, args []string) error {
 	experimentID := args[0]
 
-	// Validate variant
-	if promoteVariant != "baseline" && promoteVariant != "candidate" {
-		return fmt.Errorf("variant must be 'baseline' or 'candidate', got: %s", promoteVariant)
+	// Validate variant; A/B/n candidates are checked by the API
+	if strings.TrimSpace(promoteVariant) == "" {
+		return fmt.Errorf("variant must not be empty")
 	}
 
 	// Get config and check authentication
@@ -82,8 +85,12 @@ func runExperimentPromote(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Experiment:    %s (%s)\n", experiment.Name, experiment.ID[:8])
 	fmt.Printf("  Variant:       %s\n", promoteVariant)
 
-	pipelineName := experiment.BaselinePipeline
-	if promoteVariant == "candidate" {
+	// A/B/n candidates are named after their pipeline templates
+	pipelineName := promoteVariant
+	switch promoteVariant {
+	case "baseline":
+		pipelineName = experiment.BaselinePipeline
+	case "candidate":
 		pipelineName = experiment.CandidatePipeline
 	}
 	fmt.Printf("  Pipeline:      %s\n", pipelineName)
@@ -128,7 +135,7 @@ func runExperimentPromote(cmd *cobra.Command, args []string) error {
 		fmt.Printf("  • %s=%s\n", k, v)
 	}
 
-	if promoteVariant == "candidate" && experiment.Results != nil {
+	if promoteVariant != "baseline" && experiment.Results != nil {
 		fmt.Printf("\nExpected benefits:\n")
 		fmt.Printf("  • %.1f%% reduction in metrics cardinality\n", experiment.Results.CardinalityReduction)
 		fmt.Printf("  • %.1f%% reduction in observability costs\n", experiment.Results.CostReduction)
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/phoenix/platform/projects/phoenix-cli/internal/client"
+	"github.com/phoenix/platform/projects/phoenix-cli/internal/config"
+	"github.com/phoenix/platform/projects/phoenix-cli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// experimentRolloutCmd groups the commands controlling a staged rollout
+var experimentRolloutCmd = &cobra.Command{
+	Use:   "rollout",
+	Short: "Control the staged rollout of an experiment",
+	Long: `Control an experiment created with --waves.
+
+A rollout pauses when a wave fails its gate. It can then be resumed, which
+deploys the next wave anyway, or aborted, which stops the experiment on the
+hosts reached so far.
+
+Examples:
+  # Continue a paused rollout
+  phoenix experiment rollout resume exp-123
+
+  # Abort a rollout
+  phoenix experiment rollout abort exp-123`,
+}
+
+var experimentRolloutResumeCmd = &cobra.Command{
+	Use:   "resume [experiment-id]",
+	Short: "Resume a rollout paused by a failed gate",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := newRolloutClient()
+		if err != nil {
+			return err
+		}
+
+		if err := apiClient.ResumeRollout(args[0]); err != nil {
+			return fmt.Errorf("failed to resume rollout: %w", err)
+		}
+
+		output.PrintSuccess("Rollout resumed")
+		fmt.Printf("\nTo monitor status, run:\n  phoenix experiment status %s --follow\n", args[0])
+		return nil
+	},
+}
+
+var experimentRolloutAbortCmd = &cobra.Command{
+	Use:   "abort [experiment-id]",
+	Short: "Abort a rollout and stop the experiment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient, err := newRolloutClient()
+		if err != nil {
+			return err
+		}
+
+		if err := apiClient.AbortRollout(args[0]); err != nil {
+			return fmt.Errorf("failed to abort rollout: %w", err)
+		}
+
+		output.PrintSuccess("Rollout aborted, experiment is stopping")
+		return nil
+	},
+}
+
+func newRolloutClient() (*client.APIClient, error) {
+	cfg := config.New()
+	token := cfg.GetToken()
+	if token == "" {
+		return nil, fmt.Errorf("not authenticated. Please run: phoenix auth login")
+	}
+	return client.NewAPIClient(cfg.GetAPIEndpoint(), token), nil
+}
+
+func init() {
+	experimentCmd.AddCommand(experimentRolloutCmd)
+	experimentRolloutCmd.AddCommand(experimentRolloutResumeCmd)
+	experimentRolloutCmd.AddCommand(experimentRolloutAbortCmd)
+}
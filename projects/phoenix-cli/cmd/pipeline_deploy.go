@@ -20,6 +20,7 @@ var (
 	deployCPULimit   string
 	deployMemRequest string
 	deployMemLimit   string
+	deployPIDsLimit  int64
 )
 
 // deployPipelineCmd represents the pipeline deploy command
@@ -73,6 +74,7 @@ func init() {
 	pipelineDeployCmd.Flags().StringVar(&deployCPULimit, "cpu-limit", "500m", "CPU limit")
 	pipelineDeployCmd.Flags().StringVar(&deployMemRequest, "memory-request", "128Mi", "Memory request")
 	pipelineDeployCmd.Flags().StringVar(&deployMemLimit, "memory-limit", "512Mi", "Memory limit")
+	pipelineDeployCmd.Flags().Int64Var(&deployPIDsLimit, "pids-limit", 0, "Process limit (0 for none)")
 }
 
 func runPipelineDeploy(cmd *cobra.Command, args []string) error {
@@ -112,6 +114,7 @@ func runPipelineDeploy(cmd *cobra.Command, args []string) error {
 			Limits: client.ResourceList{
 				CPU:    deployCPULimit,
 				Memory: deployMemLimit,
+				PIDs:   deployPIDsLimit,
 			},
 		},
 	}
@@ -196,6 +196,24 @@ func (c *APIClient) StopExperiment(id string) error {
 	return c.parseResponse(resp, nil)
 }
 
+// ResumeRollout continues an experiment rollout paused by a failed gate
+func (c *APIClient) ResumeRollout(id string) error {
+	resp, err := c.doRequest("POST", "/api/v1/experiments/"+id+"/rollout/resume", nil)
+	if err != nil {
+		return err
+	}
+	return c.parseResponse(resp, nil)
+}
+
+// AbortRollout stops an unfinished experiment rollout
+func (c *APIClient) AbortRollout(id string) error {
+	resp, err := c.doRequest("POST", "/api/v1/experiments/"+id+"/rollout/abort", nil)
+	if err != nil {
+		return err
+	}
+	return c.parseResponse(resp, nil)
+}
+
 // PromoteExperiment promotes an experiment variant
 func (c *APIClient) PromoteExperiment(id string, variant string) error {
 	req := struct {
@@ -452,3 +470,75 @@ func (c *APIClient) RollbackExperiment(id string, instant bool, reason string) (
 
 	return result, nil
 }
+
+// RunAgentDiagnostic asks an agent to run a diagnostic and returns the task
+// that carries its result
+func (c *APIClient) RunAgentDiagnostic(hostID string, req AgentDiagnosticRequest) (*AgentDiagnostic, error) {
+	resp, err := c.doRequest("POST", "/api/v1/fleet/agents/"+url.PathEscape(hostID)+"/diagnostics", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostic AgentDiagnostic
+	if err := c.parseResponse(resp, &diagnostic); err != nil {
+		return nil, err
+	}
+
+	return &diagnostic, nil
+}
+
+// GetAgentDiagnostic retrieves a diagnostic and its result
+func (c *APIClient) GetAgentDiagnostic(hostID, taskID string) (*AgentDiagnostic, error) {
+	resp, err := c.doRequest("GET", "/api/v1/fleet/agents/"+url.PathEscape(hostID)+"/diagnostics/"+url.PathEscape(taskID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostic AgentDiagnostic
+	if err := c.parseResponse(resp, &diagnostic); err != nil {
+		return nil, err
+	}
+
+	return &diagnostic, nil
+}
+
+// GetAgentLogs retrieves the latest log lines shipped by an agent, oldest
+// first
+func (c *APIClient) GetAgentLogs(hostID string, req AgentLogsRequest) ([]AgentLogEntry, error) {
+	params := url.Values{}
+	if req.CollectorID != "" {
+		params.Add("collector_id", req.CollectorID)
+	}
+	if req.TaskID != "" {
+		params.Add("task_id", req.TaskID)
+	}
+	if req.Level != "" {
+		params.Add("level", req.Level)
+	}
+	if req.Since != "" {
+		params.Add("since", req.Since)
+	}
+	if req.AfterID > 0 {
+		params.Add("after_id", fmt.Sprintf("%d", req.AfterID))
+	}
+	if req.Limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", req.Limit))
+	}
+
+	endpoint := "/api/v1/fleet/agents/" + url.PathEscape(hostID) + "/logs"
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	resp, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AgentLogEntry
+	if err := c.parseResponse(resp, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -326,6 +326,68 @@ func TestAPIClient_GetExperimentMetrics(t *testing.T) {
 	assert.Equal(t, expectedMetrics.Summary.DataLossPercent, metrics.Summary.DataLossPercent)
 }
 
+func TestAPIClient_RunAgentDiagnostic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/fleet/agents/host-1/diagnostics", r.URL.Path)
+		assert.Equal(t, "POST", r.Method)
+
+		var req AgentDiagnosticRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, AgentDiagnosticRequest{Command: "log_tail", CollectorID: "exp-123-candidate", Lines: 50}, req)
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(AgentDiagnostic{ID: "42", HostID: "host-1", Action: "log_tail", Status: "pending"})
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, "test-token")
+	diagnostic, err := client.RunAgentDiagnostic("host-1", AgentDiagnosticRequest{Command: "log_tail", CollectorID: "exp-123-candidate", Lines: 50})
+
+	require.NoError(t, err)
+	assert.Equal(t, "42", diagnostic.ID)
+	assert.Equal(t, "pending", diagnostic.Status)
+}
+
+func TestAPIClient_GetAgentDiagnostic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/fleet/agents/host-1/diagnostics/42", r.URL.Path)
+		assert.Equal(t, "GET", r.Method)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"42","status":"completed","result":{"output":"ok\n","truncated":false}}`))
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, "test-token")
+	diagnostic, err := client.GetAgentDiagnostic("host-1", "42")
+
+	require.NoError(t, err)
+	assert.Equal(t, "completed", diagnostic.Status)
+	assert.Equal(t, "ok\n", diagnostic.Result["output"])
+}
+
+func TestAPIClient_GetAgentLogs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/fleet/agents/host-1/logs", r.URL.Path)
+		assert.Equal(t, "exp-123-candidate", r.URL.Query().Get("collector_id"))
+		assert.Equal(t, "warn", r.URL.Query().Get("level"))
+		assert.Equal(t, "1h", r.URL.Query().Get("since"))
+		assert.Empty(t, r.URL.Query().Get("limit"))
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[{"id":7,"collector_id":"exp-123-candidate","source":"collector","level":"error","message":"Exporting failed","timestamp":"2024-01-15T10:30:00Z"}]`))
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, "test-token")
+	entries, err := client.GetAgentLogs("host-1", AgentLogsRequest{CollectorID: "exp-123-candidate", Level: "warn", Since: "1h"})
+
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, int64(7), entries[0].ID)
+	assert.Equal(t, "Exporting failed", entries[0].Message)
+}
+
 func TestAPIClient_parseAPIError(t *testing.T) {
 	tests := []struct {
 		name          string
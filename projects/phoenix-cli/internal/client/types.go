@@ -36,6 +36,36 @@ type LoginResponse struct {
 	ExpiresAt time.Time `json:"expires_at,omitempty"`
 }
 
+// ExperimentSchedule starts runs of an experiment at StartAt or on a cron
+// expression
+type ExperimentSchedule struct {
+	StartAt   *time.Time `json:"start_at,omitempty"`
+	Cron      string     `json:"cron,omitempty"`
+	Timezone  string     `json:"timezone,omitempty"`
+	Enabled   bool       `json:"enabled,omitempty"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	RunCount  int        `json:"run_count,omitempty"`
+}
+
+// RolloutPlan deploys an experiment in waves, each gated on the success of
+// the hosts deployed so far
+type RolloutPlan struct {
+	Waves []RolloutWave `json:"waves"`
+}
+
+// RolloutWave is one step of a rollout, sized by host count or percentage
+type RolloutWave struct {
+	Hosts    int           `json:"hosts,omitempty"`
+	Percent  float64       `json:"percent,omitempty"`
+	SoakTime time.Duration `json:"soak_time,omitempty"`
+	Gate     *RolloutGate  `json:"gate,omitempty"`
+}
+
+// RolloutGate is checked after a wave has soaked
+type RolloutGate struct {
+	MinHostSuccessPercent float64 `json:"min_host_success_percent,omitempty"`
+}
+
 // SuccessCriteria defines the criteria for a successful experiment
 type SuccessCriteria struct {
 	MaxErrorRate            float64 `json:"max_error_rate,omitempty"`
@@ -63,24 +93,30 @@ type Experiment struct {
 	CompletedAt       *time.Time             `json:"completed_at,omitempty"`
 	Results           *ExperimentResults     `json:"results,omitempty"`
 	Namespace         string                 `json:"namespace,omitempty"`
+	Schedule          *ExperimentSchedule    `json:"schedule,omitempty"`
 }
 
 // CreateExperimentRequest represents a request to create an experiment
 type CreateExperimentRequest struct {
-	Name              string                 `json:"name"`
-	Description       string                 `json:"description"`
-	BaselinePipeline  string                 `json:"baseline_pipeline"`
-	CandidatePipeline string                 `json:"candidate_pipeline"`
-	TargetNodes       map[string]string      `json:"target_nodes"`
-	Duration          interface{}            `json:"duration"` // Can be time.Duration or string
-	Parameters        map[string]interface{} `json:"parameters"`
-	Namespace         string                 `json:"namespace,omitempty"`
-	PipelineA         string                 `json:"pipeline_a,omitempty"`
-	PipelineB         string                 `json:"pipeline_b,omitempty"`
-	TrafficSplit      interface{}            `json:"traffic_split,omitempty"` // Can be float64 or string
-	Selector          string                 `json:"selector,omitempty"`
-	SuccessCriteria   *SuccessCriteria       `json:"success_criteria,omitempty"`
-	Metadata          map[string]string      `json:"metadata,omitempty"`
+	Name               string                 `json:"name"`
+	Description        string                 `json:"description"`
+	BaselinePipeline   string                 `json:"baseline_pipeline"`
+	CandidatePipeline  string                 `json:"candidate_pipeline"`
+	CandidatePipelines []string               `json:"candidate_pipelines,omitempty"` // A/B/n candidates, named after their templates
+	TargetNodes        map[string]string      `json:"target_nodes"`
+	Duration           interface{}            `json:"duration"` // Can be time.Duration or string
+	Parameters         map[string]interface{} `json:"parameters"`
+	Namespace          string                 `json:"namespace,omitempty"`
+	PipelineA          string                 `json:"pipeline_a,omitempty"`
+	PipelineB          string                 `json:"pipeline_b,omitempty"`
+	TrafficSplit       interface{}            `json:"traffic_split,omitempty"` // Can be float64 or string
+	Selector           string                 `json:"selector,omitempty"`
+	SampleSize         int                    `json:"sample_size,omitempty"`
+	SamplePercent      float64                `json:"sample_percent,omitempty"`
+	SuccessCriteria    *SuccessCriteria       `json:"success_criteria,omitempty"`
+	Rollout            *RolloutPlan           `json:"rollout,omitempty"`
+	Schedule           *ExperimentSchedule    `json:"schedule,omitempty"`
+	Metadata           map[string]string      `json:"metadata,omitempty"`
 }
 
 // ListExperimentsRequest represents a request to list experiments
@@ -166,13 +202,14 @@ type MetricPoint struct {
 
 // OverlapResult represents the result of an overlap check
 type OverlapResult struct {
-	HasOverlap        bool     `json:"has_overlap"`
-	ConflictingExpIDs []string `json:"conflicting_exp_ids"`
-	AffectedNodes     []string `json:"affected_nodes"`
-	OverlapType       string   `json:"overlap_type"`
-	Severity          string   `json:"severity"`
-	Message           string   `json:"message"`
-	Suggestions       []string `json:"suggestions"`
+	HasOverlap               bool     `json:"has_overlap"`
+	ConflictingExpIDs        []string `json:"conflicting_exp_ids"`
+	ConflictingDeploymentIDs []string `json:"conflicting_deployment_ids,omitempty"`
+	AffectedNodes            []string `json:"affected_nodes"`
+	OverlapType              string   `json:"overlap_type"`
+	Severity                 string   `json:"severity"`
+	Message                  string   `json:"message"`
+	Suggestions              []string `json:"suggestions"`
 }
 
 // Pipeline represents a pipeline configuration
@@ -243,10 +280,12 @@ type ResourceRequirements struct {
 	Limits   ResourceList `json:"limits,omitempty"`
 }
 
-// ResourceList defines CPU and memory resources
+// ResourceList defines CPU and memory resources, and as a limit the number
+// of processes
 type ResourceList struct {
 	CPU    string `json:"cpu,omitempty"`
 	Memory string `json:"memory,omitempty"`
+	PIDs   int64  `json:"pids,omitempty"`
 }
 
 // RollbackPipelineRequest represents a request to rollback a pipeline
@@ -267,3 +306,47 @@ type DeploymentStatusResponse struct {
 	HealthStatus   string               `json:"health_status,omitempty"`
 	LastUpdated    time.Time            `json:"last_updated"`
 }
+
+// AgentDiagnosticRequest asks an agent to run a built-in diagnostic
+type AgentDiagnosticRequest struct {
+	Command     string `json:"command"`
+	CollectorID string `json:"collector_id,omitempty"`
+	Lines       int    `json:"lines,omitempty"`
+}
+
+// AgentDiagnostic is the task that runs a diagnostic on an agent. Result
+// holds the diagnostic's output once Status is completed.
+type AgentDiagnostic struct {
+	ID           string                 `json:"id"`
+	HostID       string                 `json:"host_id"`
+	Action       string                 `json:"action"`
+	Status       string                 `json:"status"`
+	Result       map[string]interface{} `json:"result,omitempty"`
+	ErrorMessage string                 `json:"error_message,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// AgentLogsRequest selects the shipped log lines of an agent
+type AgentLogsRequest struct {
+	CollectorID string
+	TaskID      string
+	// Level is the lowest level returned
+	Level string
+	// Since is an RFC 3339 time or a duration such as 1h
+	Since string
+	// AfterID returns only lines stored after the line with this ID
+	AfterID int64
+	Limit   int
+}
+
+// AgentLogEntry is a line of a collector's output or of an agent's own log
+type AgentLogEntry struct {
+	ID          int64     `json:"id"`
+	HostID      string    `json:"host_id"`
+	CollectorID string    `json:"collector_id,omitempty"`
+	TaskID      string    `json:"task_id,omitempty"`
+	Source      string    `json:"source"`
+	Level       string    `json:"level"`
+	Message     string    `json:"message"`
+	Timestamp   time.Time `json:"timestamp"`
+}
@@ -89,6 +89,13 @@ func PrintOverlapWarning(overlap *client.OverlapResult) {
 		}
 	}
 
+	if len(overlap.ConflictingDeploymentIDs) > 0 {
+		fmt.Printf("\nConflicting deployments:\n")
+		for _, id := range overlap.ConflictingDeploymentIDs {
+			fmt.Printf("  - %s\n", id)
+		}
+	}
+
 	if len(overlap.AffectedNodes) > 0 {
 		fmt.Printf("\nAffected nodes (%d):\n", len(overlap.AffectedNodes))
 		// Show first 5 nodes